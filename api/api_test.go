@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -25,3 +26,23 @@ func TestMetaRequestString(t *testing.T) {
 		})
 	}
 }
+
+func TestMetaResponseSetError(t *testing.T) {
+	var r MetaResponse
+	r.SetError("plain message")
+	if r.Ok || r.Message != "plain message" || r.ErrorCode != ErrCodeInternal {
+		t.Fatalf("unexpected response %+v", r)
+	}
+
+	err := fmt.Errorf("cannot get process: %w",
+		NewError(ErrCodeNotFound, "process %x not found", []byte{1}))
+	r.SetError(err)
+	if r.ErrorCode != ErrCodeNotFound || r.Message != err.Error() {
+		t.Fatalf("unexpected response %+v", r)
+	}
+
+	r.SetErrorCode(ErrCodeMempoolFull, "mempool is full")
+	if r.ErrorCode != ErrCodeMempoolFull || r.Message != "mempool is full" {
+		t.Fatalf("unexpected response %+v", r)
+	}
+}
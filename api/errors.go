@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is a stable, machine readable identifier of the reason an API
+// request failed. It is returned in the errorCode field of the MetaResponse,
+// along with the human readable message, so clients do not need to parse the
+// message to decide how to react. The values must never change.
+type ErrorCode string
+
+const (
+	// ErrCodeInvalidRequest means the request is malformed or its fields are
+	// not valid. Retrying the same request will fail again.
+	ErrCodeInvalidRequest ErrorCode = "invalidRequest"
+	// ErrCodeNotFound means the requested item (process, envelope, block,
+	// transaction, census, file...) does not exist.
+	ErrCodeNotFound ErrorCode = "notFound"
+	// ErrCodeUnauthorized means the request signer is not allowed to call the
+	// method or to perform the requested action.
+	ErrCodeUnauthorized ErrorCode = "unauthorized"
+	// ErrCodeProcessNotActive means the process exists but it is not accepting
+	// votes: it has not started yet, it already finished or it is not READY.
+	ErrCodeProcessNotActive ErrorCode = "processNotActive"
+	// ErrCodeDuplicateVote means a vote with the same nullifier was already
+	// cast or is already waiting on the mempool.
+	ErrCodeDuplicateVote ErrorCode = "duplicateVote"
+	// ErrCodeInvalidCensusProof means the census proof of the vote could not
+	// be verified against the process census.
+	ErrCodeInvalidCensusProof ErrorCode = "invalidCensusProof"
+	// ErrCodeMempoolFull means the transaction could not be queued because the
+	// mempool is full. The request can be retried later.
+	ErrCodeMempoolFull ErrorCode = "mempoolFull"
+	// ErrCodeRateLimited means the client exceeded its request quota for the
+	// method. The request can be retried later.
+	ErrCodeRateLimited ErrorCode = "rateLimited"
+	// ErrCodeReplayedRequest means the signed request timestamp is too old or
+	// too far in the future, or the same signed request was already received.
+	// The request must be signed again with the current time.
+	ErrCodeReplayedRequest ErrorCode = "replayedRequest"
+	// ErrCodeTxDropped means the transaction was accepted by the mempool but
+	// it was removed before being included on a block. It can be sent again.
+	ErrCodeTxDropped ErrorCode = "txDropped"
+	// ErrCodeTxTimeout means the transaction was accepted by the mempool but
+	// it was not included on a block in time. It might still be included.
+	ErrCodeTxTimeout ErrorCode = "txTimeout"
+	// ErrCodeInternal means the server failed processing a valid request.
+	// It is the code used if no other one applies.
+	ErrCodeInternal ErrorCode = "internal"
+)
+
+// Error is an error carrying an API error code
+type Error struct {
+	Code ErrorCode
+	Err  error
+}
+
+// NewError returns an Error with the code and the formatted message.
+// Errors passed with the %w verb can be unwrapped.
+func NewError(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCodeOf returns the code of the first Error found in the err chain,
+// or ErrCodeInternal if there is none.
+func ErrorCodeOf(err error) ErrorCode {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ErrCodeInternal
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 	"go.vocdoni.io/proto/build/go/models"
@@ -88,41 +89,43 @@ type RequestMessage struct {
 // MetaRequest contains all of the possible request fields.
 // Fields must be in alphabetical order
 type MetaRequest struct {
-	CensusID     string                         `json:"censusId,omitempty"`
-	CensusURI    string                         `json:"censusUri,omitempty"`
-	CensusKey    []byte                         `json:"censusKey,omitempty"`
-	CensusKeys   [][]byte                       `json:"censusKeys,omitempty"`
-	CensusValue  []byte                         `json:"censusValue,omitempty"`
-	CensusValues [][]byte                       `json:"censusValues,omitempty"`
-	CensusDump   []byte                         `json:"censusDump,omitempty"`
-	CensusType   models.Census_Type             `json:"censusType,omitempty"`
-	Content      []byte                         `json:"content,omitempty"`
-	Digested     bool                           `json:"digested,omitempty"`
-	EntityId     types.HexBytes                 `json:"entityId,omitempty"`
-	EthProof     *ethstorageproof.StorageResult `json:"storageProof,omitempty"`
-	Hash         []byte                         `json:"hash,omitempty"`
-	Height       uint32                         `json:"height,omitempty"`
-	From         int                            `json:"from,omitempty"`
-	ListSize     int                            `json:"listSize,omitempty"`
-	Method       string                         `json:"method"`
-	Name         string                         `json:"name,omitempty"`
-	Namespace    uint32                         `json:"namespace,omitempty"`
-	NewProcess   *NewProcess                    `json:"newProcess,omitempty"`
-	Nullifier    types.HexBytes                 `json:"nullifier,omitempty"`
-	Payload      []byte                         `json:"payload,omitempty"`
-	ProcessID    types.HexBytes                 `json:"processId,omitempty"`
-	ProofData    types.HexBytes                 `json:"proofData,omitempty"`
-	PubKeys      []string                       `json:"pubKeys,omitempty"`
-	RootHash     types.HexBytes                 `json:"rootHash,omitempty"`
-	SearchTerm   string                         `json:"searchTerm,omitempty"`
-	Signature    types.HexBytes                 `json:"signature,omitempty"`
-	SrcNetId     string                         `json:"sourceNetworkId,omitempty"`
-	Status       string                         `json:"status,omitempty"`
-	Timestamp    int32                          `json:"timestamp"`
-	TxIndex      int32                          `json:"txIndex,omitempty"`
-	Type         string                         `json:"type,omitempty"`
-	URI          string                         `json:"uri,omitempty"`
-	WithResults  bool                           `json:"withResults,omitempty"`
+	Address          types.HexBytes                 `json:"address,omitempty"`
+	CensusID         string                         `json:"censusId,omitempty"`
+	CensusURI        string                         `json:"censusUri,omitempty"`
+	CensusKey        []byte                         `json:"censusKey,omitempty"`
+	CensusKeys       [][]byte                       `json:"censusKeys,omitempty"`
+	CensusValue      []byte                         `json:"censusValue,omitempty"`
+	CensusValues     [][]byte                       `json:"censusValues,omitempty"`
+	CensusDump       []byte                         `json:"censusDump,omitempty"`
+	CensusType       models.Census_Type             `json:"censusType,omitempty"`
+	Content          []byte                         `json:"content,omitempty"`
+	Digested         bool                           `json:"digested,omitempty"`
+	EntityId         types.HexBytes                 `json:"entityId,omitempty"`
+	EthProof         *ethstorageproof.StorageResult `json:"storageProof,omitempty"`
+	Hash             []byte                         `json:"hash,omitempty"`
+	Height           uint32                         `json:"height,omitempty"`
+	From             int                            `json:"from,omitempty"`
+	ListSize         int                            `json:"listSize,omitempty"`
+	Method           string                         `json:"method"`
+	Name             string                         `json:"name,omitempty"`
+	Namespace        uint32                         `json:"namespace,omitempty"`
+	NewProcess       *NewProcess                    `json:"newProcess,omitempty"`
+	Nullifier        types.HexBytes                 `json:"nullifier,omitempty"`
+	Payload          []byte                         `json:"payload,omitempty"`
+	ProcessID        types.HexBytes                 `json:"processId,omitempty"`
+	ProofData        types.HexBytes                 `json:"proofData,omitempty"`
+	PubKeys          []string                       `json:"pubKeys,omitempty"`
+	RootHash         types.HexBytes                 `json:"rootHash,omitempty"`
+	SearchTerm       string                         `json:"searchTerm,omitempty"`
+	Signature        types.HexBytes                 `json:"signature,omitempty"`
+	SrcNetId         string                         `json:"sourceNetworkId,omitempty"`
+	Status           string                         `json:"status,omitempty"`
+	Timestamp        int32                          `json:"timestamp"`
+	TxIndex          int32                          `json:"txIndex,omitempty"`
+	Type             string                         `json:"type,omitempty"`
+	URI              string                         `json:"uri,omitempty"`
+	WaitForInclusion bool                           `json:"waitForInclusion,omitempty"`
+	WithResults      bool                           `json:"withResults,omitempty"`
 }
 
 func (r MetaRequest) String() string {
@@ -173,11 +176,31 @@ type ResponseMessage struct {
 	Signature types.HexBytes `json:"signature"`
 }
 
+// MethodInfo describes an API method, the request fields it uses and the
+// response fields it can return, by their JSON names
+type MethodInfo struct {
+	Name     string   `json:"name"`
+	API      string   `json:"api,omitempty"`
+	Public   bool     `json:"public"`
+	Required []string `json:"required,omitempty"`
+	Optional []string `json:"optional,omitempty"`
+	Response []string `json:"response,omitempty"`
+}
+
+// BatchResponseMessage wraps the responses of a batch request, in the same
+// order as the requests. Each response is signed on its own, and its ID is the
+// batch ID followed by the request index, such as "8a3f/0".
+type BatchResponseMessage struct {
+	ID        string            `json:"id"`
+	Responses []json.RawMessage `json:"responses"`
+}
+
 // MetaResponse contains all of the possible request fields.
 // Fields must be in alphabetical order
 // Those fields with valid zero-values (such as bool) must be pointers
 type MetaResponse struct {
 	APIList              []string                         `json:"apiList,omitempty"`
+	APIVersion           string                           `json:"apiVersion,omitempty"`
 	Block                *indexertypes.BlockMetadata      `json:"block,omitempty"`
 	BlockList            []*indexertypes.BlockMetadata    `json:"blockList,omitempty"`
 	BlockTime            *[5]int32                        `json:"blockTime,omitempty"`
@@ -196,13 +219,17 @@ type MetaResponse struct {
 	EntityIDs            []string                         `json:"entityIds,omitempty"`
 	Envelope             *indexertypes.EnvelopePackage    `json:"envelope,omitempty"`
 	Envelopes            []*indexertypes.EnvelopeMetadata `json:"envelopes,omitempty"`
+	ErrorCode            ErrorCode                        `json:"errorCode,omitempty"`
 	Files                []byte                           `json:"files,omitempty"`
 	Final                *bool                            `json:"final,omitempty"`
 	Finished             *bool                            `json:"finished,omitempty"`
 	Health               int32                            `json:"health,omitempty"`
 	Height               *uint32                          `json:"height,omitempty"`
 	InvalidClaims        []int                            `json:"invalidClaims,omitempty"`
+	InvalidEnvelopes     []*indexertypes.InvalidEnvelope  `json:"invalidEnvelopes,omitempty"`
+	InvalidVotes         *uint64                          `json:"invalidVotes,omitempty"`
 	Message              string                           `json:"message,omitempty"`
+	Methods              []*MethodInfo                    `json:"methods,omitempty"`
 	Nullifier            string                           `json:"nullifier,omitempty"`
 	Nullifiers           *[]string                        `json:"nullifiers,omitempty"`
 	Ok                   bool                             `json:"ok"`
@@ -213,6 +240,7 @@ type MetaResponse struct {
 	ProcessIDs           []string                         `json:"processIds,omitempty"`
 	Process              *indexertypes.Process            `json:"process,omitempty"`
 	ProcessList          []string                         `json:"processList,omitempty"`
+	Receipt              *SignedVoterReceipt              `json:"receipt,omitempty"`
 	Registered           *bool                            `json:"registered,omitempty"`
 	Request              string                           `json:"request"`
 	Results              [][]string                       `json:"results,omitempty"`
@@ -222,13 +250,17 @@ type MetaResponse struct {
 	Size                 *int64                           `json:"size,omitempty"`
 	State                string                           `json:"state,omitempty"`
 	Stats                *VochainStats                    `json:"stats,omitempty"`
+	StorageEvidence      *headerstore.StorageEvidence     `json:"storageEvidence,omitempty"`
 	Timestamp            int32                            `json:"timestamp"`
 	Type                 string                           `json:"type,omitempty"`
 	Tx                   *indexertypes.TxPackage          `json:"tx,omitempty"`
+	TxHash               types.HexBytes                   `json:"txHash,omitempty"`
+	TxIndex              *int32                           `json:"txIndex,omitempty"`
 	TxList               []*indexertypes.TxMetadata       `json:"txList,omitempty"`
 	URI                  string                           `json:"uri,omitempty"`
 	ValidatorList        []*models.Validator              `json:"validatorlist,omitempty"`
 	ValidProof           *bool                            `json:"validProof,omitempty"`
+	VoterHistory         []*indexertypes.VoterRecord      `json:"voterHistory,omitempty"`
 	Weight               string                           `json:"weight,omitempty"`
 }
 
@@ -238,8 +270,20 @@ func (r MetaResponse) String() string {
 
 // SetError sets the MetaResponse's Ok field to false, and Message to a string
 // representation of v. Usually, v's type will be error or string.
+// The ErrorCode is taken from v if it is an error carrying one, otherwise
+// it is set to ErrCodeInternal.
 func (r *MetaResponse) SetError(v interface{}) {
+	code := ErrCodeInternal
+	if err, ok := v.(error); ok {
+		code = ErrorCodeOf(err)
+	}
+	r.SetErrorCode(code, v)
+}
+
+// SetErrorCode works as SetError, but sets the ErrorCode to code.
+func (r *MetaResponse) SetErrorCode(code ErrorCode, v interface{}) {
 	r.Ok = false
+	r.ErrorCode = code
 	r.Message = fmt.Sprintf("%s", v)
 }
 
@@ -277,18 +321,40 @@ type VochainStats struct {
 	Syncing          bool      `json:"syncing"`
 }
 
+// VoterReceipt lists the envelopes cast by a voter on the processes of an entity,
+// as seen by the gateway at the given timestamp
+type VoterReceipt struct {
+	Address   types.HexBytes              `json:"address"`
+	EntityID  types.HexBytes              `json:"entityId"`
+	Envelopes []*indexertypes.VoterRecord `json:"envelopes"`
+	Timestamp int64                       `json:"timestamp"`
+}
+
+// SignedVoterReceipt contains the JSON encoded VoterReceipt and the signature of the
+// gateway over these exact bytes, so the voter can keep it and verify it later
+type SignedVoterReceipt struct {
+	Receipt   json.RawMessage `json:"receipt"`
+	Signature types.HexBytes  `json:"signature"`
+	Signer    string          `json:"signer"`
+}
+
 // NewProcess contains the fields required for creating a Vochain process
 type NewProcess struct {
 	EntityID     types.HexBytes             `json:"entityId"`
 	StartBlock   uint32                     `json:"startBlock"`
 	BlockCount   uint32                     `json:"blockCount"`
 	CensusRoot   types.HexBytes             `json:"censusRoot"`
+	CensusURI    string                     `json:"censusUri,omitempty"`
+	CensusOrigin string                     `json:"censusOrigin,omitempty"`
 	NetworkId    string                     `json:"networkId,omitempty"`
 	Metadata     string                     `json:"metadata,omitempty"`
 	SourceHeight *uint64                    `json:"sourceHeight,omitempty"`
 	EnvelopeType *models.EnvelopeType       `json:"envelopeType,omitempty"`
 	VoteOptions  *models.ProcessVoteOptions `json:"voteOptions,omitempty"`
 	EthIndexSlot *uint32                    `json:"ethIndexSlot,omitempty"`
+	TokenID      string                     `json:"tokenId,omitempty"`
+	// Nonce makes each signed gasless process request unique for the entity
+	Nonce uint64 `json:"nonce,omitempty"`
 }
 
 func (p NewProcess) String() string {
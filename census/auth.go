@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	"go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/log"
@@ -75,3 +76,27 @@ func (m *Manager) CheckAuth(reqOuter *api.RequestMessage, reqInner *api.MetaRequ
 	}
 	return nil
 }
+
+// KeyAllowed returns true if addr is the address of one of the management
+// public keys of the namespace name, so it can manage the census even if it
+// was not created by addr
+func (m *Manager) KeyAllowed(name string, addr ethcommon.Address) bool {
+	m.TreesMu.RLock()
+	defer m.TreesMu.RUnlock()
+	for _, ns := range m.Census.Namespaces {
+		if ns.Name != name {
+			continue
+		}
+		for _, keyHex := range ns.Keys {
+			key, err := hex.DecodeString(util.TrimHex(keyHex))
+			if err != nil {
+				continue
+			}
+			if keyAddr, err := ethereum.AddrFromPublicKey(key); err == nil && keyAddr == addr {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
 )
 
 func TestCompressor(t *testing.T) {
@@ -36,3 +37,19 @@ func TestLoadTreeDefaultCensusType(t *testing.T) {
 	qt.Assert(t, err, qt.IsNil)
 	qt.Assert(t, censusDefaultType, qt.Equals, tree.Type())
 }
+
+func TestKeyAllowed(t *testing.T) {
+	m := &Manager{}
+	qt.Assert(t, m.Init(t.TempDir(), ""), qt.IsNil)
+	manager := ethereum.NewSignKeys()
+	qt.Assert(t, manager.Generate(), qt.IsNil)
+	other := ethereum.NewSignKeys()
+	qt.Assert(t, other.Generate(), qt.IsNil)
+	pub, _ := manager.HexString()
+	_, err := m.AddNamespace("0x1234/census", censusDefaultType, []string{"invalid", pub})
+	qt.Assert(t, err, qt.IsNil)
+
+	qt.Assert(t, m.KeyAllowed("0x1234/census", manager.Address()), qt.IsTrue)
+	qt.Assert(t, m.KeyAllowed("0x1234/census", other.Address()), qt.IsFalse)
+	qt.Assert(t, m.KeyAllowed("0x1234/other", manager.Address()), qt.IsFalse)
+}
@@ -60,7 +60,7 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 				resp.CensusID = censusPrefix + r.CensusID
 			}
 		} else {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 		}
 		return resp
 	}
@@ -71,7 +71,7 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 				resp.CensusList = append(resp.CensusList, n)
 			}
 		} else {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 		}
 		return resp
 	}
@@ -81,7 +81,8 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 	exists := m.Exists(r.CensusID)
 	m.TreesMu.RUnlock()
 	if !exists {
-		resp.SetError(fmt.Sprintf("censusId not valid or not found %s", r.CensusID))
+		resp.SetErrorCode(api.ErrCodeNotFound,
+			fmt.Sprintf("censusId not valid or not found %s", r.CensusID))
 		return resp
 	}
 
@@ -104,7 +105,7 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 		return resp
 	}
 	if !tr.IsPublic() {
-		resp.SetError("census not yet published")
+		resp.SetErrorCode(api.ErrCodeNotFound, "census not yet published")
 		return resp
 	}
 
@@ -127,14 +128,14 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 			resp.Root = tr.Root()
 			log.Infof("%d claims addedd successfully", len(r.CensusKeys)-len(invalidClaims))
 		} else {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 		}
 		return resp
 
 	case "addClaim":
 		if isAuth && validAuthPrefix {
 			if r.CensusKey == nil {
-				resp.SetError("error decoding claim data")
+				resp.SetErrorCode(api.ErrCodeInvalidRequest, "error decoding claim data")
 				return resp
 			}
 			data := r.CensusKey
@@ -150,7 +151,7 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 				log.Debugf("claim added %x/%x", data, r.CensusValue)
 			}
 		} else {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 		}
 		return resp
 
@@ -166,23 +167,23 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 				}
 			}
 		} else {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 		}
 		return resp
 
 	case "importRemote":
 		if !isAuth || !validAuthPrefix {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 			return resp
 		}
 		if m.RemoteStorage == nil {
-			resp.SetError("not supported")
+			resp.SetErrorCode(api.ErrCodeInvalidRequest, "not supported")
 			return resp
 		}
 		if !strings.HasPrefix(r.URI, m.RemoteStorage.URIprefix()) ||
 			len(r.URI) <= len(m.RemoteStorage.URIprefix()) {
 			log.Warnf("uri not supported %s (supported prefix %s)", r.URI, m.RemoteStorage.URIprefix())
-			resp.SetError("URI not supported")
+			resp.SetErrorCode(api.ErrCodeInvalidRequest, "URI not supported")
 			return resp
 		}
 		log.Infof("retrieving remote census %s", r.CensusURI)
@@ -211,13 +212,13 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 			}
 		} else {
 			log.Warnf("no data found on the retreived census")
-			resp.SetError("no claims found")
+			resp.SetErrorCode(api.ErrCodeInvalidRequest, "no claims found")
 		}
 		return resp
 
 	case "checkProof":
 		if len(r.ProofData) < 1 {
-			resp.SetError("proofData not provided")
+			resp.SetErrorCode(api.ErrCodeInvalidRequest, "proofData not provided")
 			return resp
 		}
 		var err error
@@ -277,7 +278,7 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 
 	case "dump", "dumpPlain":
 		if !isAuth || !validAuthPrefix {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 			return resp
 		}
 		// dump the claim data and return it
@@ -304,11 +305,11 @@ func (m *Manager) Handler(ctx context.Context, r *api.MetaRequest, isAuth bool,
 
 	case "publish":
 		if !isAuth || !validAuthPrefix {
-			resp.SetError("invalid authentication")
+			resp.SetErrorCode(api.ErrCodeUnauthorized, "invalid authentication")
 			return resp
 		}
 		if m.RemoteStorage == nil {
-			resp.SetError("not supported")
+			resp.SetErrorCode(api.ErrCodeInvalidRequest, "not supported")
 			return resp
 		}
 		var dump CensusDump
@@ -5,16 +5,23 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"os"
 
 	"github.com/spf13/cobra"
 	"go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/client"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/router"
+	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 )
 
 var processCmd = &cobra.Command{
-	Use:   "process list|info|keys|results|weight|finalresults|liveresults",
+	Use:   "process list|info|keys|results|weight|export|verify|evidence|verifyevidence|finalresults|liveresults",
 	Short: "process subcommands",
 }
 
@@ -48,6 +55,44 @@ var processResultsWeightCmd = &cobra.Command{
 	RunE:  getResultsWeight,
 }
 
+var processExportCmd = &cobra.Command{
+	Use:   "export [processId]",
+	Short: "export the process audit bundle (parameters, envelopes, keys and results)",
+	RunE:  processExport,
+}
+
+var processVerifyCmd = &cobra.Command{
+	Use:   "verify [processId]",
+	Short: "recompute the process results from its envelopes and compare them with the on-chain results",
+	RunE:  processVerify,
+}
+
+var processEvidenceCmd = &cobra.Command{
+	Use:   "evidence [processId]",
+	Short: "download the ethereum storage root evidence of an EVM census process from an oracle",
+	Long: `Download the ethereum storage root evidence of an EVM census process from an oracle.
+The ethereum API oracles serve it through their API. The oracles relaying ethereum
+events keep it on <dataDir>/ethevents/<network>/ethheaders, which can be read with
+--headerStore while the oracle is stopped.`,
+	RunE: processEvidence,
+}
+
+var processVerifyEvidenceCmd = &cobra.Command{
+	Use:   "verifyevidence [file]",
+	Short: "verify offline that an EVM census root belongs to a trusted ethereum block",
+	RunE:  processVerifyEvidence,
+}
+
+var (
+	exportFormat  string
+	exportOutput  string
+	exportPublish bool
+
+	evidenceOutput      string
+	evidenceHeaderStore string
+	evidenceBlockHash   string
+)
+
 func init() {
 	rootCmd.AddCommand(processCmd)
 	processCmd.AddCommand(processListCmd)
@@ -55,6 +100,22 @@ func init() {
 	processCmd.AddCommand(processKeysCmd)
 	processCmd.AddCommand(processResultsCmd)
 	processCmd.AddCommand(processResultsWeightCmd)
+	processCmd.AddCommand(processExportCmd)
+	processCmd.AddCommand(processVerifyCmd)
+	processCmd.AddCommand(processEvidenceCmd)
+	processCmd.AddCommand(processVerifyEvidenceCmd)
+	processExportCmd.Flags().StringVar(&exportFormat, "format", "json",
+		"export format: json or csv (zip archive of CSV files)")
+	processExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "",
+		"output file (default <processId>.json or <processId>.zip)")
+	processExportCmd.Flags().BoolVar(&exportPublish, "publish", false,
+		"publish the export on the gateway IPFS storage and print its URI")
+	processEvidenceCmd.Flags().StringVarP(&evidenceOutput, "output", "o", "",
+		"output file (default <processId>.evidence.json)")
+	processEvidenceCmd.Flags().StringVar(&evidenceHeaderStore, "headerStore", "",
+		"read the evidence from a local header store directory instead of an oracle API")
+	processVerifyEvidenceCmd.Flags().StringVar(&evidenceBlockHash, "blockHash", "",
+		"trusted hash of the ethereum block the census root belongs to")
 }
 
 func processList(cmd *cobra.Command, args []string) error {
@@ -234,3 +295,289 @@ func getResultsWeight(cmd *cobra.Command, args []string) error {
 	fmt.Println(resp.Weight)
 	return err
 }
+
+func processExport(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must provide a process id")
+	}
+	if exportFormat != "json" && exportFormat != "csv" {
+		return fmt.Errorf("export format %q not supported", exportFormat)
+	}
+	// publishProcessExport is a private method
+	if exportPublish {
+		if err := opt.checkSignKey(); err != nil {
+			return err
+		}
+	}
+
+	cl, err := client.New(opt.host)
+	if err != nil {
+		return err
+	}
+	defer cl.CheckClose(&err)
+
+	// Export pages do not fit in the default read limit
+	if cl.WS != nil {
+		cl.WS.SetReadLimit(32768 * 1024)
+	}
+
+	pid, err := hex.DecodeString(util.TrimHex(args[0]))
+	if err != nil {
+		return err
+	}
+	if exportPublish {
+		req := api.MetaRequest{Method: "publishProcessExport", Type: exportFormat, ProcessID: pid}
+		resp, err := cl.Request(req, opt.signKey)
+		if err != nil {
+			return err
+		}
+		if !resp.Ok {
+			return fmt.Errorf(resp.Message)
+		}
+		fmt.Printf("URI: %v\n", resp.URI)
+		return err
+	}
+
+	// The envelopes are exported in pages, which are merged in a single bundle
+	var export *indexertypes.ProcessExport
+	for {
+		req := api.MetaRequest{Method: "exportProcess", ProcessID: pid,
+			ListSize: router.MaxExportListSize}
+		if export != nil {
+			req.From = len(export.Envelopes)
+		}
+		resp, err := cl.Request(req, nil)
+		if err != nil {
+			return err
+		}
+		if !resp.Ok {
+			return fmt.Errorf(resp.Message)
+		}
+		page := &indexertypes.ProcessExport{}
+		if err := json.Unmarshal(resp.Content, page); err != nil {
+			return err
+		}
+		if export == nil {
+			export = page
+		} else {
+			export.Envelopes = append(export.Envelopes, page.Envelopes...)
+		}
+		if len(page.Envelopes) < router.MaxExportListSize {
+			break
+		}
+	}
+
+	output := exportOutput
+	var content []byte
+	switch exportFormat {
+	case "csv":
+		if output == "" {
+			output = fmt.Sprintf("%x.zip", pid)
+		}
+		content, err = scrutinizer.ExportCSV(export)
+	default:
+		if output == "" {
+			output = fmt.Sprintf("%x.json", pid)
+		}
+		content, err = json.Marshal(export)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, content, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("process export written to %s\n", output)
+	return err
+}
+
+func processVerify(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must provide a process id")
+	}
+
+	cl, err := client.New(opt.host)
+	if err != nil {
+		return err
+	}
+	defer cl.CheckClose(&err)
+
+	pid, err := hex.DecodeString(util.TrimHex(args[0]))
+	if err != nil {
+		return err
+	}
+	request := func(req api.MetaRequest) (*api.MetaResponse, error) {
+		req.ProcessID = pid
+		resp, err := cl.Request(req, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !resp.Ok {
+			return nil, fmt.Errorf("%s: %s", req.Method, resp.Message)
+		}
+		return resp, nil
+	}
+
+	// Process parameters and revealed keys
+	resp, err := request(api.MetaRequest{Method: "getProcessInfo"})
+	if err != nil {
+		return err
+	}
+	process := resp.Process
+	privateKeys := make([]string, types.KeyKeeperMaxKeyIndex)
+	if process.Envelope.GetEncryptedVotes() {
+		if resp, err = request(api.MetaRequest{Method: "getProcessKeys"}); err != nil {
+			return err
+		}
+		for _, k := range resp.EncryptionPrivKeys {
+			if k.Idx < 0 || k.Idx >= len(privateKeys) {
+				return fmt.Errorf("invalid key index %d", k.Idx)
+			}
+			privateKeys[k.Idx] = k.Key
+		}
+	}
+
+	// Fetch all the envelopes, until a page comes back short
+	var ballots []*scrutinizer.Ballot
+	for from := 0; ; from += router.MaxListSize {
+		resp, err := request(api.MetaRequest{Method: "getEnvelopeList", From: from})
+		if err != nil {
+			return err
+		}
+		for _, e := range resp.Envelopes {
+			eresp, err := cl.Request(api.MetaRequest{Method: "getEnvelope", Nullifier: e.Nullifier}, nil)
+			if err != nil {
+				return err
+			}
+			if !eresp.Ok {
+				return fmt.Errorf("getEnvelope %x: %s", e.Nullifier, eresp.Message)
+			}
+			weight, ok := new(big.Int).SetString(eresp.Envelope.Weight, 10)
+			if !ok {
+				return fmt.Errorf("invalid weight for envelope %x", e.Nullifier)
+			}
+			ballots = append(ballots, &scrutinizer.Ballot{
+				Nullifier:            e.Nullifier,
+				VotePackage:          eresp.Envelope.VotePackage,
+				EncryptionKeyIndexes: eresp.Envelope.EncryptionKeyIndexes,
+				Weight:               weight,
+			})
+		}
+		if len(resp.Envelopes) < router.MaxListSize {
+			break
+		}
+	}
+
+	results, discarded, err := scrutinizer.Retally(process.Envelope, process.VoteOpts,
+		privateKeys, ballots)
+	if err != nil {
+		return err
+	}
+	for _, d := range discarded {
+		fmt.Printf("discarded ballot %x: %s (%s)\n", d.Nullifier, d.Reason, d.Detail)
+	}
+	fmt.Printf("counted %d of %d ballots, weight %s\n", len(ballots)-len(discarded),
+		len(ballots), results.Weight)
+	computed := scrutinizer.GetFriendlyResults(results.Votes)
+	fmt.Printf("computed results: %v\n", computed)
+
+	// Compare with the results published on chain
+	if resp, err = request(api.MetaRequest{Method: "getOracleResults"}); err != nil {
+		return err
+	}
+	fmt.Printf("on-chain results: %v\n", resp.Results)
+	if err := scrutinizer.CompareResults(results.Votes, resp.Results); err != nil {
+		return fmt.Errorf("results do not match: %w", err)
+	}
+	fmt.Println("results verified")
+	return err
+}
+
+func processEvidence(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must provide a process id")
+	}
+
+	pid, err := hex.DecodeString(util.TrimHex(args[0]))
+	if err != nil {
+		return err
+	}
+	var evidence *headerstore.StorageEvidence
+	if evidenceHeaderStore != "" {
+		// the header store of a stopped oracle, such as the ones relaying ethereum events
+		hs, err := headerstore.New(evidenceHeaderStore)
+		if err != nil {
+			return err
+		}
+		defer hs.Close()
+		if evidence, err = hs.Evidence(pid); err != nil {
+			return fmt.Errorf("cannot get storage evidence: %w", err)
+		}
+	} else {
+		cl, err := client.New(opt.host)
+		if err != nil {
+			return err
+		}
+		defer cl.CheckClose(&err)
+		resp, err := cl.Request(api.MetaRequest{Method: "getStorageEvidence", ProcessID: pid}, nil)
+		if err != nil {
+			return err
+		}
+		if !resp.Ok {
+			return fmt.Errorf(resp.Message)
+		}
+		evidence = resp.StorageEvidence
+	}
+	data, err := json.MarshalIndent(evidence, "", "  ")
+	if err != nil {
+		return err
+	}
+	output := evidenceOutput
+	if output == "" {
+		output = fmt.Sprintf("%x.evidence.json", pid)
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("storage evidence written to %s\n", output)
+	return err
+}
+
+func processVerifyEvidence(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must provide an evidence file")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	evidence := &headerstore.StorageEvidence{}
+	if err := json.Unmarshal(data, evidence); err != nil {
+		return fmt.Errorf("cannot decode evidence: %w", err)
+	}
+	if evidence.Header == nil {
+		return fmt.Errorf("evidence without block header")
+	}
+	hash := evidence.Header.Hash()
+	fmt.Printf("process %x census root %x\n", evidence.ProcessID, evidence.CensusRoot)
+	fmt.Printf("ethereum block %s hash %s\n", evidence.Header.Number, hash.Hex())
+	if err := vochain.CheckStorageRootEvidence(evidence.Header, evidence.Proof,
+		evidence.Contract, evidence.CensusRoot); err != nil {
+		return err
+	}
+	fmt.Printf("census root is the storage root of contract %x on block %s\n",
+		evidence.Contract, evidence.Header.Number)
+	if evidenceBlockHash == "" {
+		fmt.Println("no trusted block hash provided, compare the block hash with a trusted source")
+		return nil
+	}
+	trusted, err := hex.DecodeString(util.TrimHex(evidenceBlockHash))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash.Bytes(), trusted) {
+		return fmt.Errorf("block hash does not match the trusted hash %x", trusted)
+	}
+	fmt.Println("storage evidence verified")
+	return nil
+}
@@ -77,6 +77,28 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	// api
 	globalCfg.API.Websockets = *flag.Bool("apiws", true, "enable websockets transport for the API")
 	globalCfg.API.HTTP = *flag.Bool("apihttp", true, "enable http transport for the API")
+	globalCfg.API.REST = *flag.Bool("apiRest", false,
+		"enable the read-only REST API (requires the HTTP transport)")
+	globalCfg.API.JSONRPC = *flag.Bool("apiJsonRpc", false,
+		"enable the JSON-RPC 2.0 API endpoint (requires the HTTP transport)")
+	globalCfg.API.RateLimit.Enabled = *flag.Bool("apiRateLimit", false,
+		"enable the per client (IP and signing address) API rate limits")
+	globalCfg.API.RateLimit.Reads = *flag.Int("apiRateLimitReads", 600,
+		"read requests per minute allowed per client (0 means no limit)")
+	globalCfg.API.RateLimit.Votes = *flag.Int("apiRateLimitVotes", 60,
+		"submitted transactions per minute allowed per client (0 means no limit)")
+	globalCfg.API.RateLimit.Census = *flag.Int("apiRateLimitCensus", 120,
+		"census write requests per minute allowed per client (0 means no limit)")
+	globalCfg.API.RateLimit.Files = *flag.Int("apiRateLimitFiles", 30,
+		"file upload and pin requests per minute allowed per client (0 means no limit)")
+	globalCfg.API.RateLimit.AllowList = *flag.String("apiRateLimitAllowList", "",
+		"comma-delimited list of IPs and ETH addresses not rate limited")
+	globalCfg.API.Cache.Enabled = *flag.Bool("apiCache", false,
+		"cache the read methods responses until the next block")
+	globalCfg.API.Cache.TTLs = *flag.String("apiCacheTTLs",
+		"getBlockHeight:10,getBlockStatus:10,getProcessInfo:30,getProcessList:30,"+
+			"getProcessSummary:30,getResults:30,getResultsWeight:30",
+		"comma-delimited list of method:seconds, the cached methods and their max TTL")
 	globalCfg.API.File = *flag.Bool("fileApi", true, "enable the file API")
 	globalCfg.API.Census = *flag.Bool("censusApi", false, "enable the census API")
 	globalCfg.API.Vote = *flag.Bool("voteApi", true, "enable the vote API")
@@ -92,6 +114,10 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 		"allows private methods over the APIs")
 	globalCfg.API.AllowedAddrs = *flag.String("apiAllowedAddrs", "",
 		"comma-delimited list of allowed client ETH addresses for private methods")
+	globalCfg.API.RolesFile = *flag.String("apiRolesFile", "",
+		"JSON file assigning private methods roles to client ETH addresses, reloaded on changes")
+	globalCfg.API.ReplayWindow = *flag.Int("apiReplayWindow", 0,
+		"seconds a signed request timestamp is valid for, rejecting replays (0 disables it)")
 	globalCfg.API.ListenHost = *flag.String("listenHost", "0.0.0.0",
 		"API endpoint listen address")
 	globalCfg.API.ListenPort = *flag.IntP("listenPort", "p", 9090,
@@ -109,6 +135,20 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 		fmt.Sprintf("Ethereum blockchain to use: %s", ethchain.AvailableChains))
 	globalCfg.W3Config.W3External = *flag.StringArrayP("w3External", "w", []string{},
 		"ethereum web3 endpoint. Supported protocols: http(s)://, ws(s):// and IPC filepath")
+	globalCfg.W3Config.ChainEndpoints = *flag.StringArray("w3ChainEndpoint", []string{},
+		"web3 endpoint of an additional chain for the oracle to watch, as chain=endpoint")
+	globalCfg.W3Config.ChainSpecsFile = *flag.String("ethChainSpecs", "",
+		"YAML or JSON file with custom Ethereum chain specs, usable as ethChain")
+	globalCfg.W3Config.ProposalPoliciesFile = *flag.String("apiOraclePolicies", "",
+		"YAML or JSON file with the per token process creation policies (ethApiOracle mode only)")
+	globalCfg.W3Config.GaslessProcesses = *flag.Bool("apiOracleGasless", false,
+		"enable the creation of processes from EIP-712 signed requests (ethApiOracle mode only)")
+	globalCfg.W3Config.PublishResults = *flag.Bool("w3PublishResults", false,
+		"oracle publishes the final results on the Ethereum results contract of each watched chain")
+	globalCfg.W3Config.ResultsMaxGasPrice = *flag.Uint64("w3ResultsMaxGasPrice", 0,
+		"maximum gas price in gwei for publishing results on Ethereum (0 means no limit)")
+	globalCfg.W3Config.ResultsVochainID = *flag.Uint32("w3ResultsVochainID", 0,
+		"Vochain identifier sent along with the results to the Ethereum results contract")
 	// ipfs
 	globalCfg.Ipfs.NoInit = *flag.Bool("ipfsNoInit", false,
 		"disable inter planetary file system support")
@@ -158,6 +198,11 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 		"enables the process archiver component")
 	globalCfg.VochainConfig.ProcessArchiveKey = *flag.String("processArchiveKey", "",
 		"IPFS base64 encoded private key for process archive IPNS")
+	// indexer maintenance (not stored on the config file)
+	indexerRebuild := flag.Bool("indexerRebuild", false,
+		"rebuild the indexer database from the local block store and exit")
+	indexerVerify := flag.Bool("indexerVerify", false,
+		"check the indexer database against the local vochain state and exit")
 
 	// metrics
 	globalCfg.Metrics.Enabled = *flag.Bool("metricsEnabled", false, "enable prometheus metrics")
@@ -204,6 +249,16 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	viper.BindPFlag("api.Websockets", flag.Lookup("apiws"))
 	viper.BindPFlag("api.WebsocketsReadLimit", flag.Lookup("apiWsReadLimit"))
 	viper.BindPFlag("api.Http", flag.Lookup("apihttp"))
+	viper.BindPFlag("api.REST", flag.Lookup("apiRest"))
+	viper.BindPFlag("api.JSONRPC", flag.Lookup("apiJsonRpc"))
+	viper.BindPFlag("api.RateLimit.Enabled", flag.Lookup("apiRateLimit"))
+	viper.BindPFlag("api.RateLimit.Reads", flag.Lookup("apiRateLimitReads"))
+	viper.BindPFlag("api.RateLimit.Votes", flag.Lookup("apiRateLimitVotes"))
+	viper.BindPFlag("api.RateLimit.Census", flag.Lookup("apiRateLimitCensus"))
+	viper.BindPFlag("api.RateLimit.Files", flag.Lookup("apiRateLimitFiles"))
+	viper.BindPFlag("api.RateLimit.AllowList", flag.Lookup("apiRateLimitAllowList"))
+	viper.BindPFlag("api.Cache.Enabled", flag.Lookup("apiCache"))
+	viper.BindPFlag("api.Cache.TTLs", flag.Lookup("apiCacheTTLs"))
 	viper.BindPFlag("api.File", flag.Lookup("fileApi"))
 	viper.BindPFlag("api.Census", flag.Lookup("censusApi"))
 	viper.BindPFlag("api.Vote", flag.Lookup("voteApi"))
@@ -213,6 +268,8 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	viper.BindPFlag("api.Route", flag.Lookup("apiRoute"))
 	viper.BindPFlag("api.AllowPrivate", flag.Lookup("apiAllowPrivate"))
 	viper.BindPFlag("api.AllowedAddrs", flag.Lookup("apiAllowedAddrs"))
+	viper.BindPFlag("api.RolesFile", flag.Lookup("apiRolesFile"))
+	viper.BindPFlag("api.ReplayWindow", flag.Lookup("apiReplayWindow"))
 	viper.BindPFlag("api.ListenHost", flag.Lookup("listenHost"))
 	viper.BindPFlag("api.ListenPort", flag.Lookup("listenPort"))
 	viper.Set("api.Ssl.DirCert", globalCfg.DataDir+"/tls")
@@ -224,6 +281,13 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	// ethereum web3
 	viper.BindPFlag("w3Config.ChainType", flag.Lookup("ethChain"))
 	viper.BindPFlag("w3Config.W3External", flag.Lookup("w3External"))
+	viper.BindPFlag("w3Config.ChainEndpoints", flag.Lookup("w3ChainEndpoint"))
+	viper.BindPFlag("w3Config.ChainSpecsFile", flag.Lookup("ethChainSpecs"))
+	viper.BindPFlag("w3Config.ProposalPoliciesFile", flag.Lookup("apiOraclePolicies"))
+	viper.BindPFlag("w3Config.GaslessProcesses", flag.Lookup("apiOracleGasless"))
+	viper.BindPFlag("w3Config.PublishResults", flag.Lookup("w3PublishResults"))
+	viper.BindPFlag("w3Config.ResultsMaxGasPrice", flag.Lookup("w3ResultsMaxGasPrice"))
+	viper.BindPFlag("w3Config.ResultsVochainID", flag.Lookup("w3ResultsVochainID"))
 
 	// ipfs
 	viper.Set("ipfs.ConfigPath", globalCfg.DataDir+"/ipfs")
@@ -294,6 +358,8 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 			Message: fmt.Sprintf("cannot unmarshal loaded config file: %s", err),
 		}
 	}
+	globalCfg.VochainConfig.Scrutinizer.Rebuild = *indexerRebuild
+	globalCfg.VochainConfig.Scrutinizer.Verify = *indexerVerify
 
 	if len(globalCfg.EthConfig.SigningKey) < 32 {
 		fmt.Println("no signing key, generating one...")
@@ -390,6 +456,26 @@ func main() {
 		log.Fatalf("mode %s is invalid", globalCfg.Mode)
 	}
 
+	// Load the custom chain specs and check the selected chain is available
+	if globalCfg.W3Config.ChainSpecsFile != "" {
+		if err := ethchain.LoadSpecs(globalCfg.W3Config.ChainSpecsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if globalCfg.Mode == types.ModeOracle || globalCfg.Mode == types.ModeEthAPIoracle {
+		if _, err := ethchain.SpecsFor(globalCfg.W3Config.ChainType); err != nil {
+			log.Fatalf("ethereum chain %s is not available: %v", globalCfg.W3Config.ChainType, err)
+		}
+	}
+
+	// Indexer maintenance runs on the local vochain data without starting the node
+	if globalCfg.VochainConfig.Scrutinizer.Rebuild || globalCfg.VochainConfig.Scrutinizer.Verify {
+		if err := service.ScrutinizerMaintenance(globalCfg.VochainConfig); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// If dev enabled, expose debugging profiles under a port between 61000 and 61100.
 	// We log what port is being used near the start of the logs, so it can
 	// be easily grabbed. Start this before the rest of the node, since it
@@ -557,24 +643,44 @@ func main() {
 				go kk.RevealUnpublished()
 			}
 
-			var w3uris []string
-			if globalCfg.W3Config.W3External != nil {
-				for idx, web3Endpoint := range globalCfg.W3Config.W3External {
-					web3EndpointTrimmed := strings.Trim(web3Endpoint, `"[]`)
-					log.Debugf("web3endpoint %d: %s", idx, web3EndpointTrimmed)
-					switch {
-					case strings.HasPrefix(web3EndpointTrimmed, "ws"):
-						w3uris = append(w3uris, web3EndpointTrimmed)
-					case strings.HasSuffix(web3EndpointTrimmed, "ipc"):
-						w3uris = append(w3uris, web3EndpointTrimmed)
-					default:
-						log.Warnf(`invalid web3 endpoint %s must be websocket or IPC
+			// chainURIs holds the web3 endpoints of each watched chain
+			chainURIs := make(map[string][]string)
+			chains := []string{globalCfg.W3Config.ChainType}
+			addEndpoint := func(chain, web3Endpoint string) {
+				web3EndpointTrimmed := strings.Trim(web3Endpoint, `"[]`)
+				log.Debugf("web3endpoint %s: %s", chain, web3EndpointTrimmed)
+				switch {
+				case strings.HasPrefix(web3EndpointTrimmed, "ws"),
+					strings.HasSuffix(web3EndpointTrimmed, "ipc"):
+					chainURIs[chain] = append(chainURIs[chain], web3EndpointTrimmed)
+				default:
+					log.Warnf(`invalid web3 endpoint %s must be websocket or IPC
 						for event subscription`, web3EndpointTrimmed)
-					}
 				}
 			}
+			for _, web3Endpoint := range globalCfg.W3Config.W3External {
+				addEndpoint(globalCfg.W3Config.ChainType, web3Endpoint)
+			}
+			for _, chainEndpoint := range globalCfg.W3Config.ChainEndpoints {
+				chainEndpoint = strings.Trim(chainEndpoint, `"[]`)
+				if chainEndpoint == "" {
+					// avoid pflag autofill in case of empty array
+					continue
+				}
+				chain, endpoint := splitChainEndpoint(chainEndpoint)
+				if chain == "" {
+					log.Fatalf("invalid chain endpoint %q, format must be chain=endpoint", chainEndpoint)
+				}
+				if _, err := ethchain.SpecsFor(chain); err != nil {
+					log.Fatalf("ethereum chain %s is not available: %v", chain, err)
+				}
+				if _, ok := chainURIs[chain]; !ok && chain != globalCfg.W3Config.ChainType {
+					chains = append(chains, chain)
+				}
+				addEndpoint(chain, endpoint)
+			}
 			// Start ethereum events (if at least one web3 endpoint configured)
-			if len(w3uris) > 0 {
+			if len(chainURIs) > 0 {
 				var evh []ethevents.EventHandler
 				evh = append(evh, ethevents.HandleVochainOracle)
 
@@ -593,15 +699,46 @@ func main() {
 				if len(whiteListedAddr) > 0 {
 					log.Infof("ethereum whitelisted addresses %+v", whiteListedAddr)
 				}
-				if err := service.EthEvents(
-					context.Background(),
-					w3uris,
-					globalCfg.W3Config.ChainType,
-					signer,
-					vnode,
-					evh,
-					whiteListedAddr); err != nil {
-					log.Fatal(err)
+				// The cursor of the main chain used to be stored on the ethevents root
+				if err := ethevents.MigrateDataDir(path.Join(globalCfg.DataDir, "ethevents"),
+					path.Join(globalCfg.DataDir, "ethevents", globalCfg.W3Config.ChainType)); err != nil {
+					log.Fatalf("cannot migrate ethereum events data: %v", err)
+				}
+				// Each chain is watched concurrently with its own endpoints and cursor
+				for _, chain := range chains {
+					if len(chainURIs[chain]) == 0 {
+						continue
+					}
+					if err := service.EthEvents(
+						context.Background(),
+						chainURIs[chain],
+						chain,
+						signer,
+						vnode,
+						evh,
+						whiteListedAddr,
+						path.Join(globalCfg.DataDir, "ethevents"),
+						ma); err != nil {
+						log.Fatal(err)
+					}
+				}
+				// Publish the results on the Ethereum chain of each process (if enabled)
+				if globalCfg.W3Config.PublishResults {
+					for _, chain := range chains {
+						if len(chainURIs[chain]) == 0 {
+							continue
+						}
+						if err := service.EthResults(
+							context.Background(),
+							orc,
+							chainURIs[chain],
+							chain,
+							signer,
+							globalCfg.W3Config.ResultsVochainID,
+							globalCfg.W3Config.ResultsMaxGasPrice); err != nil {
+							log.Fatal(err)
+						}
+					}
 				}
 			}
 		}
@@ -626,9 +763,25 @@ func main() {
 			if err != nil {
 				log.Fatal(err)
 			}
-			if err := apior.EnableERC20(globalCfg.W3Config.ChainType, globalCfg.W3Config.W3External); err != nil {
+			var policies *apioracle.ProposalPolicies
+			if globalCfg.W3Config.ProposalPoliciesFile != "" {
+				if policies, err = apioracle.LoadPolicies(globalCfg.W3Config.ProposalPoliciesFile); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if err := apior.EnableERC20(globalCfg.W3Config.ChainType, globalCfg.W3Config.W3External,
+				policies, path.Join(globalCfg.DataDir, "apioracle")); err != nil {
+				log.Fatal(err)
+			}
+			if err := apior.EnableStorageEvidence(path.Join(globalCfg.DataDir, "ethheaders")); err != nil {
 				log.Fatal(err)
 			}
+			if globalCfg.W3Config.GaslessProcesses {
+				if err := apior.EnableGasless(path.Join(globalCfg.DataDir, "apioracle-gasless")); err != nil {
+					log.Fatal(err)
+				}
+			}
+			go apior.CollectMetrics(ma)
 		}
 
 	}
@@ -682,3 +835,13 @@ func ensureNumberFiles(min uint64) error {
 	rlim.Cur = rlim.Max
 	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rlim)
 }
+
+// splitChainEndpoint splits a chain=endpoint string. If the format is not
+// valid, the returned chain is empty.
+func splitChainEndpoint(chainEndpoint string) (chain, endpoint string) {
+	i := strings.Index(chainEndpoint, "=")
+	if i <= 0 || i == len(chainEndpoint)-1 {
+		return "", ""
+	}
+	return chainEndpoint[:i], chainEndpoint[i+1:]
+}
@@ -76,6 +76,11 @@ type API struct {
 	AllowPrivate bool
 	// AllowedAddrs allowed addresses to interact with
 	AllowedAddrs string
+	// RolesFile JSON file assigning roles for the private methods to addresses
+	RolesFile string
+	// ReplayWindow seconds a signed request timestamp is valid for, 0 disables
+	// the replay protection
+	ReplayWindow int
 	// ListenPort port where the API server will listen on
 	ListenPort int
 	// ListenHost host where the API server will listen on
@@ -90,6 +95,34 @@ type API struct {
 	WebsocketsReadLimit int64
 	// Enable HTTP API
 	HTTP bool
+	// REST enables the read-only REST API over the public methods
+	REST bool
+	// JSONRPC enables the JSON-RPC 2.0 endpoint over the router methods
+	JSONRPC bool
+	// RateLimit per client quotas of the API methods
+	RateLimit RateLimitCfg
+	// Cache of the read methods responses
+	Cache CacheCfg
+}
+
+// CacheCfg configures the cache of the read methods responses. The cached
+// responses are invalidated on each new block, and expire after their TTL.
+type CacheCfg struct {
+	Enabled bool
+	// TTLs comma-delimited list of method:seconds, the cached methods
+	TTLs string
+}
+
+// RateLimitCfg are the per client quotas of the API method classes, in
+// requests per minute. A zero quota disables the limit of the class.
+type RateLimitCfg struct {
+	Enabled bool
+	Reads   int
+	Votes   int
+	Census  int
+	Files   int
+	// AllowList comma-delimited list of IPs and ETH addresses without limits
+	AllowList string
 }
 
 // IPFSCfg includes all possible config params needed by IPFS
@@ -115,6 +148,23 @@ type W3Cfg struct {
 	ChainType string
 	// W3External URLs of an external ethereum nodes to connect with
 	W3External []string
+	// ChainEndpoints are web3 endpoints of additional chains to watch by the oracle, as chain=endpoint
+	ChainEndpoints []string
+	// ChainSpecsFile is a YAML or JSON file with custom chain specs
+	ChainSpecsFile string
+	// PublishResults if true the oracle publishes the final results on the Ethereum results
+	// contract of the chain each process was created on
+	PublishResults bool
+	// ResultsMaxGasPrice is the maximum gas price (in gwei) to pay for publishing results, 0 means no limit
+	ResultsMaxGasPrice uint64
+	// ResultsVochainID is the Vochain identifier sent along with the results to the results contract
+	ResultsVochainID uint32
+	// ProposalPoliciesFile is a YAML or JSON file with the per token process creation
+	// policies of the Ethereum API oracle
+	ProposalPoliciesFile string
+	// GaslessProcesses enables the creation of processes from EIP-712 signed
+	// requests relayed by the Ethereum API oracle
+	GaslessProcesses bool
 }
 
 // VochainCfg includes all possible config params needed by the Vochain
@@ -188,6 +238,10 @@ type ScrutinizerCfg struct {
 	Enabled bool
 	// Disables live results computation on scrutinizer
 	IgnoreLiveResults bool
+	// Rebuild wipes the scrutinizer database and indexes again the local block store
+	Rebuild bool `mapstructure:"-"`
+	// Verify checks the scrutinizer database against the local vochain state
+	Verify bool `mapstructure:"-"`
 }
 
 // OracleCfg includes all possible config params needed by the Oracle
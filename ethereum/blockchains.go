@@ -2,6 +2,7 @@ package chain
 
 import (
 	"errors"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	ethparams "github.com/ethereum/go-ethereum/params"
@@ -21,13 +22,24 @@ type Specs struct {
 	// Contracts are ordered as [processes, namespaces, erc20tokenproofs, genesis, results, entityResolver]
 	Contracts     map[string]*ethereumhandler.EthereumContract
 	NetworkSource models.SourceNetworkId
+	// ConfirmThreshold and ConfirmDepth override the event confirmation defaults of the network source
+	ConfirmThreshold time.Duration
+	ConfirmDepth     uint64
 }
 
 // AvailableChains is the list of supported ethereum networks / environments
 var AvailableChains = []string{"mainnet", "goerli", "goerlistage", "xdai", "xdaistage", "rinkeby"}
 
-// SpecsFor returns the specs for the given blockchain network name
+// SpecsFor returns the specs for the given blockchain network name.
+// Custom chains loaded with LoadSpecs are also considered.
 func SpecsFor(name string) (*Specs, error) {
+	if s, ok := customSpecsFor(name); ok {
+		return s, nil
+	}
+	return builtinSpecsFor(name)
+}
+
+func builtinSpecsFor(name string) (*Specs, error) {
 	switch name {
 	case "mainnet":
 		return &mainnet, nil
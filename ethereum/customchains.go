@@ -0,0 +1,194 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// ChainSpecsFile is the format of a custom chain specs file (YAML or JSON)
+type ChainSpecsFile struct {
+	Chains []ChainSpecsConfig
+}
+
+// ChainSpecsConfig defines a custom Ethereum network
+type ChainSpecsConfig struct {
+	// Name identifies the chain, it is the value to use as ethChain
+	Name string
+	// NetworkID is the Ethereum network identification number
+	NetworkID int
+	// StartingBlock is the block where to start looking for events
+	StartingBlock int64
+	// NetworkSource is the Vochain source network name, as defined by models.SourceNetworkId
+	NetworkSource string
+	// ENSRegistry is the address of the ENS registry used to resolve the contract domains
+	ENSRegistry string
+	// ConfirmThreshold is the time to wait before processing an event (0 uses the network default)
+	ConfirmThreshold time.Duration
+	// ConfirmDepth is the number of blocks to wait before processing an event (0 uses the network default)
+	ConfirmDepth uint64
+	// Contracts maps the contract names (processes, namespaces, erc20, genesis, results,
+	// entities) to their domain or address
+	Contracts map[string]ContractConfig
+}
+
+// ContractConfig defines where to find a contract. If Address is set, the domain is not resolved.
+type ContractConfig struct {
+	Domain          string
+	Address         string
+	ListenForEvents bool
+}
+
+var (
+	customChains     = make(map[string]*Specs)
+	customChainsLock sync.RWMutex
+)
+
+var contractNames = map[string]bool{
+	ethereumhandler.ContractNameProcesses:         true,
+	ethereumhandler.ContractNameNamespaces:        true,
+	ethereumhandler.ContractNameTokenStorageProof: true,
+	ethereumhandler.ContractNameGenesis:           true,
+	ethereumhandler.ContractNameResults:           true,
+	ethereumhandler.ContractNameEntities:          true,
+}
+
+// LoadSpecs reads the custom chain specs from a YAML or JSON file and makes
+// them available through SpecsFor. The specs are validated before being added,
+// and chains loaded again replace the previous specs with the same name.
+func LoadSpecs(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("cannot read chain specs file %s: %w", path, err)
+	}
+	file := &ChainSpecsFile{}
+	if err := v.Unmarshal(file); err != nil {
+		return fmt.Errorf("cannot decode chain specs file %s: %w", path, err)
+	}
+	if len(file.Chains) == 0 {
+		return fmt.Errorf("no chains found on %s", path)
+	}
+	specs := make([]*Specs, len(file.Chains))
+	for i, c := range file.Chains {
+		s, err := c.Specs()
+		if err != nil {
+			return fmt.Errorf("invalid chain specs %q: %w", c.Name, err)
+		}
+		for _, prev := range specs[:i] {
+			if prev.Name == s.Name {
+				return fmt.Errorf("chain %q defined twice", s.Name)
+			}
+		}
+		specs[i] = s
+	}
+
+	customChainsLock.Lock()
+	defer customChainsLock.Unlock()
+	for _, s := range specs {
+		if _, ok := customChains[s.Name]; !ok {
+			AvailableChains = append(AvailableChains, s.Name)
+		}
+		customChains[s.Name] = s
+	}
+	return nil
+}
+
+// Specs validates the chain configuration and builds the chain specs
+func (c *ChainSpecsConfig) Specs() (*Specs, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if _, err := builtinSpecsFor(c.Name); err == nil {
+		return nil, fmt.Errorf("name is already used by a built-in chain")
+	}
+	if c.NetworkID <= 0 {
+		return nil, fmt.Errorf("invalid network id %d", c.NetworkID)
+	}
+	if c.StartingBlock < 0 {
+		return nil, fmt.Errorf("invalid starting block %d", c.StartingBlock)
+	}
+	source := models.SourceNetworkId_UNKNOWN
+	if c.NetworkSource != "" {
+		id, ok := models.SourceNetworkId_value[strings.ToUpper(c.NetworkSource)]
+		if !ok {
+			return nil, fmt.Errorf("unknown network source %q", c.NetworkSource)
+		}
+		source = models.SourceNetworkId(id)
+	}
+	if c.ConfirmThreshold < 0 {
+		return nil, fmt.Errorf("invalid confirm threshold %s", c.ConfirmThreshold)
+	}
+	s := &Specs{
+		Name:             c.Name,
+		NetworkId:        c.NetworkID,
+		StartingBlock:    c.StartingBlock,
+		NetworkSource:    source,
+		ConfirmThreshold: c.ConfirmThreshold,
+		ConfirmDepth:     c.ConfirmDepth,
+		Contracts:        make(map[string]*ethereumhandler.EthereumContract),
+	}
+	needsENS := false
+	for name, cc := range c.Contracts {
+		// viper lowercases the map keys
+		name = contractName(name)
+		if !contractNames[name] {
+			return nil, fmt.Errorf("unknown contract %q", name)
+		}
+		contract := &ethereumhandler.EthereumContract{
+			ListenForEvents: cc.ListenForEvents,
+		}
+		// InitContract resolves the domain if it is set, so it is only kept without address
+		switch {
+		case cc.Address != "":
+			if !common.IsHexAddress(cc.Address) {
+				return nil, fmt.Errorf("contract %s address %q is not valid", name, cc.Address)
+			}
+			contract.Address = common.HexToAddress(cc.Address)
+		case cc.Domain != "":
+			contract.Domain = cc.Domain
+			needsENS = true
+		default:
+			return nil, fmt.Errorf("contract %s needs a domain or an address", name)
+		}
+		s.Contracts[name] = contract
+	}
+	if _, ok := s.Contracts[ethereumhandler.ContractNameProcesses]; !ok {
+		return nil, fmt.Errorf("processes contract is required")
+	}
+	if c.ENSRegistry != "" {
+		if !common.IsHexAddress(c.ENSRegistry) {
+			return nil, fmt.Errorf("ENS registry address %q is not valid", c.ENSRegistry)
+		}
+	} else if needsENS {
+		return nil, fmt.Errorf("ENS registry is required to resolve the contract domains")
+	}
+	s.Contracts[ethereumhandler.ContractNameENSregistry] = &ethereumhandler.EthereumContract{
+		Address: common.HexToAddress(c.ENSRegistry),
+	}
+	return s, nil
+}
+
+// contractName returns the contract name matching case insensitively
+func contractName(name string) string {
+	for n := range contractNames {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+	}
+	return name
+}
+
+// customSpecsFor returns the specs of a custom chain loaded with LoadSpecs
+func customSpecsFor(name string) (*Specs, bool) {
+	customChainsLock.RLock()
+	defer customChainsLock.RUnlock()
+	s, ok := customChains[name]
+	return s, ok
+}
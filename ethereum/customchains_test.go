@@ -0,0 +1,99 @@
+package chain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+const testChainSpecs = `
+chains:
+  - name: testchain
+    networkId: 1337
+    startingBlock: 100
+    networkSource: poa_xdai
+    ensRegistry: "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+    confirmThreshold: 5s
+    confirmDepth: 2
+    contracts:
+      processes:
+        domain: processes.test.vocdoni.eth
+        listenForEvents: true
+      results:
+        address: "0x1111111111111111111111111111111111111111"
+      entities:
+        domain: entities.test.vocdoni.eth
+        address: "0x2222222222222222222222222222222222222222"
+`
+
+func TestLoadSpecs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chains.yml")
+	qt.Assert(t, os.WriteFile(path, []byte(testChainSpecs), 0o600), qt.IsNil)
+	qt.Assert(t, LoadSpecs(path), qt.IsNil)
+
+	specs, err := SpecsFor("testchain")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, specs.NetworkId, qt.Equals, 1337)
+	qt.Assert(t, specs.StartingBlock, qt.Equals, int64(100))
+	qt.Assert(t, specs.NetworkSource, qt.Equals, models.SourceNetworkId_POA_XDAI)
+	qt.Assert(t, specs.ConfirmThreshold, qt.Equals, 5*time.Second)
+	qt.Assert(t, specs.ConfirmDepth, qt.Equals, uint64(2))
+	qt.Assert(t, specs.Contracts[ethereumhandler.ContractNameProcesses].Domain,
+		qt.Equals, "processes.test.vocdoni.eth")
+	qt.Assert(t, specs.Contracts[ethereumhandler.ContractNameProcesses].ListenForEvents, qt.IsTrue)
+	qt.Assert(t, specs.Contracts[ethereumhandler.ContractNameResults].Address,
+		qt.Equals, common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	qt.Assert(t, specs.Contracts[ethereumhandler.ContractNameENSregistry].Address,
+		qt.Equals, common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"))
+	// The address takes precedence over the domain
+	qt.Assert(t, specs.Contracts[ethereumhandler.ContractNameEntities].Address,
+		qt.Equals, common.HexToAddress("0x2222222222222222222222222222222222222222"))
+	qt.Assert(t, specs.Contracts[ethereumhandler.ContractNameEntities].Domain, qt.Equals, "")
+	qt.Assert(t, AvailableChains, qt.Contains, "testchain")
+
+	// Loading the specs again does not duplicate the chain
+	qt.Assert(t, LoadSpecs(path), qt.IsNil)
+	count := 0
+	for _, name := range AvailableChains {
+		if name == "testchain" {
+			count++
+		}
+	}
+	qt.Assert(t, count, qt.Equals, 1)
+
+	// Built-in chains are still available
+	_, err = SpecsFor("goerli")
+	qt.Assert(t, err, qt.IsNil)
+}
+
+func TestChainSpecsValidation(t *testing.T) {
+	processes := map[string]ContractConfig{"processes": {Domain: "processes.vocdoni.eth"}}
+	for _, tc := range []struct {
+		name string
+		c    ChainSpecsConfig
+	}{
+		{"no name", ChainSpecsConfig{NetworkID: 1, Contracts: processes}},
+		{"built-in name", ChainSpecsConfig{Name: "goerli", NetworkID: 5, Contracts: processes}},
+		{"no network id", ChainSpecsConfig{Name: "c", Contracts: processes}},
+		{"unknown source", ChainSpecsConfig{Name: "c", NetworkID: 1, NetworkSource: "foo",
+			ENSRegistry: "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e", Contracts: processes}},
+		{"no ens registry", ChainSpecsConfig{Name: "c", NetworkID: 1, Contracts: processes}},
+		{"no processes", ChainSpecsConfig{Name: "c", NetworkID: 1, Contracts: map[string]ContractConfig{
+			"results": {Address: "0x1111111111111111111111111111111111111111"}}}},
+		{"unknown contract", ChainSpecsConfig{Name: "c", NetworkID: 1, Contracts: map[string]ContractConfig{
+			"foo": {Address: "0x1111111111111111111111111111111111111111"}}}},
+		{"invalid address", ChainSpecsConfig{Name: "c", NetworkID: 1, Contracts: map[string]ContractConfig{
+			"processes": {Address: "0x1234"}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.c.Specs()
+			qt.Assert(t, err, qt.Not(qt.IsNil))
+		})
+	}
+}
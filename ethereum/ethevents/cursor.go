@@ -0,0 +1,116 @@
+package ethevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/log"
+)
+
+const cursorFileName = "cursor.json"
+
+// eventCursor keeps track of the last fully processed Ethereum block for each
+// contract. If a data directory is provided, the cursor is persisted on disk so
+// the event logs missed while the oracle was offline can be backfilled on startup.
+type eventCursor struct {
+	path   string
+	blocks map[common.Address]uint64
+	lock   sync.RWMutex
+}
+
+// newEventCursor loads the cursor stored on dataDir. If dataDir is empty, the
+// cursor is kept only in memory.
+func newEventCursor(dataDir string) (*eventCursor, error) {
+	c := &eventCursor{blocks: make(map[common.Address]uint64)}
+	if dataDir == "" {
+		return c, nil
+	}
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	c.path = filepath.Join(dataDir, cursorFileName)
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.blocks); err != nil {
+		return nil, fmt.Errorf("cannot decode event cursor %s: %w", c.path, err)
+	}
+	return c, nil
+}
+
+// get returns the last processed block for the contract and whether it is known.
+func (c *eventCursor) get(contract common.Address) (uint64, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	block, ok := c.blocks[contract]
+	return block, ok
+}
+
+// set moves forward the last processed block of the contracts and persists it.
+// The cursor never goes backwards.
+func (c *eventCursor) set(block uint64, contracts ...common.Address) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	updated := false
+	for _, contract := range contracts {
+		if current, ok := c.blocks[contract]; ok && current >= block {
+			continue
+		}
+		c.blocks[contract] = block
+		updated = true
+	}
+	if !updated || c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.blocks)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// MigrateDataDir moves the cursor and the relayed processes stored on oldDir, where
+// they were kept before each chain had its own data directory, to newDir. The files
+// already present on newDir are not overwritten.
+func MigrateDataDir(oldDir, newDir string) error {
+	for _, name := range []string{cursorFileName, relayedFileName} {
+		oldPath := filepath.Join(oldDir, name)
+		if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		newPath := filepath.Join(newDir, name)
+		if _, err := os.Stat(newPath); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.MkdirAll(newDir, os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return err
+		}
+		log.Infof("migrated ethereum events %s to %s", name, newDir)
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file first and renames it, so the file
+// is never left half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
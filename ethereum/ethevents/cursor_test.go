@@ -0,0 +1,83 @@
+package ethevents
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEventCursor(t *testing.T) {
+	a := common.HexToAddress("0x01")
+	b := common.HexToAddress("0x02")
+
+	// Without a data directory the cursor is kept in memory
+	c, err := newEventCursor("")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, c.set(10, a), qt.IsNil)
+	block, ok := c.get(a)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, block, qt.Equals, uint64(10))
+
+	dir := t.TempDir()
+	c, err = newEventCursor(dir)
+	qt.Assert(t, err, qt.IsNil)
+	_, ok = c.get(a)
+	qt.Assert(t, ok, qt.IsFalse)
+	qt.Assert(t, c.set(10, a, b), qt.IsNil)
+	qt.Assert(t, c.set(20, b), qt.IsNil)
+	// the cursor never goes backwards
+	qt.Assert(t, c.set(5, a, b), qt.IsNil)
+
+	// The cursor is loaded on restart
+	c, err = newEventCursor(dir)
+	qt.Assert(t, err, qt.IsNil)
+	block, _ = c.get(a)
+	qt.Assert(t, block, qt.Equals, uint64(10))
+	block, _ = c.get(b)
+	qt.Assert(t, block, qt.Equals, uint64(20))
+
+	// A corrupted cursor file is an error
+	qt.Assert(t, os.WriteFile(filepath.Join(dir, cursorFileName), []byte("{"), 0o600), qt.IsNil)
+	_, err = newEventCursor(dir)
+	qt.Assert(t, err, qt.ErrorMatches, "cannot decode event cursor.*")
+}
+
+func TestMigrateDataDir(t *testing.T) {
+	a := common.HexToAddress("0x01")
+	root := t.TempDir()
+	c, err := newEventCursor(root)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, c.set(10, a), qt.IsNil)
+	relayed := []byte(`{}`)
+	qt.Assert(t, os.WriteFile(filepath.Join(root, relayedFileName), relayed, 0o600), qt.IsNil)
+
+	// The files of the old location are moved to the chain directory
+	chainDir := filepath.Join(root, "goerli")
+	qt.Assert(t, MigrateDataDir(root, chainDir), qt.IsNil)
+	_, err = os.Stat(filepath.Join(root, cursorFileName))
+	qt.Assert(t, os.IsNotExist(err), qt.IsTrue)
+	c, err = newEventCursor(chainDir)
+	qt.Assert(t, err, qt.IsNil)
+	block, ok := c.get(a)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, block, qt.Equals, uint64(10))
+	data, err := os.ReadFile(filepath.Join(chainDir, relayedFileName))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, data, qt.DeepEquals, relayed)
+
+	// Nothing to migrate
+	qt.Assert(t, MigrateDataDir(root, chainDir), qt.IsNil)
+
+	// The files already on the chain directory are kept
+	old, err := newEventCursor(root)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, old.set(5, a), qt.IsNil)
+	qt.Assert(t, MigrateDataDir(root, chainDir), qt.IsNil)
+	c, err = newEventCursor(chainDir)
+	qt.Assert(t, err, qt.IsNil)
+	block, _ = c.get(a)
+	qt.Assert(t, block, qt.Equals, uint64(10))
+}
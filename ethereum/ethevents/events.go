@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,11 +23,20 @@ import (
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 )
 
 const (
+	// readBlocksPast is the number of past blocks to read when there is no
+	// cursor stored for a contract
 	readBlocksPast = 200
+	// backfillBlockRange is the maximum number of blocks requested on each
+	// filter query while backfilling, to stay within the web3 provider limits
+	backfillBlockRange = 1000
+	// eventMaxAttempts is the number of times the handlers of an event are
+	// executed before discarding it, if any of them fails
+	eventMaxAttempts = 10
 )
 
 var blockConfirmThreshold = map[models.SourceNetworkId]time.Duration{
@@ -35,9 +45,19 @@ var blockConfirmThreshold = map[models.SourceNetworkId]time.Duration{
 	models.SourceNetworkId_ETH_MAINNET: time.Second * 60,
 }
 
+// blockConfirmDepth is the number of blocks to be mined on top of an event
+// block before the event is processed
+var blockConfirmDepth = map[models.SourceNetworkId]uint64{
+	models.SourceNetworkId_UNKNOWN:     6,
+	models.SourceNetworkId_POA_XDAI:    10,
+	models.SourceNetworkId_ETH_MAINNET: 12,
+}
+
 // EthereumEvents type is used to monitorize Ethereum smart
 // contracts and call custom EventHandler functions
 type EthereumEvents struct {
+	// ChainName is the name of the Ethereum network being watched
+	ChainName string
 	// contracts handle
 	VotingHandle *ethereumhandler.EthereumHandler
 	// list of handler functions that will be called on events
@@ -58,6 +78,15 @@ type EthereumEvents struct {
 	ContractsInfo map[string]*ethereumhandler.EthereumContract
 	// EthereumLastKnownBlock keeps track of the latest Ethereum known block
 	EthereumLastKnownBlock uint64
+	// cursor keeps track of the last fully processed block for each contract
+	cursor *eventCursor
+	// relayed keeps track of the processes created on the Vochain from recent events,
+	// it is persisted on relayedPath (if not empty)
+	relayed     map[string]*relayedProcess
+	relayedPath string
+	relayedLock sync.Mutex
+	// headers keeps the evidences of the EVM census processes relayed (if enabled)
+	headers *headerstore.HeaderStore
 	// block confirm threshold for the network
 	blockConfirmThreshold time.Duration
 }
@@ -65,6 +94,10 @@ type EthereumEvents struct {
 type timedEvent struct {
 	event *ethtypes.Log
 	added time.Time
+	// attempts is the number of times the event handlers have been executed
+	attempts int
+	// done holds the index of the event handlers that succeeded
+	done map[int]bool
 }
 
 // VochainClient is the interface that any vochain client should fullfy
@@ -84,23 +117,28 @@ type CensusManager interface {
 type EventHandler func(ctx context.Context, event *ethtypes.Log, ethEvents *EthereumEvents) error
 
 // EventProcessor is in charge of processing Ethereum event logs asynchronously.
-// Uses a Queue mechanism and waits for EventProcessThreshold before processing a queued event.
+// Uses a Queue mechanism and waits for EventProcessThreshold and EventConfirmations
+// blocks before processing a queued event.
 // If during this time window the Ethereum block is reversed, the event will be deleted.
 type EventProcessor struct {
 	Events                chan ethtypes.Log
 	EventProcessThreshold time.Duration
+	EventConfirmations    uint64
 	eventProcessorRunning bool
 	eventQueue            map[string]timedEvent
 	eventQueueLock        sync.RWMutex
 }
 
-// NewEthEvents creates a new Ethereum events handler
+// NewEthEvents creates a new Ethereum events handler.
+// The last processed block of each contract is persisted on dataDir (if not empty).
 func NewEthEvents(
 	contracts map[string]*ethereumhandler.EthereumContract,
+	chainName string,
 	srcNetworkId models.SourceNetworkId,
 	signer *ethereum.SignKeys,
 	vocapp *vochain.BaseApplication,
 	ethereumWhiteList []string,
+	dataDir string,
 ) (*EthereumEvents, error) {
 	secureAddrList := make(map[common.Address]bool, len(ethereumWhiteList))
 	if len(ethereumWhiteList) != 0 {
@@ -124,25 +162,63 @@ func NewEthEvents(
 	if _, ok := blockConfirmThreshold[srcNetworkId]; ok {
 		confirmThreshold = blockConfirmThreshold[srcNetworkId]
 	}
-	log.Infof("chain %s found, block confirmation threshold set to %s",
-		srcNetworkId, confirmThreshold)
+	confirmDepth := blockConfirmDepth[0]
+	if _, ok := blockConfirmDepth[srcNetworkId]; ok {
+		confirmDepth = blockConfirmDepth[srcNetworkId]
+	}
+	log.Infof("chain %s found, block confirmation threshold set to %s and %d blocks",
+		srcNetworkId, confirmThreshold, confirmDepth)
+	cursor, err := newEventCursor(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load ethereum events cursor: %w", err)
+	}
 	ethev := &EthereumEvents{
+		ChainName:  chainName,
 		Signer:     signer,
 		VochainApp: vocapp,
 		EventProcessor: &EventProcessor{
 			Events:                make(chan ethtypes.Log),
 			EventProcessThreshold: confirmThreshold,
+			EventConfirmations:    confirmDepth,
 			eventQueue:            make(map[string]timedEvent),
 		},
 		EthereumWhiteListAddrs: secureAddrList,
 		ContractsAddress:       contractsAddress,
 		ContractsInfo:          contracts,
 		blockConfirmThreshold:  confirmThreshold,
+		cursor:                 cursor,
+		relayed:                make(map[string]*relayedProcess),
+	}
+	if dataDir != "" {
+		ethev.relayedPath = filepath.Join(dataDir, relayedFileName)
+		if err := ethev.loadRelayed(); err != nil {
+			return nil, fmt.Errorf("cannot load relayed processes: %w", err)
+		}
 	}
 
 	return ethev, nil
 }
 
+// SetConfirmations overrides the time and the number of blocks to wait before
+// processing an event. Zero values keep the network defaults.
+func (ev *EthereumEvents) SetConfirmations(threshold time.Duration, depth uint64) {
+	if threshold > 0 {
+		ev.blockConfirmThreshold = threshold
+		ev.EventProcessor.EventProcessThreshold = threshold
+	}
+	if depth > 0 {
+		ev.EventProcessor.EventConfirmations = depth
+	}
+}
+
+// EnableStorageEvidence stores on dataDir the Ethereum block headers and account proofs
+// linking the census root of the EVM census processes relayed to their source block.
+func (ev *EthereumEvents) EnableStorageEvidence(dataDir string) error {
+	var err error
+	ev.headers, err = headerstore.New(dataDir)
+	return err
+}
+
 // AddEventHandler adds a new handler even log function
 func (ev *EthereumEvents) AddEventHandler(handler EventHandler) {
 	ev.EventHandlers = append(ev.EventHandlers, handler)
@@ -160,19 +236,20 @@ func (ev *EthereumEvents) SubscribeEthereumEventLogs(ctx context.Context) {
 	var err error
 	lastBlockNumber, err := ev.VotingHandle.EthereumClient.BlockNumber(tctx)
 	if err != nil {
-		log.Fatalf("cannot get last block number: (%v)", err)
+		// return so the caller connects to the next web3 endpoint, other chains keep working
+		log.Warnf("cannot get %s last block number: (%v)", ev.ChainName, err)
+		return
 	}
 	atomic.StoreUint64(&ev.EthereumLastKnownBlock, lastBlockNumber)
 
-	// For security, even if subscribe only, force to process
-	// from some past blocks defined by `readBlocksPast`
-	if err := ev.processEventLogsFromTo(ctx, lastBlockNumber-readBlocksPast,
-		lastBlockNumber, ev.VotingHandle.EthereumClient); err != nil {
-		log.Errorf("cannot process event logs: %v", err)
+	// Process the event logs missed since the last processed block of each
+	// contract. This runs on startup and after every web3 reconnection.
+	if err := ev.backfill(ctx, lastBlockNumber, ev.VotingHandle.EthereumClient); err != nil {
+		log.Errorf("cannot backfill event logs: %v", err)
 	}
 
 	// Subscribing from latest known block
-	log.Infof("subscribing to Ethereum Events from block %d", lastBlockNumber)
+	log.Infof("subscribing to %s Ethereum Events from block %d", ev.ChainName, lastBlockNumber)
 	query := eth.FilterQuery{
 		Addresses: ev.ContractsAddress,
 		FromBlock: new(big.Int).SetUint64(lastBlockNumber),
@@ -181,8 +258,11 @@ func (ev *EthereumEvents) SubscribeEthereumEventLogs(ctx context.Context) {
 	var sub eth.Subscription
 	sub, err = ev.VotingHandle.EthereumClient.SubscribeFilterLogs(ctx, query, logs)
 	if err != nil {
-		log.Fatalf("cannot subscribe to ethereum events: (%v)", err)
+		log.Warnf("cannot subscribe to %s ethereum events: (%v)", ev.ChainName, err)
+		return
 	}
+	EthereumConnected.WithLabelValues(ev.ChainName).Set(1)
+	defer EthereumConnected.WithLabelValues(ev.ChainName).Set(0)
 
 	// Run event processor
 	if !ev.EventProcessor.eventProcessorRunning {
@@ -193,6 +273,9 @@ func (ev *EthereumEvents) SubscribeEthereumEventLogs(ctx context.Context) {
 	connFailure := make(chan error, 1)
 	go func(chan error) {
 		time.Sleep(ev.blockConfirmThreshold)
+		// settled is the head seen on the previous iteration, its events
+		// have already been received by the subscription
+		settled := lastBlockNumber
 		for {
 			select {
 			case <-ctx.Done():
@@ -211,6 +294,9 @@ func (ev *EthereumEvents) SubscribeEthereumEventLogs(ctx context.Context) {
 					return
 				}
 				atomic.StoreUint64(&ev.EthereumLastKnownBlock, block)
+				ev.advanceCursor(settled)
+				ev.reconcile(ctx, block)
+				settled = block
 				time.Sleep(ev.blockConfirmThreshold)
 			}
 		}
@@ -233,76 +319,199 @@ func (ev *EthereumEvents) SubscribeEthereumEventLogs(ctx context.Context) {
 	}
 }
 
-// ReadEthereumEventLogs reads the oracle
-// defined smart contract and looks for events.
-func (ev *EthereumEvents) processEventLogsFromTo(ctx context.Context,
-	from, to uint64, client *ethclient.Client) error {
-	for name, contract := range ev.ContractsInfo {
-		if !contract.ListenForEvents {
+// backfill processes the event logs of each contract from its last processed block
+// (or from `readBlocksPast` blocks ago if unknown) up to head, in ranges of
+// `backfillBlockRange` blocks. The cursor is moved forward after each range.
+// The events of the last blocks without enough confirmations are queued instead.
+func (ev *EthereumEvents) backfill(ctx context.Context, head uint64, client *ethclient.Client) error {
+	confirmed := uint64(0)
+	if head > ev.EventProcessor.EventConfirmations {
+		confirmed = head - ev.EventProcessor.EventConfirmations
+	}
+	// Group the contracts by the starting block, usually all of them share the cursor
+	pending := make(map[uint64][]common.Address)
+	for _, addr := range ev.ContractsAddress {
+		from := uint64(0)
+		if head > readBlocksPast {
+			from = head - readBlocksPast
+		}
+		if block, ok := ev.cursor.get(addr); ok {
+			from = block + 1
+		}
+		if from > head {
 			continue
 		}
-		ev.ContractsAddress = append(ev.ContractsAddress, contract.Address)
-		log.Infof("subscribing to contract: %s at address %s", name, contract.Address)
+		pending[from] = append(pending[from], addr)
 	}
-	log.Infof("reading ethereum events from block %d to %d", from, to)
-	query := eth.FilterQuery{
-		FromBlock: new(big.Int).SetUint64(from),
-		ToBlock:   new(big.Int).SetUint64(to),
-		Addresses: ev.ContractsAddress,
+	for from, addrs := range pending {
+		log.Infof("backfilling ethereum events for contracts %v from block %d to %d", addrs, from, head)
+		start := from
+		for ; start <= confirmed; start += backfillBlockRange {
+			end := start + backfillBlockRange - 1
+			if end > confirmed {
+				end = confirmed
+			}
+			if err := ev.processEventLogsFromTo(ctx, start, end, addrs, client); err != nil {
+				return err
+			}
+			if err := ev.cursor.set(ev.cursorBlock(end), addrs...); err != nil {
+				return fmt.Errorf("cannot store ethereum events cursor: %w", err)
+			}
+		}
+		// queue the events of the blocks pending confirmation
+		if confirmed >= from {
+			start = confirmed + 1
+		}
+		if start <= head {
+			logs, err := filterLogs(ctx, start, head, addrs, client)
+			if err != nil {
+				return err
+			}
+			for i := range logs {
+				ev.EventProcessor.add(&logs[i])
+			}
+		}
 	}
+	return nil
+}
 
-	logs, err := client.FilterLogs(ctx, query)
+// advanceCursor moves the cursor of all the contracts to the settled block, unless
+// there are queued events pending to be processed on or before that block.
+func (ev *EthereumEvents) advanceCursor(settled uint64) {
+	if err := ev.cursor.set(ev.cursorBlock(settled), ev.ContractsAddress...); err != nil {
+		log.Warnf("cannot store ethereum events cursor: %v", err)
+	}
+}
+
+// cursorBlock returns the block the cursor can be moved to once the events up to settled
+// are received, that is the block before the oldest queued event if it is not after settled.
+func (ev *EthereumEvents) cursorBlock(settled uint64) uint64 {
+	if block := ev.EventProcessor.oldestBlock(); block > 0 && block <= settled {
+		return block - 1
+	}
+	return settled
+}
+
+// processEventLogsFromTo reads the event logs of the
+// contracts between the given blocks and executes the handlers.
+// The events with failed handlers are queued to be retried by the event processor.
+func (ev *EthereumEvents) processEventLogsFromTo(ctx context.Context,
+	from, to uint64, contracts []common.Address, client *ethclient.Client) error {
+	logs, err := filterLogs(ctx, from, to, contracts, client)
 	if err != nil {
-		return fmt.Errorf("cannot execute ethereum logs filter query: %w", err)
+		return err
 	}
 
-	for _, event := range logs {
-		log.Infof("processing event log from block %d", event.BlockNumber)
-		for _, handler := range ev.EventHandlers {
+	for i := range logs {
+		log.Infof("processing event log from block %d", logs[i].BlockNumber)
+		done := make(map[int]bool)
+		for j, handler := range ev.EventHandlers {
 
 			// Use a pointer to a copy of the event for each handler.
 			// Just in case the handler runs asynchronously,
 			// or for some reason ends up modifying the event.
-			event := event
+			event := logs[i]
 
 			if err := handler(ctx, &event, ev); err != nil {
-				// TODO: handle when event cannot be processed
-				log.Warnf("cannot handle event (%+v) with error (%s)", event, err)
+				log.Warnf("cannot handle event (%+v) with error (%s), it will be retried",
+					event, err)
+				continue
 			}
+			done[j] = true
+		}
+		if len(done) < len(ev.EventHandlers) {
+			ev.EventProcessor.retry(&logs[i], done)
 		}
 	}
 	return nil
 }
 
+// filterLogs returns the event logs of the contracts between the given blocks
+func filterLogs(ctx context.Context, from, to uint64, contracts []common.Address,
+	client *ethclient.Client) ([]ethtypes.Log, error) {
+	log.Infof("reading ethereum events from block %d to %d", from, to)
+	query := eth.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: contracts,
+	}
+	logs, err := client.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute ethereum logs filter query: %w", err)
+	}
+	return logs, nil
+}
+
 func (ep *EventProcessor) id(event *ethtypes.Log) string {
 	return fmt.Sprintf("%x%d", event.TxHash, event.TxIndex)
 }
 
+// add queues the event, unless it is already queued
 func (ep *EventProcessor) add(event *ethtypes.Log) {
 	eventID := ep.id(event)
 	ep.eventQueueLock.Lock()
 	defer ep.eventQueueLock.Unlock()
+	if _, ok := ep.eventQueue[eventID]; ok {
+		return
+	}
 	ep.eventQueue[eventID] = timedEvent{event: event, added: time.Now()}
 }
 
-func (ep *EventProcessor) del(event *ethtypes.Log) {
+// retry keeps the event queued after a failed attempt, so it is processed again
+// after EventProcessThreshold by the handlers not in done. Returns the number of
+// attempts made.
+func (ep *EventProcessor) retry(event *ethtypes.Log, done map[int]bool) int {
 	eventID := ep.id(event)
 	ep.eventQueueLock.Lock()
 	defer ep.eventQueueLock.Unlock()
-	delete(ep.eventQueue, eventID)
+	tev, ok := ep.eventQueue[eventID]
+	if !ok {
+		tev = timedEvent{event: event}
+	}
+	tev.added = time.Now()
+	tev.attempts++
+	tev.done = done
+	ep.eventQueue[eventID] = tev
+	return tev.attempts
 }
 
-// next returns the first log event ready to be processed
-func (ep *EventProcessor) next() *ethtypes.Log {
+func (ep *EventProcessor) del(event *ethtypes.Log) {
+	eventID := ep.id(event)
 	ep.eventQueueLock.Lock()
 	defer ep.eventQueueLock.Unlock()
-	for id, event := range ep.eventQueue {
-		if time.Since(event.added) >= ep.EventProcessThreshold {
-			delete(ep.eventQueue, id)
-			return event.event
+	delete(ep.eventQueue, eventID)
+}
+
+// oldestBlock returns the lowest block number of the queued events, or 0 if the queue is empty
+func (ep *EventProcessor) oldestBlock() uint64 {
+	ep.eventQueueLock.RLock()
+	defer ep.eventQueueLock.RUnlock()
+	oldest := uint64(0)
+	for _, event := range ep.eventQueue {
+		if oldest == 0 || event.event.BlockNumber < oldest {
+			oldest = event.event.BlockNumber
 		}
 	}
-	return nil
+	return oldest
+}
+
+// next returns the first log event ready to be processed, given the current
+// Ethereum head, and the handlers that already processed it. The event is kept
+// on the queue until processed, so the cursor does not move past it.
+func (ep *EventProcessor) next(head uint64) (*ethtypes.Log, map[int]bool) {
+	ep.eventQueueLock.RLock()
+	defer ep.eventQueueLock.RUnlock()
+	for _, event := range ep.eventQueue {
+		if time.Since(event.added) >= ep.EventProcessThreshold &&
+			head >= event.event.BlockNumber+ep.EventConfirmations {
+			done := make(map[int]bool, len(event.done))
+			for i := range event.done {
+				done[i] = true
+			}
+			return event.event, done
+		}
+	}
+	return nil, nil
 }
 
 func (ev *EthereumEvents) runEventProcessor(ctx context.Context) {
@@ -342,13 +551,44 @@ func (ev *EthereumEvents) runEventProcessor(ctx context.Context) {
 			return
 		case <-time.After(2 * time.Second):
 		}
-		if tev := ev.EventProcessor.next(); tev != nil {
+		tev, done := ev.EventProcessor.next(atomic.LoadUint64(&ev.EthereumLastKnownBlock))
+		if tev != nil {
+			// Check the event block has not been reorged out before relaying it
+			canonical, err := ev.isCanonical(ctx, tev)
+			if err != nil {
+				log.Warnf("cannot check event %s block: %v", ev.EventProcessor.id(tev), err)
+				continue
+			}
+			if !canonical {
+				log.Warnf("discarding event %s, block %d %s is not canonical anymore",
+					ev.EventProcessor.id(tev), tev.BlockNumber, tev.BlockHash.Hex())
+				EthereumReorgedEvents.WithLabelValues(ev.ChainName).Inc()
+				ev.EventProcessor.del(tev)
+				continue
+			}
 			for i, handler := range ev.EventHandlers {
+				if done[i] {
+					continue
+				}
 				log.Infof("executing event handler %d for %s", i, ev.EventProcessor.id(tev))
 				if err := handler(ctx, tev, ev); err != nil {
 					log.Error(err)
+					continue
+				}
+				done[i] = true
+			}
+			if len(done) < len(ev.EventHandlers) {
+				attempts := ev.EventProcessor.retry(tev, done)
+				if attempts < eventMaxAttempts {
+					log.Warnf("event %s handlers failed, attempt %d of %d",
+						ev.EventProcessor.id(tev), attempts, eventMaxAttempts)
+					continue
 				}
+				log.Errorf("discarding event %s, handlers failed %d times",
+					ev.EventProcessor.id(tev), attempts)
+				EthereumFailedEvents.WithLabelValues(ev.ChainName).Inc()
 			}
+			ev.EventProcessor.del(tev)
 		}
 	}
 }
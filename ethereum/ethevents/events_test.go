@@ -0,0 +1,106 @@
+package ethevents
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/test/testcommon/ethsim"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+func TestEventProcessorRetry(t *testing.T) {
+	ep := &EventProcessor{eventQueue: make(map[string]timedEvent)}
+	event := &ethtypes.Log{BlockNumber: 5, TxIndex: 1}
+
+	ep.add(event)
+	next, done := ep.next(5)
+	qt.Assert(t, next, qt.IsNotNil)
+	qt.Assert(t, done, qt.HasLen, 0)
+	qt.Assert(t, ep.oldestBlock(), qt.Equals, uint64(5))
+
+	// A failed event stays queued and keeps the handlers that succeeded
+	qt.Assert(t, ep.retry(event, map[int]bool{0: true}), qt.Equals, 1)
+	ep.add(event)
+	qt.Assert(t, ep.retry(event, map[int]bool{0: true}), qt.Equals, 2)
+	next, done = ep.next(5)
+	qt.Assert(t, next, qt.IsNotNil)
+	qt.Assert(t, done, qt.DeepEquals, map[int]bool{0: true})
+
+	// The retries wait for the process threshold
+	ep.EventProcessThreshold = time.Hour
+	ep.retry(event, done)
+	next, _ = ep.next(5)
+	qt.Assert(t, next, qt.IsNil)
+	qt.Assert(t, ep.oldestBlock(), qt.Equals, uint64(5))
+
+	ep.del(event)
+	qt.Assert(t, ep.oldestBlock(), qt.Equals, uint64(0))
+}
+
+func TestBackfill(t *testing.T) {
+	entity := ethereum.NewSignKeys()
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	sim := ethsim.New(t, entity.Address())
+	censusRoot := "0000000000000000000000000000000000000000000000000000000000000001"
+	var blocks []uint64
+	for i := 0; i < 3; i++ {
+		sim.NewProcessStd(t, entity, censusRoot, "ipfs://census", 1000)
+		blocks = append(blocks, sim.Backend.Blockchain().CurrentBlock().NumberU64())
+	}
+	// mine a block on top, so the events are confirmed
+	sim.Backend.Commit()
+	head := sim.Backend.Blockchain().CurrentBlock().NumberU64()
+
+	// The handler fails the first time it gets the second event
+	var lock sync.Mutex
+	handled := make(map[uint64]int)
+	handler := func(ctx context.Context, event *ethtypes.Log, ev *EthereumEvents) error {
+		lock.Lock()
+		defer lock.Unlock()
+		handled[event.BlockNumber]++
+		if event.BlockNumber == blocks[1] && handled[event.BlockNumber] == 1 {
+			return context.DeadlineExceeded
+		}
+		return nil
+	}
+	dataDir := t.TempDir()
+	newEvents := func() *EthereumEvents {
+		ev, err := NewEthEvents(sim.Contracts(), "sim", models.SourceNetworkId_UNKNOWN,
+			nil, nil, nil, dataDir)
+		qt.Assert(t, err, qt.IsNil)
+		ev.SetConfirmations(time.Hour, 1)
+		ev.AddEventHandler(handler)
+		ev.VotingHandle = sim.Handler(t, ev.ContractsInfo)
+		return ev
+	}
+
+	ev := newEvents()
+	err := ev.backfill(context.Background(), head, ev.VotingHandle.EthereumClient)
+	qt.Assert(t, err, qt.IsNil)
+	for _, b := range blocks {
+		qt.Assert(t, handled[b], qt.Equals, 1)
+	}
+	// the failed event is queued and the cursor is held before it
+	qt.Assert(t, ev.EventProcessor.oldestBlock(), qt.Equals, blocks[1])
+	processes := sim.Addresses[ethereumhandler.ContractNameProcesses]
+	block, ok := ev.cursor.get(processes)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, block, qt.Equals, blocks[1]-1)
+
+	// After a restart, the backfill starts from the failed event
+	ev = newEvents()
+	err = ev.backfill(context.Background(), head, ev.VotingHandle.EthereumClient)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, handled[blocks[0]], qt.Equals, 1)
+	qt.Assert(t, handled[blocks[1]], qt.Equals, 2)
+	qt.Assert(t, handled[blocks[2]], qt.Equals, 2)
+	qt.Assert(t, ev.EventProcessor.oldestBlock(), qt.Equals, uint64(0))
+	block, _ = ev.cursor.get(processes)
+	qt.Assert(t, block, qt.Equals, head-1)
+}
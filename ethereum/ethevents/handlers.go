@@ -12,6 +12,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"go.vocdoni.io/dvote/ethereum/contracts"
 	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/vochain"
 	models "go.vocdoni.io/proto/build/go/models"
@@ -98,7 +99,7 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 		// Get process metadata
 		tctx, cancel := context.WithTimeout(ctx, time.Minute)
 		defer cancel()
-		processTx, err := newProcessMeta(tctx, &e.ContractsInfo[ethereumhandler.ContractNameProcesses].ABI,
+		processTx, evidence, err := newProcessMeta(tctx, &e.ContractsInfo[ethereumhandler.ContractNameProcesses].ABI,
 			event.Data, e.VotingHandle)
 		if err != nil {
 			return fmt.Errorf("cannot obtain process data for creating the transaction: %w", err)
@@ -135,6 +136,11 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 			processTx.Process.Mode.AutoStart {
 			processTx.Process.StartBlock = e.VochainApp.Height() + processStartBlockDelay
 		}
+		if evidence != nil {
+			if err := vochain.AttachStorageEvidence(processTx, evidence); err != nil {
+				return err
+			}
+		}
 
 		stx := &models.SignedTx{}
 		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_NewProcess{NewProcess: processTx}})
@@ -156,6 +162,13 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 			return fmt.Errorf("cannot broadcast tx: %w, res: %+v", err, res)
 		}
 		log.Infof("oracle transaction sent, hash: %x", res.Hash)
+		e.trackRelayedProcess(event, processTx.Process.ProcessId)
+		if evidence != nil && e.headers != nil {
+			if err := e.headers.AddEvidence(evidence); err != nil {
+				log.Warnf("cannot store storage evidence for process %x: %v",
+					processTx.Process.ProcessId, err)
+			}
+		}
 
 	case ethereumEventList["processesStatusUpdated"]:
 		log.Infof("executing StatusUpdate event")
@@ -346,10 +359,10 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 }
 
 func newProcessMeta(ctx context.Context, contractABI *abi.ABI, eventData []byte,
-	ph *ethereumhandler.EthereumHandler) (*models.NewProcessTx, error) {
+	ph *ethereumhandler.EthereumHandler) (*models.NewProcessTx, *headerstore.StorageEvidence, error) {
 	structuredData := &contracts.ProcessesNewProcess{}
 	if err := contractABI.UnpackIntoInterface(structuredData, "NewProcess", eventData); err != nil {
-		return nil, fmt.Errorf("cannot unpack NewProcess event: %w", err)
+		return nil, nil, fmt.Errorf("cannot unpack NewProcess event: %w", err)
 	}
 	log.Debugf("newProcessMeta eventData: %+v", structuredData)
 	return ph.NewProcessTxArgs(ctx, structuredData.ProcessId, structuredData.Namespace)
@@ -0,0 +1,103 @@
+package ethevents
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.vocdoni.io/dvote/metrics"
+)
+
+// Ethereum events collectors, labeled by chain since an oracle can watch several chains
+var (
+	// EthereumConnected is 1 while the events subscription of the chain is active
+	EthereumConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ethevents",
+		Name:      "connected",
+		Help:      "Whether the events subscription is active (1) or not (0)",
+	}, []string{"chain"})
+	// EthereumHead is the latest known Ethereum block
+	EthereumHead = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ethevents",
+		Name:      "head",
+		Help:      "Latest known Ethereum block",
+	}, []string{"chain"})
+	// EthereumCursor is the last fully processed block for each contract
+	EthereumCursor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ethevents",
+		Name:      "cursor",
+		Help:      "Last fully processed Ethereum block",
+	}, []string{"chain", "contract"})
+	// EthereumLag is the number of blocks the cursor is behind the Ethereum head
+	EthereumLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ethevents",
+		Name:      "lag",
+		Help:      "Number of blocks the processed events are behind the Ethereum head",
+	}, []string{"chain", "contract"})
+	// EthereumReorgedEvents is the number of queued events discarded by a reorg
+	EthereumReorgedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ethevents",
+		Name:      "reorged_events",
+		Help:      "Queued events discarded because their block is not canonical anymore",
+	}, []string{"chain"})
+	// EthereumReorgedProcesses is the number of relayed processes whose transaction was reorged out
+	EthereumReorgedProcesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ethevents",
+		Name:      "reorged_processes",
+		Help:      "Processes relayed to the Vochain whose Ethereum transaction was reorged out",
+	}, []string{"chain"})
+	// EthereumFailedEvents is the number of events discarded after their handlers failed
+	EthereumFailedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ethevents",
+		Name:      "failed_events",
+		Help:      "Events discarded after their handlers failed on every attempt",
+	}, []string{"chain"})
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics registers each of the ethereum events prometheus metrics.
+// The collectors are shared by all the chains, so they are registered only once.
+func (ev *EthereumEvents) registerMetrics(ma *metrics.Agent) {
+	registerMetricsOnce.Do(func() {
+		ma.Register(EthereumConnected)
+		ma.Register(EthereumHead)
+		ma.Register(EthereumCursor)
+		ma.Register(EthereumLag)
+		ma.Register(EthereumReorgedEvents)
+		ma.Register(EthereumReorgedProcesses)
+		ma.Register(EthereumFailedEvents)
+	})
+}
+
+// getMetrics updates the metrics values to the current state
+func (ev *EthereumEvents) getMetrics() {
+	head := atomic.LoadUint64(&ev.EthereumLastKnownBlock)
+	EthereumHead.WithLabelValues(ev.ChainName).Set(float64(head))
+	for _, addr := range ev.ContractsAddress {
+		block, ok := ev.cursor.get(addr)
+		if !ok {
+			continue
+		}
+		EthereumCursor.WithLabelValues(ev.ChainName, addr.Hex()).Set(float64(block))
+		lag := uint64(0)
+		if head > block {
+			lag = head - block
+		}
+		EthereumLag.WithLabelValues(ev.ChainName, addr.Hex()).Set(float64(lag))
+	}
+}
+
+// CollectMetrics constantly updates the metric values for prometheus
+// The function is blocking, should be called in a go routine
+// If the metrics Agent is nil, do nothing
+func (ev *EthereumEvents) CollectMetrics(ma *metrics.Agent) {
+	if ma != nil {
+		ev.registerMetrics(ma)
+		for {
+			time.Sleep(ma.RefreshInterval)
+			ev.getMetrics()
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package ethevents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	eth "github.com/ethereum/go-ethereum"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// reorgTrackDepth is the number of blocks a relayed process is tracked for
+// reorganisations. Once its Ethereum block is deeper than this, it is considered final.
+const reorgTrackDepth = 128
+
+// relayedFileName is the file where the relayed processes are persisted
+const relayedFileName = "relayed.json"
+
+// relayedProcess is a process created on the Vochain from an Ethereum event
+type relayedProcess struct {
+	ProcessID types.HexBytes `json:"processId"`
+	Event     *ethtypes.Log  `json:"event"`
+}
+
+// isCanonical checks that the block of the event is still part of the canonical chain
+func (ev *EthereumEvents) isCanonical(ctx context.Context, event *ethtypes.Log) (bool, error) {
+	tctx, cancel := context.WithTimeout(ctx, types.EthereumReadTimeout)
+	defer cancel()
+	header, err := ev.VotingHandle.EthereumClient.HeaderByNumber(tctx,
+		new(big.Int).SetUint64(event.BlockNumber))
+	if err != nil {
+		return false, fmt.Errorf("cannot get block header %d: %w", event.BlockNumber, err)
+	}
+	return header.Hash() == event.BlockHash, nil
+}
+
+// trackRelayedProcess keeps track of a process relayed to the Vochain, so it
+// can be reconciled if its originating Ethereum transaction is reorged out.
+func (ev *EthereumEvents) trackRelayedProcess(event *ethtypes.Log, processID []byte) {
+	ev.relayedLock.Lock()
+	defer ev.relayedLock.Unlock()
+	e := *event
+	ev.relayed[string(processID)] = &relayedProcess{ProcessID: processID, Event: &e}
+	ev.saveRelayed()
+}
+
+// untrackRelayedProcess stops tracking a relayed process
+func (ev *EthereumEvents) untrackRelayedProcess(processID []byte) {
+	ev.relayedLock.Lock()
+	defer ev.relayedLock.Unlock()
+	delete(ev.relayed, string(processID))
+	ev.saveRelayed()
+}
+
+// moveRelayedProcess updates the block of a relayed process transaction
+func (ev *EthereumEvents) moveRelayedProcess(processID []byte, receipt *ethtypes.Receipt) {
+	ev.relayedLock.Lock()
+	defer ev.relayedLock.Unlock()
+	rp, ok := ev.relayed[string(processID)]
+	if !ok {
+		return
+	}
+	e := *rp.Event
+	e.BlockNumber = receipt.BlockNumber.Uint64()
+	e.BlockHash = receipt.BlockHash
+	rp.Event = &e
+	ev.saveRelayed()
+}
+
+// relayedProcesses returns a copy of the tracked relayed processes
+func (ev *EthereumEvents) relayedProcesses() []relayedProcess {
+	ev.relayedLock.Lock()
+	defer ev.relayedLock.Unlock()
+	relayed := make([]relayedProcess, 0, len(ev.relayed))
+	for _, rp := range ev.relayed {
+		e := *rp.Event
+		relayed = append(relayed, relayedProcess{ProcessID: rp.ProcessID, Event: &e})
+	}
+	return relayed
+}
+
+// loadRelayed reads the relayed processes persisted on relayedPath
+func (ev *EthereumEvents) loadRelayed() error {
+	data, err := os.ReadFile(ev.relayedPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var relayed []*relayedProcess
+	if err := json.Unmarshal(data, &relayed); err != nil {
+		return fmt.Errorf("cannot decode %s: %w", ev.relayedPath, err)
+	}
+	for _, rp := range relayed {
+		ev.relayed[string(rp.ProcessID)] = rp
+	}
+	return nil
+}
+
+// saveRelayed persists the relayed processes, if relayedPath is set.
+// relayedLock must be held.
+func (ev *EthereumEvents) saveRelayed() {
+	if ev.relayedPath == "" {
+		return
+	}
+	relayed := make([]*relayedProcess, 0, len(ev.relayed))
+	for _, rp := range ev.relayed {
+		relayed = append(relayed, rp)
+	}
+	data, err := json.Marshal(relayed)
+	if err == nil {
+		err = writeFileAtomic(ev.relayedPath, data)
+	}
+	if err != nil {
+		log.Warnf("cannot store relayed processes: %v", err)
+	}
+}
+
+// reconcile checks the processes relayed to the Vochain against the canonical chain.
+// If the originating transaction has been mined on a different block, the tracked
+// event is updated. If it has been reorged out, the process is canceled on the Vochain
+// or, if it cannot be canceled anymore, flagged.
+// The web3 queries are made on a copy of the tracked processes, so the event
+// handlers are not blocked meanwhile.
+func (ev *EthereumEvents) reconcile(ctx context.Context, head uint64) {
+	for _, rp := range ev.relayedProcesses() {
+		if head > rp.Event.BlockNumber+reorgTrackDepth {
+			ev.untrackRelayedProcess(rp.ProcessID)
+			continue
+		}
+		canonical, err := ev.isCanonical(ctx, rp.Event)
+		if err != nil {
+			log.Warnf("cannot reconcile process %x: %v", rp.ProcessID, err)
+			continue
+		}
+		if canonical {
+			continue
+		}
+		tctx, cancel := context.WithTimeout(ctx, types.EthereumReadTimeout)
+		receipt, err := ev.VotingHandle.EthereumClient.TransactionReceipt(tctx, rp.Event.TxHash)
+		cancel()
+		if err != nil && !errors.Is(err, eth.NotFound) {
+			log.Warnf("cannot reconcile process %x: %v", rp.ProcessID, err)
+			continue
+		}
+		if err == nil && receipt.Status == ethtypes.ReceiptStatusSuccessful {
+			log.Infof("process %x transaction %s moved to block %d by a reorg",
+				rp.ProcessID, rp.Event.TxHash.Hex(), receipt.BlockNumber.Uint64())
+			ev.moveRelayedProcess(rp.ProcessID, receipt)
+			continue
+		}
+		log.Warnf("process %x transaction %s has been reorged out of the ethereum chain",
+			rp.ProcessID, rp.Event.TxHash.Hex())
+		EthereumReorgedProcesses.WithLabelValues(ev.ChainName).Inc()
+		if err := ev.cancelProcess(rp.ProcessID); err != nil {
+			log.Errorf("process %x must be reviewed, cannot be canceled: %v", rp.ProcessID, err)
+		}
+		ev.untrackRelayedProcess(rp.ProcessID)
+	}
+}
+
+// cancelProcess sends a transaction to the Vochain setting the process status to CANCELED
+func (ev *EthereumEvents) cancelProcess(processID []byte) error {
+	p, err := ev.VochainApp.State.Process(processID, false)
+	if err != nil {
+		return fmt.Errorf("cannot fetch the process from the Vochain: %w", err)
+	}
+	if p.Status != models.ProcessStatus_READY && p.Status != models.ProcessStatus_PAUSED {
+		return fmt.Errorf("process status is %s", p.Status)
+	}
+	status := models.ProcessStatus_CANCELED
+	stx := &models.SignedTx{}
+	stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_SetProcess{
+		SetProcess: &models.SetProcessTx{
+			Txtype:    models.TxType_SET_PROCESS_STATUS,
+			Nonce:     util.RandomBytes(32),
+			ProcessId: processID,
+			Status:    &status,
+		},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot marshal setProcess tx: %w", err)
+	}
+	stx.Signature, err = ev.Signer.Sign(stx.Tx)
+	if err != nil {
+		return fmt.Errorf("cannot sign oracle tx: %w", err)
+	}
+	txb, err := proto.Marshal(stx)
+	if err != nil {
+		return fmt.Errorf("error marshaling process tx: %w", err)
+	}
+	res, err := ev.VochainApp.SendTx(txb)
+	if err != nil || res == nil {
+		return fmt.Errorf("cannot broadcast tx: %w, res: %+v", err, res)
+	}
+	log.Infof("process %x canceled, oracle transaction sent, hash: %x", processID, res.Hash)
+	return nil
+}
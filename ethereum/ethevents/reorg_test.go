@@ -0,0 +1,113 @@
+package ethevents
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	eth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	qt "github.com/frankban/quicktest"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/test/testcommon/ethsim"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReconcileReorg(t *testing.T) {
+	reorged := ethereum.NewSignKeys()
+	qt.Assert(t, reorged.Generate(), qt.IsNil)
+	moved := ethereum.NewSignKeys()
+	qt.Assert(t, moved.Generate(), qt.IsNil)
+	sim := ethsim.New(t, reorged.Address(), moved.Address())
+
+	// Two processes are created on Ethereum and relayed to the Vochain
+	base := sim.Backend.Blockchain().CurrentBlock().NumberU64()
+	censusRoot := "0000000000000000000000000000000000000000000000000000000000000001"
+	reorgedPid := sim.NewProcessStd(t, reorged, censusRoot, "ipfs://census", 1000)
+	movedPid := sim.NewProcessStd(t, moved, censusRoot, "ipfs://census", 1000)
+	processes := sim.Addresses[ethereumhandler.ContractNameProcesses]
+	logs, err := sim.Backend.FilterLogs(context.Background(), eth.FilterQuery{
+		Addresses: []common.Address{processes},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	events := make(map[[32]byte]ethtypes.Log)
+	for _, l := range logs {
+		if e, err := sim.Processes.ParseNewProcess(l); err == nil {
+			events[e.ProcessId] = l
+		}
+	}
+	qt.Assert(t, events, qt.HasLen, 2)
+	movedTx, _, err := sim.Backend.TransactionByHash(context.Background(),
+		events[movedPid].TxHash)
+	qt.Assert(t, err, qt.IsNil)
+
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	app.SetTestingMethods()
+	var lock sync.Mutex
+	var sent []*models.SetProcessTx
+	app.SetFnSendTx(func(tx []byte) (*ctypes.ResultBroadcastTx, error) {
+		stx := &models.SignedTx{}
+		qt.Assert(t, proto.Unmarshal(tx, stx), qt.IsNil)
+		vtx := &models.Tx{}
+		qt.Assert(t, proto.Unmarshal(stx.Tx, vtx), qt.IsNil)
+		lock.Lock()
+		defer lock.Unlock()
+		sent = append(sent, vtx.GetSetProcess())
+		return &ctypes.ResultBroadcastTx{}, nil
+	})
+	for _, pid := range [][32]byte{reorgedPid, movedPid} {
+		pid := pid
+		qt.Assert(t, app.State.AddProcess(&models.Process{
+			ProcessId:    pid[:],
+			EntityId:     pid[:20],
+			EnvelopeType: &models.EnvelopeType{},
+			Status:       models.ProcessStatus_READY,
+			BlockCount:   1000,
+			Mode:         &models.ProcessMode{AutoStart: true},
+			VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+		}), qt.IsNil)
+	}
+
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	dataDir := t.TempDir()
+	newEvents := func() *EthereumEvents {
+		ev, err := NewEthEvents(sim.Contracts(), "sim", models.SourceNetworkId_UNKNOWN,
+			signer, app, nil, dataDir)
+		qt.Assert(t, err, qt.IsNil)
+		ev.VotingHandle = sim.Handler(t, ev.ContractsInfo)
+		return ev
+	}
+	ev := newEvents()
+	for pid, event := range events {
+		pid, event := pid, event
+		ev.trackRelayedProcess(&event, pid[:])
+	}
+	head := sim.Backend.Blockchain().CurrentBlock().NumberU64()
+	ev.reconcile(context.Background(), head)
+	qt.Assert(t, sent, qt.HasLen, 0)
+
+	// The relayed processes are kept after a restart
+	ev = newEvents()
+	qt.Assert(t, ev.relayedProcesses(), qt.HasLen, 2)
+
+	// A reorg drops the first process transaction and moves the second one
+	head = sim.Reorg(t, base, movedTx)
+	ev.reconcile(context.Background(), head)
+	qt.Assert(t, sent, qt.HasLen, 1)
+	qt.Assert(t, sent[0].ProcessId, qt.DeepEquals, reorgedPid[:])
+	qt.Assert(t, sent[0].GetStatus(), qt.Equals, models.ProcessStatus_CANCELED)
+
+	relayed := newEvents().relayedProcesses()
+	qt.Assert(t, relayed, qt.HasLen, 1)
+	qt.Assert(t, []byte(relayed[0].ProcessID), qt.DeepEquals, movedPid[:])
+	qt.Assert(t, relayed[0].Event.BlockNumber, qt.Equals, base+1)
+	canonical := sim.Backend.Blockchain().GetHeaderByNumber(base + 1).Hash()
+	qt.Assert(t, relayed[0].Event.BlockHash, qt.Equals, canonical)
+}
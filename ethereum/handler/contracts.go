@@ -86,6 +86,14 @@ func (ec *EthereumContract) InitContract(ctx context.Context, contractName strin
 		}
 		return nil
 	}
+	// the address is already known, no need to resolve the domain
+	if ec.Domain == "" && ec.Address != (common.Address{}) {
+		if err := ec.SetABI(contractName); err != nil {
+			return fmt.Errorf("couldn't set contract %s ABI: %w", contractName, err)
+		}
+		log.Infof("loaded contract %s at address: %s", contractName, ec.Address)
+		return nil
+	}
 	var addr string
 	var err error
 	addr, err = EnsResolve(ctx, ensRegistry.Hex(), ec.Domain, web3Client)
@@ -19,6 +19,7 @@ import (
 
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/ethereum/contracts"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
@@ -79,7 +80,7 @@ func NewEthereumHandler(contracts map[string]*EthereumContract, srcNetworkId mod
 		if err := contract.InitContract(ctx, name, contracts[ContractNameENSregistry].Address, eh.EthereumClient); err != nil {
 			return eh, fmt.Errorf("cannot initialize contracts: %w", err)
 		}
-		if err := eh.SetContractInstance(contract); err != nil {
+		if err := eh.SetContractInstance(name, contract); err != nil {
 			log.Errorf("cannot set contract instance: %s", err)
 		}
 	}
@@ -179,38 +180,38 @@ func (eh *EthereumHandler) PrintInfo(ctx context.Context, seconds time.Duration)
 
 // SetContractInstance creates the given contract transactor and returns
 // an object ready to interact with a web3 fashion
-func (eh *EthereumHandler) SetContractInstance(ec *EthereumContract) error {
+func (eh *EthereumHandler) SetContractInstance(contractName string, ec *EthereumContract) error {
 	var err error
-	switch {
-	case strings.HasPrefix(ec.Domain, ContractNameProcesses):
+	switch contractName {
+	case ContractNameProcesses:
 		if eh.VotingProcess, err = contracts.NewProcesses(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing processes contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameNamespaces):
+	case ContractNameNamespaces:
 		if eh.Namespace, err = contracts.NewNamespaces(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing namespace contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameTokenStorageProof):
+	case ContractNameTokenStorageProof:
 		if eh.TokenStorageProof, err = contracts.NewTokenStorageProof(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing token storage proof contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameGenesis):
+	case ContractNameGenesis:
 		if eh.Genesis, err = contracts.NewGenesis(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing genesis contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameResults):
+	case ContractNameResults:
 		if eh.Results, err = contracts.NewResults(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing results contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameEntities):
+	case ContractNameEntities:
 		if eh.EntityResolver, err = contracts.NewEntityResolver(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing results contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameENSresolver):
+	case ContractNameENSresolver:
 		if eh.ENSPublicResolver, err = contracts.NewEntityResolver(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing results contract transactor: %w", err)
 		}
-	case strings.HasPrefix(ec.Domain, ContractNameENSregistry):
+	case ContractNameENSregistry:
 		if eh.ENSPublicRegistry, err = contracts.NewEnsRegistryWithFallback(ec.Address, eh.EthereumClient); err != nil {
 			return fmt.Errorf("error constructing results contract transactor: %w", err)
 		}
@@ -220,20 +221,23 @@ func (eh *EthereumHandler) SetContractInstance(ec *EthereumContract) error {
 
 // PROCESSES WRAPPER
 
-// NewProcessTxArgs gets the info of a created process on the processes contract and creates a NewProcessTx instance
-func (eh *EthereumHandler) NewProcessTxArgs(ctx context.Context, pid [types.ProcessIDsize]byte, namespace uint32) (*models.NewProcessTx, error) {
+// NewProcessTxArgs gets the info of a created process on the processes contract and creates
+// a NewProcessTx instance. For EVM census processes, it also returns the evidence linking
+// the census root to the source block header.
+func (eh *EthereumHandler) NewProcessTxArgs(ctx context.Context, pid [types.ProcessIDsize]byte,
+	namespace uint32) (*models.NewProcessTx, *headerstore.StorageEvidence, error) {
 	// TODO: @jordipainan What to do with namespace?
 	// get process info from the processes contract
 	processMeta, err := eh.VotingProcess.Get(&ethbind.CallOpts{Context: ctx}, pid)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching process from Ethereum: %w", err)
+		return nil, nil, fmt.Errorf("error fetching process from Ethereum: %w", err)
 	}
 	processData := new(models.Process)
 
 	// check status ready or paused
 	status := models.ProcessStatus(processMeta.Status + 1) // +1 required to match with solidity enum
 	if status != models.ProcessStatus_READY && status != models.ProcessStatus_PAUSED {
-		return nil, fmt.Errorf("invalid process status on process creation: %d", status)
+		return nil, nil, fmt.Errorf("invalid process status on process creation: %d", status)
 	}
 	processData.Status = status
 	processData.ProcessId = pid[:]
@@ -241,7 +245,7 @@ func (eh *EthereumHandler) NewProcessTxArgs(ctx context.Context, pid [types.Proc
 	// entity id
 	// for evm censuses the entity id is the snapshoted contract address
 	if processData.EntityId, err = hex.DecodeString(util.TrimHex(processMeta.EntityAddressOwner[0].String())); err != nil {
-		return nil, fmt.Errorf("error decoding entity address: %w", err)
+		return nil, nil, fmt.Errorf("error decoding entity address: %w", err)
 	}
 
 	// process metadata
@@ -250,37 +254,37 @@ func (eh *EthereumHandler) NewProcessTxArgs(ctx context.Context, pid [types.Proc
 	// census root
 	processData.CensusRoot, err = hex.DecodeString(util.TrimHex(processMeta.MetadataCensusRootCensusUri[1]))
 	if err != nil {
-		return nil, fmt.Errorf("cannot decode census root: %w", err)
+		return nil, nil, fmt.Errorf("cannot decode census root: %w", err)
 	}
 
 	// census origin
 	censusOrigin := models.CensusOrigin(processMeta.ModeEnvelopeTypeCensusOrigin[2])
 	if _, ok := vochain.CensusOrigins[censusOrigin]; !ok {
-		return nil, fmt.Errorf("census origin: %d not supported", censusOrigin)
+		return nil, nil, fmt.Errorf("census origin: %d not supported", censusOrigin)
 	}
 	processData.CensusOrigin = censusOrigin
 
 	// census URI
 	if vochain.CensusOrigins[censusOrigin].NeedsURI && len(processMeta.MetadataCensusRootCensusUri[2]) == 0 {
-		return nil, fmt.Errorf("census %s needs URI, none has been provided", vochain.CensusOrigins[censusOrigin].Name)
+		return nil, nil, fmt.Errorf("census %s needs URI, none has been provided", vochain.CensusOrigins[censusOrigin].Name)
 	}
 	processData.CensusURI = &processMeta.MetadataCensusRootCensusUri[2]
 
 	// start and end blocks
 	processData.StartBlock = processMeta.StartBlockBlockCount[0]
 	if processMeta.StartBlockBlockCount[1] < types.ProcessesContractMinBlockCount {
-		return nil, fmt.Errorf("block count is too low")
+		return nil, nil, fmt.Errorf("block count is too low")
 	}
 	processData.BlockCount = processMeta.StartBlockBlockCount[1]
 
 	// process mode
 	if processData.Mode, err = extractProcessMode(processMeta.ModeEnvelopeTypeCensusOrigin[0]); err != nil {
-		return nil, fmt.Errorf("cannot extract process mode: %w", err)
+		return nil, nil, fmt.Errorf("cannot extract process mode: %w", err)
 	}
 
 	// envelope type
 	if processData.EnvelopeType, err = extractEnvelopeType(processMeta.ModeEnvelopeTypeCensusOrigin[1]); err != nil {
-		return nil, fmt.Errorf("cannot extract envelope type: %w", err)
+		return nil, nil, fmt.Errorf("cannot extract envelope type: %w", err)
 	}
 
 	// question index
@@ -308,44 +312,35 @@ func (eh *EthereumHandler) NewProcessTxArgs(ctx context.Context, pid [types.Proc
 	// namespace and sourceNetworkId
 	processData.Namespace, err = eh.VotingProcess.NamespaceId(&ethbind.CallOpts{Context: ctx})
 	if err != nil {
-		return nil, fmt.Errorf("error fetching process from Ethereum: %w", err)
+		return nil, nil, fmt.Errorf("error fetching process from Ethereum: %w", err)
 	}
 	processData.SourceNetworkId = eh.SrcNetworkId
 
 	// if EVM census, check census root provided and get index slot from the token storage proof contract
+	var evidence *headerstore.StorageEvidence
 	if vochain.CensusOrigins[censusOrigin].NeedsIndexSlot {
 		// check valid storage root provided
-		// the holder address is chosen randomly
-		randSigner := ethereum.NewSignKeys()
-		if err := randSigner.Generate(); err != nil {
-			return nil, fmt.Errorf("cannot check storage root, cannot generate random Ethereum address: %w", err)
-		}
-		fetchedRoot, err := eh.getStorageRoot(ctx, randSigner.Address(), processMeta.EntityAddressOwner[0], processMeta.SourceBlockHeight)
+		evidence, err = eh.StorageEvidence(ctx, processMeta.EntityAddressOwner[0],
+			processMeta.SourceBlockHeight.Uint64(), processData.CensusRoot)
 		if err != nil {
-			return nil, fmt.Errorf("cannot check EVM storage root: %w", err)
-		}
-		if bytes.Equal(fetchedRoot.Bytes(), common.Hash{}.Bytes()) {
-			return nil, fmt.Errorf("invalid storage root obtained from Ethereum: %x", fetchedRoot)
-		}
-		if !bytes.Equal(fetchedRoot.Bytes(), processData.CensusRoot) {
-			return nil, fmt.Errorf("invalid storage root: root fetched must be the same as root provided. "+
-				"Got: %x expected: %x", fetchedRoot, processData.CensusRoot)
+			return nil, nil, fmt.Errorf("cannot check EVM storage root: %w", err)
 		}
+		evidence.ProcessID = processData.ProcessId
 		// get index slot from the token storage proof contract
 		islot, err := eh.GetTokenBalanceMappingPosition(ctx, processMeta.EntityAddressOwner[0])
 		if err != nil {
-			return nil, fmt.Errorf("cannot get balance mapping position from the contract: %w", err)
+			return nil, nil, fmt.Errorf("cannot get balance mapping position from the contract: %w", err)
 		}
 		iSlot32 := uint32(islot.Uint64())
 		processData.EthIndexSlot = &iSlot32
 		// decode owner
 		if processData.Owner, err = hex.DecodeString(util.TrimHex(processMeta.EntityAddressOwner[1].String())); err != nil {
-			return nil, fmt.Errorf("error decoding owner address: %w", err)
+			return nil, nil, fmt.Errorf("error decoding owner address: %w", err)
 		}
 		// save sourceBlockHeight
 		sourceBlockHeight64 := uint64(processMeta.SourceBlockHeight.Uint64())
 		if sourceBlockHeight64 == 0 {
-			return nil, fmt.Errorf("source block height must be > 0: %w", err)
+			return nil, nil, fmt.Errorf("source block height must be > 0: %w", err)
 		}
 		processData.SourceBlockHeight = &sourceBlockHeight64
 	}
@@ -354,29 +349,54 @@ func (eh *EthereumHandler) NewProcessTxArgs(ctx context.Context, pid [types.Proc
 	processTxArgs := new(models.NewProcessTx)
 	processTxArgs.Txtype = models.TxType_NEW_PROCESS
 	processTxArgs.Process = processData
-	return processTxArgs, nil
+	return processTxArgs, evidence, nil
 }
 
-func (eh *EthereumHandler) getStorageRoot(ctx context.Context, holder common.Address, contractAddr common.Address, blockNum *big.Int) (hash common.Hash, err error) {
+// StorageEvidence checks that the storage root of the contract at the block height is
+// rootHash, and that the contract account proof links it to the block header. It returns
+// the evidence, so the storage root can be verified offline against the block.
+func (eh *EthereumHandler) StorageEvidence(ctx context.Context, contractAddr common.Address,
+	blockNum uint64, rootHash []byte) (*headerstore.StorageEvidence, error) {
 	// create token storage proof artifact
 	ts := token.ERC20Token{
 		RPCcli: eh.EthereumRPC,
 		Ethcli: eh.EthereumClient,
 	}
-	ts.Init(ctx, "", contractAddr.String())
+	if err := ts.Init(ctx, "", contractAddr.String()); err != nil {
+		return nil, err
+	}
 	// get block
-	blk, err := ts.GetBlock(ctx, blockNum)
+	blk, err := ts.GetBlock(ctx, new(big.Int).SetUint64(blockNum))
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("cannot get block: %w", err)
+		return nil, fmt.Errorf("cannot get block: %w", err)
+	}
+	// get proof, we use a random token holder and a dummy index slot
+	holder := ethereum.NewSignKeys()
+	if err := holder.Generate(); err != nil {
+		return nil, fmt.Errorf("cannot generate random Ethereum address: %w", err)
 	}
-	// get proof
-	log.Debugf("get EVM storage root for address %s and block %d", holder.String(), blk.NumberU64())
-	sproof, err := ts.GetProofWithIndexSlot(ctx, holder, blk, 1)
+	log.Debugf("get EVM storage root for address %s and block %d", holder.Address(), blk.NumberU64())
+	sproof, err := ts.GetProofWithIndexSlot(ctx, holder.Address(), blk, 1)
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("cannot get storage root: %w", err)
+		return nil, fmt.Errorf("cannot get storage root: %w", err)
+	}
+	if bytes.Equal(sproof.StorageHash.Bytes(), common.Hash{}.Bytes()) {
+		return nil, fmt.Errorf("invalid storage root obtained from Ethereum: %x", sproof.StorageHash)
 	}
-	// return the storage root hash
-	return sproof.StorageHash, nil
+	if !bytes.Equal(sproof.StorageHash.Bytes(), rootHash) {
+		return nil, fmt.Errorf("invalid storage root: root fetched must be the same as root provided. "+
+			"Got: %x expected: %x", sproof.StorageHash, rootHash)
+	}
+	if err := vochain.CheckStorageRootEvidence(blk.Header(), sproof,
+		contractAddr.Bytes(), rootHash); err != nil {
+		return nil, fmt.Errorf("invalid storage root evidence: %w", err)
+	}
+	return &headerstore.StorageEvidence{
+		CensusRoot: rootHash,
+		Contract:   contractAddr.Bytes(),
+		Header:     blk.Header(),
+		Proof:      sproof,
+	}, nil
 }
 
 func extractEnvelopeType(envelopeType uint8) (*models.EnvelopeType, error) {
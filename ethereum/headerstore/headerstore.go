@@ -0,0 +1,200 @@
+// Package headerstore keeps a local store of Ethereum block headers and the
+// storage root evidences of the EVM census processes relayed to the Vochain.
+package headerstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/types"
+)
+
+const (
+	headerPrefix   = "h_"
+	evidencePrefix = "e_"
+)
+
+// ErrNotFound is returned when the requested header or evidence is not stored
+var ErrNotFound = errors.New("not found")
+
+// StorageEvidence links the census root of an EVM census process to an Ethereum
+// block header. The account proof of the token contract proves that the census
+// root is the contract storage root on the state of that block.
+type StorageEvidence struct {
+	ProcessID  types.HexBytes                `json:"processId"`
+	CensusRoot types.HexBytes                `json:"censusRoot"`
+	Contract   types.HexBytes                `json:"contract"`
+	Header     *ethtypes.Header              `json:"header"`
+	Proof      *ethstorageproof.StorageProof `json:"proof"`
+}
+
+// HeaderStore is a persistent store of Ethereum block headers. Headers of
+// consecutive blocks must be linked by their parent hash.
+type HeaderStore struct {
+	db   db.Database
+	lock sync.Mutex
+}
+
+// New opens or creates a header store on dataDir
+func New(dataDir string) (*HeaderStore, error) {
+	database, err := db.NewBadgerDB(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open header store: %w", err)
+	}
+	return &HeaderStore{db: database}, nil
+}
+
+// Close closes the header store database
+func (hs *HeaderStore) Close() error {
+	return hs.db.Close()
+}
+
+func headerKey(number uint64) []byte {
+	key := make([]byte, len(headerPrefix)+8)
+	copy(key, headerPrefix)
+	binary.BigEndian.PutUint64(key[len(headerPrefix):], number)
+	return key
+}
+
+// AddHeader stores a block header. If the parent or the child block headers are
+// already stored, they must be linked with the new header. A different header
+// for an already stored height is rejected.
+func (hs *HeaderStore) AddHeader(header *ethtypes.Header) error {
+	if header == nil || header.Number == nil || !header.Number.IsUint64() {
+		return fmt.Errorf("invalid header")
+	}
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	number := header.Number.Uint64()
+	hash := header.Hash()
+	current, err := hs.header(number)
+	if err == nil {
+		if current.Hash() != hash {
+			return fmt.Errorf("header %d already stored with a different hash %x", number, current.Hash())
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if number > 0 {
+		parent, err := hs.header(number - 1)
+		if err == nil && parent.Hash() != header.ParentHash {
+			return fmt.Errorf("header %d parent hash does not match the stored header", number)
+		}
+	}
+	child, err := hs.header(number + 1)
+	if err == nil && child.ParentHash != hash {
+		return fmt.Errorf("header %d hash does not match the stored child parent hash", number)
+	}
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return fmt.Errorf("cannot encode header: %w", err)
+	}
+	return hs.db.Put(headerKey(number), data)
+}
+
+// Header returns the stored header for the block number
+func (hs *HeaderStore) Header(number uint64) (*ethtypes.Header, error) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	return hs.header(number)
+}
+
+func (hs *HeaderStore) header(number uint64) (*ethtypes.Header, error) {
+	key := headerKey(number)
+	exists, err := hs.db.Has(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	data, err := hs.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	header := &ethtypes.Header{}
+	if err := rlp.DecodeBytes(data, header); err != nil {
+		return nil, fmt.Errorf("cannot decode header %d: %w", number, err)
+	}
+	return header, nil
+}
+
+// VerifyChain checks that all the headers between from and to (both included)
+// are stored and linked by their parent hash
+func (hs *HeaderStore) VerifyChain(from, to uint64) error {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	if from > to {
+		return fmt.Errorf("invalid range %d-%d", from, to)
+	}
+	var prev *ethtypes.Header
+	for number := from; number <= to; number++ {
+		header, err := hs.header(number)
+		if err != nil {
+			return fmt.Errorf("cannot get header %d: %w", number, err)
+		}
+		if prev != nil && header.ParentHash != prev.Hash() {
+			return fmt.Errorf("header %d is not linked to header %d", number, number-1)
+		}
+		prev = header
+	}
+	return nil
+}
+
+// AddEvidence stores the storage root evidence of a process, along with its header.
+// The evidence must have been verified by the caller.
+func (hs *HeaderStore) AddEvidence(evidence *StorageEvidence) error {
+	if evidence == nil || evidence.Header == nil || len(evidence.ProcessID) == 0 {
+		return fmt.Errorf("invalid evidence")
+	}
+	if err := hs.AddHeader(evidence.Header); err != nil {
+		return err
+	}
+	data, err := json.Marshal(evidence)
+	if err != nil {
+		return fmt.Errorf("cannot encode evidence: %w", err)
+	}
+	return hs.db.Put(append([]byte(evidencePrefix), evidence.ProcessID...), data)
+}
+
+// Evidence returns the storage root evidence of a process. The evidence header
+// must match the one on the header store for the evidence block.
+func (hs *HeaderStore) Evidence(processID []byte) (*StorageEvidence, error) {
+	key := append([]byte(evidencePrefix), processID...)
+	exists, err := hs.db.Has(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	data, err := hs.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	evidence := &StorageEvidence{}
+	if err := json.Unmarshal(data, evidence); err != nil {
+		return nil, fmt.Errorf("cannot decode evidence: %w", err)
+	}
+	if evidence.Header == nil || evidence.Header.Number == nil {
+		return nil, fmt.Errorf("evidence without header")
+	}
+	header, err := hs.Header(evidence.Header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header.Hash().Bytes(), evidence.Header.Hash().Bytes()) {
+		return nil, fmt.Errorf("evidence header does not match the stored header")
+	}
+	return evidence, nil
+}
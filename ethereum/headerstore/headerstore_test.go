@@ -0,0 +1,73 @@
+package headerstore
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"go.vocdoni.io/dvote/util"
+)
+
+func TestHeaderStore(t *testing.T) {
+	hs, err := New(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	defer hs.Close()
+
+	// Build a chain of linked headers
+	headers := []*ethtypes.Header{}
+	parent := ethcommon.Hash{}
+	for i := int64(0); i < 5; i++ {
+		h := &ethtypes.Header{
+			Number:     big.NewInt(100 + i),
+			ParentHash: parent,
+			Root:       ethcommon.BytesToHash(util.RandomBytes(32)),
+			Difficulty: big.NewInt(1),
+		}
+		headers = append(headers, h)
+		parent = h.Hash()
+	}
+	// Add them unordered, leaving a gap
+	for _, i := range []int{4, 0, 1, 3} {
+		qt.Assert(t, hs.AddHeader(headers[i]), qt.IsNil)
+	}
+	qt.Assert(t, hs.VerifyChain(100, 101), qt.IsNil)
+	qt.Assert(t, hs.VerifyChain(100, 104), qt.Not(qt.IsNil))
+	_, err = hs.Header(102)
+	qt.Assert(t, errors.Is(err, ErrNotFound), qt.IsTrue)
+
+	// A header not linked with its neighbours is rejected
+	fork := *headers[2]
+	fork.Root = ethcommon.BytesToHash(util.RandomBytes(32))
+	qt.Assert(t, hs.AddHeader(&fork), qt.Not(qt.IsNil))
+	qt.Assert(t, hs.AddHeader(headers[2]), qt.IsNil)
+	qt.Assert(t, hs.VerifyChain(100, 104), qt.IsNil)
+
+	// A different header for a stored height is rejected
+	fork = *headers[4]
+	fork.Root = ethcommon.BytesToHash(util.RandomBytes(32))
+	qt.Assert(t, hs.AddHeader(&fork), qt.Not(qt.IsNil))
+
+	stored, err := hs.Header(103)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stored.Hash(), qt.Equals, headers[3].Hash())
+
+	// Store and retrieve a process evidence
+	evidence := &StorageEvidence{
+		ProcessID:  util.RandomBytes(32),
+		CensusRoot: util.RandomBytes(32),
+		Contract:   util.RandomBytes(20),
+		Header:     headers[3],
+		Proof:      &ethstorageproof.StorageProof{AccountProof: []string{"0x01"}},
+	}
+	qt.Assert(t, hs.AddEvidence(evidence), qt.IsNil)
+	stEvidence, err := hs.Evidence(evidence.ProcessID)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stEvidence.Header.Hash(), qt.Equals, headers[3].Hash())
+	qt.Assert(t, stEvidence.CensusRoot, qt.DeepEquals, evidence.CensusRoot)
+	_, err = hs.Evidence(util.RandomBytes(32))
+	qt.Assert(t, errors.Is(err, ErrNotFound), qt.IsTrue)
+}
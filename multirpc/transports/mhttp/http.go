@@ -18,6 +18,8 @@ type HttpHandler struct {
 type HttpContext struct {
 	Writer  http.ResponseWriter
 	Request *http.Request
+	// StatusCode is the HTTP status of the response, 200 if not set
+	StatusCode int
 
 	sent chan struct{}
 }
@@ -78,6 +80,9 @@ func (h *HttpContext) Send(msg transports.Message) error {
 	}
 	h.Writer.Header().Set("Content-Length", fmt.Sprintf("%d", len(msg.Data)+1))
 	h.Writer.Header().Set("Content-Type", "application/json")
+	if h.StatusCode != 0 {
+		h.Writer.WriteHeader(h.StatusCode)
+	}
 	if _, err := h.Writer.Write(msg.Data); err != nil {
 		return err
 	}
@@ -29,8 +29,9 @@ import (
 
 const desiredSoMaxConn = 4096
 
-// ProxyWsHandler function signature required to add a handler in the net/http Server
-type ProxyWsHandler func(c *websocket.Conn)
+// ProxyWsHandler function signature required to add a handler in the net/http Server.
+// The request is the HTTP request upgraded to the websocket connection.
+type ProxyWsHandler func(c *websocket.Conn, r *http.Request)
 
 // Proxy represents a proxy
 type Proxy struct {
@@ -109,7 +110,7 @@ func (p *Proxy) Init() error {
 		log.Infof("fetching letsencrypt TLS certificate for %s", p.Conn.TLSdomain)
 		s, m := p.GenerateSSLCertificate(p.TLSConfig)
 		s.ReadTimeout = 20 * time.Second
-		s.WriteTimeout = 15 * time.Second
+		s.WriteTimeout = 30 * time.Second
 		s.IdleTimeout = 10 * time.Second
 		s.ReadHeaderTimeout = 5 * time.Second
 		s.Handler = p.Server
@@ -133,7 +134,7 @@ func (p *Proxy) Init() error {
 		log.Info("starting go-chi http server")
 		s := &http.Server{
 			ReadTimeout:       10 * time.Second,
-			WriteTimeout:      10 * time.Second,
+			WriteTimeout:      30 * time.Second,
 			IdleTimeout:       10 * time.Second,
 			ReadHeaderTimeout: 3 * time.Second,
 			Handler:           p.Server,
@@ -278,7 +279,7 @@ func (p *Proxy) ProxyIPC(path string) http.HandlerFunc {
 
 // AddWsHTTPBridge adds a WS endpoint to interact with the underlying web3
 func (p *Proxy) AddWsHTTPBridge(url string) ProxyWsHandler {
-	return func(c *websocket.Conn) {
+	return func(c *websocket.Conn, _ *http.Request) {
 		for {
 			msgType, msg, err := c.Reader(context.TODO())
 			if err != nil {
@@ -312,7 +313,7 @@ func (p *Proxy) AddWsHTTPBridge(url string) ProxyWsHandler {
 
 // AddWsWsBridge adds a WS endpoint to interact with the underlying websocket server
 func (p *Proxy) AddWsWsBridge(url string, readLimit int64) ProxyWsHandler {
-	return func(wsServer *websocket.Conn) {
+	return func(wsServer *websocket.Conn, _ *http.Request) {
 		// connection to web3 or vochain
 		wsClient := recws.RecConn{
 			KeepAliveTimeout: 10 * time.Second,
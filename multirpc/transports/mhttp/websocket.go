@@ -26,6 +26,8 @@ type WebsocketHandle struct {
 
 type WebsocketContext struct {
 	Conn *websocket.Conn
+	// RemoteAddr is the network address of the client that opened the connection
+	RemoteAddr string
 }
 
 func (c WebsocketContext) ConnectionType() string {
@@ -59,8 +61,8 @@ func (w *WebsocketHandle) Init(c *transports.Connection) error {
 	return nil
 }
 
-func getWsHandler(path string, receiver chan transports.Message) func(conn *websocket.Conn) {
-	return func(conn *websocket.Conn) {
+func getWsHandler(path string, receiver chan transports.Message) ProxyWsHandler {
+	return func(conn *websocket.Conn, r *http.Request) {
 		// Read websocket messages until the connection is closed. HTTP
 		// handlers are run in new goroutines, so we don't need to spawn
 		// another goroutine.
@@ -73,7 +75,7 @@ func getWsHandler(path string, receiver chan transports.Message) func(conn *webs
 			msg := transports.Message{
 				Data:      payload,
 				TimeStamp: int32(time.Now().Unix()),
-				Context:   &WebsocketContext{Conn: conn},
+				Context:   &WebsocketContext{Conn: conn, RemoteAddr: r.RemoteAddr},
 				Namespace: path,
 			}
 			receiver <- msg
@@ -145,7 +147,7 @@ func wshandler(w http.ResponseWriter, r *http.Request, ph ProxyWsHandler, readLi
 		return
 	}
 	conn.SetReadLimit(readLimit)
-	ph(conn)
+	ph(conn, r)
 }
 
 func somaxconn() int {
@@ -1,93 +1,144 @@
 package apioracle
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 )
 
 var ErrMaxProposalsReached = fmt.Errorf("max proposals per address reached")
 
+// proposalACL counts the processes created by each holder and token contract,
+// limited by the token proposal policy. The counters are persisted on disk, so
+// they are kept across restarts.
 type proposalACL struct {
-	store        sync.Map
-	maxProposals uint
-	purgePeriod  time.Duration
+	store    db.Database
+	lock     sync.Mutex
+	policies *ProposalPolicies
 }
 
 type proposalCounter struct {
-	creationTime  time.Time
-	creationCount uint
+	CreationTime  time.Time `json:"creationTime"`
+	CreationCount uint      `json:"creationCount"`
 }
 
-func NewProposalACL(purgePeriod time.Duration, maxProposals uint) *proposalACL {
+// NewProposalACL opens the proposal ACL stored on dataDir
+func NewProposalACL(dataDir string, policies *ProposalPolicies) (*proposalACL, error) {
+	store, err := db.NewBadgerDB(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open proposal ACL: %w", err)
+	}
 	pACL := &proposalACL{
-		purgePeriod:  purgePeriod,
-		maxProposals: maxProposals,
+		store:    store,
+		policies: policies,
 	}
 	go func() {
 		for {
 			time.Sleep(aclPurgePeriod)
-			n := pACL.purge()
+			n, err := pACL.purge()
+			if err != nil {
+				log.Warnf("cannot purge ACL entries: %v", err)
+			}
 			if n > 0 {
 				log.Infof("purged %d ACL entries", n)
 			}
-			log.Infof("proposal ACL size: %d", pACL.count())
 		}
 	}()
-	return pACL
+	return pACL, nil
+}
+
+func (p *proposalACL) id(holder, contract []byte) []byte {
+	return append(append([]byte{}, holder...), contract...)
 }
 
-func (p *proposalACL) id(holder, contract []byte) string {
-	return fmt.Sprintf("%s%s", holder, contract)
+// expired returns true if the counter period of the contract policy is over
+func (p *proposalACL) expired(contract []byte, pc *proposalCounter) bool {
+	return time.Since(pc.CreationTime) > p.policies.For(contract).Period
 }
 
-func (p *proposalACL) purge() int {
-	rmList := []string{}
-	p.store.Range(func(key, value interface{}) bool {
-		pc := value.(*proposalCounter)
-		if time.Since(pc.creationTime) > p.purgePeriod {
-			rmList = append(rmList, key.(string))
+func (p *proposalACL) purge() (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	rmList := [][]byte{}
+	iter := p.store.NewIterator()
+	for iter.Next() {
+		pc := &proposalCounter{}
+		if err := json.Unmarshal(iter.Value(), pc); err != nil {
+			log.Warnf("cannot decode ACL entry %x: %v", iter.Key(), err)
+			continue
 		}
-		return true
-	})
+		key := iter.Key()
+		if len(key) > types.EntityIDsize && p.expired(key[len(key)-types.EntityIDsize:], pc) {
+			rmList = append(rmList, append([]byte{}, key...))
+		}
+	}
+	iter.Release()
 	for _, id := range rmList {
-		p.store.Delete(id)
+		if err := p.store.Del(id); err != nil {
+			return 0, err
+		}
 	}
-	return len(rmList)
+	return len(rmList), nil
 }
 
 func (p *proposalACL) count() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	count := 0
-	p.store.Range(func(key, value interface{}) bool {
+	iter := p.store.NewIterator()
+	for iter.Next() {
 		count++
-		return true
-	})
+	}
+	iter.Release()
 	return count
 }
 
+// add counts a new process created by holder for the contract. If the holder
+// has already reached the maximum proposals for the period, an error is returned.
 func (p *proposalACL) add(holder, contract []byte) error {
 	if holder == nil || len(contract) != types.EntityIDsize ||
 		len(holder) < common.AddressLength {
 		return fmt.Errorf("holder or contract address, wrong format")
 	}
-	id := p.id(holder, contract)
-	val, ok := p.store.Load(id)
-	if !ok {
-		p.store.Store(id, &proposalCounter{
-			creationTime:  time.Now(),
-			creationCount: 1,
-		})
+	policy := p.policies.For(contract)
+	if policy.ProposalsPerPeriod == 0 {
 		return nil
 	}
-	pc := val.(*proposalCounter)
-	if pc.creationCount >= p.maxProposals {
-		return ErrMaxProposalsReached
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	id := p.id(holder, contract)
+	pc := &proposalCounter{CreationTime: time.Now()}
+	exists, err := p.store.Has(id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		data, err := p.store.Get(id)
+		if err != nil {
+			return err
+		}
+		stored := &proposalCounter{}
+		if err := json.Unmarshal(data, stored); err != nil {
+			return fmt.Errorf("cannot decode ACL entry: %w", err)
+		}
+		if !p.expired(contract, stored) {
+			pc = stored
+		}
+	}
+	if pc.CreationCount >= policy.ProposalsPerPeriod {
+		return fmt.Errorf("%w: %d per %s", ErrMaxProposalsReached,
+			policy.ProposalsPerPeriod, policy.Period)
+	}
+	pc.CreationCount++
+	data, err := json.Marshal(pc)
+	if err != nil {
+		return err
 	}
-	pc.creationCount++
-	p.store.Store(id, pc)
-	return nil
+	return p.store.Put(id, data)
 }
@@ -1,23 +1,26 @@
 package apioracle
 
 import (
-	"bytes"
 	"context"
 	"encoding/hex"
 	"fmt"
-	"math/big"
+	"strings"
+	"sync"
 	"time"
 
+	ethbind "github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core"
 	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
-	ethtoken "github.com/vocdoni/storage-proofs-eth-go/token"
 	"go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	chain "go.vocdoni.io/dvote/ethereum"
 	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/oracle"
 	"go.vocdoni.io/dvote/router"
+	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/proto/build/go/models"
@@ -30,6 +33,14 @@ const (
 	maxProposalPerWindow = 3
 )
 
+// evmCensusOrigins are the census origins accepted by handleNewEthProcess, by name
+var evmCensusOrigins = map[string]models.CensusOrigin{
+	"":        models.CensusOrigin_ERC20,
+	"erc20":   models.CensusOrigin_ERC20,
+	"erc721":  models.CensusOrigin_ERC721,
+	"erc1155": models.CensusOrigin_ERC1155,
+}
+
 var srcNetworkIds = map[string]models.SourceNetworkId{
 	"default":   models.SourceNetworkId_UNKNOWN,
 	"mainnet":   models.SourceNetworkId_ETH_MAINNET_SIGNALING,
@@ -44,6 +55,15 @@ type APIoracle struct {
 	eh               *ethereumhandler.EthereumHandler
 	chainNames       map[string]bool
 	erc20proposalACL *proposalACL
+	policies         *ProposalPolicies
+	headers          *headerstore.HeaderStore
+	processes        common.Address
+	gaslessACL       *proposalACL
+	gaslessDomain    core.TypedDataDomain
+	// gaslessPending holds the gasless processes sent to the mempool but
+	// maybe not committed yet, with the time they were sent
+	gaslessPending     map[string]time.Time
+	gaslessPendingLock sync.Mutex
 }
 
 func NewAPIoracle(o *oracle.Oracle, r *router.Router) (*APIoracle, error) {
@@ -51,7 +71,11 @@ func NewAPIoracle(o *oracle.Oracle, r *router.Router) (*APIoracle, error) {
 	return a, nil
 }
 
-func (a *APIoracle) EnableERC20(chainName string, web3Endpoints []string) error {
+// EnableERC20 enables the creation of EVM census processes. The process creation
+// is limited by the token proposal policies, if nil DefaultProposalPolicies is used.
+// The proposal counters are persisted on dataDir.
+func (a *APIoracle) EnableERC20(chainName string, web3Endpoints []string,
+	policies *ProposalPolicies, dataDir string) error {
 	if chainName == "" || len(web3Endpoints) == 0 {
 		return fmt.Errorf("no web3 endpoint or chain name provided")
 	}
@@ -59,7 +83,13 @@ func (a *APIoracle) EnableERC20(chainName string, web3Endpoints []string) error
 	if err != nil {
 		return err
 	}
-	a.erc20proposalACL = NewProposalACL(aclTimeWindow, maxProposalPerWindow)
+	if policies == nil {
+		policies = DefaultProposalPolicies()
+	}
+	a.policies = policies
+	if a.erc20proposalACL, err = NewProposalACL(dataDir, policies); err != nil {
+		return err
+	}
 	srcNetId, ok := srcNetworkIds[chainName]
 	if !ok {
 		srcNetId = srcNetworkIds["default"]
@@ -70,35 +100,73 @@ func (a *APIoracle) EnableERC20(chainName string, web3Endpoints []string) error
 		return err
 	}
 	a.eh.WaitSync()
+	a.processes = specs.Contracts[ethereumhandler.ContractNameProcesses].Address
 	for k, v := range srcNetworkIds {
 		if v.String() == srcNetId.String() {
 			a.chainNames[k] = true
 		}
 	}
-	a.router.RegisterPublic("newERC20process", a.handleNewEthProcess)
+	a.router.Register("oracle", router.Method{Name: "newERC20process", Public: true,
+		Handler: a.handleNewEthProcess, Required: []string{"newProcess", "storageProof"},
+		Response: []string{"processId"}})
 	a.router.APIs = append(a.router.APIs, "oracle")
 	return nil
 }
 
+// EnableStorageEvidence stores the Ethereum block headers and account proofs used to
+// validate the census root of the processes created, and makes them available through
+// the getStorageEvidence method so the storage roots can be verified offline.
+func (a *APIoracle) EnableStorageEvidence(dataDir string) error {
+	var err error
+	if a.headers, err = headerstore.New(dataDir); err != nil {
+		return err
+	}
+	a.router.Register("oracle", router.Method{Name: "getStorageEvidence", Public: true,
+		Handler: a.handleGetStorageEvidence, Required: []string{"processId"},
+		Response: []string{"storageEvidence"}})
+	return nil
+}
+
 func (a *APIoracle) handleNewEthProcess(req router.RouterRequest) {
 	var response api.MetaResponse
 	if req.NewProcess == nil {
-		a.router.SendError(req, "newProcess is empty")
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "newProcess is empty")
 		return
 	}
 	if _, ok := a.chainNames[req.NewProcess.NetworkId]; !ok {
-		a.router.SendError(req, fmt.Sprintf("provided chainId does not match ours (%s)",
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("provided chainId does not match ours (%s)",
 			req.NewProcess.NetworkId))
 		return
 	}
 	if req.NewProcess.EthIndexSlot == nil {
-		a.router.SendError(req, "index slot not provided")
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "index slot not provided")
 		return
 	}
 	if req.NewProcess.SourceHeight == nil {
-		a.router.SendError(req, "no source height provided")
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "no source height provided")
+		return
+	}
+	censusOrigin, ok := evmCensusOrigins[strings.ToLower(req.NewProcess.CensusOrigin)]
+	if !ok {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("census origin %q not supported", req.NewProcess.CensusOrigin))
 		return
 	}
+	if !a.oracle.VochainApp.State.CensusOriginActive(censusOrigin) {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("census origin %q not yet enabled", req.NewProcess.CensusOrigin))
+		return
+	}
+	var censusURI *string
+	if censusOrigin == models.CensusOrigin_ERC1155 {
+		// the token ID is stored as the census URI
+		if req.NewProcess.TokenID == "" {
+			a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "token ID not provided")
+			return
+		}
+		censusURI = &req.NewProcess.TokenID
+	}
 
 	pidseed := fmt.Sprintf("%d%d%x%x",
 		a.Namespace,
@@ -112,6 +180,7 @@ func (a *APIoracle) handleNewEthProcess(req router.RouterRequest) {
 		StartBlock:        req.NewProcess.StartBlock,
 		BlockCount:        req.NewProcess.BlockCount,
 		CensusRoot:        req.NewProcess.CensusRoot,
+		CensusURI:         censusURI,
 		EnvelopeType:      req.NewProcess.EnvelopeType,
 		VoteOptions:       req.NewProcess.VoteOptions,
 		EthIndexSlot:      req.NewProcess.EthIndexSlot,
@@ -120,15 +189,18 @@ func (a *APIoracle) handleNewEthProcess(req router.RouterRequest) {
 		ProcessId:         ethereum.HashRaw([]byte(pidseed)),
 		Status:            models.ProcessStatus_READY,
 		Namespace:         a.Namespace,
-		CensusOrigin:      models.CensusOrigin_ERC20,
+		CensusOrigin:      censusOrigin,
 		Mode:              &models.ProcessMode{AutoStart: true},
 		SourceNetworkId:   a.eh.SrcNetworkId,
 		Owner:             req.GetAddress().Bytes(),
 	}
 
-	// Check the ACL
-	if err := a.erc20proposalACL.add(p.Owner, p.EntityId); err != nil {
-		a.router.SendError(req, err.Error())
+	// Check the token policy allows the process parameters
+	token := tokenLabel(a.policies, p.EntityId)
+	policy := a.policies.For(p.EntityId)
+	if err := policy.checkProcess(p); err != nil {
+		ProposalsRejected.WithLabelValues(token, rejectReason(err)).Inc()
+		a.router.SendErrorCode(req, api.ErrCodeUnauthorized, err.Error())
 		return
 	}
 
@@ -139,43 +211,69 @@ func (a *APIoracle) handleNewEthProcess(req router.RouterRequest) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), ethQueryTimeOut)
 	defer cancel()
-	index, err := a.getIndexSlot(ctx, p.EntityId, p.GetSourceBlockHeight(), p.CensusRoot)
+	evidence, err := a.checkStorageRoot(ctx, p.EntityId, p.GetSourceBlockHeight(), p.CensusRoot)
+	if err != nil {
+		a.router.SendError(req, err.Error())
+		return
+	}
+	// The index slot must be the one registered for the token, otherwise the
+	// creator could point the census to any mapping of the contract storage
+	index, err := a.getIndexSlot(ctx, p.EntityId)
 	if err != nil {
 		a.router.SendError(req, err.Error())
 		return
 	}
 	log.Infof("fetched index slot %d for contract %x", index, p.EntityId)
 	if index != p.GetEthIndexSlot() {
-		a.router.SendError(req, "index slot does not match")
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "index slot does not match")
 		return
 	}
 
-	slot, err := ethtoken.GetSlot(req.GetAddress().Hex(), int(index))
+	slot, err := vochain.StorageSlot(p, *req.GetAddress())
 	if err != nil {
-		a.router.SendError(req, fmt.Sprintf("cannot fetch slot: %s", err))
+		a.router.SendError(req, err.Error())
 		return
 	}
 
-	log.Debugf("ERC20 index slot %d, storage slot %x", index, slot)
+	log.Debugf("%s index slot %d, storage slot %x", censusOrigin, p.GetEthIndexSlot(), slot)
 
-	valid, _, err := vochain.CheckProof(sproof,
-		models.CensusOrigin_ERC20,
+	valid, balance, err := vochain.CheckProof(sproof,
+		censusOrigin,
 		p.CensusRoot,
 		p.ProcessId,
-		slot[:],
+		slot,
 	)
 	if err != nil {
 		a.router.SendError(req, err.Error())
 		return
 	}
 	if !valid {
-		a.router.SendError(req, "proof is not valid")
+		a.router.SendErrorCode(req, api.ErrCodeInvalidCensusProof, "proof is not valid")
+		return
+	}
+
+	// Check the holder balance and the ACL
+	if err := policy.checkBalance(balance); err != nil {
+		ProposalsRejected.WithLabelValues(token, rejectReason(err)).Inc()
+		a.router.SendErrorCode(req, api.ErrCodeUnauthorized, err.Error())
+		return
+	}
+	if err := a.erc20proposalACL.add(p.Owner, p.EntityId); err != nil {
+		ProposalsRejected.WithLabelValues(token, rejectReason(err)).Inc()
+		a.router.SendErrorCode(req, api.ErrCodeUnauthorized, err.Error())
 		return
 	}
-	if err := a.oracle.NewProcess(p); err != nil {
+	evidence.ProcessID = p.ProcessId
+	if err := a.oracle.NewProcessWithEvidence(p, evidence); err != nil {
 		a.router.SendError(req, err.Error())
 		return
 	}
+	ProposalsAccepted.WithLabelValues(token).Inc()
+	if a.headers != nil {
+		if err := a.headers.AddEvidence(evidence); err != nil {
+			log.Warnf("cannot store storage evidence for process %x: %v", p.ProcessId, err)
+		}
+	}
 
 	response.ProcessID = p.ProcessId
 	if err := req.Send(a.router.BuildReply(req, &response)); err != nil {
@@ -214,65 +312,47 @@ func buildETHproof(proof *ethstorageproof.StorageResult) (*models.Proof, error)
 	}}, nil
 }
 
-func (a *APIoracle) getIndexSlot(ctx context.Context, contractAddr []byte,
-	evmBlockHeight uint64, rootHash []byte) (uint32, error) {
-	// check valid storage root provided
-	if len(contractAddr) != common.AddressLength {
-		return 0, fmt.Errorf("contractAddress length is not correct")
-	}
-	addr := common.Address{}
-	copy(addr[:], contractAddr[:])
-	fetchedRoot, err := a.getStorageRoot(ctx, addr, evmBlockHeight)
-	if err != nil {
-		return 0, fmt.Errorf("cannot check EVM storage root: %w", err)
-	}
-	if bytes.Equal(fetchedRoot.Bytes(), common.Hash{}.Bytes()) {
-		return 0, fmt.Errorf("invalid storage root obtained from Ethereum: %x", fetchedRoot)
-	}
-	if !bytes.Equal(fetchedRoot.Bytes(), rootHash) {
-		return 0, fmt.Errorf("invalid storage root, got: %x expected: %x",
-			fetchedRoot, rootHash)
-	}
-	// get index slot from the token storage proof contract
-	islot, err := a.eh.GetTokenBalanceMappingPosition(ctx, addr)
+// getIndexSlot returns the balance mapping index slot registered on the token
+// storage proof contract
+func (a *APIoracle) getIndexSlot(ctx context.Context, contractAddr []byte) (uint32, error) {
+	addr := common.BytesToAddress(contractAddr)
+	token, err := a.eh.TokenStorageProof.Tokens(&ethbind.CallOpts{Context: ctx}, addr)
 	if err != nil {
 		return 0, fmt.Errorf("cannot get balance mapping position from the contract: %w", err)
 	}
-	return uint32(islot.Uint64()), nil
-}
-
-// getStorageRoot returns the storage Root Hash of the Ethereum Patricia trie for
-// a contract address and a block height
-func (a *APIoracle) getStorageRoot(ctx context.Context, contractAddr common.Address,
-	blockNum uint64) (hash common.Hash, err error) {
-	// create token storage proof artifact
-	ts := ethtoken.ERC20Token{
-		RPCcli: a.eh.EthereumRPC,
-		Ethcli: a.eh.EthereumClient,
-	}
-	if err := ts.Init(ctx, "", contractAddr.String()); err != nil {
-		return common.Hash{}, err
+	// the balance mapping position of the tokens not registered is zero
+	if !token.Registered {
+		return 0, fmt.Errorf("token %s is not registered", addr.Hex())
 	}
+	return uint32(token.BalanceMappingPosition.Uint64()), nil
+}
 
-	// get block
-	blk, err := ts.GetBlock(ctx, new(big.Int).SetUint64(blockNum))
-	if err != nil {
-		return common.Hash{}, fmt.Errorf("cannot get block: %w", err)
+// checkStorageRoot checks the storage root of the contract at the EVM block height
+// matches the provided root hash. It returns the evidence linking the root hash to
+// the block header.
+func (a *APIoracle) checkStorageRoot(ctx context.Context, contractAddr []byte,
+	evmBlockHeight uint64, rootHash []byte) (*headerstore.StorageEvidence, error) {
+	if len(contractAddr) != common.AddressLength {
+		return nil, fmt.Errorf("contractAddress length is not correct")
 	}
+	return a.eh.StorageEvidence(ctx, common.BytesToAddress(contractAddr), evmBlockHeight, rootHash)
+}
 
-	// get proof, we use a random token holder and a dummy index slot
-	holder := ethereum.NewSignKeys()
-	if err := holder.Generate(); err != nil {
-		return common.Hash{},
-			fmt.Errorf("cannot check storage root, cannot generate random Ethereum address: %w", err)
+// handleGetStorageEvidence returns the evidence linking the census root of an
+// EVM census process to the Ethereum block header it was created from
+func (a *APIoracle) handleGetStorageEvidence(req router.RouterRequest) {
+	var response api.MetaResponse
+	if len(req.ProcessID) != types.ProcessIDsize {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "invalid process id")
+		return
 	}
-
-	log.Debugf("get EVM storage root for address %s and block %d", holder.Address(), blockNum)
-	sproof, err := ts.GetProofWithIndexSlot(ctx, holder.Address(), blk, 1)
+	evidence, err := a.headers.Evidence(req.ProcessID)
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("cannot get storage root: %w", err)
+		a.router.SendError(req, fmt.Sprintf("cannot get storage evidence: (%v)", err))
+		return
+	}
+	response.StorageEvidence = evidence
+	if err := req.Send(a.router.BuildReply(req, &response)); err != nil {
+		log.Warn(err)
 	}
-
-	// return the storage root hash
-	return sproof.StorageHash, nil
 }
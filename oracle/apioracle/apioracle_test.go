@@ -0,0 +1,55 @@
+package apioracle
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/oracle"
+	"go.vocdoni.io/dvote/router"
+	"go.vocdoni.io/dvote/test/testcommon/ethsim"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+)
+
+func TestIndexSlotNotRegistered(t *testing.T) {
+	sim := ethsim.New(t)
+	a := &APIoracle{eh: sim.Handler(t, nil)}
+	// the tokens not registered have a zero index slot, which must not be trusted
+	_, err := a.getIndexSlot(context.Background(), util.RandomBytes(20))
+	qt.Assert(t, err, qt.ErrorMatches, "token .* is not registered")
+}
+
+func TestNFTCensusOriginNotEnabled(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	app.State.SetChainID("vocdoni-release-1.0.1")
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	o, err := oracle.NewOracle(app, signer)
+	qt.Assert(t, err, qt.IsNil)
+	a, err := NewAPIoracle(o, router.NewRouter(nil, nil, signer, nil, false))
+	qt.Assert(t, err, qt.IsNil)
+	a.chainNames["goerli"] = true
+
+	indexSlot, height := uint32(1), uint64(1)
+	for _, origin := range []string{"erc721", "ERC1155"} {
+		ctx := &replyContext{}
+		a.handleNewEthProcess(router.RouterRequest{
+			MetaRequest: api.MetaRequest{NewProcess: &api.NewProcess{
+				NetworkId:    "goerli",
+				EthIndexSlot: &indexSlot,
+				SourceHeight: &height,
+				CensusOrigin: origin,
+				TokenID:      "1",
+			}},
+			MessageContext: ctx,
+		})
+		qt.Assert(t, ctx.replies, qt.HasLen, 1)
+		qt.Assert(t, ctx.replies[0].Ok, qt.IsFalse)
+		qt.Assert(t, ctx.replies[0].ErrorCode, qt.Equals, api.ErrCodeInvalidRequest)
+		qt.Assert(t, ctx.replies[0].Message, qt.Matches, ".* not yet enabled")
+	}
+}
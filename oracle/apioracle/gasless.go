@@ -0,0 +1,343 @@
+package apioracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	ethbind "github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/router"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+const (
+	// GaslessDomainName and GaslessDomainVersion identify the EIP-712 domain
+	// of the gasless process creation requests
+	GaslessDomainName    = "Vocdoni"
+	GaslessDomainVersion = "1"
+	gaslessPrimaryType   = "NewProcess"
+	// gaslessPendingTimeout is the time a sent gasless process is considered in
+	// flight, afterwards the committed state decides if it was created
+	gaslessPendingTimeout = 10 * time.Minute
+)
+
+// ErrEntityNotControlled is returned if the gasless process signer does not control the entity
+var ErrEntityNotControlled = errors.New("signer does not control the entity")
+
+// gaslessTypes are the EIP-712 types of a gasless process creation request
+var gaslessTypes = core.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	gaslessPrimaryType: {
+		{Name: "entityId", Type: "address"},
+		{Name: "startBlock", Type: "uint32"},
+		{Name: "blockCount", Type: "uint32"},
+		{Name: "censusRoot", Type: "bytes"},
+		{Name: "censusUri", Type: "string"},
+		{Name: "censusOrigin", Type: "string"},
+		{Name: "metadata", Type: "string"},
+		{Name: "encryptedVotes", Type: "bool"},
+		{Name: "uniqueValues", Type: "bool"},
+		{Name: "costFromWeight", Type: "bool"},
+		{Name: "maxCount", Type: "uint32"},
+		{Name: "maxValue", Type: "uint32"},
+		{Name: "maxVoteOverwrites", Type: "uint32"},
+		{Name: "maxTotalCost", Type: "uint32"},
+		{Name: "costExponent", Type: "uint32"},
+		{Name: "nonce", Type: "uint64"},
+	},
+}
+
+// GaslessDomain returns the EIP-712 domain of the gasless process creation requests
+// for the Ethereum chain and processes contract
+func GaslessDomain(chainID *big.Int, processesContract common.Address) core.TypedDataDomain {
+	return core.TypedDataDomain{
+		Name:              GaslessDomainName,
+		Version:           GaslessDomainVersion,
+		ChainId:           (*math.HexOrDecimal256)(chainID),
+		VerifyingContract: processesContract.Hex(),
+	}
+}
+
+// GaslessProcessTypedData returns the EIP-712 typed data of a gasless process creation
+// request. Its JSON encoding can be signed by a wallet with eth_signTypedData_v4.
+func GaslessProcessTypedData(domain core.TypedDataDomain, p *api.NewProcess) *core.TypedData {
+	uint32str := func(v uint32) string { return strconv.FormatUint(uint64(v), 10) }
+	envelope := p.EnvelopeType
+	if envelope == nil {
+		envelope = &models.EnvelopeType{}
+	}
+	options := p.VoteOptions
+	if options == nil {
+		options = &models.ProcessVoteOptions{}
+	}
+	return &core.TypedData{
+		Types:       gaslessTypes,
+		PrimaryType: gaslessPrimaryType,
+		Domain:      domain,
+		Message: core.TypedDataMessage{
+			"entityId":          common.BytesToAddress(p.EntityID).Hex(),
+			"startBlock":        uint32str(p.StartBlock),
+			"blockCount":        uint32str(p.BlockCount),
+			"censusRoot":        []byte(p.CensusRoot),
+			"censusUri":         p.CensusURI,
+			"censusOrigin":      p.CensusOrigin,
+			"metadata":          p.Metadata,
+			"encryptedVotes":    envelope.EncryptedVotes,
+			"uniqueValues":      envelope.UniqueValues,
+			"costFromWeight":    envelope.CostFromWeight,
+			"maxCount":          uint32str(options.MaxCount),
+			"maxValue":          uint32str(options.MaxValue),
+			"maxVoteOverwrites": uint32str(options.MaxVoteOverwrites),
+			"maxTotalCost":      uint32str(options.MaxTotalCost),
+			"costExponent":      uint32str(options.CostExponent),
+			"nonce":             strconv.FormatUint(p.Nonce, 10),
+		},
+	}
+}
+
+// TypedDataHash returns the EIP-712 hash to be signed for the typed data
+func TypedDataHash(typedData *core.TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash typed data domain: %w", err)
+	}
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash typed data message: %w", err)
+	}
+	rawData := append([]byte("\x19\x01"), domainSeparator...)
+	return ethcrypto.Keccak256(append(rawData, messageHash...)), nil
+}
+
+// SignTypedData signs the EIP-712 typed data. The recovery byte is 27 or 28,
+// as returned by eth_signTypedData_v4.
+func SignTypedData(keys *ethereum.SignKeys, typedData *core.TypedData) ([]byte, error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := ethcrypto.Sign(hash, &keys.Private)
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27
+	return signature, nil
+}
+
+// TypedDataSigner returns the address that signed the EIP-712 typed data
+func TypedDataSigner(typedData *core.TypedData, signature []byte) (common.Address, error) {
+	if len(signature) != ethereum.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature length not correct (%d)", len(signature))
+	}
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pubKey, err := ethcrypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("cannot recover signer: %w", err)
+	}
+	return ethcrypto.PubkeyToAddress(*pubKey), nil
+}
+
+// EnableGasless enables the creation of processes from EIP-712 signed requests,
+// so entities do not need to pay gas for creating processes on Ethereum. The signer
+// must be the entity or the address set on the entity resolver for it. The processes
+// created are limited by the default proposal policy, the counters are persisted on dataDir.
+// EnableERC20 must be called first, the domain uses its Ethereum chain.
+func (a *APIoracle) EnableGasless(dataDir string) error {
+	if a.eh == nil || a.eh.VotingProcess == nil {
+		return fmt.Errorf("ethereum handler with the processes contract not enabled")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ethQueryTimeOut)
+	defer cancel()
+	chainID, err := a.eh.EthereumClient.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot get ethereum chain id: %w", err)
+	}
+	a.gaslessDomain = GaslessDomain(chainID, a.processes)
+	if a.gaslessACL, err = NewProposalACL(dataDir, DefaultProposalPolicies()); err != nil {
+		return err
+	}
+	log.Infof("gasless processes enabled, EIP-712 domain chain %s contract %s",
+		chainID, a.gaslessDomain.VerifyingContract)
+	a.router.Register("oracle", router.Method{Name: "newGaslessProcess", Public: true,
+		Handler: a.handleNewGaslessProcess, Required: []string{"newProcess", "signature"},
+		Response: []string{"processId"}})
+	return nil
+}
+
+// gaslessProcess builds the Vochain process of a gasless process request
+func (a *APIoracle) gaslessProcess(req *api.NewProcess) (*models.Process, error) {
+	if len(req.EntityID) != common.AddressLength {
+		return nil, fmt.Errorf("invalid entity id")
+	}
+	origin, ok := models.CensusOrigin_value[strings.ToUpper(req.CensusOrigin)]
+	if !ok {
+		return nil, fmt.Errorf("census origin %q not supported", req.CensusOrigin)
+	}
+	censusOrigin := models.CensusOrigin(origin)
+	if info, ok := vochain.CensusOrigins[censusOrigin]; !ok || info.NeedsIndexSlot {
+		return nil, fmt.Errorf("census origin %q not supported for gasless processes", req.CensusOrigin)
+	}
+	if req.EnvelopeType == nil || req.VoteOptions == nil {
+		return nil, fmt.Errorf("envelope type and vote options are required")
+	}
+	envelope := &models.EnvelopeType{
+		EncryptedVotes: req.EnvelopeType.EncryptedVotes,
+		UniqueValues:   req.EnvelopeType.UniqueValues,
+		CostFromWeight: req.EnvelopeType.CostFromWeight,
+	}
+	options := &models.ProcessVoteOptions{
+		MaxCount:          req.VoteOptions.MaxCount,
+		MaxValue:          req.VoteOptions.MaxValue,
+		MaxVoteOverwrites: req.VoteOptions.MaxVoteOverwrites,
+		MaxTotalCost:      req.VoteOptions.MaxTotalCost,
+		CostExponent:      req.VoteOptions.CostExponent,
+	}
+	metadata, censusURI := req.Metadata, req.CensusURI
+	// the process id is bound to the entity and nonce, so a signed request cannot be replayed
+	pidseed := fmt.Sprintf("gasless%d%x%d", a.Namespace, req.EntityID, req.Nonce)
+	return &models.Process{
+		ProcessId:       ethereum.HashRaw([]byte(pidseed)),
+		EntityId:        req.EntityID,
+		StartBlock:      req.StartBlock,
+		BlockCount:      req.BlockCount,
+		CensusRoot:      req.CensusRoot,
+		CensusURI:       &censusURI,
+		CensusOrigin:    censusOrigin,
+		EnvelopeType:    envelope,
+		VoteOptions:     options,
+		Metadata:        &metadata,
+		Status:          models.ProcessStatus_READY,
+		Namespace:       a.Namespace,
+		Mode:            &models.ProcessMode{AutoStart: true},
+		SourceNetworkId: a.eh.SrcNetworkId,
+	}, nil
+}
+
+// controlsEntity returns nil if the signer is the entity or the address set for
+// the entity node on the entity resolver
+func (a *APIoracle) controlsEntity(ctx context.Context, signer, entity common.Address) error {
+	if signer == entity {
+		return nil
+	}
+	if a.eh.EntityResolver == nil {
+		return ErrEntityNotControlled
+	}
+	node := ethcrypto.Keccak256Hash(entity.Bytes())
+	addr, err := a.eh.EntityResolver.Addr(&ethbind.CallOpts{Context: ctx}, node)
+	if err != nil {
+		return fmt.Errorf("cannot resolve entity address: %w", err)
+	}
+	if addr != signer {
+		return ErrEntityNotControlled
+	}
+	return nil
+}
+
+// reserveGasless marks the gasless process pid as in flight. It returns false
+// if the process was already sent and it might not be committed yet, since the
+// committed state does not know about the transactions on the mempool.
+func (a *APIoracle) reserveGasless(pid []byte) bool {
+	a.gaslessPendingLock.Lock()
+	defer a.gaslessPendingLock.Unlock()
+	if a.gaslessPending == nil {
+		a.gaslessPending = make(map[string]time.Time)
+	}
+	for k, sent := range a.gaslessPending {
+		if time.Since(sent) > gaslessPendingTimeout {
+			delete(a.gaslessPending, k)
+		}
+	}
+	if _, ok := a.gaslessPending[string(pid)]; ok {
+		return false
+	}
+	a.gaslessPending[string(pid)] = time.Now()
+	return true
+}
+
+// releaseGasless removes the gasless process pid from the in flight set
+func (a *APIoracle) releaseGasless(pid []byte) {
+	a.gaslessPendingLock.Lock()
+	defer a.gaslessPendingLock.Unlock()
+	delete(a.gaslessPending, string(pid))
+}
+
+func (a *APIoracle) handleNewGaslessProcess(req router.RouterRequest) {
+	var response api.MetaResponse
+	if req.NewProcess == nil {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, "newProcess is empty")
+		return
+	}
+	p, err := a.gaslessProcess(req.NewProcess)
+	if err != nil {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	signer, err := TypedDataSigner(GaslessProcessTypedData(a.gaslessDomain, req.NewProcess), req.Signature)
+	if err != nil {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("cannot verify gasless process signature: (%v)", err))
+		return
+	}
+	entity := common.BytesToAddress(p.EntityId)
+	ctx, cancel := context.WithTimeout(context.Background(), ethQueryTimeOut)
+	defer cancel()
+	if err := a.controlsEntity(ctx, signer, entity); err != nil {
+		code := api.ErrCodeInternal
+		if errors.Is(err, ErrEntityNotControlled) {
+			code = api.ErrCodeUnauthorized
+		}
+		a.router.SendErrorCode(req, code, fmt.Sprintf("signer %s: %v", signer.Hex(), err))
+		return
+	}
+	if _, err := a.oracle.VochainApp.State.Process(p.ProcessId, true); err == nil {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest,
+			"process already exists, nonce already used")
+		return
+	}
+	if !a.reserveGasless(p.ProcessId) {
+		a.router.SendErrorCode(req, api.ErrCodeInvalidRequest,
+			"process creation already in progress, nonce already used")
+		return
+	}
+	if err := a.gaslessACL.add(entity.Bytes(), p.EntityId); err != nil {
+		a.releaseGasless(p.ProcessId)
+		a.router.SendErrorCode(req, api.ErrCodeUnauthorized, err.Error())
+		return
+	}
+	if err := a.oracle.NewProcess(p); err != nil {
+		a.releaseGasless(p.ProcessId)
+		a.router.SendError(req, err.Error())
+		return
+	}
+	log.Infof("gasless process %x created for entity %s, signed by %s",
+		p.ProcessId, entity.Hex(), signer.Hex())
+	response.ProcessID = p.ProcessId
+	if err := req.Send(a.router.BuildReply(req, &response)); err != nil {
+		log.Warn(err)
+	}
+}
@@ -0,0 +1,210 @@
+package apioracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core"
+	qt "github.com/frankban/quicktest"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/multirpc/transports"
+	"go.vocdoni.io/dvote/oracle"
+	"go.vocdoni.io/dvote/router"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+func TestTypedDataHash(t *testing.T) {
+	// Mail example of the EIP-712 specification
+	typedData := &core.TypedData{
+		Types: core.Types{
+			"EIP712Domain": gaslessTypes["EIP712Domain"],
+			"Person": {
+				{Name: "name", Type: "string"},
+				{Name: "wallet", Type: "address"},
+			},
+			"Mail": {
+				{Name: "from", Type: "Person"},
+				{Name: "to", Type: "Person"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: core.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: core.TypedDataMessage{
+			"from": map[string]interface{}{
+				"name":   "Cow",
+				"wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+			},
+			"to": map[string]interface{}{
+				"name":   "Bob",
+				"wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+			},
+			"contents": "Hello, Bob!",
+		},
+	}
+	hash, err := TypedDataHash(typedData)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, hash, qt.DeepEquals,
+		testutil.Hex2byte(t, "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"))
+}
+
+func TestGaslessProcessSignature(t *testing.T) {
+	entity := ethereum.NewSignKeys()
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	domain := GaslessDomain(big.NewInt(1), common.HexToAddress("0x1234"))
+	p := &api.NewProcess{
+		EntityID:     entity.Address().Bytes(),
+		StartBlock:   1,
+		BlockCount:   1000,
+		CensusRoot:   testutil.Hex2byte(t, "0123456789abcdef"),
+		CensusURI:    "ipfs://census",
+		CensusOrigin: "off_chain_tree",
+		Metadata:     "ipfs://metadata",
+		EnvelopeType: &models.EnvelopeType{EncryptedVotes: true},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 3},
+		Nonce:        1,
+	}
+	signature, err := SignTypedData(entity, GaslessProcessTypedData(domain, p))
+	qt.Assert(t, err, qt.IsNil)
+	signer, err := TypedDataSigner(GaslessProcessTypedData(domain, p), signature)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, signer, qt.Equals, entity.Address())
+
+	// Any change on the request or the domain recovers a different signer
+	p.Nonce = 2
+	signer, err = TypedDataSigner(GaslessProcessTypedData(domain, p), signature)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, signer, qt.Not(qt.Equals), entity.Address())
+	p.Nonce = 1
+	domain = GaslessDomain(big.NewInt(5), common.HexToAddress("0x1234"))
+	signer, err = TypedDataSigner(GaslessProcessTypedData(domain, p), signature)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, signer, qt.Not(qt.Equals), entity.Address())
+
+	// The process id only depends on the entity and the nonce
+	a := &APIoracle{eh: &ethereumhandler.EthereumHandler{}}
+	process1, err := a.gaslessProcess(p)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process1.GetCensusURI(), qt.Equals, p.CensusURI)
+	qt.Assert(t, process1.CensusOrigin, qt.Equals, models.CensusOrigin_OFF_CHAIN_TREE)
+	p.StartBlock = 10
+	process2, err := a.gaslessProcess(p)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process2.ProcessId, qt.DeepEquals, process1.ProcessId)
+	p.Nonce = 2
+	process2, err = a.gaslessProcess(p)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process2.ProcessId, qt.Not(qt.DeepEquals), process1.ProcessId)
+
+	// EVM census processes must be created with a storage proof
+	p.CensusOrigin = "erc20"
+	_, err = a.gaslessProcess(p)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
+
+// replyContext keeps the API responses sent to the client
+type replyContext struct {
+	replies []api.MetaResponse
+}
+
+func (c *replyContext) ConnectionType() string { return "test" }
+
+func (c *replyContext) Send(msg transports.Message) error {
+	var outer api.ResponseMessage
+	if err := json.Unmarshal(msg.Data, &outer); err != nil {
+		return err
+	}
+	var resp api.MetaResponse
+	if err := json.Unmarshal(outer.MetaResponse, &resp); err != nil {
+		return err
+	}
+	c.replies = append(c.replies, resp)
+	return nil
+}
+
+func TestGaslessProcessReplay(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	app.SetTestingMethods()
+	sent := 0
+	app.SetFnSendTx(func(tx []byte) (*ctypes.ResultBroadcastTx, error) {
+		sent++
+		return &ctypes.ResultBroadcastTx{}, nil
+	})
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	o, err := oracle.NewOracle(app, signer)
+	qt.Assert(t, err, qt.IsNil)
+	a, err := NewAPIoracle(o, router.NewRouter(nil, nil, signer, nil, false))
+	qt.Assert(t, err, qt.IsNil)
+	a.eh = &ethereumhandler.EthereumHandler{}
+	a.gaslessDomain = GaslessDomain(big.NewInt(1), common.HexToAddress("0x1234"))
+	a.gaslessACL, err = NewProposalACL(t.TempDir(), DefaultProposalPolicies())
+	qt.Assert(t, err, qt.IsNil)
+
+	entity := ethereum.NewSignKeys()
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	p := &api.NewProcess{
+		EntityID:     entity.Address().Bytes(),
+		StartBlock:   1,
+		BlockCount:   1000,
+		CensusRoot:   testutil.Hex2byte(t, "0123456789abcdef"),
+		CensusURI:    "ipfs://census",
+		CensusOrigin: "off_chain_tree",
+		Metadata:     "ipfs://metadata",
+		EnvelopeType: &models.EnvelopeType{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 3},
+		Nonce:        1,
+	}
+	signature, err := SignTypedData(entity, GaslessProcessTypedData(a.gaslessDomain, p))
+	qt.Assert(t, err, qt.IsNil)
+	submit := func() api.MetaResponse {
+		ctx := &replyContext{}
+		a.handleNewGaslessProcess(router.RouterRequest{
+			MetaRequest:    api.MetaRequest{NewProcess: p, Signature: signature},
+			MessageContext: ctx,
+		})
+		qt.Assert(t, ctx.replies, qt.HasLen, 1)
+		return ctx.replies[0]
+	}
+
+	// The process transaction is on the mempool, so the same request is
+	// rejected even if the process is not committed yet
+	resp := submit()
+	qt.Assert(t, resp.Ok, qt.IsTrue, qt.Commentf("%s", resp.Message))
+	qt.Assert(t, sent, qt.Equals, 1)
+	resp = submit()
+	qt.Assert(t, resp.Ok, qt.IsFalse)
+	qt.Assert(t, resp.ErrorCode, qt.Equals, api.ErrCodeInvalidRequest)
+	qt.Assert(t, resp.Message, qt.Matches, ".*already in progress.*")
+	qt.Assert(t, sent, qt.Equals, 1)
+
+	// A failed transaction does not keep the process in flight
+	app.SetFnSendTx(func(tx []byte) (*ctypes.ResultBroadcastTx, error) {
+		sent++
+		return nil, fmt.Errorf("mempool is full")
+	})
+	p.Nonce = 2
+	signature, err = SignTypedData(entity, GaslessProcessTypedData(a.gaslessDomain, p))
+	qt.Assert(t, err, qt.IsNil)
+	resp = submit()
+	qt.Assert(t, resp.Ok, qt.IsFalse)
+	qt.Assert(t, resp.Message, qt.Matches, ".*mempool is full.*")
+	pid, err := a.gaslessProcess(p)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, a.reserveGasless(pid.ProcessId), qt.IsTrue)
+}
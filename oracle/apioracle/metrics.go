@@ -0,0 +1,90 @@
+package apioracle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.vocdoni.io/dvote/metrics"
+)
+
+// API oracle collectors, labeled by token contract. Only the tokens with a specific
+// proposal policy are labeled by their address, the rest share the "other" label,
+// so the number of series does not grow with the tokens requested by the users.
+var (
+	// ProposalsAccepted is the number of processes created through the API oracle
+	ProposalsAccepted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "apioracle",
+		Name:      "proposals_accepted",
+		Help:      "Processes created through the API oracle",
+	}, []string{"token"})
+	// ProposalsRejected is the number of process creations rejected by the token policy
+	ProposalsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "apioracle",
+		Name:      "proposals_rejected",
+		Help:      "Process creations rejected by the token proposal policy",
+	}, []string{"token", "reason"})
+	// ACLSize is the number of holders being rate limited
+	ACLSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "apioracle",
+		Name:      "acl_size",
+		Help:      "Number of holder and token pairs on the proposal ACL",
+	})
+)
+
+// tokenLabel returns the metrics label for a token
+func tokenLabel(policies *ProposalPolicies, token []byte) string {
+	addr := common.BytesToAddress(token)
+	if _, ok := policies.tokens[addr]; !ok {
+		return "other"
+	}
+	return addr.Hex()
+}
+
+// rejectReason returns the metrics label for a policy rejection error
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMaxProposalsReached):
+		return "max_proposals"
+	case errors.Is(err, ErrBalanceTooLow):
+		return "balance"
+	case errors.Is(err, ErrBlockCountTooHigh):
+		return "block_count"
+	case errors.Is(err, ErrEncryptedVotesNotAllowed):
+		return "encrypted_votes"
+	case errors.Is(err, ErrCensusOriginNotAllowed):
+		return "census_origin"
+	case errors.Is(err, ErrProposalsDisabledForToken):
+		return "disabled"
+	default:
+		return "other"
+	}
+}
+
+// registerMetrics registers each of the API oracle prometheus metrics
+func (a *APIoracle) registerMetrics(ma *metrics.Agent) {
+	ma.Register(ProposalsAccepted)
+	ma.Register(ProposalsRejected)
+	ma.Register(ACLSize)
+}
+
+// getMetrics updates the metrics values to the current state
+func (a *APIoracle) getMetrics() {
+	if a.erc20proposalACL != nil {
+		ACLSize.Set(float64(a.erc20proposalACL.count()))
+	}
+}
+
+// CollectMetrics constantly updates the metric values for prometheus
+// The function is blocking, should be called in a go routine
+// If the metrics Agent is nil, do nothing
+func (a *APIoracle) CollectMetrics(ma *metrics.Agent) {
+	if ma != nil {
+		a.registerMetrics(ma)
+		for {
+			time.Sleep(ma.RefreshInterval)
+			a.getMetrics()
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package apioracle
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// Proposal policy rejection reasons
+var (
+	ErrBalanceTooLow             = errors.New("holder balance is lower than required")
+	ErrBlockCountTooHigh         = errors.New("process block count is higher than allowed")
+	ErrEncryptedVotesNotAllowed  = errors.New("encrypted votes are not allowed")
+	ErrCensusOriginNotAllowed    = errors.New("census origin is not allowed")
+	ErrProposalsDisabledForToken = errors.New("process creation is disabled for this token")
+)
+
+// ProposalPolicy defines the conditions for a token holder to create a process
+type ProposalPolicy struct {
+	// Token is the token contract address, empty for the default policy
+	Token string
+	// Disabled rejects any process creation for the token
+	Disabled bool
+	// MinBalance is the minimum holder balance, as a decimal integer in the token units
+	MinBalance string
+	// ProposalsPerPeriod is the maximum number of processes a holder can create per period,
+	// 0 means no limit (the default policy falls back to maxProposalPerWindow)
+	ProposalsPerPeriod uint
+	// Period is the time window for ProposalsPerPeriod
+	Period time.Duration
+	// MaxBlockCount is the maximum duration of a process in Vochain blocks, 0 means no limit
+	MaxBlockCount uint32
+	// DisallowEncryptedVotes rejects processes with encrypted votes
+	DisallowEncryptedVotes bool
+	// CensusOrigins are the allowed census origins (erc20, erc721, erc1155), empty allows all
+	CensusOrigins []string
+
+	minBalance    *big.Int
+	censusOrigins map[models.CensusOrigin]bool
+	address       common.Address
+}
+
+// ProposalPoliciesFile is the format of a proposal policies file (YAML or JSON)
+type ProposalPoliciesFile struct {
+	// Default is the policy for the tokens without a specific policy
+	Default ProposalPolicy
+	// Tokens are the per token policies
+	Tokens []ProposalPolicy
+}
+
+// ProposalPolicies holds the default and per token proposal policies
+type ProposalPolicies struct {
+	def    *ProposalPolicy
+	tokens map[common.Address]*ProposalPolicy
+}
+
+// DefaultProposalPolicies returns the policies used if no policies file is provided:
+// maxProposalPerWindow processes per holder and token every aclTimeWindow.
+func DefaultProposalPolicies() *ProposalPolicies {
+	return &ProposalPolicies{
+		def: &ProposalPolicy{
+			ProposalsPerPeriod: maxProposalPerWindow,
+			Period:             aclTimeWindow,
+		},
+		tokens: make(map[common.Address]*ProposalPolicy),
+	}
+}
+
+// LoadPolicies reads the proposal policies from a YAML or JSON file.
+// The default policy fields not set fall back to DefaultProposalPolicies.
+func LoadPolicies(path string) (*ProposalPolicies, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("cannot read proposal policies file %s: %w", path, err)
+	}
+	file := &ProposalPoliciesFile{}
+	if err := v.Unmarshal(file); err != nil {
+		return nil, fmt.Errorf("cannot decode proposal policies file %s: %w", path, err)
+	}
+	policies := DefaultProposalPolicies()
+	if file.Default.Token != "" {
+		return nil, fmt.Errorf("default policy cannot have a token")
+	}
+	if file.Default.ProposalsPerPeriod == 0 {
+		file.Default.ProposalsPerPeriod = policies.def.ProposalsPerPeriod
+	}
+	if file.Default.Period == 0 {
+		file.Default.Period = policies.def.Period
+	}
+	if err := file.Default.init(); err != nil {
+		return nil, fmt.Errorf("invalid default policy: %w", err)
+	}
+	policies.def = &file.Default
+	for i := range file.Tokens {
+		p := &file.Tokens[i]
+		if !common.IsHexAddress(p.Token) {
+			return nil, fmt.Errorf("invalid policy token address %q", p.Token)
+		}
+		if err := p.init(); err != nil {
+			return nil, fmt.Errorf("invalid policy for token %s: %w", p.Token, err)
+		}
+		if _, ok := policies.tokens[p.address]; ok {
+			return nil, fmt.Errorf("token %s policy defined twice", p.Token)
+		}
+		policies.tokens[p.address] = p
+	}
+	return policies, nil
+}
+
+// init validates the policy and parses its fields
+func (p *ProposalPolicy) init() error {
+	if p.Token != "" {
+		p.address = common.HexToAddress(p.Token)
+	}
+	if p.ProposalsPerPeriod > 0 && p.Period <= 0 {
+		return fmt.Errorf("a period is required to limit the proposals")
+	}
+	if p.MinBalance != "" {
+		var ok bool
+		if p.minBalance, ok = new(big.Int).SetString(p.MinBalance, 10); !ok || p.minBalance.Sign() < 0 {
+			return fmt.Errorf("invalid min balance %q", p.MinBalance)
+		}
+	}
+	if len(p.CensusOrigins) > 0 {
+		p.censusOrigins = make(map[models.CensusOrigin]bool)
+		for _, name := range p.CensusOrigins {
+			origin, ok := evmCensusOrigins[strings.ToLower(name)]
+			if !ok || name == "" {
+				return fmt.Errorf("unknown census origin %q", name)
+			}
+			p.censusOrigins[origin] = true
+		}
+	}
+	return nil
+}
+
+// For returns the policy for the token, or the default one
+func (ps *ProposalPolicies) For(token []byte) *ProposalPolicy {
+	if p, ok := ps.tokens[common.BytesToAddress(token)]; ok {
+		return p
+	}
+	return ps.def
+}
+
+// checkProcess checks the process parameters are allowed by the policy
+func (p *ProposalPolicy) checkProcess(process *models.Process) error {
+	if p.Disabled {
+		return ErrProposalsDisabledForToken
+	}
+	if p.censusOrigins != nil && !p.censusOrigins[process.CensusOrigin] {
+		return fmt.Errorf("%w: %s", ErrCensusOriginNotAllowed, process.CensusOrigin)
+	}
+	if p.MaxBlockCount > 0 && process.BlockCount > p.MaxBlockCount {
+		return fmt.Errorf("%w: %d > %d", ErrBlockCountTooHigh, process.BlockCount, p.MaxBlockCount)
+	}
+	if p.DisallowEncryptedVotes && process.GetEnvelopeType().GetEncryptedVotes() {
+		return ErrEncryptedVotesNotAllowed
+	}
+	return nil
+}
+
+// checkBalance checks the holder balance is enough to create a process
+func (p *ProposalPolicy) checkBalance(balance *big.Int) error {
+	if p.minBalance == nil {
+		return nil
+	}
+	if balance == nil || balance.Cmp(p.minBalance) < 0 {
+		return fmt.Errorf("%w: %s < %s", ErrBalanceTooLow, balance, p.minBalance)
+	}
+	return nil
+}
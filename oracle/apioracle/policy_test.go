@@ -0,0 +1,104 @@
+package apioracle
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+const testPolicies = `
+default:
+  minBalance: "10"
+tokens:
+  - token: "0x6b175474e89094c44da98b954eedeac495271d0f"
+    proposalsPerPeriod: 1
+    period: 1h
+    maxBlockCount: 1000
+    disallowEncryptedVotes: true
+    censusOrigins: [erc20]
+  - token: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+    disabled: true
+`
+
+func TestProposalPolicies(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policies.yml")
+	qt.Assert(t, os.WriteFile(file, []byte(testPolicies), 0o600), qt.IsNil)
+	policies, err := LoadPolicies(file)
+	qt.Assert(t, err, qt.IsNil)
+
+	// The default policy keeps the default limits
+	token := util.RandomBytes(20)
+	def := policies.For(token)
+	qt.Assert(t, def.ProposalsPerPeriod, qt.Equals, uint(maxProposalPerWindow))
+	qt.Assert(t, def.Period, qt.Equals, aclTimeWindow)
+	qt.Assert(t, errors.Is(def.checkBalance(big.NewInt(9)), ErrBalanceTooLow), qt.IsTrue)
+	qt.Assert(t, def.checkBalance(big.NewInt(10)), qt.IsNil)
+
+	dai := testutil.Hex2byte(t, "6b175474e89094c44da98b954eedeac495271d0f")
+	policy := policies.For(dai)
+	qt.Assert(t, policy.Period, qt.Equals, time.Hour)
+	qt.Assert(t, policy.checkBalance(big.NewInt(1)), qt.IsNil)
+	process := &models.Process{
+		BlockCount:   2000,
+		CensusOrigin: models.CensusOrigin_ERC20,
+		EnvelopeType: &models.EnvelopeType{},
+	}
+	qt.Assert(t, errors.Is(policy.checkProcess(process), ErrBlockCountTooHigh), qt.IsTrue)
+	process.BlockCount = 1000
+	qt.Assert(t, policy.checkProcess(process), qt.IsNil)
+	process.EnvelopeType.EncryptedVotes = true
+	qt.Assert(t, errors.Is(policy.checkProcess(process), ErrEncryptedVotesNotAllowed), qt.IsTrue)
+	process.EnvelopeType.EncryptedVotes = false
+	process.CensusOrigin = models.CensusOrigin_ERC721
+	qt.Assert(t, errors.Is(policy.checkProcess(process), ErrCensusOriginNotAllowed), qt.IsTrue)
+
+	weth := testutil.Hex2byte(t, "c02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	qt.Assert(t, errors.Is(policies.For(weth).checkProcess(process), ErrProposalsDisabledForToken), qt.IsTrue)
+
+	// The proposal counters are limited per token and kept across restarts
+	acl, err := NewProposalACL(filepath.Join(dir, "acl"), policies)
+	qt.Assert(t, err, qt.IsNil)
+	holder := util.RandomBytes(20)
+	qt.Assert(t, acl.add(holder, dai), qt.IsNil)
+	qt.Assert(t, errors.Is(acl.add(holder, dai), ErrMaxProposalsReached), qt.IsTrue)
+	for i := 0; i < maxProposalPerWindow; i++ {
+		qt.Assert(t, acl.add(holder, token), qt.IsNil)
+	}
+	qt.Assert(t, errors.Is(acl.add(holder, token), ErrMaxProposalsReached), qt.IsTrue)
+	qt.Assert(t, acl.store.Close(), qt.IsNil)
+
+	acl, err = NewProposalACL(filepath.Join(dir, "acl"), policies)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, acl.count(), qt.Equals, 2)
+	qt.Assert(t, errors.Is(acl.add(holder, dai), ErrMaxProposalsReached), qt.IsTrue)
+	qt.Assert(t, acl.add(util.RandomBytes(20), dai), qt.IsNil)
+	qt.Assert(t, acl.store.Close(), qt.IsNil)
+
+	// Invalid policies are rejected
+	qt.Assert(t, os.WriteFile(file, []byte("tokens:\n  - token: \"0x01\"\n"), 0o600), qt.IsNil)
+	_, err = LoadPolicies(file)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
+
+func TestTokenLabel(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policies.yml")
+	qt.Assert(t, os.WriteFile(file, []byte(testPolicies), 0o600), qt.IsNil)
+	policies, err := LoadPolicies(file)
+	qt.Assert(t, err, qt.IsNil)
+
+	// Only the tokens with a specific policy get their own metrics label
+	dai := testutil.Hex2byte(t, "6b175474e89094c44da98b954eedeac495271d0f")
+	qt.Assert(t, tokenLabel(policies, dai), qt.Equals, "0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	qt.Assert(t, tokenLabel(policies, util.RandomBytes(20)), qt.Equals, "other")
+	qt.Assert(t, tokenLabel(DefaultProposalPolicies(), dai), qt.Equals, "other")
+}
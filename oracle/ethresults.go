@@ -0,0 +1,271 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	ethbind "github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+)
+
+const (
+	// resultsPublishRetries is the number of attempts to publish the results of a process
+	resultsPublishRetries = 10
+	// resultsPublishRetryWait is the time to wait between attempts, multiplied by the attempt number
+	resultsPublishRetryWait = time.Second * 30
+	// resultsMinedTimeout is the maximum time to wait for a results transaction to be mined
+	resultsMinedTimeout = time.Minute * 10
+	// resultsQueueSize is the number of results that can be pending to be published
+	resultsQueueSize = 128
+	// resultsNotFound is the revert reason of the results contract for a process without results
+	resultsNotFound = "Not found"
+)
+
+// ResultsDialer connects to a web3 endpoint and returns an ethereum handler with
+// the results contract initialized. It is called again after every failure, so
+// it can rotate through the available endpoints.
+type ResultsDialer func() (*ethereumhandler.EthereumHandler, error)
+
+// ResultsPublisher submits the final results computed on the Vochain to the
+// Ethereum results contract, so on-chain actions can be executed from them.
+// Transactions are sent one at a time, managing the account nonce locally.
+// The web3 connection is only used from the publishing goroutine.
+type ResultsPublisher struct {
+	dial        ResultsDialer
+	handler     *ethereumhandler.EthereumHandler
+	signer      *ethereum.SignKeys
+	chainID     *big.Int
+	vochainID   uint32
+	maxGasPrice *big.Int
+	nonce       uint64
+	nonceValid  bool
+	nonceLock   sync.Mutex
+	queue       chan *resultsJob
+}
+
+// resultsJob are the results of a process pending to be published, along with
+// the Vochain app hash at the moment they were sent to the Vochain
+type resultsJob struct {
+	results *indexertypes.Results
+	appHash []byte
+}
+
+// NewResultsPublisher creates a results publisher that connects to the results
+// contract using dial. The connection is established when the first results are
+// published, and established again after a failure. The transactions are signed
+// with signer. If maxGasPrice (in wei) is not nil, no transaction is sent while
+// the suggested gas price is higher.
+func NewResultsPublisher(ctx context.Context, dial ResultsDialer,
+	signer *ethereum.SignKeys, vochainID uint32, maxGasPrice *big.Int) *ResultsPublisher {
+	rp := &ResultsPublisher{
+		dial:        dial,
+		signer:      signer,
+		vochainID:   vochainID,
+		maxGasPrice: maxGasPrice,
+		queue:       make(chan *resultsJob, resultsQueueSize),
+	}
+	go rp.run(ctx)
+	return rp
+}
+
+// Publish queues the results to be published on the Ethereum results contract.
+// The results are published along with the Vochain height they were computed at.
+func (rp *ResultsPublisher) Publish(results *indexertypes.Results, appHash []byte) {
+	select {
+	case rp.queue <- &resultsJob{results: results, appHash: appHash}:
+	default:
+		log.Errorf("results publish queue is full, discarding results of process %x", results.ProcessID)
+	}
+}
+
+func (rp *ResultsPublisher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-rp.queue:
+			results := job.results
+			for attempt := 1; attempt <= resultsPublishRetries; attempt++ {
+				err := rp.attempt(ctx, job)
+				if err == nil {
+					break
+				}
+				log.Warnf("cannot publish results of process %x on ethereum (attempt %d/%d): %v",
+					results.ProcessID, attempt, resultsPublishRetries, err)
+				if attempt == resultsPublishRetries {
+					log.Errorf("giving up publishing results of process %x on ethereum", results.ProcessID)
+					break
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(resultsPublishRetryWait * time.Duration(attempt)):
+				}
+			}
+		}
+	}
+}
+
+// attempt publishes the results, connecting first if needed. On failure the
+// connection is dropped, so the next attempt dials again.
+func (rp *ResultsPublisher) attempt(ctx context.Context, job *resultsJob) error {
+	if err := rp.connect(ctx); err != nil {
+		return err
+	}
+	if err := rp.publish(ctx, job); err != nil {
+		rp.disconnect()
+		return err
+	}
+	return nil
+}
+
+// connect dials the web3 endpoint if there is no connection
+func (rp *ResultsPublisher) connect(ctx context.Context) error {
+	if rp.handler != nil {
+		return nil
+	}
+	eh, err := rp.dial()
+	if err != nil {
+		if eh != nil && eh.EthereumClient != nil {
+			eh.EthereumClient.Close()
+		}
+		return fmt.Errorf("cannot connect to web3: %w", err)
+	}
+	if eh.Results == nil {
+		eh.EthereumClient.Close()
+		return fmt.Errorf("results contract not available")
+	}
+	tctx, cancel := context.WithTimeout(ctx, types.EthereumReadTimeout)
+	defer cancel()
+	chainID, err := eh.EthereumClient.ChainID(tctx)
+	if err != nil {
+		eh.EthereumClient.Close()
+		return fmt.Errorf("cannot get ethereum chain id: %w", err)
+	}
+	rp.handler = eh
+	rp.chainID = chainID
+	return nil
+}
+
+// disconnect closes the web3 connection. The account nonce is fetched again
+// once connected, since the failed transaction could have been sent or not.
+func (rp *ResultsPublisher) disconnect() {
+	if rp.handler == nil {
+		return
+	}
+	rp.handler.EthereumClient.Close()
+	rp.handler = nil
+	rp.nonceLock.Lock()
+	rp.nonceValid = false
+	rp.nonceLock.Unlock()
+}
+
+// publish sends the results transaction and waits for it to be mined.
+// If the results are already on the contract, nothing is done.
+func (rp *ResultsPublisher) publish(ctx context.Context, job *resultsJob) error {
+	results := job.results
+	var pid [types.ProcessIDsize]byte
+	if len(results.ProcessID) != types.ProcessIDsize {
+		return fmt.Errorf("invalid process id size %d", len(results.ProcessID))
+	}
+	copy(pid[:], results.ProcessID)
+	tally, err := buildTally(results.Votes)
+	if err != nil {
+		return err
+	}
+
+	// Idempotency check, the results could have been published by another oracle
+	rctx, rcancel := context.WithTimeout(ctx, types.EthereumReadTimeout)
+	defer rcancel()
+	current, err := rp.handler.Results.GetResults(&ethbind.CallOpts{Context: rctx}, pid)
+	// the contract reverts the call if the process has no results yet
+	if err != nil && !strings.Contains(err.Error(), resultsNotFound) {
+		return fmt.Errorf("cannot get current results: %w", err)
+	}
+	if err == nil && (current.Height > 0 || len(current.Tally) > 0) {
+		log.Infof("results of process %x already published on ethereum at height %d, skipping",
+			results.ProcessID, current.Height)
+		return nil
+	}
+
+	gctx, gcancel := context.WithTimeout(ctx, types.EthereumReadTimeout)
+	defer gcancel()
+	gasPrice, err := rp.handler.EthereumClient.SuggestGasPrice(gctx)
+	if err != nil {
+		return fmt.Errorf("cannot get gas price: %w", err)
+	}
+	if rp.maxGasPrice != nil && gasPrice.Cmp(rp.maxGasPrice) > 0 {
+		return fmt.Errorf("gas price %s is higher than the maximum %s", gasPrice, rp.maxGasPrice)
+	}
+
+	rp.nonceLock.Lock()
+	defer rp.nonceLock.Unlock()
+	if !rp.nonceValid {
+		nctx, ncancel := context.WithTimeout(ctx, types.EthereumReadTimeout)
+		rp.nonce, err = rp.handler.EthereumClient.PendingNonceAt(nctx, rp.signer.Address())
+		ncancel()
+		if err != nil {
+			return fmt.Errorf("cannot get account nonce: %w", err)
+		}
+		rp.nonceValid = true
+	}
+	opts, err := ethbind.NewKeyedTransactorWithChainID(&rp.signer.Private, rp.chainID)
+	if err != nil {
+		return err
+	}
+	sctx, scancel := context.WithTimeout(ctx, types.EthereumWriteTimeout)
+	defer scancel()
+	opts.Context = sctx
+	opts.Nonce = new(big.Int).SetUint64(rp.nonce)
+	opts.GasPrice = gasPrice
+
+	log.Infof("publishing results of process %x on ethereum, vochain height %d app hash %x",
+		results.ProcessID, results.BlockHeight, job.appHash)
+	tx, err := rp.handler.Results.SetResults(opts, pid, tally, results.BlockHeight, rp.vochainID)
+	if err != nil {
+		// The nonce could be out of sync, fetch it again on the next attempt
+		rp.nonceValid = false
+		return fmt.Errorf("cannot send results transaction: %w", err)
+	}
+	rp.nonce++
+	log.Infof("results transaction for process %x sent, hash %s", results.ProcessID, tx.Hash().Hex())
+
+	mctx, mcancel := context.WithTimeout(ctx, resultsMinedTimeout)
+	defer mcancel()
+	receipt, err := ethbind.WaitMined(mctx, rp.handler.EthereumClient, tx)
+	if err != nil {
+		rp.nonceValid = false
+		return fmt.Errorf("results transaction %s not mined: %w", tx.Hash().Hex(), err)
+	}
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+		return fmt.Errorf("results transaction %s reverted", tx.Hash().Hex())
+	}
+	log.Infof("results of process %x published on ethereum block %d",
+		results.ProcessID, receipt.BlockNumber.Uint64())
+	return nil
+}
+
+// buildTally converts the results votes to the format expected by the results contract
+func buildTally(votes [][]*big.Int) ([][]uint32, error) {
+	tally := make([][]uint32, len(votes))
+	for q := range votes {
+		tally[q] = make([]uint32, len(votes[q]))
+		for v, value := range votes[q] {
+			if !value.IsUint64() || value.Uint64() > math.MaxUint32 {
+				return nil, fmt.Errorf("value %s of question %d overflows the results contract", value, q)
+			}
+			tally[q][v] = uint32(value.Uint64())
+		}
+	}
+	return tally, nil
+}
@@ -0,0 +1,145 @@
+package oracle
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	ethbind "github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/test/testcommon/ethsim"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// newResultsChain creates a simulated chain with a process and a vochain whose
+// only oracle is the returned signer
+func newResultsChain(t *testing.T) (
+	*ethsim.SimulatedEthereum, *ethereum.SignKeys, uint32, [32]byte) {
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	entity := ethereum.NewSignKeys()
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	sim := ethsim.New(t, signer.Address(), entity.Address())
+
+	tx, err := sim.Genesis.NewChain(sim.Transactor(t, sim.Deployer), "genesis",
+		[][]byte{}, []common.Address{signer.Address()})
+	sim.Mine(t, tx, err)
+	count, err := sim.Genesis.GetChainCount(&ethbind.CallOpts{})
+	qt.Assert(t, err, qt.IsNil)
+	pid := sim.NewProcessStd(t, entity,
+		"0000000000000000000000000000000000000000000000000000000000000001", "ipfs://census", 1000)
+	sim.AutoMine(t, time.Millisecond*50)
+	return sim, signer, count - 1, pid
+}
+
+func testResults(pid [32]byte) *indexertypes.Results {
+	return &indexertypes.Results{
+		ProcessID:   pid[:],
+		Votes:       [][]*big.Int{{big.NewInt(3), big.NewInt(5)}},
+		BlockHeight: 10,
+	}
+}
+
+func TestResultsPublisher(t *testing.T) {
+	sim, signer, vochainID, pid := newResultsChain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dial := func() (*ethereumhandler.EthereumHandler, error) {
+		return sim.Handler(t, nil), nil
+	}
+	rp := NewResultsPublisher(ctx, dial, signer, vochainID, nil)
+	rp.Publish(testResults(pid), []byte{1})
+
+	c := qt.New(t)
+	var current struct {
+		Tally  [][]uint32
+		Height uint32
+	}
+	for i := 0; ; i++ {
+		var err error
+		current, err = sim.Results.GetResults(&ethbind.CallOpts{}, pid)
+		if err == nil {
+			break
+		}
+		c.Assert(err, qt.ErrorMatches, ".*"+resultsNotFound)
+		if i == 100 {
+			t.Fatal("results not published")
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	c.Assert(current.Height, qt.Equals, uint32(10))
+	c.Assert(current.Tally, qt.DeepEquals, [][]uint32{{3, 5}})
+
+	// The results already on the contract are not sent again
+	rp2 := &ResultsPublisher{dial: dial, signer: signer, vochainID: vochainID}
+	c.Assert(rp2.attempt(ctx, &resultsJob{results: testResults(pid)}), qt.IsNil)
+	c.Assert(rp2.nonceValid, qt.IsFalse)
+}
+
+func TestResultsPublisherReconnect(t *testing.T) {
+	sim, signer, vochainID, pid := newResultsChain(t)
+	ctx := context.Background()
+	dials := 0
+	rp := &ResultsPublisher{
+		dial: func() (*ethereumhandler.EthereumHandler, error) {
+			dials++
+			return sim.Handler(t, nil), nil
+		},
+		signer:    signer,
+		vochainID: vochainID,
+	}
+	c := qt.New(t)
+	c.Assert(rp.connect(ctx), qt.IsNil)
+	c.Assert(dials, qt.Equals, 1)
+
+	// The web3 connection is lost, the failed attempt drops it
+	rp.handler.EthereumClient.Close()
+	job := &resultsJob{results: testResults(pid)}
+	c.Assert(rp.attempt(ctx, job), qt.IsNotNil)
+	c.Assert(rp.handler, qt.IsNil)
+
+	// The next attempt dials again and publishes the results
+	c.Assert(rp.attempt(ctx, job), qt.IsNil)
+	c.Assert(dials, qt.Equals, 2)
+	current, err := sim.Results.GetResults(&ethbind.CallOpts{}, pid)
+	c.Assert(err, qt.IsNil)
+	c.Assert(current.Height, qt.Equals, uint32(10))
+}
+
+func TestEthereumResultsNetwork(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	o, err := NewOracle(app, nil)
+	qt.Assert(t, err, qt.IsNil)
+	goerli := &ResultsPublisher{queue: make(chan *resultsJob, 1)}
+	xdai := &ResultsPublisher{queue: make(chan *resultsJob, 1)}
+	qt.Assert(t, o.EnableEthereumResults(models.SourceNetworkId_ETH_GOERLI, goerli), qt.IsNil)
+	qt.Assert(t, o.EnableEthereumResults(models.SourceNetworkId_POA_XDAI, xdai), qt.IsNil)
+	qt.Assert(t, o.EnableEthereumResults(models.SourceNetworkId_POA_XDAI, goerli),
+		qt.ErrorMatches, "ethereum results already enabled.*")
+
+	// The results are published on the network the process was created on
+	results := testResults([32]byte{1})
+	o.publishEthereumResults(results, models.SourceNetworkId_POA_XDAI)
+	qt.Assert(t, goerli.queue, qt.HasLen, 0)
+	qt.Assert(t, xdai.queue, qt.HasLen, 1)
+	qt.Assert(t, (<-xdai.queue).results, qt.Equals, results)
+	o.publishEthereumResults(results, models.SourceNetworkId_ETH_MAINNET)
+	qt.Assert(t, goerli.queue, qt.HasLen, 0)
+	qt.Assert(t, xdai.queue, qt.HasLen, 0)
+}
+
+func TestBuildTally(t *testing.T) {
+	tally, err := buildTally([][]*big.Int{{big.NewInt(1), big.NewInt(0)}, {big.NewInt(7)}})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tally, qt.DeepEquals, [][]uint32{{1, 0}, {7}})
+
+	_, err = buildTally([][]*big.Int{{new(big.Int).Lsh(big.NewInt(1), 32)}})
+	qt.Assert(t, err, qt.ErrorMatches, ".*overflows the results contract")
+}
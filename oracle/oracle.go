@@ -2,8 +2,10 @@ package oracle
 
 import (
 	"fmt"
+	"sync"
 
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
@@ -17,10 +19,18 @@ import (
 type Oracle struct {
 	VochainApp *vochain.BaseApplication
 	signer     *ethereum.SignKeys
+	// ethResults holds the results publisher of each source network. They can be
+	// enabled once the oracle is already receiving results.
+	ethResults     map[models.SourceNetworkId]*ResultsPublisher
+	ethResultsLock sync.RWMutex
 }
 
 func NewOracle(app *vochain.BaseApplication, signer *ethereum.SignKeys) (*Oracle, error) {
-	return &Oracle{VochainApp: app, signer: signer}, nil
+	return &Oracle{
+		VochainApp: app,
+		signer:     signer,
+		ethResults: make(map[models.SourceNetworkId]*ResultsPublisher),
+	}, nil
 }
 
 func (o *Oracle) EnableResults(scr *scrutinizer.Scrutinizer) {
@@ -28,7 +38,28 @@ func (o *Oracle) EnableResults(scr *scrutinizer.Scrutinizer) {
 	scr.AddEventListener(o)
 }
 
+// EnableEthereumResults makes the oracle publish the final results of the processes
+// created on the network also on its Ethereum results contract, using the given
+// results publisher. Only one publisher can be enabled for each network.
+func (o *Oracle) EnableEthereumResults(network models.SourceNetworkId, rp *ResultsPublisher) error {
+	o.ethResultsLock.Lock()
+	defer o.ethResultsLock.Unlock()
+	if _, ok := o.ethResults[network]; ok {
+		return fmt.Errorf("ethereum results already enabled for network %s", network)
+	}
+	log.Infof("oracle ethereum results publishing enabled for network %s", network)
+	o.ethResults[network] = rp
+	return nil
+}
+
 func (o *Oracle) NewProcess(process *models.Process) error {
+	return o.NewProcessWithEvidence(process, nil)
+}
+
+// NewProcessWithEvidence creates a new process like NewProcess, attaching to the
+// transaction the storage root evidence of its EVM census (if not nil)
+func (o *Oracle) NewProcessWithEvidence(process *models.Process,
+	evidence *headerstore.StorageEvidence) error {
 	// Sanity checks
 	if process == nil {
 		return fmt.Errorf("process is nil")
@@ -72,6 +103,11 @@ func (o *Oracle) NewProcess(process *models.Process) error {
 		Nonce:   util.RandomBytes(32),
 		Txtype:  models.TxType_NEW_PROCESS,
 	}
+	if evidence != nil {
+		if err := vochain.AttachStorageEvidence(processTx, evidence); err != nil {
+			return err
+		}
+	}
 	var err error
 	stx := &models.SignedTx{}
 	stx.Tx, err = proto.Marshal(&models.Tx{
@@ -103,6 +139,7 @@ func (o *Oracle) NewProcess(process *models.Process) error {
 // OnComputeResults is called once a process result is computed by the scrutinizer.
 // The Oracle will build and send a RESULTS transaction to the Vochain.
 // The transaction includes the final results for the process.
+// If enabled, the results are also published on the Ethereum results contract.
 func (o *Oracle) OnComputeResults(results *indexertypes.Results) {
 	log.Infof("launching on computeResults callback for process %x", results.ProcessID)
 	// check vochain process status
@@ -117,6 +154,7 @@ func (o *Oracle) OnComputeResults(results *indexertypes.Results) {
 		if len(vocProcessData.Results.Votes) > 0 {
 			log.Infof("process %x results already added to the Vochain, skipping",
 				results.ProcessID)
+			o.publishEthereumResults(results, vocProcessData.SourceNetworkId)
 			return
 		}
 	case models.ProcessStatus_READY:
@@ -169,4 +207,18 @@ func (o *Oracle) OnComputeResults(results *indexertypes.Results) {
 		return
 	}
 	log.Infof("oracle transaction sent, hash:%x", res.Hash)
+	o.publishEthereumResults(results, vocProcessData.SourceNetworkId)
+}
+
+// publishEthereumResults queues the results to be published on the Ethereum network
+// the process was created on, if enabled for it
+func (o *Oracle) publishEthereumResults(results *indexertypes.Results,
+	network models.SourceNetworkId) {
+	o.ethResultsLock.RLock()
+	rp := o.ethResults[network]
+	o.ethResultsLock.RUnlock()
+	if rp == nil {
+		return
+	}
+	rp.Publish(results, o.VochainApp.State.AppHash(true))
 }
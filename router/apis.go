@@ -1,24 +1,115 @@
 package router
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/census"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 )
 
+// APIVersion is the version of the router methods and of their request and
+// response fields, returned by getInfo and getMethods. The major version is
+// increased on incompatible changes.
+const APIVersion = "1.0.0"
+
+// Method declares a router method, the MetaRequest fields it uses and the
+// MetaResponse fields it can return, by their JSON names. The method, signature
+// and timestamp request fields, and the ok, request, timestamp, message and
+// errorCode response fields are common to all the methods.
+type Method struct {
+	Name     string
+	Public   bool
+	Handler  func(RouterRequest)
+	Required []string
+	Optional []string
+	Response []string
+}
+
+// Register registers the methods of an API. The fields of the methods must
+// exist on the MetaRequest and MetaResponse types.
+func (r *Router) Register(apiName string, methods ...Method) {
+	for _, m := range methods {
+		if err := r.checkMethod(m); err != nil {
+			log.Fatal(err)
+		}
+		r.methods[m.Name] = RegisteredMethod{
+			public:  m.Public,
+			handler: m.Handler,
+			info: api.MethodInfo{
+				Name:     m.Name,
+				API:      apiName,
+				Public:   m.Public,
+				Required: m.Required,
+				Optional: m.Optional,
+				Response: m.Response,
+			},
+		}
+	}
+}
+
+var (
+	requestFields  = jsonFields(api.MetaRequest{})
+	responseFields = jsonFields(api.MetaResponse{})
+)
+
+// jsonFields returns the JSON names of the fields of a struct
+func jsonFields(v interface{}) map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// checkMethod returns an error if the method is already registered or it
+// declares fields not existing on the MetaRequest and MetaResponse types
+func (r *Router) checkMethod(m Method) error {
+	if _, ok := r.methods[m.Name]; ok {
+		return fmt.Errorf("duplicate method: %q", m.Name)
+	}
+	if err := checkFields(m.Name, requestFields, m.Required, m.Optional); err != nil {
+		return err
+	}
+	return checkFields(m.Name, responseFields, m.Response)
+}
+
+func checkFields(method string, valid map[string]bool, lists ...[]string) error {
+	for _, list := range lists {
+		for _, f := range list {
+			if !valid[f] {
+				return fmt.Errorf("method %q declares unknown field %q", method, f)
+			}
+		}
+	}
+	return nil
+}
+
 // EnableFileAPI enables the FILE API in the Router
 func (r *Router) EnableFileAPI() {
 	r.APIs = append(r.APIs, "file")
-	r.RegisterPublic("fetchFile", r.fetchFile)
+	r.Register("file",
+		Method{Name: "fetchFile", Public: true, Handler: r.fetchFile,
+			Required: []string{"uri"}, Response: []string{"content"}},
+		Method{Name: "pinList", Handler: r.pinList, Response: []string{"files"}},
+		Method{Name: "pinFile", Handler: r.pinFile, Required: []string{"uri"}},
+		Method{Name: "unpinFile", Handler: r.unpinFile, Required: []string{"uri"}},
+	)
 	if r.allowPrivate {
-		r.RegisterPrivate("addFile", r.addFile)
+		r.Register("file", Method{Name: "addFile", Handler: r.addFile,
+			Required: []string{"content", "type"}, Response: []string{"uri"}})
 	} else {
-		r.RegisterPublic("addFile", r.addJSONfile)
+		r.Register("file", Method{Name: "addFile", Public: true, Handler: r.addJSONfile,
+			Required: []string{"content", "type"}, Response: []string{"uri"}})
 	}
-	r.RegisterPrivate("pinList", r.pinList)
-	r.RegisterPrivate("pinFile", r.pinFile)
-	r.RegisterPrivate("unpinFile", r.unpinFile)
 }
 
 // EnableCensusAPI enables the Census API in the Router
@@ -28,18 +119,41 @@ func (r *Router) EnableCensusAPI(cm *census.Manager) {
 	if cm.RemoteStorage == nil {
 		cm.RemoteStorage = r.storage
 	}
-	r.RegisterPublic("getRoot", r.censusLocal)
-	r.RegisterPrivate("dump", r.censusLocal)
-	r.RegisterPrivate("dumpPlain", r.censusLocal)
-	r.RegisterPublic("getSize", r.censusLocal)
-	r.RegisterPublic("genProof", r.censusLocal)
-	r.RegisterPublic("checkProof", r.censusLocal)
-	r.RegisterPrivate("addCensus", r.censusLocal)
-	r.RegisterPrivate("addClaim", r.censusLocal)
-	r.RegisterPrivate("addClaimBulk", r.censusLocal)
-	r.RegisterPrivate("publish", r.censusLocal)
-	r.RegisterPrivate("importRemote", r.censusLocal)
-	r.RegisterPrivate("getCensusList", r.censusLocal)
+	r.Register("census",
+		Method{Name: "getRoot", Public: true, Handler: r.censusLocal,
+			Required: []string{"censusId"}, Response: []string{"root"}},
+		Method{Name: "dump", Handler: r.censusLocal,
+			Required: []string{"censusId"}, Optional: []string{"rootHash"},
+			Response: []string{"censusDump"}},
+		Method{Name: "dumpPlain", Handler: r.censusLocal,
+			Required: []string{"censusId"}, Optional: []string{"rootHash"},
+			Response: []string{"censusKeys", "censusValues"}},
+		Method{Name: "getSize", Public: true, Handler: r.censusLocal,
+			Required: []string{"censusId"}, Optional: []string{"rootHash"},
+			Response: []string{"size"}},
+		Method{Name: "genProof", Public: true, Handler: r.censusLocal,
+			Required: []string{"censusId", "censusKey"},
+			Optional: []string{"censusValue", "rootHash"}, Response: []string{"siblings"}},
+		Method{Name: "checkProof", Public: true, Handler: r.censusLocal,
+			Required: []string{"censusId", "censusKey", "proofData"},
+			Optional: []string{"censusValue", "rootHash"}, Response: []string{"validProof"}},
+		Method{Name: "addCensus", Handler: r.censusLocal,
+			Required: []string{"censusId"}, Optional: []string{"censusType", "pubKeys"},
+			Response: []string{"censusId"}},
+		Method{Name: "addClaim", Handler: r.censusLocal,
+			Required: []string{"censusId", "censusKey"}, Optional: []string{"censusValue"},
+			Response: []string{"root"}},
+		Method{Name: "addClaimBulk", Handler: r.censusLocal,
+			Required: []string{"censusId", "censusKeys"}, Optional: []string{"censusValues"},
+			Response: []string{"root", "invalidClaims"}},
+		Method{Name: "publish", Handler: r.censusLocal,
+			Required: []string{"censusId"}, Optional: []string{"pubKeys"},
+			Response: []string{"uri", "root"}},
+		Method{Name: "importRemote", Handler: r.censusLocal,
+			Required: []string{"censusId", "uri"}},
+		Method{Name: "getCensusList", Handler: r.censusLocal,
+			Response: []string{"censusList"}},
+	)
 }
 
 // EnableVoteAPI enabled the Vote API in the Router
@@ -47,14 +161,29 @@ func (r *Router) EnableVoteAPI(vocapp *vochain.BaseApplication, vocInfo *vochain
 	r.APIs = append(r.APIs, "vote")
 	r.vocapp = vocapp
 	r.vocinfo = vocInfo
-	r.RegisterPublic("submitRawTx", r.submitRawTx)
-	r.RegisterPublic("submitEnvelope", r.submitEnvelope)
-	r.RegisterPublic("getEnvelopeStatus", r.getEnvelopeStatus)
-	r.RegisterPublic("getEnvelopeHeight", r.getEnvelopeHeight)
-	r.RegisterPublic("getBlockHeight", r.getBlockHeight)
-	r.RegisterPublic("getProcessKeys", r.getProcessKeys)
-	r.RegisterPublic("getBlockStatus", r.getBlockStatus)
-	r.RegisterPublic("getOracleResults", r.getOracleResults)
+	r.Register("vote",
+		Method{Name: "submitRawTx", Public: true, Handler: r.submitRawTx,
+			Required: []string{"payload"}, Optional: []string{"waitForInclusion"},
+			Response: []string{"payload", "height", "txIndex", "txHash"}},
+		Method{Name: "submitEnvelope", Public: true, Handler: r.submitEnvelope,
+			Required: []string{"payload"}, Optional: []string{"signature", "waitForInclusion"},
+			Response: []string{"nullifier", "height", "txIndex", "txHash"}},
+		Method{Name: "getEnvelopeStatus", Public: true, Handler: r.getEnvelopeStatus,
+			Required: []string{"processId", "nullifier"},
+			Response: []string{"registered", "height", "blockTimestamp", "processId"}},
+		Method{Name: "getEnvelopeHeight", Public: true, Handler: r.getEnvelopeHeight,
+			Required: []string{"processId"}, Response: []string{"height"}},
+		Method{Name: "getBlockHeight", Public: true, Handler: r.getBlockHeight,
+			Response: []string{"height", "blockTimestamp"}},
+		Method{Name: "getProcessKeys", Public: true, Handler: r.getProcessKeys,
+			Required: []string{"processId"},
+			Response: []string{"encryptionPubKeys", "encryptionPrivKeys", "commitmentKeys",
+				"revealKeys"}},
+		Method{Name: "getBlockStatus", Public: true, Handler: r.getBlockStatus,
+			Response: []string{"height", "blockTime", "blockTimestamp"}},
+		Method{Name: "getOracleResults", Public: true, Handler: r.getOracleResults,
+			Required: []string{"processId"}, Response: []string{"results"}},
+	)
 }
 
 // EnableVoteAPI enabled the Vote API in the Router
@@ -64,15 +193,45 @@ func (r *Router) EnableResultsAPI(vocapp *vochain.BaseApplication,
 		log.Fatal("cannot enable results API without scrutinizer")
 	}
 	r.APIs = append(r.APIs, "results")
-	r.RegisterPublic("getProcessList", r.getProcessList)
-	r.RegisterPublic("getProcessInfo", r.getProcessInfo)
-	r.RegisterPublic("getProcessSummary", r.getProcessSummary)
-	r.RegisterPublic("getProcessCount", r.getProcessCount)
-	r.RegisterPublic("getResults", r.getResults)
-	r.RegisterPublic("getResultsWeight", r.getResultsWeight)
-	r.RegisterPublic("getEntityList", r.getEntityList)
-	r.RegisterPublic("getEntityCount", r.getEntityCount)
-	r.RegisterPublic("getEnvelope", r.getEnvelope)
+	r.Register("results",
+		Method{Name: "getProcessList", Public: true, Handler: r.getProcessList,
+			Optional: []string{"entityId", "from", "listSize", "searchTerm", "namespace",
+				"sourceNetworkId", "status", "withResults"},
+			Response: []string{"processList", "size"}},
+		Method{Name: "getProcessInfo", Public: true, Handler: r.getProcessInfo,
+			Required: []string{"processId"}, Response: []string{"process"}},
+		Method{Name: "getProcessSummary", Public: true, Handler: r.getProcessSummary,
+			Required: []string{"processId"}, Response: []string{"processSummary"}},
+		Method{Name: "getProcessCount", Public: true, Handler: r.getProcessCount,
+			Optional: []string{"entityId"}, Response: []string{"size"}},
+		Method{Name: "getResults", Public: true, Handler: r.getResults,
+			Required: []string{"processId"},
+			Response: []string{"results", "type", "state", "final", "height", "invalidVotes",
+				"weight"}},
+		Method{Name: "getResultsWeight", Public: true, Handler: r.getResultsWeight,
+			Required: []string{"processId"}, Response: []string{"weight"}},
+		Method{Name: "getInvalidEnvelopes", Public: true, Handler: r.getInvalidEnvelopes,
+			Required: []string{"processId"}, Optional: []string{"from", "listSize"},
+			Response: []string{"invalidEnvelopes"}},
+		Method{Name: "getEntityList", Public: true, Handler: r.getEntityList,
+			Optional: []string{"from", "listSize", "searchTerm"},
+			Response: []string{"entityIds"}},
+		Method{Name: "getEntityCount", Public: true, Handler: r.getEntityCount,
+			Response: []string{"size"}},
+		Method{Name: "getEnvelope", Public: true, Handler: r.getEnvelope,
+			Required: []string{"nullifier"}, Response: []string{"envelope", "registered"}},
+		Method{Name: "getVoterHistory", Public: true, Handler: r.getVoterHistory,
+			Required: []string{"address", "entityId"}, Optional: []string{"from", "listSize"},
+			Response: []string{"voterHistory", "receipt"}},
+		Method{Name: "exportProcess", Public: true, Handler: r.exportProcess,
+			Required: []string{"processId"}, Optional: []string{"type", "from", "listSize"},
+			Response: []string{"content"}},
+	)
+	if r.storage != nil {
+		r.Register("results", Method{Name: "publishProcessExport",
+			Handler: r.publishProcessExport, Required: []string{"processId"},
+			Optional: []string{"type"}, Response: []string{"uri"}})
+	}
 }
 
 // EnableVoteAPI enabled the Vote API in the Router
@@ -82,13 +241,26 @@ func (r *Router) EnableIndexerAPI(vocapp *vochain.BaseApplication,
 		log.Fatal("cannot enable indexer API without scrutinizer")
 	}
 	r.APIs = append(r.APIs, "indexer")
-	r.RegisterPublic("getStats", r.getStats)
-	r.RegisterPublic("getEnvelopeList", r.getEnvelopeList)
-	r.RegisterPublic("getBlock", r.getBlock)
-	r.RegisterPublic("getBlockByHash", r.getBlockByHash)
-	r.RegisterPublic("getBlockList", r.getBlockList)
-	r.RegisterPublic("getTx", r.getTx)
-	r.RegisterPublic("getTxByHeight", r.getTxByHeight)
-	r.RegisterPublic("getValidatorList", r.getValidatorList)
-	r.RegisterPublic("getTxListForBlock", r.getTxListForBlock)
+	r.Register("indexer",
+		Method{Name: "getStats", Public: true, Handler: r.getStats,
+			Response: []string{"stats"}},
+		Method{Name: "getEnvelopeList", Public: true, Handler: r.getEnvelopeList,
+			Optional: []string{"processId", "from", "listSize", "searchTerm"},
+			Response: []string{"envelopes"}},
+		Method{Name: "getBlock", Public: true, Handler: r.getBlock,
+			Required: []string{"height"}, Response: []string{"block"}},
+		Method{Name: "getBlockByHash", Public: true, Handler: r.getBlockByHash,
+			Required: []string{"hash"}, Response: []string{"block"}},
+		Method{Name: "getBlockList", Public: true, Handler: r.getBlockList,
+			Optional: []string{"from", "listSize"}, Response: []string{"blockList"}},
+		Method{Name: "getTx", Public: true, Handler: r.getTx,
+			Required: []string{"height", "txIndex"}, Response: []string{"tx"}},
+		Method{Name: "getTxByHeight", Public: true, Handler: r.getTxByHeight,
+			Required: []string{"height"}, Response: []string{"tx"}},
+		Method{Name: "getValidatorList", Public: true, Handler: r.getValidatorList,
+			Response: []string{"validatorlist"}},
+		Method{Name: "getTxListForBlock", Public: true, Handler: r.getTxListForBlock,
+			Required: []string{"height"}, Optional: []string{"from", "listSize"},
+			Response: []string{"txList"}},
+	)
 }
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/census"
+	"go.vocdoni.io/dvote/vochain/scrutinizer"
+)
+
+func TestCheckMethod(t *testing.T) {
+	r := newTestRouter(t)
+	for _, tc := range []struct {
+		method Method
+		err    string
+	}{
+		{Method{Name: "test", Required: []string{"processId"}, Optional: []string{"from"},
+			Response: []string{"processList"}}, ""},
+		{Method{Name: "getInfo"}, `duplicate method: "getInfo"`},
+		{Method{Name: "test", Required: []string{"processID"}},
+			`method "test" declares unknown field "processID"`},
+		{Method{Name: "test", Optional: []string{"processList"}},
+			`method "test" declares unknown field "processList"`},
+		{Method{Name: "test", Response: []string{"listSize"}},
+			`method "test" declares unknown field "listSize"`},
+	} {
+		err := r.checkMethod(tc.method)
+		if tc.err == "" {
+			qt.Assert(t, err, qt.IsNil)
+			continue
+		}
+		qt.Assert(t, err, qt.ErrorMatches, tc.err)
+	}
+}
+
+func TestRegisterAPIs(t *testing.T) {
+	// All the methods declare existing fields, Register would exit otherwise
+	r := newTestRouter(t)
+	r.Scrutinizer = &scrutinizer.Scrutinizer{}
+	r.EnableFileAPI()
+	r.EnableCensusAPI(&census.Manager{})
+	r.EnableVoteAPI(nil, nil)
+	r.EnableResultsAPI(nil, nil)
+	r.EnableIndexerAPI(nil, nil)
+	qt.Assert(t, r.APIs, qt.DeepEquals, []string{"file", "census", "vote", "results", "indexer"})
+	for _, m := range r.methods {
+		qt.Assert(t, m.handler, qt.Not(qt.IsNil), qt.Commentf("%s", m.info.Name))
+	}
+}
+
+func TestGetMethods(t *testing.T) {
+	r := newTestRouter(t)
+	registerEcho(r)
+	ctx := &jsonrpcContext{}
+	r.getMethods(RouterRequest{MessageContext: ctx, id: "1"})
+	var outer api.ResponseMessage
+	qt.Assert(t, json.Unmarshal(ctx.data, &outer), qt.IsNil)
+	var resp api.MetaResponse
+	qt.Assert(t, json.Unmarshal(outer.MetaResponse, &resp), qt.IsNil)
+	qt.Assert(t, resp.APIVersion, qt.Equals, APIVersion)
+
+	// The methods are sorted by name, with their API and fields
+	var names []string
+	for _, m := range resp.Methods {
+		names = append(names, m.Name)
+	}
+	qt.Assert(t, sort.StringsAreSorted(names), qt.IsTrue, qt.Commentf("%v", names))
+	qt.Assert(t, names, qt.DeepEquals, []string{"echo", "getInfo", "getMethods", "private"})
+	qt.Assert(t, *resp.Methods[0], qt.DeepEquals, api.MethodInfo{
+		Name:     "echo",
+		API:      "test",
+		Public:   true,
+		Optional: []string{"censusId"},
+		Response: []string{"censusId"},
+	})
+	qt.Assert(t, resp.Methods[3].Public, qt.IsFalse)
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/multirpc/transports"
+)
+
+const (
+	// MaxBatchSize is the maximum number of requests of a batch request
+	MaxBatchSize = 32
+	// batchConcurrency is the number of requests of a batch executed at a time
+	batchConcurrency = 8
+)
+
+// batchContext is the MessageContext of the requests of a batch, it keeps the
+// first response sent by the router method
+type batchContext struct {
+	parent transports.MessageContext
+	data   []byte
+}
+
+func (c *batchContext) ConnectionType() string {
+	return c.parent.ConnectionType()
+}
+
+func (c *batchContext) Send(msg transports.Message) error {
+	if c.data == nil {
+		c.data = msg.Data
+	}
+	return nil
+}
+
+// isBatch returns true if the signed request is an array of requests
+func isBatch(request json.RawMessage) bool {
+	request = bytes.TrimSpace(request)
+	return len(request) > 0 && request[0] == '['
+}
+
+// dispatchBatch handles the requests of a batch concurrently, and sends their
+// responses in the same order. The batch signature, made over the whole array
+// of requests, authenticates each one of them.
+func (r *Router) dispatchBatch(reqOuter *api.RequestMessage, context transports.MessageContext) {
+	batchRequest := RouterRequest{MessageContext: context, id: reqOuter.ID}
+	var items []json.RawMessage
+	if err := json.Unmarshal(reqOuter.MetaRequest, &items); err != nil {
+		r.SendErrorCode(batchRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if len(items) == 0 || len(items) > MaxBatchSize {
+		r.SendErrorCode(batchRequest, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("batch size must be between 1 and %d", MaxBatchSize))
+		return
+	}
+
+	responses := make([]json.RawMessage, len(items))
+	sem := make(chan struct{}, batchConcurrency)
+	wg := sync.WaitGroup{}
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item json.RawMessage) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ctx := &batchContext{parent: context}
+			request := RouterRequest{
+				MessageContext: ctx,
+				id:             fmt.Sprintf("%s/%d", reqOuter.ID, i),
+			}
+			err := json.Unmarshal(item, &request.MetaRequest)
+			if err == nil {
+				// the signature covers the whole batch, so each request is
+				// identified by its position for the replay guard
+				replayKey := append([]byte(fmt.Sprintf("%d/", i)), reqOuter.MetaRequest...)
+				err = r.authenticate(&request, reqOuter.MetaRequest, reqOuter.Signature,
+					replayKey)
+			}
+			r.dispatch(request, err)
+			if ctx.data == nil {
+				r.SendError(request, "no response from the router method")
+			}
+			responses[i] = ctx.data
+		}(i, item)
+	}
+	wg.Wait()
+
+	data, err := json.Marshal(api.BatchResponseMessage{ID: reqOuter.ID, Responses: responses})
+	if err != nil {
+		r.SendError(batchRequest, fmt.Sprintf("cannot marshal batch response: %v", err))
+		return
+	}
+	msg := transports.Message{
+		TimeStamp: int32(time.Now().Unix()),
+		Context:   context,
+		Data:      data,
+	}
+	if err := context.Send(msg); err != nil {
+		log.Warnf("error sending batch response: %v", err)
+	}
+}
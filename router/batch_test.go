@@ -0,0 +1,139 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+)
+
+// sendBatch dispatches a batch of requests, and returns the batch response
+func sendBatch(t *testing.T, r *Router, id, items string) []json.RawMessage {
+	ctx := &jsonrpcContext{}
+	r.dispatchBatch(&api.RequestMessage{ID: id, MetaRequest: json.RawMessage(items)}, ctx)
+	var batch api.BatchResponseMessage
+	qt.Assert(t, json.Unmarshal(ctx.data, &batch), qt.IsNil, qt.Commentf("%s", ctx.data))
+	qt.Assert(t, batch.ID, qt.Equals, id)
+	return batch.Responses
+}
+
+// batchItem returns the inner response of a batch response item
+func batchItem(t *testing.T, data json.RawMessage) api.MetaResponse {
+	var outer api.ResponseMessage
+	qt.Assert(t, json.Unmarshal(data, &outer), qt.IsNil)
+	var inner api.MetaResponse
+	qt.Assert(t, json.Unmarshal(outer.MetaResponse, &inner), qt.IsNil)
+	qt.Assert(t, inner.Request, qt.Equals, outer.ID)
+	return inner
+}
+
+func TestDispatchBatchOrder(t *testing.T) {
+	r := newTestRouter(t)
+	// the first requests take longer, so they finish after the last ones
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	r.Register("test", Method{Name: "sleep", Public: true,
+		Optional: []string{"censusId"}, Response: []string{"censusId"},
+		Handler: func(request RouterRequest) {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			i, _ := strconv.Atoi(request.CensusID)
+			time.Sleep(time.Duration(MaxBatchSize-i) * time.Millisecond)
+			lock.Lock()
+			running--
+			lock.Unlock()
+			response := api.MetaResponse{CensusID: request.CensusID}
+			if err := request.Send(r.BuildReply(request, &response)); err != nil {
+				log.Warn(err)
+			}
+		}})
+	items := make([]string, MaxBatchSize)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"method":"sleep","censusId":"%d"}`, i)
+	}
+	responses := sendBatch(t, r, "batch", "["+strings.Join(items, ",")+"]")
+	qt.Assert(t, responses, qt.HasLen, MaxBatchSize)
+	for i, data := range responses {
+		resp := batchItem(t, data)
+		qt.Assert(t, resp.Ok, qt.IsTrue)
+		qt.Assert(t, resp.Request, qt.Equals, fmt.Sprintf("batch/%d", i))
+		qt.Assert(t, resp.CensusID, qt.Equals, strconv.Itoa(i))
+	}
+	qt.Assert(t, maxRunning <= batchConcurrency, qt.IsTrue, qt.Commentf("%d", maxRunning))
+}
+
+func TestDispatchBatchErrors(t *testing.T) {
+	r := newTestRouter(t)
+	registerEcho(r)
+	r.Register("test", Method{Name: "silent", Public: true, Handler: func(RouterRequest) {}})
+
+	// Each request of the batch fails on its own
+	responses := sendBatch(t, r, "b1", `[
+		{"method":"echo","censusId":"c0"},
+		1,
+		{"method":"unknown"},
+		{"method":"echo","censusId":"missing"},
+		{"method":"private"},
+		{"method":"silent"}
+	]`)
+	qt.Assert(t, responses, qt.HasLen, 6)
+	for i, tc := range []struct {
+		code    api.ErrorCode
+		message string
+	}{
+		{"", ""},
+		{api.ErrCodeInvalidRequest, "json: cannot unmarshal number.*"},
+		{api.ErrCodeInvalidRequest, "method not valid .unknown."},
+		{api.ErrCodeNotFound, "census not found"},
+		{api.ErrCodeInvalidRequest, ".*"},
+		{api.ErrCodeInternal, "no response from the router method"},
+	} {
+		resp := batchItem(t, responses[i])
+		qt.Assert(t, resp.Request, qt.Equals, fmt.Sprintf("b1/%d", i))
+		if tc.code == "" {
+			qt.Assert(t, resp.Ok, qt.IsTrue)
+			continue
+		}
+		qt.Assert(t, resp.Ok, qt.IsFalse, qt.Commentf("request %d", i))
+		qt.Assert(t, resp.ErrorCode, qt.Equals, tc.code, qt.Commentf("request %d", i))
+		qt.Assert(t, resp.Message, qt.Matches, tc.message, qt.Commentf("request %d", i))
+	}
+
+	// The batch size is limited, the batch gets a single error response
+	items := make([]string, MaxBatchSize+1)
+	for i := range items {
+		items[i] = `{"method":"echo"}`
+	}
+	for _, batch := range []string{"[]", "[" + strings.Join(items, ",") + "]", "[1,"} {
+		ctx := &jsonrpcContext{}
+		r.dispatchBatch(&api.RequestMessage{ID: "b2", MetaRequest: json.RawMessage(batch)}, ctx)
+		resp := batchItem(t, ctx.data)
+		qt.Assert(t, resp.Ok, qt.IsFalse)
+		qt.Assert(t, resp.ErrorCode, qt.Equals, api.ErrCodeInvalidRequest)
+		qt.Assert(t, resp.Request, qt.Equals, "b2")
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	for request, batch := range map[string]bool{
+		`[{"method":"echo"}]`: true,
+		"  \n[]":              true,
+		`{"method":"echo"}`:   false,
+		"":                    false,
+		`"[not an array]"`:    false,
+	} {
+		qt.Assert(t, isBatch(json.RawMessage(request)), qt.Equals, batch,
+			qt.Commentf("%q", request))
+	}
+}
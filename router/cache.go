@@ -0,0 +1,160 @@
+package router
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// cacheMaxEntries is the maximum number of responses kept by the cache
+const cacheMaxEntries = 10000
+
+// cachedResponse is a successful response of a router method. The signed
+// inner response is sent as it is, so the responses are not signed again. It
+// does not include the request ID, which is only set on the outer message.
+type cachedResponse struct {
+	inner     []byte
+	signature []byte
+	expires   time.Time
+}
+
+// ResponseCache keeps the responses of the read methods for the current block.
+// All the responses are invalidated when a new block is committed, and each one
+// expires after the TTL of its method, since some data (such as the results)
+// is computed asynchronously after the block commit.
+type ResponseCache struct {
+	ttl     map[string]time.Duration
+	entries map[string]*cachedResponse
+	height  uint32
+	lock    sync.RWMutex
+}
+
+// NewResponseCache creates a response cache for the methods with a positive TTL.
+// It must be added as a Vochain event listener, to be invalidated on Commit.
+func NewResponseCache(ttl map[string]time.Duration) *ResponseCache {
+	return &ResponseCache{
+		ttl:     ttl,
+		entries: make(map[string]*cachedResponse),
+	}
+}
+
+// key returns the cache key of a request and the current block height, or an
+// empty key if the method is not cached. The key only depends on the method and
+// its parameters, so the clients share the responses whatever their request ID.
+func (c *ResponseCache) key(req api.MetaRequest) (string, uint32) {
+	if c.ttl[req.Method] <= 0 {
+		return "", 0
+	}
+	// the fields that do not change the response are ignored
+	req.Timestamp = 0
+	req.Signature = nil
+	key, err := json.Marshal(req)
+	if err != nil {
+		log.Warnf("cannot build cache key: %v", err)
+		return "", 0
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return string(key), c.height
+}
+
+func (c *ResponseCache) get(key string) *cachedResponse {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil
+	}
+	return entry
+}
+
+// store adds the response of a request received at the given block height,
+// unless a new block was committed meanwhile
+func (c *ResponseCache) store(key, method string, height uint32, entry *cachedResponse) {
+	entry.expires = time.Now().Add(c.ttl[method])
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if height != c.height {
+		return
+	}
+	if len(c.entries) >= cacheMaxEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= cacheMaxEntries {
+			return
+		}
+	}
+	c.entries[key] = entry
+}
+
+// Commit invalidates all the cached responses
+func (c *ResponseCache) Commit(height uint32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.height = height
+	c.entries = make(map[string]*cachedResponse)
+	return nil
+}
+
+func (c *ResponseCache) Rollback()                                                {}
+func (c *ResponseCache) OnVote(v *models.Vote, txIndex int32)                     {}
+func (c *ResponseCache) OnNewTx(blockHeight uint32, txIndex int32)                {}
+func (c *ResponseCache) OnCancel(pid []byte, txIndex int32)                       {}
+func (c *ResponseCache) OnProcessKeys(pid []byte, pub, com string, txIndex int32) {}
+func (c *ResponseCache) OnRevealKeys(pid []byte, priv, rev string, txIndex int32) {}
+func (c *ResponseCache) OnProcess(pid, eid []byte, censusRoot, censusURI string,
+	txIndex int32) {
+}
+func (c *ResponseCache) OnProcessStatusChange(pid []byte, status models.ProcessStatus,
+	txIndex int32) {
+}
+func (c *ResponseCache) OnProcessResults(pid []byte, results []*models.QuestionResult,
+	txIndex int32) error {
+	return nil
+}
+
+// SetResponseCache serves the read methods responses from c. If c is nil, the
+// responses are not cached.
+func (r *Router) SetResponseCache(c *ResponseCache) {
+	r.cache = c
+}
+
+// handle calls the method handler, unless the response is cached. On a cache
+// miss, the request is marked to store its response on BuildReply.
+func (r *Router) handle(request RouterRequest, handler func(RouterRequest)) {
+	if r.cache == nil {
+		handler(request)
+		return
+	}
+	key, height := r.cache.key(request.MetaRequest)
+	if key == "" {
+		handler(request)
+		return
+	}
+	entry := r.cache.get(key)
+	if r.metricsagent != nil {
+		if entry != nil {
+			RouterCacheHits.With(prometheus.Labels{"method": request.method}).Inc()
+		} else {
+			RouterCacheMisses.With(prometheus.Labels{"method": request.method}).Inc()
+		}
+	}
+	if entry == nil {
+		request.cacheKey = key
+		request.cacheHeight = height
+		handler(request)
+		return
+	}
+	if err := request.Send(replyMessage(request, entry.inner, entry.signature)); err != nil {
+		log.Warnf("error sending cached response: %v", err)
+	}
+}
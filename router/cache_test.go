@@ -0,0 +1,134 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/multirpc/transports"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	c := NewResponseCache(map[string]time.Duration{"getBlockHeight": time.Minute})
+	req := api.MetaRequest{Method: "getBlockHeight", Timestamp: 10}
+
+	key, _ := c.key(api.MetaRequest{Method: "getBlockStatus"})
+	qt.Assert(t, key, qt.Equals, "")
+	key, _ = c.key(req)
+	qt.Assert(t, key, qt.Not(qt.Equals), "")
+
+	for _, tc := range []struct {
+		name  string
+		req   api.MetaRequest
+		equal bool
+	}{
+		{"timestamp", api.MetaRequest{Method: "getBlockHeight", Timestamp: 20}, true},
+		{"signature", api.MetaRequest{Method: "getBlockHeight", Signature: []byte{1}}, true},
+		{"fields", api.MetaRequest{Method: "getBlockHeight", ListSize: 10}, false},
+	} {
+		other, _ := c.key(tc.req)
+		qt.Assert(t, other == key, qt.Equals, tc.equal, qt.Commentf("%s", tc.name))
+	}
+}
+
+func TestResponseCacheStore(t *testing.T) {
+	c := NewResponseCache(map[string]time.Duration{
+		"getBlockHeight": time.Minute,
+		"getResults":     100 * time.Millisecond,
+	})
+	qt.Assert(t, c.Commit(5), qt.IsNil)
+
+	c.store("a", "getBlockHeight", 5, &cachedResponse{inner: []byte("a")})
+	qt.Assert(t, c.get("a"), qt.Not(qt.IsNil))
+	qt.Assert(t, string(c.get("a").inner), qt.Equals, "a")
+
+	// The responses computed before the last block are not stored
+	c.store("b", "getBlockHeight", 4, &cachedResponse{inner: []byte("b")})
+	qt.Assert(t, c.get("b"), qt.IsNil)
+
+	// Each response expires after its method TTL
+	c.store("c", "getResults", 5, &cachedResponse{inner: []byte("c")})
+	time.Sleep(110 * time.Millisecond)
+	qt.Assert(t, c.get("c"), qt.IsNil)
+
+	// A new block invalidates all the responses
+	qt.Assert(t, c.Commit(6), qt.IsNil)
+	qt.Assert(t, c.get("a"), qt.IsNil)
+
+	// The expired responses are removed to make room when the cache is full
+	for i := 0; i < cacheMaxEntries; i++ {
+		c.store(fmt.Sprint(i), "getResults", 6, &cachedResponse{})
+	}
+	c.store("full", "getBlockHeight", 6, &cachedResponse{})
+	qt.Assert(t, c.get("full"), qt.IsNil)
+	time.Sleep(110 * time.Millisecond)
+	c.store("full", "getBlockHeight", 6, &cachedResponse{})
+	qt.Assert(t, c.get("full"), qt.Not(qt.IsNil))
+	qt.Assert(t, c.entries, qt.HasLen, 1)
+}
+
+func TestRouterCache(t *testing.T) {
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	r := NewRouter(nil, nil, signer, nil, false)
+	r.SetResponseCache(NewResponseCache(map[string]time.Duration{"getBlockHeight": time.Minute}))
+	calls := 0
+	handler := func(request RouterRequest) {
+		calls++
+		height := uint32(calls)
+		if err := request.Send(r.BuildReply(request, &api.MetaResponse{Height: &height})); err != nil {
+			t.Error(err)
+		}
+	}
+	call := func(ctx transports.MessageContext, id string) {
+		r.handle(RouterRequest{
+			MetaRequest:    api.MetaRequest{Method: "getBlockHeight"},
+			MessageContext: ctx,
+			method:         "getBlockHeight",
+			id:             id,
+		}, handler)
+	}
+	response := func(data []byte) (api.ResponseMessage, api.MetaResponse) {
+		var outer api.ResponseMessage
+		qt.Assert(t, json.Unmarshal(data, &outer), qt.IsNil)
+		var inner api.MetaResponse
+		qt.Assert(t, json.Unmarshal(outer.MetaResponse, &inner), qt.IsNil)
+		return outer, inner
+	}
+
+	// A cache hit sends the same signed response, without calling the handler
+	ctx1, ctx2 := &jsonrpcContext{}, &jsonrpcContext{}
+	call(ctx1, "id1")
+	call(ctx2, "id1")
+	qt.Assert(t, calls, qt.Equals, 1)
+	qt.Assert(t, ctx2.data, qt.DeepEquals, ctx1.data)
+	outer1, inner1 := response(ctx1.data)
+	qt.Assert(t, outer1.ID, qt.Equals, "id1")
+	qt.Assert(t, inner1.Request, qt.Equals, "")
+
+	// The signed response does not include the request ID, so the requests
+	// with other IDs, from any transport, hit the same entry
+	ctx3, rest := &jsonrpcContext{}, &restContext{}
+	call(ctx3, "id2")
+	call(rest, "rest")
+	qt.Assert(t, calls, qt.Equals, 1)
+	for data, id := range map[string]string{string(ctx3.data): "id2", string(rest.data): "rest"} {
+		outer, inner := response([]byte(data))
+		qt.Assert(t, outer.ID, qt.Equals, id)
+		qt.Assert(t, outer.Signature, qt.DeepEquals, outer1.Signature)
+		qt.Assert(t, []byte(outer.MetaResponse), qt.DeepEquals, []byte(outer1.MetaResponse))
+		qt.Assert(t, *inner.Height, qt.Equals, uint32(1))
+	}
+	ok, err := ethereum.Verify(outer1.MetaResponse, outer1.Signature, signer.PublicKey())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ok, qt.IsTrue)
+
+	// A new block invalidates the cache
+	qt.Assert(t, r.cache.Commit(1), qt.IsNil)
+	call(&jsonrpcContext{}, "id1")
+	qt.Assert(t, calls, qt.Equals, 2)
+}
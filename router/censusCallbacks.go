@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"go.vocdoni.io/dvote/api"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/util"
 )
@@ -14,15 +15,22 @@ func (r *Router) censusLocal(request RouterRequest) {
 	log.Debugf("client authorization %t. Recovered address is [%s]", auth, addr.Hex())
 	if auth {
 		if len(addr) < 20 {
-			r.SendError(request, "cannot recover address")
+			r.SendErrorCode(request, api.ErrCodeUnauthorized, "cannot recover address")
 			return
 		}
 	}
+	// the census IDs are prefixed by the address of their creator, unless the
+	// signer is one of the census management keys of namespaces.json
+	censusPrefix := util.TrimHex(addr.String()) + "/"
+	if auth && request.Method != "addCensus" &&
+		r.census.KeyAllowed(util.TrimHex(request.CensusID), addr) {
+		censusPrefix = ""
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
-	resp := r.census.Handler(ctx, &request.MetaRequest, auth, util.TrimHex(addr.String())+"/")
+	resp := r.census.Handler(ctx, &request.MetaRequest, auth, censusPrefix)
 	if !resp.Ok {
-		r.SendError(request, resp.Message)
+		r.SendErrorCode(request, resp.ErrorCode, resp.Message)
 		return
 	}
 	if err := request.Send(r.BuildReply(request, resp)); err != nil {
@@ -28,13 +28,13 @@ func (r *Router) fetchFile(request RouterRequest) {
 		content, err = r.storage.Retrieve(ctx, request.URI, maxFetchFile)
 		cancel()
 	case "bzz", "bzz-feed":
-		err = fmt.Errorf("bzz and bzz-feed not implemented yet")
+		err = api.NewError(api.ErrCodeInvalidRequest, "bzz and bzz-feed not implemented yet")
 	default:
-		err = fmt.Errorf("transport type not supported")
+		err = api.NewError(api.ErrCodeInvalidRequest, "transport type not supported")
 	}
 
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("error fetching file: (%s)", err))
+		r.SendErrorCode(request, api.ErrorCodeOf(err), fmt.Sprintf("error fetching file: (%s)", err))
 		return
 	}
 	log.Debugf("fetched file of size %d", len(content))
@@ -70,12 +70,12 @@ func (r *Router) addFile(request RouterRequest) {
 
 func (r *Router) addJSONfile(request RouterRequest) {
 	if len(request.Content) > maxJSONsize {
-		r.SendError(request,
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
 			fmt.Sprintf("JSON file too big: %d bytes", len(request.Content)))
 		return
 	}
 	if !isJSON(request.Content) {
-		r.SendError(request, "not a JSON file")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest, "not a JSON file")
 		return
 	}
 	r.addFile(request)
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+)
+
+func TestAddJSONFileErrorCodes(t *testing.T) {
+	r := newTestRouter(t)
+	for _, content := range [][]byte{
+		[]byte("not json"),
+		[]byte(fmt.Sprintf("%q", make([]byte, maxJSONsize))),
+	} {
+		ctx := &jsonrpcContext{}
+		r.addJSONfile(RouterRequest{
+			MetaRequest:    api.MetaRequest{Content: content},
+			MessageContext: ctx,
+		})
+		resp := replyOf(t, ctx)
+		qt.Assert(t, resp.Ok, qt.IsFalse)
+		qt.Assert(t, resp.ErrorCode, qt.Equals, api.ErrCodeInvalidRequest)
+	}
+}
@@ -52,7 +52,7 @@ func (r *Router) getEnvelopeList(request RouterRequest) {
 	var err error
 	if response.Envelopes, err = r.Scrutinizer.GetEnvelopes(
 		request.ProcessID, max, request.From, request.SearchTerm); err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get envelope list: (%s)", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get envelope list: (%s)", err))
 		return
 	}
 	if err := request.Send(r.BuildReply(request, &response)); err != nil {
@@ -64,7 +64,7 @@ func (r *Router) getValidatorList(request RouterRequest) {
 	var response api.MetaResponse
 	var err error
 	if response.ValidatorList, err = r.vocapp.State.Validators(true); err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get validator list: %v", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get validator list: %v", err))
 		return
 	}
 	if err := request.Send(r.BuildReply(request, &response)); err != nil {
@@ -75,13 +75,14 @@ func (r *Router) getValidatorList(request RouterRequest) {
 func (r *Router) getBlock(request RouterRequest) {
 	var response api.MetaResponse
 	if request.Height > r.vocapp.Height() {
-		r.SendError(request, fmt.Sprintf(
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest, fmt.Sprintf(
 			"block height %d not valid for vochain with height %d", request.Height, r.vocapp.Height()))
 		return
 	}
 	if response.Block = blockMetadataFromBlockModel(
 		r.Scrutinizer.App.GetBlockByHeight(int64(request.Height)), false, true); response.Block == nil {
-		r.SendError(request, fmt.Sprintf("cannot get block: no block with height %d", request.Height))
+		r.SendErrorCode(request, api.ErrCodeNotFound,
+			fmt.Sprintf("cannot get block: no block with height %d", request.Height))
 		return
 	}
 	if err := request.Send(r.BuildReply(request, &response)); err != nil {
@@ -94,7 +95,8 @@ func (r *Router) getBlockByHash(request RouterRequest) {
 	response.Block = blockMetadataFromBlockModel(
 		r.Scrutinizer.App.GetBlockByHash(request.Hash), true, false)
 	if response.Block == nil {
-		r.SendError(request, fmt.Sprintf("cannot get block: no block with hash %x", request.Hash))
+		r.SendErrorCode(request, api.ErrCodeNotFound,
+			fmt.Sprintf("cannot get block: no block with hash %x", request.Hash))
 		return
 	}
 	if err := request.Send(r.BuildReply(request, &response)); err != nil {
@@ -122,7 +124,7 @@ func (r *Router) getTx(request RouterRequest) {
 	var response api.MetaResponse
 	tx, hash, err := r.Scrutinizer.App.GetTxHash(request.Height, request.TxIndex)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get tx: %v", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get tx: %v", err))
 		return
 	}
 	response.Tx = &indexertypes.TxPackage{
@@ -146,7 +148,7 @@ func (r *Router) getTxByHeight(request RouterRequest) {
 	}
 	tx, hash, err := r.Scrutinizer.App.GetTxHash(txRef.BlockHeight, int32(txRef.TxBlockIndex))
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get tx: %v", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get tx: %v", err))
 		return
 	}
 	response.Tx = &indexertypes.TxPackage{
@@ -166,7 +168,7 @@ func (r *Router) getTxListForBlock(request RouterRequest) {
 	var response api.MetaResponse
 	block := r.vocapp.Node.BlockStore().LoadBlock(int64(request.Height))
 	if block == nil {
-		r.SendError(request, "cannot get tx list: block does not exist")
+		r.SendErrorCode(request, api.ErrCodeNotFound, "cannot get tx list: block does not exist")
 		return
 	}
 	if request.ListSize > MaxListSize || request.ListSize <= 0 {
@@ -178,11 +180,11 @@ func (r *Router) getTxListForBlock(request RouterRequest) {
 		tx := new(models.Tx)
 		var err error
 		if err = proto.Unmarshal(block.Txs[i], signedTx); err != nil {
-			r.SendError(request, fmt.Sprintf("cannot get signed tx: %v", err))
+			r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get signed tx: %v", err))
 			return
 		}
 		if err = proto.Unmarshal(signedTx.Tx, tx); err != nil {
-			r.SendError(request, fmt.Sprintf("cannot get tx: %v", err))
+			r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get tx: %v", err))
 			return
 		}
 		var txType string
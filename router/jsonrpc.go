@@ -0,0 +1,290 @@
+package router
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/multirpc/transports"
+	"go.vocdoni.io/dvote/multirpc/transports/mhttp"
+	"go.vocdoni.io/dvote/util"
+)
+
+const (
+	// JSONRPCVersion is the supported JSON-RPC protocol version
+	JSONRPCVersion = "2.0"
+	// JSONRPCSignatureHeader is the HTTP header carrying the signature of the
+	// request body made by the client, and the signature of the response body
+	// made by the gateway keys
+	JSONRPCSignatureHeader = "X-Vocdoni-Signature"
+
+	jsonrpcMaxBodySize  = 10 << 20
+	jsonrpcMaxBatchSize = 64
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcInternalError  = -32603
+	// jsonrpcServerError is used for the router errors, the API error code is
+	// added to the error data
+	jsonrpcServerError = -32000
+)
+
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// ID is nil for the notifications, which get no response
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonrpcError struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Data    *jsonrpcErrorData `json:"data,omitempty"`
+}
+
+type jsonrpcErrorData struct {
+	ErrorCode api.ErrorCode `json:"errorCode"`
+}
+
+// jsonrpcContext captures the response of a router method called through the
+// JSON-RPC endpoint
+type jsonrpcContext struct {
+	remoteAddr string
+	data       []byte
+}
+
+func (c *jsonrpcContext) ConnectionType() string {
+	return "JSONRPC"
+}
+
+func (c *jsonrpcContext) Send(msg transports.Message) error {
+	if c.data == nil {
+		c.data = msg.Data
+	}
+	return nil
+}
+
+// EnableJSONRPC serves the router methods at route+"jsonrpc" as JSON-RPC 2.0
+// methods, including batch requests. The params object holds the MetaRequest
+// fields. Requests can be signed by adding the hex encoded signature of the
+// HTTP body on the JSONRPCSignatureHeader, the responses are signed the same way.
+func (r *Router) EnableJSONRPC(pxy *mhttp.Proxy, route string) {
+	path := route + "jsonrpc"
+	pxy.AddHandler(path, r.jsonrpcHandler)
+	log.Infof("JSON-RPC API available at %s", path)
+}
+
+func (r *Router) jsonrpcHandler(w http.ResponseWriter, hr *http.Request) {
+	if hr.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, hr.Body, jsonrpcMaxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var signature []byte
+	if sig := hr.Header.Get(JSONRPCSignatureHeader); sig != "" {
+		if signature, err = hex.DecodeString(util.TrimHex(sig)); err != nil {
+			r.writeJSONRPC(w, newJSONRPCError(nil, jsonrpcInvalidRequest,
+				"signature header is not hex encoded"))
+			return
+		}
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		var req jsonrpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			r.writeJSONRPC(w, newJSONRPCError(nil, jsonrpcParseError, err.Error()))
+			return
+		}
+		if resp := r.jsonrpcCall(&req, hr.RemoteAddr, body, signature, body); resp != nil {
+			r.writeJSONRPC(w, resp)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		r.writeJSONRPC(w, newJSONRPCError(nil, jsonrpcParseError, err.Error()))
+		return
+	}
+	if len(batch) == 0 || len(batch) > jsonrpcMaxBatchSize {
+		r.writeJSONRPC(w, newJSONRPCError(nil, jsonrpcInvalidRequest,
+			fmt.Sprintf("batch size must be between 1 and %d", jsonrpcMaxBatchSize)))
+		return
+	}
+	responses := make([]*jsonrpcResponse, len(batch))
+	sem := make(chan struct{}, batchConcurrency)
+	wg := sync.WaitGroup{}
+	for i, raw := range batch {
+		var req jsonrpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses[i] = newJSONRPCError(nil, jsonrpcInvalidRequest, err.Error())
+			continue
+		}
+		// the signature covers the whole batch, so each call is identified
+		// by its position for the replay guard
+		replayKey := append([]byte(strconv.Itoa(i)+"/"), body...)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *jsonrpcRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			responses[i] = r.jsonrpcCall(req, hr.RemoteAddr, body, signature, replayKey)
+		}(i, &req)
+	}
+	wg.Wait()
+	var sent []*jsonrpcResponse
+	for _, resp := range responses {
+		if resp != nil {
+			sent = append(sent, resp)
+		}
+	}
+	if len(sent) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	r.writeJSONRPC(w, sent)
+}
+
+// jsonrpcCall calls the router method of a JSON-RPC request, and returns the
+// response, or nil if the request is a notification
+func (r *Router) jsonrpcCall(req *jsonrpcRequest, remoteAddr string,
+	signed, signature, replayKey []byte) *jsonrpcResponse {
+	if req.JSONRPC != JSONRPCVersion || req.Method == "" {
+		return newJSONRPCError(req.ID, jsonrpcInvalidRequest,
+			fmt.Sprintf("jsonrpc must be %q and method must be set", JSONRPCVersion))
+	}
+	// the notifications do not get error responses either
+	fail := func(code int, message string) *jsonrpcResponse {
+		if req.ID == nil {
+			return nil
+		}
+		return newJSONRPCError(req.ID, code, message)
+	}
+	if _, ok := r.methods[req.Method]; !ok {
+		return fail(jsonrpcMethodNotFound, fmt.Sprintf("router has no method %q", req.Method))
+	}
+	var meta api.MetaRequest
+	if params := bytes.TrimSpace(req.Params); len(params) > 0 && !bytes.Equal(params,
+		[]byte("null")) {
+		if params[0] != '{' {
+			return fail(jsonrpcInvalidParams, "params must be an object")
+		}
+		if err := json.Unmarshal(params, &meta); err != nil {
+			return fail(jsonrpcInvalidParams, err.Error())
+		}
+	}
+	meta.Method = req.Method
+
+	ctx := &jsonrpcContext{remoteAddr: remoteAddr}
+	request := RouterRequest{
+		MetaRequest:    meta,
+		MessageContext: ctx,
+		id:             jsonrpcID(req.ID),
+	}
+	err := r.authenticate(&request, signed, signature, replayKey)
+	r.dispatch(request, err)
+	if req.ID == nil {
+		return nil
+	}
+
+	var outer api.ResponseMessage
+	if err := json.Unmarshal(ctx.data, &outer); err != nil || len(outer.MetaResponse) == 0 {
+		return newJSONRPCError(req.ID, jsonrpcInternalError, "no response from the router")
+	}
+	var inner struct {
+		Ok        bool          `json:"ok"`
+		Message   string        `json:"message"`
+		ErrorCode api.ErrorCode `json:"errorCode"`
+	}
+	if err := json.Unmarshal(outer.MetaResponse, &inner); err != nil {
+		return newJSONRPCError(req.ID, jsonrpcInternalError, err.Error())
+	}
+	if !inner.Ok {
+		resp := newJSONRPCError(req.ID, jsonrpcErrorCode(inner.ErrorCode), inner.Message)
+		resp.Error.Data = &jsonrpcErrorData{ErrorCode: inner.ErrorCode}
+		return resp
+	}
+	return &jsonrpcResponse{JSONRPC: JSONRPCVersion, Result: outer.MetaResponse, ID: req.ID}
+}
+
+// writeJSONRPC writes a response or a batch of responses, signed by the gateway
+func (r *Router) writeJSONRPC(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	signature, err := r.signer.Sign(data)
+	if err != nil {
+		log.Error(err)
+		// continue without the signature
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(JSONRPCSignatureHeader, hex.EncodeToString(signature))
+	if _, err := w.Write(data); err != nil {
+		log.Warnf("error sending jsonrpc response: %v", err)
+	}
+}
+
+func newJSONRPCError(id json.RawMessage, code int, message string) *jsonrpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &jsonrpcResponse{
+		JSONRPC: JSONRPCVersion,
+		Error:   &jsonrpcError{Code: code, Message: message},
+		ID:      id,
+	}
+}
+
+// jsonrpcErrorCode returns the JSON-RPC error code of an API error code
+func jsonrpcErrorCode(code api.ErrorCode) int {
+	switch code {
+	case api.ErrCodeInvalidRequest:
+		return jsonrpcInvalidParams
+	case api.ErrCodeInternal:
+		return jsonrpcInternalError
+	default:
+		return jsonrpcServerError
+	}
+}
+
+// jsonrpcID returns the request ID used on the router response, the JSON-RPC
+// string IDs are unquoted
+func jsonrpcID(id json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(id, &s); err == nil {
+		return s
+	}
+	return string(id)
+}
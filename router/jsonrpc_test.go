@@ -0,0 +1,321 @@
+package router
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/log"
+)
+
+// registerEcho registers the public echo method, replying with the request
+// censusId or failing with notFound, and an empty private method. It returns
+// the number of calls of the echo method.
+func registerEcho(r *Router) *int32 {
+	calls := new(int32)
+	r.Register("test",
+		Method{Name: "echo", Public: true, Optional: []string{"censusId"},
+			Response: []string{"censusId"},
+			Handler: func(request RouterRequest) {
+				atomic.AddInt32(calls, 1)
+				if request.CensusID == "missing" {
+					r.SendErrorCode(request, api.ErrCodeNotFound, "census not found")
+					return
+				}
+				response := api.MetaResponse{CensusID: request.CensusID}
+				if err := request.Send(r.BuildReply(request, &response)); err != nil {
+					log.Warn(err)
+				}
+			}},
+		Method{Name: "private", Handler: func(request RouterRequest) {
+			if err := request.Send(r.BuildReply(request, &api.MetaResponse{})); err != nil {
+				log.Warn(err)
+			}
+		}},
+	)
+	return calls
+}
+
+// postJSONRPC sends body to the JSON-RPC handler, and checks the response is
+// signed by the router
+func postJSONRPC(t *testing.T, r *Router, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.jsonrpcHandler(w, httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body)))
+	if w.Code == http.StatusOK {
+		signature, err := hex.DecodeString(w.Header().Get(JSONRPCSignatureHeader))
+		qt.Assert(t, err, qt.IsNil)
+		addr, err := ethereum.AddrFromSignature(w.Body.Bytes(), signature)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, addr, qt.Equals, r.signer.Address())
+	}
+	return w
+}
+
+func TestJSONRPCCall(t *testing.T) {
+	r := newTestRouter(t)
+	registerEcho(r)
+	for _, tc := range []struct {
+		name      string
+		body      string
+		id        string
+		code      int
+		errorCode api.ErrorCode
+		censusID  string
+	}{
+		{
+			name:     "string id",
+			body:     `{"jsonrpc":"2.0","method":"echo","params":{"censusId":"c1"},"id":"a"}`,
+			id:       `"a"`,
+			censusID: "c1",
+		},
+		{
+			name: "numeric id without params",
+			body: `{"jsonrpc":"2.0","method":"echo","id":7}`,
+			id:   "7",
+		},
+		{
+			name: "null params",
+			body: `{"jsonrpc":"2.0","method":"echo","params":null,"id":7}`,
+			id:   "7",
+		},
+		{
+			name: "invalid version",
+			body: `{"jsonrpc":"1.0","method":"echo","id":1}`,
+			id:   "1",
+			code: jsonrpcInvalidRequest,
+		},
+		{
+			name: "unknown method",
+			body: `{"jsonrpc":"2.0","method":"unknown","id":1}`,
+			id:   "1",
+			code: jsonrpcMethodNotFound,
+		},
+		{
+			name: "positional params",
+			body: `{"jsonrpc":"2.0","method":"echo","params":["c1"],"id":1}`,
+			id:   "1",
+			code: jsonrpcInvalidParams,
+		},
+		{
+			name: "invalid params",
+			body: `{"jsonrpc":"2.0","method":"echo","params":{"censusId":1},"id":1}`,
+			id:   "1",
+			code: jsonrpcInvalidParams,
+		},
+		{
+			name:      "method error",
+			body:      `{"jsonrpc":"2.0","method":"echo","params":{"censusId":"missing"},"id":1}`,
+			id:        "1",
+			code:      jsonrpcServerError,
+			errorCode: api.ErrCodeNotFound,
+		},
+		{
+			name:      "unsigned private method",
+			body:      `{"jsonrpc":"2.0","method":"private","id":1}`,
+			id:        "1",
+			code:      jsonrpcInvalidParams,
+			errorCode: api.ErrCodeInvalidRequest,
+		},
+		{
+			name: "parse error",
+			body: `{"jsonrpc":"2.0",`,
+			id:   "null",
+			code: jsonrpcParseError,
+		},
+	} {
+		w := postJSONRPC(t, r, tc.body)
+		qt.Assert(t, w.Code, qt.Equals, http.StatusOK, qt.Commentf("%s", tc.name))
+		var resp jsonrpcResponse
+		qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &resp), qt.IsNil)
+		qt.Assert(t, resp.JSONRPC, qt.Equals, JSONRPCVersion)
+		qt.Assert(t, string(resp.ID), qt.Equals, tc.id, qt.Commentf("%s", tc.name))
+		if tc.code != 0 {
+			qt.Assert(t, resp.Error, qt.Not(qt.IsNil), qt.Commentf("%s", tc.name))
+			qt.Assert(t, resp.Error.Code, qt.Equals, tc.code, qt.Commentf("%s", tc.name))
+			qt.Assert(t, resp.Result, qt.IsNil)
+			if tc.errorCode != "" {
+				qt.Assert(t, resp.Error.Data.ErrorCode, qt.Equals, tc.errorCode)
+			}
+			continue
+		}
+		qt.Assert(t, resp.Error, qt.IsNil, qt.Commentf("%s", tc.name))
+		var result api.MetaResponse
+		qt.Assert(t, json.Unmarshal(resp.Result, &result), qt.IsNil)
+		qt.Assert(t, result.Ok, qt.IsTrue)
+		qt.Assert(t, result.CensusID, qt.Equals, tc.censusID)
+	}
+}
+
+func TestJSONRPCNotifications(t *testing.T) {
+	r := newTestRouter(t)
+	calls := registerEcho(r)
+
+	// The notifications are called, but get no response, not even on errors
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"echo"}`,
+		`{"jsonrpc":"2.0","method":"echo","params":{"censusId":"missing"}}`,
+		`{"jsonrpc":"2.0","method":"unknown"}`,
+	} {
+		w := postJSONRPC(t, r, body)
+		qt.Assert(t, w.Code, qt.Equals, http.StatusNoContent, qt.Commentf("%s", body))
+		qt.Assert(t, w.Body.Len(), qt.Equals, 0)
+	}
+	qt.Assert(t, atomic.LoadInt32(calls), qt.Equals, int32(2))
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	r := newTestRouter(t)
+	calls := registerEcho(r)
+
+	// The responses keep the order of the requests, without the notifications
+	w := postJSONRPC(t, r, `[
+		{"jsonrpc":"2.0","method":"echo","params":{"censusId":"c1"},"id":1},
+		{"jsonrpc":"2.0","method":"echo","params":{"censusId":"c2"}},
+		1,
+		{"jsonrpc":"2.0","method":"unknown","id":3},
+		{"jsonrpc":"2.0","method":"echo","params":{"censusId":"c4"},"id":4}
+	]`)
+	qt.Assert(t, w.Code, qt.Equals, http.StatusOK)
+	var responses []jsonrpcResponse
+	qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &responses), qt.IsNil)
+	qt.Assert(t, responses, qt.HasLen, 4)
+	ids := []string{"1", "null", "3", "4"}
+	codes := []int{0, jsonrpcInvalidRequest, jsonrpcMethodNotFound, 0}
+	for i, resp := range responses {
+		qt.Assert(t, string(resp.ID), qt.Equals, ids[i])
+		if codes[i] != 0 {
+			qt.Assert(t, resp.Error.Code, qt.Equals, codes[i])
+		} else {
+			qt.Assert(t, resp.Error, qt.IsNil)
+		}
+	}
+	var result api.MetaResponse
+	qt.Assert(t, json.Unmarshal(responses[3].Result, &result), qt.IsNil)
+	qt.Assert(t, result.CensusID, qt.Equals, "c4")
+	qt.Assert(t, atomic.LoadInt32(calls), qt.Equals, int32(3))
+
+	// A batch of notifications gets no response
+	w = postJSONRPC(t, r, `[{"jsonrpc":"2.0","method":"echo"},{"jsonrpc":"2.0","method":"echo"}]`)
+	qt.Assert(t, w.Code, qt.Equals, http.StatusNoContent)
+
+	// The batch size is limited
+	batch := make([]string, jsonrpcMaxBatchSize+1)
+	for i := range batch {
+		batch[i] = `{"jsonrpc":"2.0","method":"echo","id":1}`
+	}
+	for _, body := range []string{"[]", "[" + strings.Join(batch, ",") + "]"} {
+		w = postJSONRPC(t, r, body)
+		var resp jsonrpcResponse
+		qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &resp), qt.IsNil)
+		qt.Assert(t, resp.Error.Code, qt.Equals, jsonrpcInvalidRequest)
+	}
+}
+
+func TestJSONRPCBatchConcurrency(t *testing.T) {
+	r := newTestRouter(t)
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	r.Register("test", Method{Name: "sleep", Public: true,
+		Optional: []string{"censusId"}, Response: []string{"censusId"},
+		Handler: func(request RouterRequest) {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			lock.Lock()
+			running--
+			lock.Unlock()
+			response := api.MetaResponse{CensusID: request.CensusID}
+			if err := request.Send(r.BuildReply(request, &response)); err != nil {
+				log.Warn(err)
+			}
+		}})
+	batch := make([]string, jsonrpcMaxBatchSize)
+	for i := range batch {
+		batch[i] = fmt.Sprintf(
+			`{"jsonrpc":"2.0","method":"sleep","params":{"censusId":"%d"},"id":%d}`, i, i)
+	}
+	w := postJSONRPC(t, r, "["+strings.Join(batch, ",")+"]")
+	qt.Assert(t, w.Code, qt.Equals, http.StatusOK)
+	var responses []jsonrpcResponse
+	qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &responses), qt.IsNil)
+	qt.Assert(t, responses, qt.HasLen, jsonrpcMaxBatchSize)
+	for i, resp := range responses {
+		qt.Assert(t, string(resp.ID), qt.Equals, strconv.Itoa(i))
+		var result api.MetaResponse
+		qt.Assert(t, json.Unmarshal(resp.Result, &result), qt.IsNil)
+		qt.Assert(t, result.CensusID, qt.Equals, strconv.Itoa(i))
+	}
+	qt.Assert(t, maxRunning <= batchConcurrency, qt.IsTrue, qt.Commentf("%d", maxRunning))
+}
+
+func TestJSONRPCHandler(t *testing.T) {
+	r := newTestRouter(t)
+	registerEcho(r)
+
+	w := httptest.NewRecorder()
+	r.jsonrpcHandler(w, httptest.NewRequest(http.MethodGet, "/jsonrpc", nil))
+	qt.Assert(t, w.Code, qt.Equals, http.StatusMethodNotAllowed)
+	qt.Assert(t, w.Header().Get("Allow"), qt.Equals, "POST")
+
+	post := func(body, signature string) jsonrpcResponse {
+		hr := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+		hr.Header.Set(JSONRPCSignatureHeader, signature)
+		w := httptest.NewRecorder()
+		r.jsonrpcHandler(w, hr)
+		var resp jsonrpcResponse
+		qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &resp), qt.IsNil)
+		return resp
+	}
+
+	// The request signature header must be hex encoded
+	resp := post(`{"jsonrpc":"2.0","method":"echo","id":1}`, "xyz")
+	qt.Assert(t, resp.Error.Code, qt.Equals, jsonrpcInvalidRequest)
+
+	// The signature of the body authenticates the private method calls
+	client := ethereum.NewSignKeys()
+	qt.Assert(t, client.Generate(), qt.IsNil)
+	body := `{"jsonrpc":"2.0","method":"private","id":1}`
+	signature, err := client.Sign([]byte(body))
+	qt.Assert(t, err, qt.IsNil)
+	resp = post(body, hex.EncodeToString(signature))
+	qt.Assert(t, resp.Error.Code, qt.Equals, jsonrpcServerError)
+	qt.Assert(t, resp.Error.Data.ErrorCode, qt.Equals, api.ErrCodeUnauthorized)
+	r.signer.AddAuthKey(client.Address())
+	resp = post(body, hex.EncodeToString(signature))
+	qt.Assert(t, resp.Error, qt.IsNil)
+	qt.Assert(t, resp.Result, qt.Not(qt.IsNil))
+
+	// A method not sending any response is an internal error
+	r.Register("test", Method{Name: "silent", Public: true, Handler: func(RouterRequest) {}})
+	resp = post(`{"jsonrpc":"2.0","method":"silent","id":1}`, "")
+	qt.Assert(t, resp.Error.Code, qt.Equals, jsonrpcInternalError)
+}
+
+func TestJSONRPCErrorCode(t *testing.T) {
+	for code, expected := range map[api.ErrorCode]int{
+		api.ErrCodeInvalidRequest: jsonrpcInvalidParams,
+		api.ErrCodeInternal:       jsonrpcInternalError,
+		api.ErrCodeNotFound:       jsonrpcServerError,
+		api.ErrCodeRateLimited:    jsonrpcServerError,
+	} {
+		qt.Assert(t, jsonrpcErrorCode(code), qt.Equals, expected, qt.Commentf("%s", code))
+	}
+	qt.Assert(t, jsonrpcID(json.RawMessage(`"a"`)), qt.Equals, "a")
+	qt.Assert(t, jsonrpcID(json.RawMessage(`7`)), qt.Equals, "7")
+	qt.Assert(t, jsonrpcID(nil), qt.Equals, "")
+}
@@ -20,9 +20,30 @@ var (
 		Name:      "public_reqs",
 		Help:      "The number of public requests processed",
 	}, []string{"method"})
+	// RouterThrottledReqs ...
+	RouterThrottledReqs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "router",
+		Name:      "throttled_reqs",
+		Help:      "The number of requests rejected by the rate limiter",
+	}, []string{"class"})
+	// RouterCacheHits ...
+	RouterCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "router",
+		Name:      "cache_hits",
+		Help:      "The number of requests served from the response cache",
+	}, []string{"method"})
+	// RouterCacheMisses ...
+	RouterCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "router",
+		Name:      "cache_misses",
+		Help:      "The number of cacheable requests not found on the response cache",
+	}, []string{"method"})
 )
 
 func (r *Router) RegisterMetrics(ma *metrics.Agent) {
 	ma.Register(RouterPrivateReqs)
 	ma.Register(RouterPublicReqs)
+	ma.Register(RouterThrottledReqs)
+	ma.Register(RouterCacheHits)
+	ma.Register(RouterCacheMisses)
 }
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// OpenAPI 3.0 document, limited to the fields used to describe the REST API
+
+type openAPIDoc struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    openAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description"`
+	Required    bool          `json:"required"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type string `json:"type"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// buildOpenAPI generates the OpenAPI document of the REST routes served under prefix
+func buildOpenAPI(prefix string, routes []restRoute) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title: "Vocdoni gateway REST API",
+			Description: "Read-only access to the gateway API methods. The body is the " +
+				"API response, signed by the gateway keys; the signature is returned " +
+				"on the " + RESTSignatureHeader + " header.",
+			Version: RESTVersion,
+		},
+		Paths: make(map[string]map[string]openAPIOperation),
+	}
+	responses := map[string]openAPIResponse{}
+	for _, status := range []int{
+		http.StatusOK,
+		http.StatusNotModified,
+		http.StatusBadRequest,
+		http.StatusNotFound,
+		http.StatusInternalServerError,
+	} {
+		responses[strconv.Itoa(status)] = openAPIResponse{Description: http.StatusText(status)}
+	}
+	for _, rr := range routes {
+		op := openAPIOperation{
+			OperationID: rr.method,
+			Summary:     rr.summary,
+			Responses:   responses,
+		}
+		for _, p := range rr.params {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:        p.name,
+				In:          p.in,
+				Description: p.description,
+				Required:    p.in == "path",
+				Schema:      openAPISchema{Type: p.kind},
+			})
+		}
+		doc.Paths[prefix+rr.path] = map[string]openAPIOperation{"get": op}
+	}
+	return doc
+}
@@ -0,0 +1,238 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/multirpc/transports"
+	"go.vocdoni.io/dvote/multirpc/transports/mhttp"
+)
+
+// Method classes of the rate limiter. Each class has its own quota, so
+// heavy read clients do not exhaust the vote submission quota.
+const (
+	RateClassReads  = "reads"
+	RateClassVotes  = "votes"
+	RateClassCensus = "census"
+	RateClassFiles  = "files"
+)
+
+// rateLimitIdle is the time after which the bucket of an idle client is deleted
+const rateLimitIdle = 10 * time.Minute
+
+// rateClasses maps the methods that are not reads to their class
+var rateClasses = map[string]string{
+	"submitEnvelope": RateClassVotes,
+	"submitRawTx":    RateClassVotes,
+	"addCensus":      RateClassCensus,
+	"addClaim":       RateClassCensus,
+	"addClaimBulk":   RateClassCensus,
+	"publish":        RateClassCensus,
+	"importRemote":   RateClassCensus,
+	"addFile":        RateClassFiles,
+	"pinFile":        RateClassFiles,
+	"unpinFile":      RateClassFiles,
+}
+
+// RateClass returns the rate limiter class of a router method
+func RateClass(method string) string {
+	if class, ok := rateClasses[method]; ok {
+		return class
+	}
+	return RateClassReads
+}
+
+// tokenBucket holds up to quota tokens, refilled at quota tokens per minute
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter limits the number of requests of each method class that a client,
+// identified by its remote IP and by its signing address, can make per minute.
+type RateLimiter struct {
+	// perMinute is the quota of each method class, classes without quota
+	// are not limited
+	perMinute map[string]int
+	allowList map[string]bool
+	buckets   map[string]*tokenBucket
+	lastPurge time.Time
+	lock      sync.Mutex
+}
+
+// NewRateLimiter creates a rate limiter with the per minute quota of each method
+// class. The clients on the allow list (IP addresses or Ethereum addresses) are
+// not limited.
+func NewRateLimiter(perMinute map[string]int, allowList []string) *RateLimiter {
+	rl := &RateLimiter{
+		perMinute: perMinute,
+		allowList: make(map[string]bool),
+		buckets:   make(map[string]*tokenBucket),
+		lastPurge: time.Now(),
+	}
+	for _, c := range allowList {
+		c = strings.TrimSpace(c)
+		if ethcommon.IsHexAddress(c) {
+			c = ethcommon.HexToAddress(c).Hex()
+		}
+		if c != "" {
+			rl.allowList[c] = true
+		}
+	}
+	return rl
+}
+
+// Allow returns false if the client exceeded the quota for the method class.
+// The request counts both against the remote IP and the signing address quota,
+// if any of them is known. The tokens are only taken if both quotas allow it.
+func (rl *RateLimiter) Allow(method, ip string, addr ethcommon.Address) bool {
+	class := RateClass(method)
+	quota := rl.perMinute[class]
+	if quota <= 0 {
+		return true
+	}
+	var clients []string
+	if ip != "" {
+		clients = append(clients, ip)
+	}
+	if addr != (ethcommon.Address{}) {
+		clients = append(clients, addr.Hex())
+	}
+	for _, c := range clients {
+		if rl.allowList[c] {
+			return true
+		}
+	}
+
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	now := time.Now()
+	if now.Sub(rl.lastPurge) > rateLimitIdle {
+		rl.purge(now)
+	}
+	buckets := make([]*tokenBucket, len(clients))
+	for i, c := range clients {
+		buckets[i] = rl.refill(class+"/"+c, quota, now)
+		if buckets[i].tokens < 1 {
+			return false
+		}
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true
+}
+
+// Exhausted returns true if the remote IP has no quota left for the method class,
+// without taking a token. It allows rejecting the requests before doing any work
+// on them, such as recovering their signer.
+func (rl *RateLimiter) Exhausted(method, ip string) bool {
+	class := RateClass(method)
+	quota := rl.perMinute[class]
+	if quota <= 0 || ip == "" || rl.allowList[ip] {
+		return false
+	}
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	b, ok := rl.buckets[class+"/"+ip]
+	if !ok {
+		return false
+	}
+	return b.tokens+time.Since(b.last).Minutes()*float64(quota) < 1
+}
+
+// refill returns the bucket of the client, refilled up to now
+func (rl *RateLimiter) refill(key string, quota int, now time.Time) *tokenBucket {
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(quota), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Minutes() * float64(quota)
+	if b.tokens > float64(quota) {
+		b.tokens = float64(quota)
+	}
+	b.last = now
+	return b
+}
+
+// purge deletes the buckets of the clients idle for rateLimitIdle, they are
+// full again and would be created as new
+func (rl *RateLimiter) purge(now time.Time) {
+	for k, b := range rl.buckets {
+		if now.Sub(b.last) > rateLimitIdle {
+			delete(rl.buckets, k)
+		}
+	}
+	rl.lastPurge = now
+}
+
+// errRateLimited is returned by authenticate if the client IP has no quota left,
+// so the request signer is not recovered
+var errRateLimited = errors.New("rate limit exceeded")
+
+// throttled returns true if the request exceeds the client quota
+func (r *Router) throttled(request RouterRequest, ip string) bool {
+	if r.limiter == nil || r.limiter.Allow(request.method, ip, request.address) {
+		return false
+	}
+	r.countThrottled(request)
+	return true
+}
+
+// exhausted returns true if the request remote IP has no quota left. It is
+// checked before recovering the request signer, which is expensive.
+func (r *Router) exhausted(request RouterRequest) bool {
+	if r.limiter == nil || !r.limiter.Exhausted(request.method, remoteIP(request.MessageContext)) {
+		return false
+	}
+	r.countThrottled(request)
+	return true
+}
+
+func (r *Router) countThrottled(request RouterRequest) {
+	if r.metricsagent != nil {
+		RouterThrottledReqs.With(prometheus.Labels{"class": RateClass(request.method)}).Inc()
+	}
+}
+
+// sendRateLimited sends the rateLimited error, with the HTTP 429 status on
+// HTTP requests
+func (r *Router) sendRateLimited(request RouterRequest) {
+	if hc, ok := request.MessageContext.(*mhttp.HttpContext); ok {
+		hc.Writer.Header().Set("Retry-After", "60")
+		hc.StatusCode = http.StatusTooManyRequests
+	}
+	r.SendErrorCode(request, api.ErrCodeRateLimited,
+		fmt.Sprintf("rate limit exceeded for %s requests", RateClass(request.method)))
+}
+
+// remoteIP returns the IP address of the client of a request, or an empty
+// string if the transport does not provide it
+func remoteIP(ctx transports.MessageContext) string {
+	var addr string
+	switch c := ctx.(type) {
+	case *mhttp.HttpContext:
+		addr = c.Request.RemoteAddr
+	case *mhttp.WebsocketContext:
+		addr = c.RemoteAddr
+	case *jsonrpcContext:
+		addr = c.remoteAddr
+	case *batchContext:
+		return remoteIP(c.parent)
+	default:
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,137 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/multirpc/transports/mhttp"
+)
+
+func TestRateClass(t *testing.T) {
+	for method, class := range map[string]string{
+		"submitEnvelope": RateClassVotes,
+		"addClaimBulk":   RateClassCensus,
+		"pinFile":        RateClassFiles,
+		"getProcessInfo": RateClassReads,
+		"unknownMethod":  RateClassReads,
+	} {
+		qt.Assert(t, RateClass(method), qt.Equals, class, qt.Commentf("%s", method))
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	addr := ethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	allowed := ethcommon.HexToAddress("0x1000000000000000000000000000000000000002")
+	rl := NewRateLimiter(map[string]int{RateClassReads: 2, RateClassVotes: 1},
+		[]string{" 10.0.0.1 ", allowed.Hex()})
+	for _, tc := range []struct {
+		name   string
+		method string
+		ip     string
+		addr   ethcommon.Address
+		allow  bool
+	}{
+		{"first read", "getBlockHeight", "10.0.0.2", ethcommon.Address{}, true},
+		{"second read", "getBlockHeight", "10.0.0.2", ethcommon.Address{}, true},
+		{"read quota exhausted", "getProcessInfo", "10.0.0.2", ethcommon.Address{}, false},
+		{"each class has its own quota", "submitEnvelope", "10.0.0.2", ethcommon.Address{}, true},
+		{"vote quota exhausted", "submitEnvelope", "10.0.0.2", ethcommon.Address{}, false},
+		{"classes without quota", "addFile", "10.0.0.2", ethcommon.Address{}, true},
+		{"each ip has its own quota", "getBlockHeight", "10.0.0.3", ethcommon.Address{}, true},
+		{"the address quota", "submitEnvelope", "10.0.0.4", addr, true},
+		{"the address is limited from any ip", "submitEnvelope", "10.0.0.5", addr, false},
+		{"the address quota without ip", "submitEnvelope", "", addr, false},
+		{"allowed ip", "submitEnvelope", "10.0.0.1", addr, true},
+		{"allowed address", "submitEnvelope", "10.0.0.2", allowed, true},
+		{"unknown clients", "submitEnvelope", "", ethcommon.Address{}, true},
+	} {
+		qt.Assert(t, rl.Allow(tc.method, tc.ip, tc.addr), qt.Equals, tc.allow,
+			qt.Commentf("%s", tc.name))
+	}
+}
+
+func TestRateLimiterBothQuotas(t *testing.T) {
+	addr := ethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	rl := NewRateLimiter(map[string]int{RateClassVotes: 1}, nil)
+	qt.Assert(t, rl.Allow("submitEnvelope", "10.0.0.1", addr), qt.IsTrue)
+	// The address quota is exhausted, so the request from another ip does not
+	// take the token of that ip
+	qt.Assert(t, rl.Allow("submitEnvelope", "10.0.0.2", addr), qt.IsFalse)
+	qt.Assert(t, rl.Allow("submitEnvelope", "10.0.0.2", ethcommon.Address{}), qt.IsTrue)
+}
+
+func TestRateLimiterExhausted(t *testing.T) {
+	rl := NewRateLimiter(map[string]int{RateClassVotes: 1}, []string{"10.0.0.9"})
+	qt.Assert(t, rl.Exhausted("submitEnvelope", "10.0.0.1"), qt.IsFalse)
+	qt.Assert(t, rl.Allow("submitEnvelope", "10.0.0.1", ethcommon.Address{}), qt.IsTrue)
+	qt.Assert(t, rl.Exhausted("submitEnvelope", "10.0.0.1"), qt.IsTrue)
+	// checking it does not take tokens from other classes or ips
+	qt.Assert(t, rl.Exhausted("getBlockHeight", "10.0.0.1"), qt.IsFalse)
+	qt.Assert(t, rl.Exhausted("submitEnvelope", "10.0.0.2"), qt.IsFalse)
+	qt.Assert(t, rl.Allow("submitEnvelope", "10.0.0.2", ethcommon.Address{}), qt.IsTrue)
+	qt.Assert(t, rl.Exhausted("submitEnvelope", "10.0.0.9"), qt.IsFalse)
+
+	// The exhausted ips are rejected before recovering the request signer
+	r := newTestRouter(t)
+	registerEcho(r)
+	r.SetRateLimiter(NewRateLimiter(map[string]int{RateClassReads: 1}, nil))
+	qt.Assert(t, r.limiter.Allow("echo", "10.0.0.1", ethcommon.Address{}), qt.IsTrue)
+	ctx := &jsonrpcContext{remoteAddr: "10.0.0.1:80"}
+	request := RouterRequest{MetaRequest: api.MetaRequest{Method: "echo"}, MessageContext: ctx}
+	err := r.authenticate(&request, []byte("{}"), []byte("invalid signature"), nil)
+	qt.Assert(t, err, qt.Equals, errRateLimited)
+	r.dispatch(request, err)
+	qt.Assert(t, replyOf(t, ctx).ErrorCode, qt.Equals, api.ErrCodeRateLimited)
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl := NewRateLimiter(nil, nil)
+	now := time.Now()
+	b := rl.refill("reads/ip", 3, now)
+	qt.Assert(t, b.tokens, qt.Equals, float64(3))
+	b.tokens = 0
+
+	// The bucket is refilled at quota tokens per minute
+	now = now.Add(20 * time.Second)
+	qt.Assert(t, rl.refill("reads/ip", 3, now).tokens, qt.Equals, float64(1))
+
+	// and it never holds more than quota tokens
+	now = now.Add(time.Hour)
+	qt.Assert(t, rl.refill("reads/ip", 3, now).tokens, qt.Equals, float64(3))
+	qt.Assert(t, rl.buckets, qt.HasLen, 1)
+}
+
+func TestRateLimiterPurge(t *testing.T) {
+	rl := NewRateLimiter(nil, nil)
+	now := time.Now()
+	rl.refill("reads/idle", 1, now)
+	rl.refill("reads/active", 1, now.Add(rateLimitIdle))
+	rl.purge(now.Add(rateLimitIdle + time.Second))
+	qt.Assert(t, rl.buckets, qt.HasLen, 1)
+	qt.Assert(t, rl.buckets["reads/active"], qt.Not(qt.IsNil))
+	qt.Assert(t, rl.lastPurge, qt.Equals, now.Add(rateLimitIdle+time.Second))
+
+	// Allow purges the idle buckets once every rateLimitIdle
+	rl = NewRateLimiter(map[string]int{RateClassReads: 1}, nil)
+	qt.Assert(t, rl.Allow("getBlockHeight", "10.0.0.1", ethcommon.Address{}), qt.IsTrue)
+	rl.buckets["reads/10.0.0.1"].last = now.Add(-2 * rateLimitIdle)
+	qt.Assert(t, rl.Allow("getBlockHeight", "10.0.0.2", ethcommon.Address{}), qt.IsTrue)
+	qt.Assert(t, rl.buckets, qt.HasLen, 2)
+	rl.lastPurge = now.Add(-2 * rateLimitIdle)
+	qt.Assert(t, rl.Allow("getBlockHeight", "10.0.0.2", ethcommon.Address{}), qt.IsFalse)
+	qt.Assert(t, rl.buckets, qt.HasLen, 1)
+}
+
+func TestRemoteIP(t *testing.T) {
+	hr := restRequest("/", nil)
+	hr.RemoteAddr = "10.0.0.1:4321"
+	hc := &mhttp.HttpContext{Request: hr}
+	qt.Assert(t, remoteIP(hc), qt.Equals, "10.0.0.1")
+	qt.Assert(t, remoteIP(&jsonrpcContext{remoteAddr: "[::1]:80"}), qt.Equals, "::1")
+	qt.Assert(t, remoteIP(&jsonrpcContext{remoteAddr: "10.0.0.2"}), qt.Equals, "10.0.0.2")
+	qt.Assert(t, remoteIP(&batchContext{parent: hc}), qt.Equals, "10.0.0.1")
+	qt.Assert(t, remoteIP(&restContext{}), qt.Equals, "")
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"go.vocdoni.io/dvote/api"
+)
+
+// ReplayGuard rejects the signed requests whose timestamp is outside a time
+// window, or that were already received. The outer request ID is not signed,
+// so the requests are identified by the hash of the signed request.
+type ReplayGuard struct {
+	window time.Duration
+	// seen maps the received requests to the time their timestamp expires
+	seen      map[[sha256.Size]byte]time.Time
+	lastPurge time.Time
+	lock      sync.Mutex
+}
+
+// NewReplayGuard creates a replay guard accepting the request timestamps up to
+// window away from the current time.
+func NewReplayGuard(window time.Duration) *ReplayGuard {
+	return &ReplayGuard{
+		window:    window,
+		seen:      make(map[[sha256.Size]byte]time.Time),
+		lastPurge: time.Now(),
+	}
+}
+
+// Check returns an error with the replayedRequest code if the timestamp is not
+// valid or the signed request was already received. Otherwise, the request is
+// marked as received until its timestamp expires.
+func (g *ReplayGuard) Check(timestamp int32, signedRequest []byte) error {
+	// timestamps have a resolution of seconds
+	now := time.Now()
+	window := int64(g.window / time.Second)
+	if ts := int64(timestamp); ts < now.Unix()-window || ts > now.Unix()+window {
+		return api.NewError(api.ErrCodeReplayedRequest,
+			"request timestamp %d is not within %s of the gateway time %d",
+			timestamp, g.window, now.Unix())
+	}
+	key := sha256.Sum256(signedRequest)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if now.Sub(g.lastPurge) > g.window {
+		for k, expires := range g.seen {
+			if now.After(expires) {
+				delete(g.seen, k)
+			}
+		}
+		g.lastPurge = now
+	}
+	if _, ok := g.seen[key]; ok {
+		return api.NewError(api.ErrCodeReplayedRequest, "request already received")
+	}
+	g.seen[key] = time.Unix(int64(timestamp)+window+1, 0)
+	return nil
+}
+
+// SetReplayGuard checks the signed requests with g. If g is nil, the signed
+// requests are not checked for replays.
+func (r *Router) SetReplayGuard(g *ReplayGuard) {
+	r.replay = g
+}
+
+// requestErrorCode returns the API error code of a request that could not be
+// parsed or authenticated
+func requestErrorCode(err error) api.ErrorCode {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return api.ErrCodeInvalidRequest
+}
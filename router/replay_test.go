@@ -0,0 +1,101 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+)
+
+func TestReplayGuardCheck(t *testing.T) {
+	g := NewReplayGuard(10 * time.Second)
+	now := int32(time.Now().Unix())
+	for _, tc := range []struct {
+		name      string
+		timestamp int32
+		request   string
+		err       string
+	}{
+		{"current", now, "a", ""},
+		{"duplicate", now, "a", "request already received"},
+		{"other request", now, "b", ""},
+		{"past within the window", now - 9, "c", ""},
+		{"future within the window", now + 9, "d", ""},
+		{"past", now - 20, "e", "request timestamp .* is not within 10s of the gateway time .*"},
+		{"future", now + 20, "f", "request timestamp .* is not within 10s of the gateway time .*"},
+		{"rejected requests are not marked", now, "e", ""},
+	} {
+		err := g.Check(tc.timestamp, []byte(tc.request))
+		if tc.err == "" {
+			qt.Assert(t, err, qt.IsNil, qt.Commentf("%s", tc.name))
+			continue
+		}
+		qt.Assert(t, err, qt.ErrorMatches, tc.err, qt.Commentf("%s", tc.name))
+		qt.Assert(t, requestErrorCode(err), qt.Equals, api.ErrCodeReplayedRequest)
+	}
+}
+
+func TestReplayGuardPurge(t *testing.T) {
+	g := NewReplayGuard(time.Second)
+	now := int32(time.Now().Unix())
+	qt.Assert(t, g.Check(now, []byte("a")), qt.IsNil)
+	qt.Assert(t, g.seen, qt.HasLen, 1)
+
+	// The requests are forgotten once their timestamp is out of the window,
+	// when the expired requests are purged
+	expired := time.Now().Add(-time.Minute)
+	for k := range g.seen {
+		g.seen[k] = expired
+	}
+	qt.Assert(t, g.Check(now, []byte("b")), qt.IsNil)
+	qt.Assert(t, g.seen, qt.HasLen, 2)
+	g.lastPurge = expired
+	qt.Assert(t, g.Check(now, []byte("c")), qt.IsNil)
+	qt.Assert(t, g.seen, qt.HasLen, 2)
+	qt.Assert(t, g.Check(now, []byte("a")), qt.IsNil)
+}
+
+func TestRequestErrorCode(t *testing.T) {
+	qt.Assert(t, requestErrorCode(fmt.Errorf("method is empty")), qt.Equals,
+		api.ErrCodeInvalidRequest)
+	err := api.NewError(api.ErrCodeReplayedRequest, "request already received")
+	qt.Assert(t, requestErrorCode(fmt.Errorf("auth: %w", err)), qt.Equals,
+		api.ErrCodeReplayedRequest)
+}
+
+func TestAuthenticateReplay(t *testing.T) {
+	r := newTestRouter(t)
+	r.SetReplayGuard(NewReplayGuard(time.Minute))
+	client := ethereum.NewSignKeys()
+	qt.Assert(t, client.Generate(), qt.IsNil)
+	signed := []byte(`{"method":"getInfo"}`)
+	signature, err := client.Sign(signed)
+	qt.Assert(t, err, qt.IsNil)
+	authenticate := func(signature []byte) (*RouterRequest, error) {
+		request := &RouterRequest{MetaRequest: api.MetaRequest{
+			Method:    "getInfo",
+			Timestamp: int32(time.Now().Unix()),
+		}}
+		return request, r.authenticate(request, signed, signature, signed)
+	}
+
+	request, err := authenticate(signature)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, request.authenticated, qt.IsTrue)
+	qt.Assert(t, request.address, qt.Equals, client.Address())
+
+	// The same signed request is rejected
+	request, err = authenticate(signature)
+	qt.Assert(t, err, qt.ErrorMatches, "request already received")
+	qt.Assert(t, request.authenticated, qt.IsFalse)
+
+	// The requests without signature are not checked
+	for i := 0; i < 2; i++ {
+		request, err = authenticate(nil)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, request.authenticated, qt.IsTrue)
+	}
+}
@@ -0,0 +1,373 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/multirpc/transports"
+	"go.vocdoni.io/dvote/multirpc/transports/mhttp"
+	"go.vocdoni.io/dvote/util"
+)
+
+const (
+	// RESTVersion is the path prefix of the REST API routes
+	RESTVersion = "v1"
+	// RESTSignatureHeader is the HTTP header carrying the signature of the
+	// returned response, made by the gateway keys, as on the /dvote endpoint
+	RESTSignatureHeader = "X-Vocdoni-Signature"
+)
+
+// restParam is a path or query parameter of a REST route, it sets the
+// corresponding field of the MetaRequest passed to the router method
+type restParam struct {
+	name        string
+	in          string // path or query
+	kind        string // OpenAPI schema type
+	description string
+	set         func(req *api.MetaRequest, value string) error
+}
+
+// restRoute maps a read-only REST endpoint to a public router method
+type restRoute struct {
+	path    string
+	method  string
+	summary string
+	params  []restParam
+	// maxAge is the number of seconds the response can be cached for
+	maxAge int
+}
+
+func hexParam(name, in, description string,
+	field func(req *api.MetaRequest) *[]byte) restParam {
+	return restParam{
+		name: name, in: in, kind: "string", description: description,
+		set: func(req *api.MetaRequest, value string) error {
+			b, err := hex.DecodeString(util.TrimHex(value))
+			if err != nil {
+				return fmt.Errorf("%s is not hex encoded", name)
+			}
+			*field(req) = b
+			return nil
+		},
+	}
+}
+
+func intParam(name, in, description string,
+	set func(req *api.MetaRequest, value int64)) restParam {
+	return restParam{
+		name: name, in: in, kind: "integer", description: description,
+		set: func(req *api.MetaRequest, value string) error {
+			n, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s is not a valid positive integer", name)
+			}
+			set(req, n)
+			return nil
+		},
+	}
+}
+
+func stringParam(name, description string,
+	set func(req *api.MetaRequest, value string)) restParam {
+	return restParam{
+		name: name, in: "query", kind: "string", description: description,
+		set: func(req *api.MetaRequest, value string) error {
+			set(req, value)
+			return nil
+		},
+	}
+}
+
+var (
+	restProcessID = hexParam("processId", "path", "hex encoded process identifier",
+		func(req *api.MetaRequest) *[]byte { return (*[]byte)(&req.ProcessID) })
+	restNullifier = hexParam("nullifier", "path", "hex encoded vote envelope nullifier",
+		func(req *api.MetaRequest) *[]byte { return (*[]byte)(&req.Nullifier) })
+	restEntityID = hexParam("entityId", "query", "hex encoded entity identifier",
+		func(req *api.MetaRequest) *[]byte { return (*[]byte)(&req.EntityId) })
+	restHeight = intParam("height", "path", "block height",
+		func(req *api.MetaRequest, v int64) { req.Height = uint32(v) })
+	restTxIndex = intParam("txIndex", "path", "index of the transaction in the block",
+		func(req *api.MetaRequest, v int64) { req.TxIndex = int32(v) })
+	restFrom = intParam("from", "query", "index of the first item to return",
+		func(req *api.MetaRequest, v int64) { req.From = int(v) })
+	restListSize = intParam("listSize", "query",
+		fmt.Sprintf("maximum number of items to return (up to %d)", MaxListSize),
+		func(req *api.MetaRequest, v int64) { req.ListSize = int(v) })
+	restSearchTerm = stringParam("searchTerm", "full or partial hex identifier to search for",
+		func(req *api.MetaRequest, v string) { req.SearchTerm = v })
+	restProcessStatus = stringParam("status",
+		"process status (READY, ENDED, CANCELED, PAUSED, RESULTS)",
+		func(req *api.MetaRequest, v string) { req.Status = v })
+)
+
+// restRoutes are the REST endpoints, they are only served if the router
+// method they map to is registered as public
+var restRoutes = []restRoute{
+	{
+		path: "/info", method: "getInfo", maxAge: 60,
+		summary: "Gateway information and enabled APIs",
+	},
+	{
+		path: "/methods", method: "getMethods", maxAge: 60,
+		summary: "Registered API methods and their request and response fields",
+	},
+	{
+		path: "/stats", method: "getStats", maxAge: 10,
+		summary: "Vochain statistics",
+	},
+	{
+		path: "/height", method: "getBlockHeight", maxAge: 5,
+		summary: "Current Vochain block height",
+	},
+	{
+		path: "/blocks/{height}", method: "getBlock", maxAge: 3600,
+		summary: "Block by height",
+		params:  []restParam{restHeight},
+	},
+	{
+		path: "/blocks/{height}/transactions", method: "getTxListForBlock", maxAge: 3600,
+		summary: "Transactions of a block",
+		params:  []restParam{restHeight, restFrom, restListSize},
+	},
+	{
+		path: "/blocks/{height}/transactions/{txIndex}", method: "getTx", maxAge: 3600,
+		summary: "Transaction by block height and index",
+		params:  []restParam{restHeight, restTxIndex},
+	},
+	{
+		path: "/entities", method: "getEntityList", maxAge: 10,
+		summary: "Known entities",
+		params:  []restParam{restSearchTerm, restFrom, restListSize},
+	},
+	{
+		path: "/processes", method: "getProcessList", maxAge: 10,
+		summary: "Processes, optionally filtered by entity and status",
+		params: []restParam{
+			restEntityID, restSearchTerm, restProcessStatus, restFrom, restListSize,
+		},
+	},
+	{
+		path: "/processes/{processId}", method: "getProcessInfo", maxAge: 10,
+		summary: "Process information",
+		params:  []restParam{restProcessID},
+	},
+	{
+		path: "/processes/{processId}/summary", method: "getProcessSummary", maxAge: 10,
+		summary: "Process summary",
+		params:  []restParam{restProcessID},
+	},
+	{
+		path: "/processes/{processId}/results", method: "getResults", maxAge: 10,
+		summary: "Process results",
+		params:  []restParam{restProcessID},
+	},
+	{
+		path: "/processes/{processId}/weight", method: "getResultsWeight", maxAge: 10,
+		summary: "Process results weight",
+		params:  []restParam{restProcessID},
+	},
+	{
+		path: "/processes/{processId}/keys", method: "getProcessKeys", maxAge: 10,
+		summary: "Process encryption keys",
+		params:  []restParam{restProcessID},
+	},
+	{
+		path: "/processes/{processId}/envelopes", method: "getEnvelopeList", maxAge: 10,
+		summary: "Vote envelopes of a process",
+		params:  []restParam{restProcessID, restSearchTerm, restFrom, restListSize},
+	},
+	{
+		path: "/processes/{processId}/envelopes/count", method: "getEnvelopeHeight", maxAge: 10,
+		summary: "Number of vote envelopes of a process",
+		params:  []restParam{restProcessID},
+	},
+	{
+		path: "/envelopes/{nullifier}", method: "getEnvelope", maxAge: 3600,
+		summary: "Vote envelope by nullifier",
+		params:  []restParam{restNullifier},
+	},
+}
+
+// restContext is the MessageContext of the REST requests, it keeps the
+// first response sent by the router method
+type restContext struct {
+	data []byte
+}
+
+func (c *restContext) ConnectionType() string {
+	return "REST"
+}
+
+func (c *restContext) Send(msg transports.Message) error {
+	if c.data == nil {
+		c.data = msg.Data
+	}
+	return nil
+}
+
+// EnableREST serves the read-only REST API on the proxy, under route+RESTVersion.
+// The REST endpoints call the same public router methods as the /dvote
+// endpoint, so the APIs providing them must be enabled first. The OpenAPI
+// document of the enabled endpoints is served at route+RESTVersion/openapi.json
+func (r *Router) EnableREST(pxy *mhttp.Proxy, route string) {
+	prefix := route + RESTVersion
+	var enabled []restRoute
+	for _, rr := range restRoutes {
+		method, ok := r.methods[rr.method]
+		if !ok || !method.public {
+			continue
+		}
+		pxy.AddHandler(prefix+rr.path, r.restHandler(rr, method.handler))
+		enabled = append(enabled, rr)
+	}
+	doc, err := json.Marshal(buildOpenAPI(prefix, enabled))
+	if err != nil {
+		log.Fatal(err)
+	}
+	pxy.AddHandler(prefix+"/openapi.json", func(w http.ResponseWriter, hr *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(doc); err != nil {
+			log.Warnf("error sending openapi document: %v", err)
+		}
+	})
+	log.Infof("REST API available at %s (%d endpoints)", prefix, len(enabled))
+}
+
+func (r *Router) restHandler(rr restRoute, handler func(RouterRequest)) http.HandlerFunc {
+	return func(w http.ResponseWriter, hr *http.Request) {
+		if hr.Method != http.MethodGet && hr.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := &restContext{}
+		request := RouterRequest{
+			MetaRequest: api.MetaRequest{
+				Method:    rr.method,
+				Timestamp: int32(time.Now().Unix()),
+			},
+			MessageContext: ctx,
+			method:         rr.method,
+			id:             util.RandomHex(16),
+		}
+		if err := rr.parse(hr, &request.MetaRequest); err != nil {
+			r.SendErrorCode(request, api.ErrCodeInvalidRequest, err.Error())
+		} else if r.throttled(request, remoteIP(&mhttp.HttpContext{Request: hr})) {
+			w.Header().Set("Retry-After", "60")
+			r.sendRateLimited(request)
+		} else {
+			log.Debugf("rest query %s", request.MetaRequest.String())
+			atomic.AddUint64(&r.PublicCalls, 1)
+			if r.metricsagent != nil {
+				RouterPublicReqs.With(prometheus.Labels{"method": rr.method}).Inc()
+			}
+			r.handle(request, handler)
+		}
+		writeRESTResponse(w, hr, rr.maxAge, ctx.data)
+	}
+}
+
+// parse sets the MetaRequest fields from the path and query parameters
+func (rr *restRoute) parse(hr *http.Request, req *api.MetaRequest) error {
+	query := hr.URL.Query()
+	for _, p := range rr.params {
+		var value string
+		if p.in == "path" {
+			value = chi.URLParam(hr, p.name)
+		} else {
+			value = query.Get(p.name)
+		}
+		if value == "" {
+			continue
+		}
+		if err := p.set(req, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRESTResponse writes the signed inner response produced by the router
+// method. The response ETag ignores the request ID and timestamp, so clients
+// and proxies can revalidate cached responses.
+func writeRESTResponse(w http.ResponseWriter, hr *http.Request, maxAge int, data []byte) {
+	var outer api.ResponseMessage
+	if err := json.Unmarshal(data, &outer); err != nil || len(outer.MetaResponse) == 0 {
+		http.Error(w, "no response from the router", http.StatusInternalServerError)
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(outer.MetaResponse, &fields); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var ok bool
+	var code api.ErrorCode
+	// both fields are always set by BuildReply and SendErrorCode
+	_ = json.Unmarshal(fields["ok"], &ok)
+	if raw, found := fields["errorCode"]; found {
+		_ = json.Unmarshal(raw, &code)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(RESTSignatureHeader, hex.EncodeToString(outer.Signature))
+	status := http.StatusOK
+	if ok {
+		delete(fields, "request")
+		delete(fields, "timestamp")
+		content, err := json.Marshal(fields)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hash := sha256.Sum256(content)
+		etag := fmt.Sprintf("%q", hex.EncodeToString(hash[:16]))
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+		if hr.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+		status = httpStatus(code)
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(outer.MetaResponse); err != nil {
+		log.Warnf("error sending rest response: %v", err)
+	}
+}
+
+// httpStatus returns the HTTP status code of an API error code
+func httpStatus(code api.ErrorCode) int {
+	switch code {
+	case api.ErrCodeInvalidRequest, api.ErrCodeInvalidCensusProof, api.ErrCodeReplayedRequest:
+		return http.StatusBadRequest
+	case api.ErrCodeNotFound:
+		return http.StatusNotFound
+	case api.ErrCodeUnauthorized:
+		return http.StatusForbidden
+	case api.ErrCodeProcessNotActive, api.ErrCodeDuplicateVote:
+		return http.StatusConflict
+	case api.ErrCodeRateLimited:
+		return http.StatusTooManyRequests
+	case api.ErrCodeMempoolFull, api.ErrCodeTxDropped:
+		return http.StatusServiceUnavailable
+	case api.ErrCodeTxTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,185 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/go-chi/chi"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+)
+
+// newTestRouter returns a router signing its responses with a new key
+func newTestRouter(t *testing.T) *Router {
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	return NewRouter(nil, nil, signer, nil, false)
+}
+
+// restRequest returns a GET request of path, with the given chi path parameters
+func restRequest(path string, params map[string]string) *http.Request {
+	hr := httptest.NewRequest(http.MethodGet, path, nil)
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	return hr.WithContext(context.WithValue(hr.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestRESTParse(t *testing.T) {
+	route := restRoute{params: []restParam{
+		restProcessID, restHeight, restEntityID, restFrom, restListSize, restProcessStatus,
+	}}
+	for _, tc := range []struct {
+		name   string
+		path   string
+		params map[string]string
+		err    string
+		check  func(req *api.MetaRequest)
+	}{
+		{
+			name:   "all",
+			path:   "/?entityId=0xabcd&from=5&listSize=10&status=READY",
+			params: map[string]string{"processId": "0102", "height": "42"},
+			check: func(req *api.MetaRequest) {
+				qt.Assert(t, []byte(req.ProcessID), qt.DeepEquals, []byte{1, 2})
+				qt.Assert(t, []byte(req.EntityId), qt.DeepEquals, []byte{0xab, 0xcd})
+				qt.Assert(t, req.Height, qt.Equals, uint32(42))
+				qt.Assert(t, req.From, qt.Equals, 5)
+				qt.Assert(t, req.ListSize, qt.Equals, 10)
+				qt.Assert(t, req.Status, qt.Equals, "READY")
+			},
+		},
+		{
+			name: "missing parameters are not set",
+			path: "/",
+			check: func(req *api.MetaRequest) {
+				qt.Assert(t, req.ProcessID, qt.IsNil)
+				qt.Assert(t, req.ListSize, qt.Equals, 0)
+			},
+		},
+		{
+			name:   "invalid hex",
+			path:   "/",
+			params: map[string]string{"processId": "xyz"},
+			err:    "processId is not hex encoded",
+		},
+		{
+			name: "negative integer",
+			path: "/?from=-1",
+			err:  "from is not a valid positive integer",
+		},
+		{
+			name:   "integer overflow",
+			path:   "/",
+			params: map[string]string{"height": "4294967296"},
+			err:    "height is not a valid positive integer",
+		},
+	} {
+		var req api.MetaRequest
+		err := route.parse(restRequest(tc.path, tc.params), &req)
+		if tc.err != "" {
+			qt.Assert(t, err, qt.ErrorMatches, tc.err, qt.Commentf("%s", tc.name))
+			continue
+		}
+		qt.Assert(t, err, qt.IsNil, qt.Commentf("%s", tc.name))
+		tc.check(&req)
+	}
+}
+
+func TestRESTHandler(t *testing.T) {
+	r := newTestRouter(t)
+	height := uint32(10)
+	handler := r.restHandler(restRoute{method: "getBlockHeight", maxAge: 5},
+		func(request RouterRequest) {
+			if err := request.Send(r.BuildReply(request,
+				&api.MetaResponse{Height: &height})); err != nil {
+				t.Error(err)
+			}
+		})
+	get := func(etag string) *httptest.ResponseRecorder {
+		hr := restRequest("/v1/height", nil)
+		if etag != "" {
+			hr.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler(w, hr)
+		return w
+	}
+
+	w := get("")
+	qt.Assert(t, w.Code, qt.Equals, http.StatusOK)
+	qt.Assert(t, w.Header().Get("Cache-Control"), qt.Equals, "public, max-age=5")
+	qt.Assert(t, w.Header().Get(RESTSignatureHeader), qt.Not(qt.Equals), "")
+	var resp api.MetaResponse
+	qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &resp), qt.IsNil)
+	qt.Assert(t, *resp.Height, qt.Equals, height)
+	etag := w.Header().Get("ETag")
+	qt.Assert(t, etag, qt.Not(qt.Equals), "")
+
+	// The ETag ignores the request ID and timestamp
+	w = get(etag)
+	qt.Assert(t, w.Code, qt.Equals, http.StatusNotModified)
+	qt.Assert(t, w.Body.Len(), qt.Equals, 0)
+	qt.Assert(t, w.Header().Get("ETag"), qt.Equals, etag)
+
+	// A different response has a different ETag
+	height = 11
+	w = get(etag)
+	qt.Assert(t, w.Code, qt.Equals, http.StatusOK)
+	qt.Assert(t, w.Header().Get("ETag"), qt.Not(qt.Equals), etag)
+
+	// Only GET and HEAD are allowed
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/v1/height", nil))
+	qt.Assert(t, w.Code, qt.Equals, http.StatusMethodNotAllowed)
+	qt.Assert(t, w.Header().Get("Allow"), qt.Equals, "GET, HEAD")
+}
+
+func TestRESTErrorStatus(t *testing.T) {
+	r := newTestRouter(t)
+	for _, tc := range []struct {
+		code   api.ErrorCode
+		status int
+	}{
+		{api.ErrCodeInvalidRequest, http.StatusBadRequest},
+		{api.ErrCodeNotFound, http.StatusNotFound},
+		{api.ErrCodeUnauthorized, http.StatusForbidden},
+		{api.ErrCodeDuplicateVote, http.StatusConflict},
+		{api.ErrCodeRateLimited, http.StatusTooManyRequests},
+		{api.ErrCodeMempoolFull, http.StatusServiceUnavailable},
+		{api.ErrCodeTxTimeout, http.StatusGatewayTimeout},
+		{api.ErrCodeInternal, http.StatusInternalServerError},
+	} {
+		handler := r.restHandler(restRoute{method: "getProcessInfo", maxAge: 10},
+			func(request RouterRequest) {
+				r.SendErrorCode(request, tc.code, "failed")
+			})
+		w := httptest.NewRecorder()
+		handler(w, restRequest("/v1/processes/01", nil))
+		qt.Assert(t, w.Code, qt.Equals, tc.status, qt.Commentf("%s", tc.code))
+		qt.Assert(t, w.Header().Get("Cache-Control"), qt.Equals, "no-store")
+		qt.Assert(t, w.Header().Get("ETag"), qt.Equals, "")
+		var resp api.MetaResponse
+		qt.Assert(t, json.Unmarshal(w.Body.Bytes(), &resp), qt.IsNil)
+		qt.Assert(t, resp.ErrorCode, qt.Equals, tc.code)
+		qt.Assert(t, resp.Message, qt.Equals, "failed")
+	}
+
+	// The parameter errors are sent without calling the method
+	handler := r.restHandler(restRoute{method: "getProcessInfo", params: []restParam{restProcessID}},
+		func(request RouterRequest) { t.Error("method called with an invalid parameter") })
+	w := httptest.NewRecorder()
+	handler(w, restRequest("/v1/processes/xyz", map[string]string{"processId": "xyz"}))
+	qt.Assert(t, w.Code, qt.Equals, http.StatusBadRequest)
+
+	// A method not sending any response is an internal error
+	handler = r.restHandler(restRoute{method: "getProcessInfo"}, func(RouterRequest) {})
+	w = httptest.NewRecorder()
+	handler(w, restRequest("/v1/processes/01", nil))
+	qt.Assert(t, w.Code, qt.Equals, http.StatusInternalServerError)
+}
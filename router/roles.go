@@ -0,0 +1,160 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/log"
+)
+
+// Built-in roles of the private methods access control. The census methods
+// allowed by a role only manage the censuses created by the signer address, or
+// listing its public key as a management key of the namespace.
+const (
+	RoleCensusAdmin = "census-admin"
+	RoleFileAdmin   = "file-admin"
+	RoleOperator    = "operator"
+	RoleReadOnly    = "read-only"
+)
+
+// roleAllMethods gives a role access to all the private methods
+const roleAllMethods = "*"
+
+// defaultRoles are the private methods allowed to each built-in role
+var defaultRoles = map[string][]string{
+	RoleCensusAdmin: {"addCensus", "addClaim", "addClaimBulk", "publish", "importRemote",
+		"dump", "dumpPlain", "getCensusList"},
+	RoleFileAdmin: {"addFile", "pinList", "pinFile", "unpinFile"},
+	RoleOperator:  {roleAllMethods},
+	RoleReadOnly:  {"dump", "dumpPlain", "getCensusList", "pinList"},
+}
+
+// RolesFile is the format of the access control file. Roles can redefine the
+// methods of the built-in roles or add new ones, and Addresses assigns roles to
+// the Ethereum addresses of the clients.
+//
+//	{
+//	  "roles": {"exporter": ["publishProcessExport"]},
+//	  "addresses": {"0x8b3f...": ["census-admin", "exporter"]}
+//	}
+type RolesFile struct {
+	Roles     map[string][]string `json:"roles,omitempty"`
+	Addresses map[string][]string `json:"addresses"`
+}
+
+// AccessControl authorizes the private methods calls according to the roles
+// of the request signer, loaded from a file which is reloaded on changes.
+type AccessControl struct {
+	path    string
+	modTime time.Time
+	// roles maps each address to the allowed methods and the role allowing them
+	roles map[ethcommon.Address]map[string]string
+	lock  sync.RWMutex
+}
+
+// NewAccessControl creates an access control loading the roles from path
+func NewAccessControl(path string) (*AccessControl, error) {
+	ac := &AccessControl{path: filepath.Clean(path)}
+	if err := ac.load(); err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
+// load reads and parses the roles file
+func (ac *AccessControl) load() error {
+	info, err := os.Stat(ac.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(ac.path)
+	if err != nil {
+		return err
+	}
+	var rf RolesFile
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return fmt.Errorf("cannot parse roles file %s: %w", ac.path, err)
+	}
+	roleMethods := make(map[string][]string)
+	for role, methods := range defaultRoles {
+		roleMethods[role] = methods
+	}
+	for role, methods := range rf.Roles {
+		roleMethods[role] = methods
+	}
+	roles := make(map[ethcommon.Address]map[string]string)
+	for addr, names := range rf.Addresses {
+		if !ethcommon.IsHexAddress(addr) {
+			return fmt.Errorf("invalid address %q on roles file", addr)
+		}
+		allowed := make(map[string]string)
+		for _, role := range names {
+			methods, ok := roleMethods[role]
+			if !ok {
+				return fmt.Errorf("unknown role %q for address %s", role, addr)
+			}
+			for _, m := range methods {
+				if _, ok := allowed[m]; !ok {
+					allowed[m] = role
+				}
+			}
+		}
+		roles[ethcommon.HexToAddress(addr)] = allowed
+	}
+
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	ac.roles = roles
+	ac.modTime = info.ModTime()
+	log.Infof("loaded roles for %d addresses from %s", len(roles), ac.path)
+	return nil
+}
+
+// Watch reloads the roles file each time it is modified, checking it every
+// interval. If the new file is not valid, the previous roles are kept.
+func (ac *AccessControl) Watch(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		info, err := os.Stat(ac.path)
+		if err != nil {
+			log.Warnf("cannot check roles file: %v", err)
+			continue
+		}
+		ac.lock.RLock()
+		modified := !info.ModTime().Equal(ac.modTime)
+		ac.lock.RUnlock()
+		if !modified {
+			continue
+		}
+		if err := ac.load(); err != nil {
+			log.Errorf("cannot reload roles file, keeping the previous roles: %v", err)
+		}
+	}
+}
+
+// Allowed returns the role that allows addr to call the private method, and
+// false if none of its roles allows it
+func (ac *AccessControl) Allowed(addr ethcommon.Address, method string) (string, bool) {
+	ac.lock.RLock()
+	defer ac.lock.RUnlock()
+	allowed := ac.roles[addr]
+	if role, ok := allowed[method]; ok {
+		return role, true
+	}
+	if role, ok := allowed[roleAllMethods]; ok {
+		return role, true
+	}
+	return "", false
+}
+
+// SetAccessControl authorizes the private methods calls with the roles of ac,
+// in addition to the authorized keys of the signer. If ac is nil, only the
+// signer authorized keys are used.
+func (r *Router) SetAccessControl(ac *AccessControl) {
+	r.acl = ac
+}
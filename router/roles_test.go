@@ -0,0 +1,97 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+)
+
+func writeRoles(t *testing.T, path, content string) {
+	qt.Assert(t, os.WriteFile(path, []byte(content), 0o600), qt.IsNil)
+}
+
+func TestAccessControlLoad(t *testing.T) {
+	admin := ethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	operator := ethcommon.HexToAddress("0x1000000000000000000000000000000000000002")
+	path := filepath.Join(t.TempDir(), "roles.json")
+	writeRoles(t, path, `{
+		"roles": {"exporter": ["publishProcessExport"], "file-admin": ["addFile"]},
+		"addresses": {
+			"0x1000000000000000000000000000000000000001": ["census-admin", "exporter", "file-admin"],
+			"0x1000000000000000000000000000000000000002": ["operator"]
+		}
+	}`)
+	ac, err := NewAccessControl(path)
+	qt.Assert(t, err, qt.IsNil)
+
+	for _, tc := range []struct {
+		addr    ethcommon.Address
+		method  string
+		role    string
+		allowed bool
+	}{
+		{admin, "addClaim", RoleCensusAdmin, true},
+		{admin, "publishProcessExport", "exporter", true},
+		{admin, "addFile", RoleFileAdmin, true},
+		// the file-admin role is redefined without pinFile
+		{admin, "pinFile", "", false},
+		{admin, "exportProcess", "", false},
+		// the operator role allows all the methods
+		{operator, "exportProcess", RoleOperator, true},
+		{operator, "anyMethod", RoleOperator, true},
+		{ethcommon.Address{}, "addClaim", "", false},
+	} {
+		role, allowed := ac.Allowed(tc.addr, tc.method)
+		qt.Assert(t, allowed, qt.Equals, tc.allowed, qt.Commentf("%s %s", tc.addr, tc.method))
+		qt.Assert(t, role, qt.Equals, tc.role, qt.Commentf("%s %s", tc.addr, tc.method))
+	}
+
+	for _, content := range []string{
+		`{"addresses": {"0x1000000000000000000000000000000000000001": ["unknown"]}}`,
+		`{"addresses": {"0x10": ["operator"]}}`,
+		`{"addresses": `,
+	} {
+		writeRoles(t, path, content)
+		_, err := NewAccessControl(path)
+		qt.Assert(t, err, qt.Not(qt.IsNil), qt.Commentf("%s", content))
+	}
+	_, err = NewAccessControl(filepath.Join(t.TempDir(), "missing.json"))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
+
+func TestAccessControlWatch(t *testing.T) {
+	addr := ethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	path := filepath.Join(t.TempDir(), "roles.json")
+	writeRoles(t, path, `{"addresses": {"`+addr.Hex()+`": ["read-only"]}}`)
+	ac, err := NewAccessControl(path)
+	qt.Assert(t, err, qt.IsNil)
+	go ac.Watch(10 * time.Millisecond)
+
+	waitRole := func(method, role string) {
+		for i := 0; i < 100; i++ {
+			if r, _ := ac.Allowed(addr, method); r == role {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("role of %s is not %q", method, role)
+	}
+	waitRole("addFile", "")
+
+	// The modified file is reloaded
+	modTime := time.Now().Add(time.Second)
+	writeRoles(t, path, `{"addresses": {"`+addr.Hex()+`": ["file-admin"]}}`)
+	qt.Assert(t, os.Chtimes(path, modTime, modTime), qt.IsNil)
+	waitRole("addFile", RoleFileAdmin)
+
+	// An invalid file keeps the previous roles
+	modTime = modTime.Add(time.Second)
+	writeRoles(t, path, `{"addresses": {"`+addr.Hex()+`": ["unknown"]}}`)
+	qt.Assert(t, os.Chtimes(path, modTime, modTime), qt.IsNil)
+	time.Sleep(50 * time.Millisecond)
+	waitRole("addFile", RoleFileAdmin)
+}
@@ -4,6 +4,7 @@ package router
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -34,9 +35,12 @@ const (
 
 func (r *Router) BuildReply(request RouterRequest, resp *api.MetaResponse) transports.Message {
 	// Add any last fields to the inner response, and marshal it with sorted
-	// fields for signing.
+	// fields for signing. The cached responses are shared by all the clients,
+	// so their request ID is only set on the outer response.
 	resp.Ok = true
-	resp.Request = request.id
+	if request.cacheKey == "" {
+		resp.Request = request.id
+	}
 	resp.Timestamp = int32(time.Now().Unix())
 	respInner, err := crypto.SortedMarshalJSON(resp)
 	if err != nil {
@@ -56,9 +60,16 @@ func (r *Router) BuildReply(request RouterRequest, resp *api.MetaResponse) trans
 		log.Error(err)
 		// continue without the signature
 	}
+	if request.cacheKey != "" {
+		r.cache.store(request.cacheKey, request.method, request.cacheHeight,
+			&cachedResponse{inner: respInner, signature: signature})
+	}
+	return replyMessage(request, respInner, signature)
+}
 
-	// Build the outer response with the already-marshaled inner response
-	// and its signature.
+// replyMessage builds the outer response with the already-marshaled inner
+// response and its signature.
+func replyMessage(request RouterRequest, respInner, signature []byte) transports.Message {
 	respOuter := api.ResponseMessage{
 		ID:           request.id,
 		Signature:    signature,
@@ -88,6 +99,7 @@ func (r *Router) BuildReply(request RouterRequest, resp *api.MetaResponse) trans
 type RegisteredMethod struct {
 	public  bool
 	handler func(RouterRequest)
+	info    api.MethodInfo
 }
 
 // Router holds a router object
@@ -101,6 +113,10 @@ type Router struct {
 	metricsagent *metrics.Agent
 	vocinfo      *vochaininfo.VochainInfo
 	allowPrivate bool
+	limiter      *RateLimiter
+	cache        *ResponseCache
+	acl          *AccessControl
+	replay       *ReplayGuard
 	Scrutinizer  *scrutinizer.Scrutinizer
 	PrivateCalls uint64
 	PublicCalls  uint64
@@ -118,7 +134,12 @@ func NewRouter(inbound <-chan transports.Message, storage data.Storage,
 	r.signer = signer
 	r.metricsagent = metricsagent
 	r.allowPrivate = allowPrivate
-	r.RegisterPublic("getInfo", r.info)
+	r.Register("info",
+		Method{Name: "getInfo", Public: true, Handler: r.info,
+			Response: []string{"apiList", "apiVersion", "health"}},
+		Method{Name: "getMethods", Public: true, Handler: r.getMethods,
+			Response: []string{"apiVersion", "methods"}},
+	)
 	if metricsagent != nil {
 		r.RegisterMetrics(metricsagent)
 	}
@@ -134,22 +155,22 @@ type RouterRequest struct {
 	authenticated bool
 	address       ethcommon.Address
 	private       bool
+	// role is the access control role allowing the private method call
+	role string
+	// cacheKey is set if the response must be stored on the response cache,
+	// it was not found at cacheHeight
+	cacheKey    string
+	cacheHeight uint32
 }
 
 func (r *RouterRequest) GetAddress() *ethcommon.Address {
 	return &r.address
 }
 
-// semi-unmarshalls message, returns method name
-func (r *Router) getRequest(payload []byte,
+// semi-unmarshalls the inner message of reqOuter, returns method name
+func (r *Router) getRequest(reqOuter *api.RequestMessage,
 	context transports.MessageContext) (request RouterRequest, err error) {
 	request.MessageContext = context
-	// First unmarshal the outer layer, to obtain the request ID, the signed
-	// request, and the signature.
-	var reqOuter api.RequestMessage
-	if err := json.Unmarshal(payload, &reqOuter); err != nil {
-		return request, err
-	}
 	request.id = reqOuter.ID
 
 	var reqInner api.MetaRequest
@@ -157,32 +178,54 @@ func (r *Router) getRequest(payload []byte,
 		return request, err
 	}
 	request.MetaRequest = reqInner
-	request.method = reqInner.Method
+	err = r.authenticate(&request, reqOuter.MetaRequest, reqOuter.Signature,
+		reqOuter.MetaRequest)
+	return request, err
+}
+
+// authenticate recovers the signer address of the request and authenticates it
+// for the private methods. The replay guard identifies the request by replayKey.
+func (r *Router) authenticate(request *RouterRequest, signed, signature,
+	replayKey []byte) (err error) {
+	request.method = request.Method
 	if request.method == "" {
-		return request, fmt.Errorf("method is empty")
+		return fmt.Errorf("method is empty")
 	}
 
 	method, ok := r.methods[request.method]
 	if !ok {
-		return request, fmt.Errorf("method not valid [%s]", request.method)
+		return fmt.Errorf("method not valid [%s]", request.method)
+	}
+	if r.exhausted(*request) {
+		return errRateLimited
 	}
 	if method.public {
 		request.private = false
 		request.authenticated = true
-		if len(reqOuter.Signature) > 0 {
-			_, request.address, _ = r.signer.VerifySender(
-				reqOuter.MetaRequest, reqOuter.Signature)
+		if len(signature) > 0 {
+			_, request.address, _ = r.signer.VerifySender(signed, signature)
 		}
 	} else {
 		request.private = true
-		request.authenticated, request.address, err = r.signer.VerifySender(
-			reqOuter.MetaRequest, reqOuter.Signature)
-		// if no authrized keys, authenticate all requests if allowPrivate=true
-		if r.allowPrivate && !request.authenticated && len(r.signer.Authorized) == 0 {
+		request.authenticated, request.address, err = r.signer.VerifySender(signed, signature)
+		// if the signer is not an authorized key, check its roles
+		if err == nil && !request.authenticated && r.acl != nil {
+			request.role, request.authenticated = r.acl.Allowed(request.address, request.method)
+		}
+		// if no authrized keys nor roles, authenticate all requests if allowPrivate=true
+		if r.allowPrivate && !request.authenticated && len(r.signer.Authorized) == 0 &&
+			r.acl == nil {
 			request.authenticated = true
 		}
 	}
-	return request, err
+	// check the replays of the requests with a valid signature
+	if r.replay != nil && err == nil && request.address != (ethcommon.Address{}) {
+		if err := r.replay.Check(request.Timestamp, replayKey); err != nil {
+			request.authenticated = false
+			return err
+		}
+	}
+	return err
 }
 
 // InitRouter sets up a Router object which can then be used to route requests
@@ -196,18 +239,22 @@ func InitRouter(inbound <-chan transports.Message, storage data.Storage,
 	return NewRouter(inbound, storage, signer, metricsagent, allowPrivate)
 }
 
+// SetRateLimiter limits the requests of each client with rl. If rl is nil,
+// the requests are not limited.
+func (r *Router) SetRateLimiter(rl *RateLimiter) {
+	r.limiter = rl
+}
+
+// RegisterPrivate registers a private method without declaring its fields,
+// Register should be used instead
 func (r *Router) RegisterPrivate(name string, handler func(RouterRequest)) {
-	if _, ok := r.methods[name]; ok {
-		log.Fatalf("duplicate method: %q", name)
-	}
-	r.methods[name] = RegisteredMethod{handler: handler}
+	r.Register("", Method{Name: name, Handler: handler})
 }
 
+// RegisterPublic registers a public method without declaring its fields,
+// Register should be used instead
 func (r *Router) RegisterPublic(name string, handler func(RouterRequest)) {
-	if _, ok := r.methods[name]; ok {
-		log.Fatalf("duplicate method: %q", name)
-	}
-	r.methods[name] = RegisteredMethod{public: true, handler: handler}
+	r.Register("", Method{Name: name, Public: true, Handler: handler})
 }
 
 // Route routes requests through the Router object
@@ -219,42 +266,83 @@ func (r *Router) Route() {
 	}
 	for {
 		msg := <-r.inbound
-		request, err := r.getRequest(msg.Data, msg.Context)
-		if !request.authenticated && err != nil {
-			go r.SendError(request, err.Error())
+		// First unmarshal the outer layer, to obtain the request ID, the signed
+		// request, and the signature.
+		var reqOuter api.RequestMessage
+		if err := json.Unmarshal(msg.Data, &reqOuter); err != nil {
+			go r.dispatch(RouterRequest{MessageContext: msg.Context}, err)
 			continue
 		}
-		method, ok := r.methods[request.method]
-		if !ok {
-			errMsg := fmt.Sprintf("router has no method %q", request.method)
-			go r.SendError(request, errMsg)
+		if isBatch(reqOuter.MetaRequest) {
+			go r.dispatchBatch(&reqOuter, msg.Context)
 			continue
 		}
-		if !method.public && !request.authenticated {
-			errMsg := fmt.Sprintf("authentication is required for %q", request.method)
-			go r.SendError(request, errMsg)
-			continue
+		request, err := r.getRequest(&reqOuter, msg.Context)
+		go r.dispatch(request, err)
+	}
+}
+
+// dispatch handles a parsed request, or sends the error response if it cannot
+// be handled. The err is the parsing and authentication error of the request.
+func (r *Router) dispatch(request RouterRequest, err error) {
+	if err == errRateLimited {
+		r.sendRateLimited(request)
+		return
+	}
+	if !request.authenticated && err != nil {
+		r.SendErrorCode(request, requestErrorCode(err), err.Error())
+		return
+	}
+	method, ok := r.methods[request.method]
+	if !ok {
+		errMsg := fmt.Sprintf("router has no method %q", request.method)
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest, errMsg)
+		return
+	}
+	if !method.public && !request.authenticated {
+		log.Warnf("[audit] %s denied private method %s", request.address.Hex(), request.method)
+		errMsg := fmt.Sprintf("authentication is required for %q", request.method)
+		r.SendErrorCode(request, api.ErrCodeUnauthorized, errMsg)
+		return
+	}
+	if request.private {
+		role := request.role
+		if role == "" {
+			role = "authorized key"
 		}
-		log.Debugf("api query %s", request.MetaRequest.String())
+		log.Infof("[audit] %s called private method %s as %s",
+			request.address.Hex(), request.method, role)
+	}
+	if r.throttled(request, remoteIP(request.MessageContext)) {
+		r.sendRateLimited(request)
+		return
+	}
+	log.Debugf("api query %s", request.MetaRequest.String())
+	if request.private {
+		atomic.AddUint64(&r.PrivateCalls, 1)
+	} else {
+		atomic.AddUint64(&r.PublicCalls, 1)
+	}
+
+	if r.metricsagent != nil {
 		if request.private {
-			atomic.AddUint64(&r.PrivateCalls, 1)
+			RouterPrivateReqs.With(prometheus.Labels{"method": request.method}).Inc()
 		} else {
-			atomic.AddUint64(&r.PublicCalls, 1)
+			RouterPublicReqs.With(prometheus.Labels{"method": request.method}).Inc()
 		}
-
-		if r.metricsagent != nil {
-			if request.private {
-				RouterPrivateReqs.With(prometheus.Labels{"method": request.method}).Inc()
-			} else {
-				RouterPublicReqs.With(prometheus.Labels{"method": request.method}).Inc()
-			}
-		}
-
-		go method.handler(request)
 	}
+
+	r.handle(request, method.handler)
 }
 
+// SendError sends an error response with the internal error code.
 func (r *Router) SendError(request RouterRequest, errMsg string) {
+	r.SendErrorCode(request, api.ErrCodeInternal, errMsg)
+}
+
+// SendErrorCode sends an error response with the given error code, so the
+// clients can react to the failure without parsing the message.
+func (r *Router) SendErrorCode(request RouterRequest, code api.ErrorCode, errMsg string) {
 	if request.MessageContext == nil {
 		log.Errorf("failed to send error: MessageContext==nil: %s", errMsg)
 		return
@@ -267,7 +355,7 @@ func (r *Router) SendError(request RouterRequest, errMsg string) {
 		Request:   request.id,
 		Timestamp: int32(time.Now().Unix()),
 	}
-	response.SetError(errMsg)
+	response.SetErrorCode(code, errMsg)
 	respInner, err := crypto.SortedMarshalJSON(response)
 	if err != nil {
 		log.Error(err)
@@ -303,6 +391,7 @@ func (r *Router) SendError(request RouterRequest, errMsg string) {
 func (r *Router) info(request RouterRequest) {
 	var response api.MetaResponse
 	response.APIList = r.APIs
+	response.APIVersion = APIVersion
 	response.Request = request.id
 	if health, err := getHealth(); err == nil {
 		response.Health = health
@@ -315,6 +404,22 @@ func (r *Router) info(request RouterRequest) {
 	}
 }
 
+// getMethods returns all the registered methods, sorted by name
+func (r *Router) getMethods(request RouterRequest) {
+	var response api.MetaResponse
+	response.APIVersion = APIVersion
+	for _, m := range r.methods {
+		info := m.info
+		response.Methods = append(response.Methods, &info)
+	}
+	sort.Slice(response.Methods, func(i, j int) bool {
+		return response.Methods[i].Name < response.Methods[j].Name
+	})
+	if err := request.Send(r.BuildReply(request, &response)); err != nil {
+		log.Warnf("error sending response: %s", err)
+	}
+}
+
 // Health is a number between 0 and 99 that represents the status of the node, as bigger the better
 // The formula ued to calculate health is: 100* (1- ( Sum(weight[0..1] * value/value_max) ))
 // Weight is a number between 0 and 1 used to give a specific weight to a value.
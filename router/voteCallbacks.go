@@ -1,12 +1,21 @@
 package router
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	mempl "github.com/tendermint/tendermint/mempool"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/scrutinizer"
 	models "go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
@@ -14,10 +23,17 @@ import (
 
 const MaxListSize = 64
 
+// MaxExportListSize is the maximum number of envelopes returned by an exportProcess call
+const MaxExportListSize = 1024
+
+// TxInclusionTimeout is the maximum time a transaction submission waits for
+// the transaction to be included on a block, if the request asks for it
+const TxInclusionTimeout = 25 * time.Second
+
 func (r *Router) submitRawTx(request RouterRequest) {
-	res, err := r.vocapp.SendTx(request.Payload)
+	res, result, err := r.sendTx(request, request.Payload)
 	if err != nil {
-		r.SendError(request, err.Error())
+		r.SendErrorCode(request, sendTxErrorCode(err, isVoteTx(request.Payload)), err.Error())
 		return
 	}
 	if res == nil {
@@ -25,21 +41,119 @@ func (r *Router) submitRawTx(request RouterRequest) {
 		return
 	}
 	if res.Code != 0 {
-		r.SendError(request, string(res.Data))
+		r.SendErrorCode(request, checkTxErrorCode(res.Code), string(res.Data))
 		return
 	}
 	log.Debugf("broadcasting tx hash:%s", res.Hash)
 	var response api.MetaResponse
+	if result != nil && !r.setTxResult(request, &response, result) {
+		return
+	}
 	response.Payload = fmt.Sprintf("%x", res.Data) // return nullifier or other info
 	if err = request.Send(r.BuildReply(request, &response)); err != nil {
 		log.Warnf("error sending raw tx: %v", err)
 	}
 }
 
+// sendTx sends a transaction to the Vochain mempool. If the request asks to
+// wait for inclusion, it also waits until the transaction is included on a
+// committed block, and returns its result.
+func (r *Router) sendTx(request RouterRequest,
+	tx []byte) (*ctypes.ResultBroadcastTx, *vochain.TxResult, error) {
+	if !request.WaitForInclusion {
+		res, err := r.vocapp.SendTx(tx)
+		return res, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TxInclusionTimeout)
+	defer cancel()
+	res, result, err := r.vocapp.SendTxAndWait(ctx, tx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("transaction %s not included on a block after %s: %w",
+			res.Hash, TxInclusionTimeout, err)
+	}
+	return res, result, err
+}
+
+// setTxResult adds the block position of an included transaction to the
+// response. If the transaction failed on DeliverTx, the error is sent and
+// false is returned.
+func (r *Router) setTxResult(request RouterRequest, response *api.MetaResponse,
+	result *vochain.TxResult) bool {
+	if result.Code != vochain.TxCodeOK {
+		r.SendErrorCode(request, checkTxErrorCode(result.Code),
+			fmt.Sprintf("transaction rejected on block %d: %s", result.Height, result.Data))
+		return false
+	}
+	response.Height = &result.Height
+	response.TxIndex = &result.Index
+	response.TxHash = result.Hash
+	return true
+}
+
+// errorCode returns the API error code of an error returned by the Vochain
+// state or the scrutinizer.
+func errorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, vochain.ErrProcessNotFound),
+		errors.Is(err, vochain.ErrVoteDoesNotExist),
+		errors.Is(err, scrutinizer.ErrNotFoundInDatabase):
+		return api.ErrCodeNotFound
+	default:
+		return api.ErrorCodeOf(err)
+	}
+}
+
+// sendTxErrorCode returns the API error code of an error returned by the
+// Vochain SendTx method, or while waiting for the transaction inclusion.
+// A transaction already on the mempool cache is only a duplicate vote if vote
+// is true, otherwise it is an invalid request.
+func sendTxErrorCode(err error, vote bool) api.ErrorCode {
+	var full mempl.ErrMempoolIsFull
+	switch {
+	case errors.As(err, &full):
+		return api.ErrCodeMempoolFull
+	case errors.Is(err, mempl.ErrTxInCache) && vote:
+		return api.ErrCodeDuplicateVote
+	case errors.Is(err, mempl.ErrTxInCache):
+		return api.ErrCodeInvalidRequest
+	case errors.Is(err, vochain.ErrTxDropped):
+		return api.ErrCodeTxDropped
+	case errors.Is(err, context.DeadlineExceeded):
+		return api.ErrCodeTxTimeout
+	default:
+		return api.ErrCodeInternal
+	}
+}
+
+// isVoteTx returns true if txBytes is a signed vote transaction
+func isVoteTx(txBytes []byte) bool {
+	tx, _, _, err := vochain.UnmarshalTx(txBytes)
+	return err == nil && tx.GetVote() != nil
+}
+
+// checkTxErrorCode returns the API error code of a transaction rejected with
+// the given ABCI response code.
+func checkTxErrorCode(code uint32) api.ErrorCode {
+	switch code {
+	case vochain.TxCodeProcessNotFound:
+		return api.ErrCodeNotFound
+	case vochain.TxCodeProcessNotActive:
+		return api.ErrCodeProcessNotActive
+	case vochain.TxCodeVoteAlreadyExists:
+		return api.ErrCodeDuplicateVote
+	case vochain.TxCodeInvalidCensusProof:
+		return api.ErrCodeInvalidCensusProof
+	case vochain.TxCodeUnauthorized:
+		return api.ErrCodeUnauthorized
+	default:
+		return api.ErrCodeInvalidRequest
+	}
+}
+
 func (r *Router) submitEnvelope(request RouterRequest) {
 	var err error
 	if request.Payload == nil {
-		r.SendError(request, "payload is empty")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest, "payload is empty")
 		return
 	}
 	// Prepare Vote transaction
@@ -55,19 +169,23 @@ func (r *Router) submitEnvelope(request RouterRequest) {
 		return
 	}
 
-	res, err := r.vocapp.SendTx(txBytes)
+	res, result, err := r.sendTx(request, txBytes)
 	if err != nil || res == nil {
-		r.SendError(request, fmt.Sprintf("cannot broadcast transaction: (%s)", err))
+		r.SendErrorCode(request, sendTxErrorCode(err, true),
+			fmt.Sprintf("cannot broadcast transaction: (%s)", err))
 		return
 	}
 
 	// Get mempool checkTx reply
 	if res.Code != 0 {
-		r.SendError(request, string(res.Data))
+		r.SendErrorCode(request, checkTxErrorCode(res.Code), string(res.Data))
 		return
 	}
 	log.Infof("broadcasting vochain tx hash: %s code: %d", res.Hash, res.Code)
 	var response api.MetaResponse
+	if result != nil && !r.setTxResult(request, &response, result) {
+		return
+	}
 	response.Nullifier = fmt.Sprintf("%x", res.Data)
 	if err = request.Send(r.BuildReply(request, &response)); err != nil {
 		log.Warnf("error on submitEnvelope: %v", err)
@@ -77,12 +195,14 @@ func (r *Router) submitEnvelope(request RouterRequest) {
 func (r *Router) getEnvelopeStatus(request RouterRequest) {
 	// check pid
 	if len(request.ProcessID) != types.ProcessIDsize {
-		r.SendError(request, "cannot get envelope status: (malformed processId)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get envelope status: (malformed processId)")
 		return
 	}
 	// check nullifier
 	if len(request.Nullifier) != types.VoteNullifierSize {
-		r.SendError(request, "cannot get envelope status: (malformed nullifier)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get envelope status: (malformed nullifier)")
 		return
 	}
 
@@ -97,7 +217,7 @@ func (r *Router) getEnvelopeStatus(request RouterRequest) {
 			}
 			return
 		}
-		r.SendError(request, fmt.Sprintf("cannot get envelope status: (%v)", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get envelope status: (%v)", err))
 		return
 	}
 	response.Registered = types.True
@@ -112,12 +232,12 @@ func (r *Router) getEnvelopeStatus(request RouterRequest) {
 func (r *Router) getEnvelope(request RouterRequest) {
 	// check nullifier
 	if len(request.Nullifier) != types.VoteNullifierSize {
-		r.SendError(request, "cannot get envelope: (malformed nullifier)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest, "cannot get envelope: (malformed nullifier)")
 		return
 	}
 	env, err := r.Scrutinizer.GetEnvelope(request.Nullifier)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get envelope: (%v)", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get envelope: (%v)", err))
 		return
 	}
 	var response api.MetaResponse
@@ -128,15 +248,152 @@ func (r *Router) getEnvelope(request RouterRequest) {
 	}
 }
 
+func (r *Router) getVoterHistory(request RouterRequest) {
+	// check address and entity
+	if len(request.Address) != types.EthereumAddressSize {
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get voter history: (malformed address)")
+		return
+	}
+	if len(request.EntityId) != types.EntityIDsize {
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get voter history: (malformed entityId)")
+		return
+	}
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	address := common.BytesToAddress(request.Address)
+	records, err := r.Scrutinizer.VoterHistory(address, request.EntityId, request.From, max)
+	if err != nil {
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get voter history: (%v)", err))
+		return
+	}
+
+	// Build the receipt and sign it, so the voter can keep it as a proof
+	receipt, err := crypto.SortedMarshalJSON(&api.VoterReceipt{
+		Address:   request.Address,
+		EntityID:  request.EntityId,
+		Envelopes: records,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		r.SendError(request, fmt.Sprintf("cannot build voter receipt: (%v)", err))
+		return
+	}
+	signature, err := r.signer.Sign(receipt)
+	if err != nil {
+		r.SendError(request, fmt.Sprintf("cannot sign voter receipt: (%v)", err))
+		return
+	}
+	var response api.MetaResponse
+	response.VoterHistory = records
+	response.Receipt = &api.SignedVoterReceipt{
+		Receipt:   receipt,
+		Signature: signature,
+		Signer:    r.signer.AddressString(),
+	}
+	if err := request.Send(r.BuildReply(request, &response)); err != nil {
+		log.Warnf("error sending response: %s", err)
+	}
+}
+
+// processExport builds the process audit bundle in the format given by the request type,
+// json (default) or csv (a zip archive of CSV files), with up to max envelopes starting
+// at the request from (all of them if max is zero)
+func (r *Router) processExport(request RouterRequest, max int) ([]byte, error) {
+	if len(request.ProcessID) != types.ProcessIDsize {
+		return nil, api.NewError(api.ErrCodeInvalidRequest, "malformed processId")
+	}
+	if request.From < 0 {
+		return nil, api.NewError(api.ErrCodeInvalidRequest, "invalid from %d", request.From)
+	}
+	export, err := r.Scrutinizer.ExportProcess(request.ProcessID, request.From, max)
+	if err != nil {
+		return nil, err
+	}
+	switch request.Type {
+	case "", "json":
+		return json.Marshal(export)
+	case "csv":
+		return scrutinizer.ExportCSV(export)
+	default:
+		return nil, api.NewError(api.ErrCodeInvalidRequest,
+			"export type %q not supported", request.Type)
+	}
+}
+
+func (r *Router) exportProcess(request RouterRequest) {
+	max := request.ListSize
+	if max > MaxExportListSize || max <= 0 {
+		max = MaxExportListSize
+	}
+	content, err := r.processExport(request, max)
+	if err != nil {
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot export process: (%v)", err))
+		return
+	}
+	var response api.MetaResponse
+	response.Content = content
+	if err := request.Send(r.BuildReply(request, &response)); err != nil {
+		log.Warnf("error sending response: %s", err)
+	}
+}
+
+func (r *Router) publishProcessExport(request RouterRequest) {
+	content, err := r.processExport(request, 0)
+	if err != nil {
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot export process: (%v)", err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	cid, err := r.storage.Publish(ctx, content)
+	if err != nil {
+		r.SendError(request, fmt.Sprintf("cannot publish process export: (%v)", err))
+		return
+	}
+	log.Infof("published export of process %x on %s", request.ProcessID, cid)
+	var response api.MetaResponse
+	response.URI = r.storage.URIprefix() + cid
+	if err := request.Send(r.BuildReply(request, &response)); err != nil {
+		log.Warnf("error sending response: %s", err)
+	}
+}
+
+func (r *Router) getInvalidEnvelopes(request RouterRequest) {
+	if len(request.ProcessID) != types.ProcessIDsize {
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get invalid envelopes: (malformed processId)")
+		return
+	}
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	var response api.MetaResponse
+	var err error
+	if response.InvalidEnvelopes, err = r.Scrutinizer.GetInvalidEnvelopes(
+		request.ProcessID, request.From, max); err != nil {
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get invalid envelopes: (%v)", err))
+		return
+	}
+	if err := request.Send(r.BuildReply(request, &response)); err != nil {
+		log.Warnf("error sending response: %s", err)
+	}
+}
+
 func (r *Router) getEnvelopeHeight(request RouterRequest) {
 	// check pid
 	if len(request.ProcessID) != types.ProcessIDsize && len(request.ProcessID) != 0 {
-		r.SendError(request, "cannot get envelope height: (malformed processId)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get envelope height: (malformed processId)")
 		return
 	}
 	votes, err := r.Scrutinizer.GetEnvelopeHeight(request.ProcessID)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get envelope height: (%v)", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get envelope height: (%v)", err))
 		return
 	}
 
@@ -174,7 +431,7 @@ func (r *Router) getProcessList(request RouterRequest) {
 		request.Status,
 		request.WithResults)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get process list: (%s)", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get process list: (%s)", err))
 		return
 	}
 	for _, p := range processList {
@@ -200,7 +457,7 @@ func (r *Router) getProcessInfo(request RouterRequest) {
 	var err error
 	response.Process, err = r.Scrutinizer.ProcessInfo(request.ProcessID)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get process info: %v", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get process info: %v", err))
 		return
 	}
 	if err := request.Send(r.BuildReply(request, &response)); err != nil {
@@ -211,7 +468,8 @@ func (r *Router) getProcessInfo(request RouterRequest) {
 func (r *Router) getProcessSummary(request RouterRequest) {
 	var response api.MetaResponse
 	if len(request.ProcessID) != types.ProcessIDsize {
-		r.SendError(request, "cannot get envelope status: (malformed processId)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get envelope status: (malformed processId)")
 		return
 	}
 
@@ -219,7 +477,7 @@ func (r *Router) getProcessSummary(request RouterRequest) {
 	procInfo, err := r.Scrutinizer.ProcessInfo(request.ProcessID)
 	if err != nil {
 		log.Warn(err)
-		r.SendError(request, err.Error())
+		r.SendErrorCode(request, errorCode(err), err.Error())
 		return
 	}
 
@@ -272,12 +530,14 @@ func (r *Router) getEntityCount(request RouterRequest) {
 func (r *Router) getProcessKeys(request RouterRequest) {
 	// check pid
 	if len(request.ProcessID) != types.ProcessIDsize {
-		r.SendError(request, "cannot get envelope status: (malformed processId)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get envelope status: (malformed processId)")
 		return
 	}
 	process, err := r.vocapp.State.Process(request.ProcessID, true)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get process encryption public keys: (%s)", err))
+		r.SendErrorCode(request, errorCode(err),
+			fmt.Sprintf("cannot get process encryption public keys: (%s)", err))
 		return
 	}
 	var response api.MetaResponse
@@ -315,7 +575,7 @@ func (r *Router) getResultsWeight(request RouterRequest) {
 	var response api.MetaResponse
 	w, err := r.Scrutinizer.GetResultsWeight(request.ProcessID)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get results weight: %v", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get results weight: %v", err))
 		return
 	}
 	response.Weight = w.String()
@@ -327,13 +587,14 @@ func (r *Router) getResultsWeight(request RouterRequest) {
 func (r *Router) getOracleResults(request RouterRequest) {
 	var response api.MetaResponse
 	if len(request.ProcessID) != types.ProcessIDsize {
-		r.SendError(request, "cannot get oracle results: (malformed processId)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get oracle results: (malformed processId)")
 		return
 	}
 	var err error
 	response.Results, err = r.vocapp.State.GetProcessResults(request.ProcessID)
 	if err != nil {
-		r.SendError(request, fmt.Sprintf("cannot get oracle results: %v", err))
+		r.SendErrorCode(request, errorCode(err), fmt.Sprintf("cannot get oracle results: %v", err))
 		return
 	}
 	request.Send(r.BuildReply(request, &response))
@@ -341,7 +602,8 @@ func (r *Router) getOracleResults(request RouterRequest) {
 
 func (r *Router) getResults(request RouterRequest) {
 	if len(request.ProcessID) != types.ProcessIDsize {
-		r.SendError(request, "cannot get envelope status: (malformed processId)")
+		r.SendErrorCode(request, api.ErrCodeInvalidRequest,
+			"cannot get envelope status: (malformed processId)")
 		return
 	}
 
@@ -351,7 +613,7 @@ func (r *Router) getResults(request RouterRequest) {
 	procInfo, err := r.Scrutinizer.ProcessInfo(request.ProcessID)
 	if err != nil {
 		log.Warn(err)
-		r.SendError(request, err.Error())
+		r.SendErrorCode(request, errorCode(err), err.Error())
 		return
 	}
 
@@ -375,7 +637,7 @@ func (r *Router) getResults(request RouterRequest) {
 	// Get results info
 	vr, err := r.Scrutinizer.GetResults(request.ProcessID)
 	if err != nil && err != scrutinizer.ErrNoResultsYet {
-		r.SendError(request, err.Error())
+		r.SendErrorCode(request, errorCode(err), err.Error())
 		return
 	}
 	if err == scrutinizer.ErrNoResultsYet {
@@ -393,6 +655,7 @@ func (r *Router) getResults(request RouterRequest) {
 	response.Final = &vr.Final
 	h := uint32(vr.EnvelopeHeight)
 	response.Height = &h
+	response.InvalidVotes = &vr.InvalidVotes
 	// Get total number of votes (including invalid/null)
 	eh, err := r.Scrutinizer.GetEnvelopeHeight(request.ProcessID)
 	if err != nil {
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	mempl "github.com/tendermint/tendermint/mempool"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSendTxErrorCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		vote bool
+		code api.ErrorCode
+	}{
+		{mempl.ErrMempoolIsFull{}, true, api.ErrCodeMempoolFull},
+		{mempl.ErrTxInCache, true, api.ErrCodeDuplicateVote},
+		{mempl.ErrTxInCache, false, api.ErrCodeInvalidRequest},
+		{fmt.Errorf("send: %w", mempl.ErrTxInCache), false, api.ErrCodeInvalidRequest},
+		{vochain.ErrTxDropped, false, api.ErrCodeTxDropped},
+		{fmt.Errorf("not included: %w", context.DeadlineExceeded), true, api.ErrCodeTxTimeout},
+		{fmt.Errorf("connection refused"), true, api.ErrCodeInternal},
+	} {
+		qt.Assert(t, sendTxErrorCode(tc.err, tc.vote), qt.Equals, tc.code,
+			qt.Commentf("%v, vote %v", tc.err, tc.vote))
+	}
+}
+
+func TestIsVoteTx(t *testing.T) {
+	signedTx := func(tx *models.Tx) []byte {
+		txBytes, err := proto.Marshal(tx)
+		qt.Assert(t, err, qt.IsNil)
+		stx, err := proto.Marshal(&models.SignedTx{Tx: txBytes})
+		qt.Assert(t, err, qt.IsNil)
+		return stx
+	}
+	qt.Assert(t, isVoteTx(signedTx(&models.Tx{Payload: &models.Tx_Vote{
+		Vote: &models.VoteEnvelope{ProcessId: []byte{1}}}})), qt.IsTrue)
+	qt.Assert(t, isVoteTx(signedTx(&models.Tx{Payload: &models.Tx_SetProcess{
+		SetProcess: &models.SetProcessTx{ProcessId: []byte{1}}}})), qt.IsFalse)
+	qt.Assert(t, isVoteTx([]byte("not a transaction")), qt.IsFalse)
+}
+
+// replyOf returns the MetaResponse sent on a captured context
+func replyOf(t *testing.T, ctx *jsonrpcContext) api.MetaResponse {
+	var outer api.ResponseMessage
+	qt.Assert(t, json.Unmarshal(ctx.data, &outer), qt.IsNil)
+	var inner api.MetaResponse
+	qt.Assert(t, json.Unmarshal(outer.MetaResponse, &inner), qt.IsNil)
+	return inner
+}
+
+func TestExportProcessErrorCodes(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	r := newTestRouter(t)
+	r.Scrutinizer, err = scrutinizer.NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	for _, tc := range []struct {
+		request api.MetaRequest
+		code    api.ErrorCode
+	}{
+		{api.MetaRequest{ProcessID: []byte{1}}, api.ErrCodeInvalidRequest},
+		{api.MetaRequest{ProcessID: pid, From: -1}, api.ErrCodeInvalidRequest},
+		{api.MetaRequest{ProcessID: pid}, api.ErrCodeNotFound},
+	} {
+		ctx := &jsonrpcContext{}
+		r.exportProcess(RouterRequest{MetaRequest: tc.request, MessageContext: ctx})
+		resp := replyOf(t, ctx)
+		qt.Assert(t, resp.Ok, qt.IsFalse)
+		qt.Assert(t, resp.ErrorCode, qt.Equals, tc.code, qt.Commentf("%s", resp.Message))
+	}
+}
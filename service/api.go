@@ -2,6 +2,8 @@ package service
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -21,6 +23,9 @@ import (
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 )
 
+// rolesReloadInterval is the interval to check the API roles file for changes
+const rolesReloadInterval = 10 * time.Second
+
 func API(apiconfig *config.API, pxy *mhttp.Proxy, storage data.Storage, cm *census.Manager,
 	vapp *vochain.BaseApplication, sc *scrutinizer.Scrutinizer, vi *vochaininfo.VochainInfo,
 	vochainRPCaddr string, signer *ethereum.SignKeys, ma *metrics.Agent) (*router.Router, error) {
@@ -55,6 +60,44 @@ func API(apiconfig *config.API, pxy *mhttp.Proxy, storage data.Storage, cm *cens
 	log.Infof("%s API available at %s", htransport.ConnectionType(), apiconfig.Route+"dvote")
 
 	routerAPI := router.InitRouter(listenerOutput, storage, signer, ma, apiconfig.AllowPrivate)
+	if rl := apiconfig.RateLimit; rl.Enabled {
+		log.Infof("enabling API rate limits per minute: reads %d, votes %d, census %d, files %d",
+			rl.Reads, rl.Votes, rl.Census, rl.Files)
+		var allowList []string
+		if rl.AllowList != "" {
+			allowList = strings.Split(rl.AllowList, ",")
+		}
+		routerAPI.SetRateLimiter(router.NewRateLimiter(map[string]int{
+			router.RateClassReads:  rl.Reads,
+			router.RateClassVotes:  rl.Votes,
+			router.RateClassCensus: rl.Census,
+			router.RateClassFiles:  rl.Files,
+		}, allowList))
+	}
+	if apiconfig.RolesFile != "" {
+		acl, err := router.NewAccessControl(apiconfig.RolesFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("enabling private methods roles from %s", apiconfig.RolesFile)
+		go acl.Watch(rolesReloadInterval)
+		routerAPI.SetAccessControl(acl)
+	}
+	if apiconfig.ReplayWindow > 0 {
+		window := time.Duration(apiconfig.ReplayWindow) * time.Second
+		log.Infof("enabling signed requests replay protection with a %s window", window)
+		routerAPI.SetReplayGuard(router.NewReplayGuard(window))
+	}
+	if apiconfig.Cache.Enabled && vapp != nil {
+		ttl, err := parseCacheTTLs(apiconfig.Cache.TTLs)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("enabling API response cache for %d methods", len(ttl))
+		cache := router.NewResponseCache(ttl)
+		vapp.State.AddEventListener(cache)
+		routerAPI.SetResponseCache(cache)
+	}
 	if apiconfig.File && storage != nil {
 		log.Info("enabling file API")
 		routerAPI.EnableFileAPI()
@@ -78,6 +121,20 @@ func API(apiconfig *config.API, pxy *mhttp.Proxy, storage data.Storage, cm *cens
 			routerAPI.EnableIndexerAPI(vapp, vi)
 		}
 	}
+	if apiconfig.REST {
+		if !apiconfig.HTTP {
+			return nil, fmt.Errorf("the REST API requires the HTTP transport")
+		}
+		log.Info("enabling REST API")
+		routerAPI.EnableREST(pxy, apiconfig.Route)
+	}
+	if apiconfig.JSONRPC {
+		if !apiconfig.HTTP {
+			return nil, fmt.Errorf("the JSON-RPC API requires the HTTP transport")
+		}
+		log.Info("enabling JSON-RPC API")
+		routerAPI.EnableJSONRPC(pxy, apiconfig.Route)
+	}
 	go routerAPI.Route()
 
 	go func() {
@@ -89,3 +146,24 @@ func API(apiconfig *config.API, pxy *mhttp.Proxy, storage data.Storage, cm *cens
 	}()
 	return routerAPI, nil
 }
+
+// parseCacheTTLs parses a comma-delimited list of method:seconds
+func parseCacheTTLs(list string) (map[string]time.Duration, error) {
+	ttl := make(map[string]time.Duration)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid cache TTL %q, expected method:seconds", item)
+		}
+		seconds, err := strconv.Atoi(parts[1])
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid cache TTL seconds for method %s", parts[0])
+		}
+		ttl[parts[0]] = time.Duration(seconds) * time.Second
+	}
+	return ttl, nil
+}
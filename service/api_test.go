@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseCacheTTLs(t *testing.T) {
+	for _, tc := range []struct {
+		list string
+		ttl  map[string]time.Duration
+		err  string
+	}{
+		{"", map[string]time.Duration{}, ""},
+		{"getBlockHeight:5", map[string]time.Duration{"getBlockHeight": 5 * time.Second}, ""},
+		{" getBlockHeight:5 , getResults:0,", map[string]time.Duration{
+			"getBlockHeight": 5 * time.Second,
+			"getResults":     0,
+		}, ""},
+		{"getBlockHeight", nil, "invalid cache TTL.*"},
+		{"getBlockHeight:5:1", nil, "invalid cache TTL.*"},
+		{"getBlockHeight:five", nil, "invalid cache TTL seconds for method getBlockHeight"},
+		{"getBlockHeight:-1", nil, "invalid cache TTL seconds for method getBlockHeight"},
+	} {
+		ttl, err := parseCacheTTLs(tc.list)
+		if tc.err != "" {
+			qt.Assert(t, err, qt.ErrorMatches, tc.err, qt.Commentf("%q", tc.list))
+			continue
+		}
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, ttl, qt.DeepEquals, tc.ttl, qt.Commentf("%q", tc.list))
+	}
+}
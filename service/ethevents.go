@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -13,13 +14,18 @@ import (
 	"go.vocdoni.io/dvote/ethereum/ethevents"
 	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
 	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/metrics"
 	"go.vocdoni.io/dvote/vochain"
 )
 
 // EthEvents service registers on the Ethereum smart contract specified in
 // ethProcDomain, the provided event handlers.
 // w3host and w3port must point to a working web3 websocket endpoint.
-// If endBlock=0 is enabled the service will only subscribe for new blocks
+// If endBlock=0 is enabled the service will only subscribe for new blocks.
+// The last processed block is persisted on dataDir/networkName, so the events missed
+// while offline are backfilled on startup and after each web3 reconnection. The
+// storage root evidences of the EVM census processes relayed are kept there too.
+// To watch several chains, call it once per chain, each one with its own endpoints.
 func EthEvents(
 	ctx context.Context,
 	w3uris []string,
@@ -28,8 +34,10 @@ func EthEvents(
 	vocapp *vochain.BaseApplication,
 	evh []ethevents.EventHandler,
 	ethereumWhiteList []string,
+	dataDir string,
+	ma *metrics.Agent,
 ) error {
-	log.Infof("creating ethereum events service")
+	log.Infof("creating ethereum events service for %s", networkName)
 	specs, err := chain.SpecsFor(networkName)
 	if err != nil {
 		return fmt.Errorf("cannot get specs for the selected network: %w", err)
@@ -37,15 +45,22 @@ func EthEvents(
 
 	ev, err := ethevents.NewEthEvents(
 		specs.Contracts,
+		networkName,
 		specs.NetworkSource,
 		signer,
 		vocapp,
 		ethereumWhiteList,
+		filepath.Join(dataDir, networkName),
 	)
 	if err != nil {
 		return fmt.Errorf("couldn't create ethereum events listener: %w", err)
 	}
 
+	ev.SetConfirmations(specs.ConfirmThreshold, specs.ConfirmDepth)
+	if err := ev.EnableStorageEvidence(filepath.Join(dataDir, networkName, "ethheaders")); err != nil {
+		return fmt.Errorf("cannot open ethereum header store: %w", err)
+	}
+
 	// Save as last known block the starting block for the selected chain.
 	// Events will start to be monitorized from this block.
 	ev.EthereumLastKnownBlock = uint64(specs.StartingBlock)
@@ -53,6 +68,7 @@ func EthEvents(
 	for _, e := range evh {
 		ev.AddEventHandler(e)
 	}
+	go ev.CollectMetrics(ma)
 
 	// The web3 queue manages the list of web3 endpoints
 	w3q := new(w3queue)
@@ -90,7 +106,7 @@ func EthEvents(
 	go func() {
 		for {
 			time.Sleep(time.Second * 300)
-			log.Infof("web3 failures %s", w3q.Failures())
+			log.Infof("%s web3 failures %s", networkName, w3q.Failures())
 		}
 	}()
 
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	chain "go.vocdoni.io/dvote/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/oracle"
+)
+
+// EthResults service makes the oracle publish the final results of the processes
+// created on networkName on its Ethereum results contract. To publish on several
+// chains, call it once per chain, each one with its own endpoints. The connection
+// is established when needed, and established again after a failure, trying each
+// of the w3uris endpoints until one works. maxGasPrice is expressed in gwei
+// (0 means no limit).
+func EthResults(
+	ctx context.Context,
+	orc *oracle.Oracle,
+	w3uris []string,
+	networkName string,
+	signer *ethereum.SignKeys,
+	vochainID uint32,
+	maxGasPrice uint64,
+) error {
+	log.Infof("creating ethereum results publisher service")
+	specs, err := chain.SpecsFor(networkName)
+	if err != nil {
+		return fmt.Errorf("cannot get specs for the selected network: %w", err)
+	}
+	if len(w3uris) == 0 {
+		return fmt.Errorf("no web3 endpoints provided")
+	}
+	var maxGasPriceWei *big.Int
+	if maxGasPrice > 0 {
+		maxGasPriceWei = new(big.Int).Mul(new(big.Int).SetUint64(maxGasPrice), big.NewInt(1e9))
+	}
+
+	w3q := new(w3queue)
+	w3q.SetEndpoints(w3uris)
+	dial := func() (*ethereumhandler.EthereumHandler, error) {
+		eh, err := ethereumhandler.NewEthereumHandler(specs.Contracts, specs.NetworkSource, w3q.Get())
+		if err != nil {
+			// the next connection attempt uses the following endpoint
+			w3q.Next()
+		}
+		return eh, err
+	}
+	return orc.EnableEthereumResults(specs.NetworkSource,
+		oracle.NewResultsPublisher(ctx, dial, signer, vochainID, maxGasPriceWei))
+}
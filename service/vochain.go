@@ -169,6 +169,50 @@ func Vochain(vconfig *config.VochainCfg, waitForSync bool,
 	return
 }
 
+// ScrutinizerMaintenance opens the local vochain data without starting the node and runs
+// the scrutinizer maintenance tasks enabled on the configuration: rebuild and/or verify.
+// An error is returned if the verification finds any inconsistency.
+func ScrutinizerMaintenance(vconfig *config.VochainCfg) error {
+	app, closeApp, err := vochain.NewOfflineApplication(vconfig.DataDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := closeApp(); err != nil {
+			log.Warnf("cannot close vochain databases: %v", err)
+		}
+	}()
+	sc, err := scrutinizer.NewScrutinizer(
+		filepath.Join(vconfig.DataDir, "scrutinizer"),
+		app,
+		!vconfig.Scrutinizer.IgnoreLiveResults,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := sc.Close(); err != nil {
+			log.Warnf("cannot close scrutinizer database: %v", err)
+		}
+	}()
+	if vconfig.Scrutinizer.Rebuild {
+		if err := sc.Rebuild(); err != nil {
+			return fmt.Errorf("cannot rebuild scrutinizer: %w", err)
+		}
+	}
+	if vconfig.Scrutinizer.Verify {
+		inconsistencies, err := sc.Verify()
+		if err != nil {
+			return fmt.Errorf("cannot verify scrutinizer: %w", err)
+		}
+		if len(inconsistencies) > 0 {
+			return fmt.Errorf("found %d scrutinizer inconsistencies", len(inconsistencies))
+		}
+		log.Infof("scrutinizer database is consistent")
+	}
+	return nil
+}
+
 // VochainPrintInfo initializes the Vochain statistics recollection
 func VochainPrintInfo(sleepSecs int64, vi *vochaininfo.VochainInfo) {
 	var a *[5]int32
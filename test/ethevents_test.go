@@ -0,0 +1,58 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/ethereum/ethevents"
+	"go.vocdoni.io/dvote/test/testcommon"
+	"go.vocdoni.io/dvote/test/testcommon/ethsim"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// TestEthereumOracleNewProcess creates a process on a simulated Ethereum chain and
+// checks that the oracle relays it to the Vochain
+func TestEthereumOracleNewProcess(t *testing.T) {
+	entity := ethereum.NewSignKeys()
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	sim := ethsim.New(t, entity.Address())
+	sim.AutoMine(t, 200*time.Millisecond)
+
+	oracle := &testcommon.DvoteAPIServer{Signer: ethereum.NewSignKeys()}
+	qt.Assert(t, oracle.Signer.Generate(), qt.IsNil)
+	vnode := testcommon.NewMockVochainNode(t, oracle)
+
+	ev, err := ethevents.NewEthEvents(sim.Contracts(), "sim", models.SourceNetworkId_UNKNOWN,
+		oracle.Signer, vnode, nil, t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	ev.SetConfirmations(time.Second, 1)
+	ev.AddEventHandler(ethevents.HandleVochainOracle)
+	ev.VotingHandle = sim.Handler(t, ev.ContractsInfo)
+
+	censusRoot := "0000000000000000000000000000000000000000000000000000000000000001"
+	waitProcess := func(pid [32]byte) {
+		for i := 0; i < 60; i++ {
+			if p, err := vnode.State.Process(pid[:], true); err == nil {
+				qt.Assert(t, p.EntityId, qt.DeepEquals, entity.Address().Bytes())
+				qt.Assert(t, p.BlockCount, qt.Equals, uint32(1000))
+				return
+			}
+			time.Sleep(time.Second)
+		}
+		t.Fatalf("process %x not created on the vochain", pid)
+	}
+
+	// the first process is created before subscribing, so its event is backfilled
+	pid := sim.NewProcessStd(t, entity, censusRoot, "ipfs://census", 1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go ev.SubscribeEthereumEventLogs(ctx)
+	waitProcess(pid)
+
+	// the second process event is received by the subscription
+	pid = sim.NewProcessStd(t, entity, censusRoot, "ipfs://census", 1000)
+	waitProcess(pid)
+}
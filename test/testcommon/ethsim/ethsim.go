@@ -0,0 +1,289 @@
+// Package ethsim provides an in-process simulated Ethereum network with the
+// Vocdoni contracts deployed, reachable through a websocket web3 endpoint.
+// It allows testing the Ethereum to Vochain flow (event handlers, process
+// fetching and storage proofs) without a real web3 node.
+package ethsim
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/ethereum/contracts"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+)
+
+const (
+	// gasLimit is the block gas limit of the simulated chain
+	gasLimit = 20_000_000
+	// EthChainID is the ethereum chain id registered on the processes contract
+	EthChainID = 1
+)
+
+// initialBalance is the balance of the funded accounts (1000 ether)
+var initialBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+
+// SimulatedEthereum is a simulated Ethereum chain with the processes, namespaces,
+// token storage proof, genesis and results contracts deployed. Transactions sent
+// through the web3 endpoint are mined immediately, each one on its own block.
+type SimulatedEthereum struct {
+	Backend *backends.SimulatedBackend
+	// Deployer is the account that deployed the contracts, it is funded
+	Deployer *ethereum.SignKeys
+	// Endpoint is the websocket web3 endpoint of the simulated chain
+	Endpoint string
+	// ChainID is the chain id used to sign the transactions
+	ChainID *big.Int
+
+	Processes         *contracts.Processes
+	Namespaces        *contracts.Namespaces
+	TokenStorageProof *contracts.TokenStorageProof
+	Genesis           *contracts.Genesis
+	Results           *contracts.Results
+	// Addresses of the deployed contracts, by contract name
+	Addresses map[string]common.Address
+
+	server *rpc.Server
+	http   *httptest.Server
+	// lock serializes the transactions, so each one is mined on its own block
+	lock sync.Mutex
+}
+
+// New creates a simulated Ethereum chain, deploys the Vocdoni contracts and
+// starts its web3 endpoint. Everything is stopped when the test ends.
+func New(tb testing.TB, funded ...common.Address) *SimulatedEthereum {
+	deployer := ethereum.NewSignKeys()
+	if err := deployer.Generate(); err != nil {
+		tb.Fatal(err)
+	}
+	alloc := core.GenesisAlloc{deployer.Address(): {Balance: initialBalance}}
+	for _, addr := range funded {
+		alloc[addr] = core.GenesisAccount{Balance: initialBalance}
+	}
+	sim := &SimulatedEthereum{
+		Backend:   backends.NewSimulatedBackend(alloc, gasLimit),
+		Deployer:  deployer,
+		ChainID:   params.AllEthashProtocolChanges.ChainID,
+		Addresses: make(map[string]common.Address),
+	}
+	tb.Cleanup(func() { sim.Backend.Close() })
+	sim.deploy(tb)
+
+	sim.server = rpc.NewServer()
+	if err := sim.server.RegisterName("eth", &ethAPI{sim: sim}); err != nil {
+		tb.Fatal(err)
+	}
+	if err := sim.server.RegisterName("net", &netAPI{sim: sim}); err != nil {
+		tb.Fatal(err)
+	}
+	sim.http = httptest.NewServer(sim.server.WebsocketHandler([]string{"*"}))
+	sim.Endpoint = "ws" + strings.TrimPrefix(sim.http.URL, "http")
+	tb.Cleanup(func() {
+		sim.http.Close()
+		sim.server.Stop()
+	})
+	return sim
+}
+
+// Transactor returns the transaction options for signing with the keys
+func (sim *SimulatedEthereum) Transactor(tb testing.TB, keys *ethereum.SignKeys) *bind.TransactOpts {
+	opts, err := bind.NewKeyedTransactorWithChainID(&keys.Private, sim.ChainID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return opts
+}
+
+// Mine waits for the transaction sent through the contract bindings to be mined
+// and returns its receipt. The transaction must succeed.
+func (sim *SimulatedEthereum) Mine(tb testing.TB, tx *ethtypes.Transaction, err error) *ethtypes.Receipt {
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sim.lock.Lock()
+	sim.Backend.Commit()
+	sim.lock.Unlock()
+	receipt, err := bind.WaitMined(context.Background(), sim.Backend, tx)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+		tb.Fatalf("transaction %s reverted", tx.Hash().Hex())
+	}
+	return receipt
+}
+
+// AutoMine mines a new block every period until the test ends. The Ethereum
+// events subscription expects the chain head to keep moving forward.
+func (sim *SimulatedEthereum) AutoMine(tb testing.TB, period time.Duration) {
+	done := make(chan struct{})
+	tb.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(period):
+				sim.lock.Lock()
+				sim.Backend.Commit()
+				sim.lock.Unlock()
+			}
+		}
+	}()
+}
+
+// Reorg replaces the blocks after the parent block with a longer fork, so the chain
+// reorganises. The given transactions are included on the first block of the fork,
+// the transactions of the replaced blocks are dropped. It returns the new head.
+func (sim *SimulatedEthereum) Reorg(tb testing.TB, parent uint64,
+	txs ...*ethtypes.Transaction) uint64 {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	bc := sim.Backend.Blockchain()
+	head := bc.CurrentBlock().NumberU64()
+	block := bc.GetBlockByNumber(parent)
+	var fork []*ethtypes.Block
+	for i := uint64(0); i <= head-parent; i++ {
+		statedb, err := bc.StateAt(block.Root())
+		if err != nil {
+			tb.Fatal(err)
+		}
+		header := &ethtypes.Header{
+			ParentHash: block.Hash(),
+			Coinbase:   sim.Deployer.Address(),
+			Number:     new(big.Int).Add(block.Number(), big.NewInt(1)),
+			GasLimit:   block.GasLimit(),
+			Time:       block.Time() + 10,
+		}
+		header.Difficulty = bc.Engine().CalcDifficulty(bc, header.Time, block.Header())
+		var blockTxs []*ethtypes.Transaction
+		var receipts []*ethtypes.Receipt
+		if i == 0 {
+			gp := new(core.GasPool).AddGas(header.GasLimit)
+			for j, tx := range txs {
+				statedb.Prepare(tx.Hash(), common.Hash{}, j)
+				receipt, err := core.ApplyTransaction(bc.Config(), bc, &header.Coinbase, gp,
+					statedb, header, tx, &header.GasUsed, vm.Config{})
+				if err != nil {
+					tb.Fatal(err)
+				}
+				blockTxs = append(blockTxs, tx)
+				receipts = append(receipts, receipt)
+			}
+		}
+		block, err = bc.Engine().FinalizeAndAssemble(bc, header, statedb, blockTxs, nil, receipts)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		// the state is written to the database, the backend builds its pending
+		// block from there
+		root, err := statedb.Commit(true)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if err := statedb.Database().TrieDB().Commit(root, false, nil); err != nil {
+			tb.Fatal(err)
+		}
+		fork = append(fork, block)
+	}
+	if _, err := bc.InsertChain(fork); err != nil {
+		tb.Fatal(err)
+	}
+	// rebuild the pending block on top of the new head
+	sim.Backend.Rollback()
+	return bc.CurrentBlock().NumberU64()
+}
+
+// Contracts returns the contracts definition to use with ethereumhandler and
+// ethevents. The processes and namespaces contracts listen for events.
+func (sim *SimulatedEthereum) Contracts() map[string]*ethereumhandler.EthereumContract {
+	c := make(map[string]*ethereumhandler.EthereumContract)
+	for name, addr := range sim.Addresses {
+		c[name] = &ethereumhandler.EthereumContract{
+			Address: addr,
+			ListenForEvents: name == ethereumhandler.ContractNameProcesses ||
+				name == ethereumhandler.ContractNameNamespaces,
+		}
+	}
+	// the contracts are not resolved by ENS
+	c[ethereumhandler.ContractNameENSregistry] = &ethereumhandler.EthereumContract{}
+	return c
+}
+
+// Handler returns an ethereum handler connected to the simulated chain. The contracts
+// ABIs are initialized by the handler; if contracts is nil, Contracts() is used.
+func (sim *SimulatedEthereum) Handler(tb testing.TB,
+	contracts map[string]*ethereumhandler.EthereumContract) *ethereumhandler.EthereumHandler {
+	if contracts == nil {
+		contracts = sim.Contracts()
+	}
+	eh, err := ethereumhandler.NewEthereumHandler(contracts, 0, sim.Endpoint)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(eh.EthereumClient.Close)
+	return eh
+}
+
+// deploy deploys the contracts and registers the processes contract on the results one
+func (sim *SimulatedEthereum) deploy(tb testing.TB) {
+	opts := sim.Transactor(tb, sim.Deployer)
+	var tx *ethtypes.Transaction
+	var err error
+	var namespaces, tsp, genesis, results, processes common.Address
+
+	namespaces, tx, sim.Namespaces, err = contracts.DeployNamespaces(opts, sim.Backend)
+	sim.Mine(tb, tx, err)
+	tsp, tx, sim.TokenStorageProof, err = contracts.DeployTokenStorageProof(opts, sim.Backend)
+	sim.Mine(tb, tx, err)
+	genesis, tx, sim.Genesis, err = contracts.DeployGenesis(opts, sim.Backend)
+	sim.Mine(tb, tx, err)
+	results, tx, sim.Results, err = contracts.DeployResults(opts, sim.Backend, genesis)
+	sim.Mine(tb, tx, err)
+	processes, tx, sim.Processes, err = contracts.DeployProcesses(opts, sim.Backend,
+		common.Address{}, namespaces, results, tsp, EthChainID, big.NewInt(0))
+	sim.Mine(tb, tx, err)
+	tx, err = sim.Results.SetProcessesAddress(opts, processes)
+	sim.Mine(tb, tx, err)
+
+	sim.Addresses[ethereumhandler.ContractNameNamespaces] = namespaces
+	sim.Addresses[ethereumhandler.ContractNameTokenStorageProof] = tsp
+	sim.Addresses[ethereumhandler.ContractNameGenesis] = genesis
+	sim.Addresses[ethereumhandler.ContractNameResults] = results
+	sim.Addresses[ethereumhandler.ContractNameProcesses] = processes
+}
+
+// NewProcessStd creates a process with an off-chain census on the processes contract,
+// signed by the entity keys. It returns the process ID.
+func (sim *SimulatedEthereum) NewProcessStd(tb testing.TB, entity *ethereum.SignKeys,
+	censusRoot, censusURI string, blockCount uint32) [32]byte {
+	tx, err := sim.Processes.NewProcessStd(sim.Transactor(tb, entity),
+		[3]uint8{1, 0, 1}, // autostart mode, plain envelope, off-chain tree census
+		[3]string{"ipfs://metadata", censusRoot, censusURI},
+		[2]uint32{1, blockCount},
+		[4]uint8{1, 1, 2, 0}, // one question with 2 options
+		[2]uint16{1, 0},
+		[32]byte{},
+	)
+	receipt := sim.Mine(tb, tx, err)
+	for _, l := range receipt.Logs {
+		if ev, err := sim.Processes.ParseNewProcess(*l); err == nil {
+			return ev.ProcessId
+		}
+	}
+	tb.Fatal("new process event not found")
+	return [32]byte{}
+}
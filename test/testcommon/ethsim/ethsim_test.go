@@ -0,0 +1,73 @@
+package ethsim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/storage-proofs-eth-go/token"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	ethereumhandler "go.vocdoni.io/dvote/ethereum/handler"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+func TestNewProcessTxArgs(t *testing.T) {
+	entity := ethereum.NewSignKeys()
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	sim := New(t, entity.Address())
+
+	censusRoot := fmt.Sprintf("%064x", 1)
+	pid := sim.NewProcessStd(t, entity, censusRoot, "ipfs://census", 1000)
+
+	eh := sim.Handler(t, nil)
+	tx, evidence, err := eh.NewProcessTxArgs(context.Background(), pid, 0)
+	qt.Assert(t, err, qt.IsNil)
+	// only the EVM census processes have a storage root evidence
+	qt.Assert(t, evidence, qt.IsNil)
+	p := tx.Process
+	qt.Assert(t, p.ProcessId, qt.DeepEquals, pid[:])
+	qt.Assert(t, p.EntityId, qt.DeepEquals, entity.Address().Bytes())
+	qt.Assert(t, fmt.Sprintf("%x", p.CensusRoot), qt.Equals, censusRoot)
+	qt.Assert(t, p.GetCensusURI(), qt.Equals, "ipfs://census")
+	qt.Assert(t, p.CensusOrigin, qt.Equals, models.CensusOrigin_OFF_CHAIN_TREE)
+	qt.Assert(t, p.BlockCount, qt.Equals, uint32(1000))
+	qt.Assert(t, p.Status, qt.Equals, models.ProcessStatus_READY)
+	qt.Assert(t, p.Mode.AutoStart, qt.IsTrue)
+}
+
+func TestStorageProof(t *testing.T) {
+	sim := New(t)
+	eh := sim.Handler(t, nil)
+	ctx := context.Background()
+
+	ts := token.ERC20Token{RPCcli: eh.EthereumRPC, Ethcli: eh.EthereumClient}
+	contract := sim.Addresses[ethereumhandler.ContractNameProcesses]
+	qt.Assert(t, ts.Init(ctx, "", contract.String()), qt.IsNil)
+	block, err := ts.GetBlock(ctx, nil)
+	qt.Assert(t, err, qt.IsNil)
+	proof, err := ts.GetProofWithIndexSlot(ctx, sim.Deployer.Address(), block, 1)
+	qt.Assert(t, err, qt.IsNil)
+
+	header, err := eh.EthereumClient.HeaderByNumber(ctx, block.Number())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, vochain.CheckStorageRootEvidence(header, proof,
+		contract.Bytes(), proof.StorageHash.Bytes()), qt.IsNil)
+
+	// the evidence must not verify against another block
+	parent, err := eh.EthereumClient.HeaderByHash(ctx, header.ParentHash)
+	qt.Assert(t, err, qt.IsNil)
+	proof.Height = nil
+	qt.Assert(t, vochain.CheckStorageRootEvidence(parent, proof,
+		contract.Bytes(), proof.StorageHash.Bytes()), qt.Not(qt.IsNil))
+
+	// The handler builds the same evidence, checked against the provided root
+	evidence, err := eh.StorageEvidence(ctx, contract, block.NumberU64(), proof.StorageHash.Bytes())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, evidence.Header.Hash(), qt.Equals, header.Hash())
+	qt.Assert(t, vochain.CheckStorageRootEvidence(evidence.Header, evidence.Proof,
+		evidence.Contract, evidence.CensusRoot), qt.IsNil)
+	_, err = eh.StorageEvidence(ctx, contract, block.NumberU64(), make([]byte, 32))
+	qt.Assert(t, err, qt.ErrorMatches, "invalid storage root.*")
+}
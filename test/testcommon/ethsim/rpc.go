@@ -0,0 +1,369 @@
+package ethsim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	eth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ethAPI implements the subset of the eth web3 namespace used by the
+// Vocdoni Ethereum clients on top of the simulated backend
+type ethAPI struct {
+	sim *SimulatedEthereum
+}
+
+// netAPI implements the net web3 namespace
+type netAPI struct {
+	sim *SimulatedEthereum
+}
+
+// callArgs are the arguments of eth_call and eth_estimateGas
+type callArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     *hexutil.Bytes  `json:"data"`
+	Input    *hexutil.Bytes  `json:"input"`
+}
+
+func (args *callArgs) msg() eth.CallMsg {
+	msg := eth.CallMsg{From: args.From, To: args.To}
+	if args.Gas != nil {
+		msg.Gas = uint64(*args.Gas)
+	}
+	if args.GasPrice != nil {
+		msg.GasPrice = args.GasPrice.ToInt()
+	}
+	if args.Value != nil {
+		msg.Value = args.Value.ToInt()
+	}
+	if args.Input != nil {
+		msg.Data = *args.Input
+	} else if args.Data != nil {
+		msg.Data = *args.Data
+	}
+	return msg
+}
+
+// storageResult and accountResult are the eth_getProof response
+type storageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+type accountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []storageResult `json:"storageProof"`
+}
+
+func (n *netAPI) Version() string {
+	return n.sim.ChainID.String()
+}
+
+func (n *netAPI) PeerCount() hexutil.Uint {
+	return 1
+}
+
+func (api *ethAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(api.sim.ChainID)
+}
+
+func (api *ethAPI) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(api.sim.Backend.Blockchain().CurrentBlock().NumberU64())
+}
+
+func (api *ethAPI) Syncing() bool {
+	return false
+}
+
+func (api *ethAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
+	price, err := api.sim.Backend.SuggestGasPrice(ctx)
+	return (*hexutil.Big)(price), err
+}
+
+// block returns the block for the number, latest and pending are the current block
+func (api *ethAPI) block(number rpc.BlockNumber) *ethtypes.Block {
+	bc := api.sim.Backend.Blockchain()
+	if number < 0 {
+		return bc.CurrentBlock()
+	}
+	return bc.GetBlockByNumber(uint64(number))
+}
+
+func (api *ethAPI) blockOrHash(blockNrOrHash rpc.BlockNumberOrHash) (*ethtypes.Block, error) {
+	var block *ethtypes.Block
+	if number, ok := blockNrOrHash.Number(); ok {
+		block = api.block(number)
+	} else if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.sim.Backend.Blockchain().GetBlockByHash(hash)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block not found")
+	}
+	return block, nil
+}
+
+func (api *ethAPI) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	return api.marshalBlock(api.block(number), fullTx)
+}
+
+func (api *ethAPI) GetBlockByHash(hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	return api.marshalBlock(api.sim.Backend.Blockchain().GetBlockByHash(hash), fullTx)
+}
+
+// marshalBlock returns the JSON fields of a block, as expected by ethclient
+func (api *ethAPI) marshalBlock(block *ethtypes.Block, fullTx bool) (map[string]interface{}, error) {
+	if block == nil {
+		return nil, nil
+	}
+	fields, err := toMap(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	txs := []interface{}{}
+	for i, tx := range block.Transactions() {
+		if !fullTx {
+			txs = append(txs, tx.Hash())
+			continue
+		}
+		rpcTx, err := api.marshalTx(tx, block.Hash(), block.NumberU64(), uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, rpcTx)
+	}
+	fields["transactions"] = txs
+	fields["uncles"] = []common.Hash{}
+	fields["size"] = hexutil.Uint64(block.Size())
+	return fields, nil
+}
+
+// marshalTx returns the JSON fields of a mined transaction, including its sender
+func (api *ethAPI) marshalTx(tx *ethtypes.Transaction, blockHash common.Hash,
+	blockNumber, index uint64) (map[string]interface{}, error) {
+	fields, err := toMap(tx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := ethtypes.Sender(ethtypes.NewEIP155Signer(api.sim.ChainID), tx)
+	if err != nil {
+		return nil, err
+	}
+	fields["from"] = from
+	fields["blockHash"] = blockHash
+	fields["blockNumber"] = (*hexutil.Big)(new(big.Int).SetUint64(blockNumber))
+	fields["transactionIndex"] = hexutil.Uint64(index)
+	return fields, nil
+}
+
+func (api *ethAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	tx, _, err := api.sim.Backend.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, nil
+	}
+	receipt, err := api.sim.Backend.TransactionReceipt(ctx, hash)
+	if err != nil || receipt == nil {
+		return nil, nil
+	}
+	return api.marshalTx(tx, receipt.BlockHash, receipt.BlockNumber.Uint64(), uint64(receipt.TransactionIndex))
+}
+
+func (api *ethAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*ethtypes.Receipt, error) {
+	receipt, err := api.sim.Backend.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, nil
+	}
+	return receipt, nil
+}
+
+func (api *ethAPI) GetTransactionCount(ctx context.Context, address common.Address,
+	blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	nonce, err := api.sim.Backend.PendingNonceAt(ctx, address)
+	return hexutil.Uint64(nonce), err
+}
+
+func (api *ethAPI) GetBalance(ctx context.Context, address common.Address,
+	blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	balance, err := api.sim.Backend.BalanceAt(ctx, address, nil)
+	return (*hexutil.Big)(balance), err
+}
+
+func (api *ethAPI) GetCode(ctx context.Context, address common.Address,
+	blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	return api.sim.Backend.CodeAt(ctx, address, nil)
+}
+
+func (api *ethAPI) Call(ctx context.Context, args callArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	return api.sim.Backend.CallContract(ctx, args.msg(), nil)
+}
+
+func (api *ethAPI) EstimateGas(ctx context.Context, args callArgs) (hexutil.Uint64, error) {
+	gas, err := api.sim.Backend.EstimateGas(ctx, args.msg())
+	return hexutil.Uint64(gas), err
+}
+
+// SendRawTransaction mines the transaction on its own block
+func (api *ethAPI) SendRawTransaction(ctx context.Context, data hexutil.Bytes) (hash common.Hash, err error) {
+	tx := new(ethtypes.Transaction)
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+	api.sim.lock.Lock()
+	defer api.sim.lock.Unlock()
+	// the simulated backend panics on invalid transactions
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if err := api.sim.Backend.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	api.sim.Backend.Commit()
+	return tx.Hash(), nil
+}
+
+func (api *ethAPI) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([]ethtypes.Log, error) {
+	logs, err := api.sim.Backend.FilterLogs(ctx, eth.FilterQuery(crit))
+	if logs == nil {
+		logs = []ethtypes.Log{}
+	}
+	return logs, err
+}
+
+// GetProof returns the account and storage proofs (EIP-1186)
+func (api *ethAPI) GetProof(address common.Address, storageKeys []string,
+	blockNrOrHash rpc.BlockNumberOrHash) (*accountResult, error) {
+	block, err := api.blockOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	state, err := api.sim.Backend.Blockchain().StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	accountProof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	result := &accountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     state.GetCodeHash(address),
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  ethtypes.EmptyRootHash,
+		StorageProof: []storageResult{},
+	}
+	if storageTrie := state.StorageTrie(address); storageTrie != nil {
+		result.StorageHash = storageTrie.Hash()
+	}
+	for _, key := range storageKeys {
+		slot := common.HexToHash(key)
+		proof, err := state.GetStorageProof(address, slot)
+		if err != nil {
+			return nil, err
+		}
+		result.StorageProof = append(result.StorageProof, storageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(state.GetState(address, slot).Big()),
+			Proof: toHexSlice(proof),
+		})
+	}
+	return result, nil
+}
+
+// NewHeads sends a notification each time a new block is mined
+func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	headers := make(chan *ethtypes.Header)
+	sub, err := api.sim.Backend.SubscribeNewHead(context.Background(), headers)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case h := <-headers:
+				if err := notifier.Notify(rpcSub.ID, h); err != nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Logs sends a notification for each new log matching the criteria
+func (api *ethAPI) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	logs := make(chan ethtypes.Log)
+	sub, err := api.sim.Backend.SubscribeFilterLogs(context.Background(), eth.FilterQuery(crit), logs)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case l := <-logs:
+				if err := notifier.Notify(rpcSub.ID, &l); err != nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// toMap converts a JSON marshalable value to a map of its fields
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	return fields, json.Unmarshal(data, &fields)
+}
+
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
+}
@@ -36,10 +36,13 @@ type BaseApplication struct {
 	fnGetBlockByHeight func(height int64) *tmtypes.Block
 	fnGetBlockByHash   func(hash []byte) *tmtypes.Block
 	fnSendTx           func(tx []byte) (*ctypes.ResultBroadcastTx, error)
-	blockCache         *lru.AtomicCache
-	height             uint32
-	timestamp          int64
-	chainId            string
+	// fnGetBlockTxResults is only set on offline applications, see NewOfflineApplication
+	fnGetBlockTxResults func(height int64) ([]*abcitypes.ResponseDeliverTx, error)
+	blockCache          *lru.AtomicCache
+	txWaiters           txWaiters
+	height              uint32
+	timestamp           int64
+	chainId             string
 }
 
 var _ abcitypes.Application = (*BaseApplication)(nil)
@@ -134,6 +137,7 @@ func (app *BaseApplication) ChainID() string {
 // from the Tendermint mempool.
 func (app *BaseApplication) MempoolRemoveTx(txKey [32]byte) {
 	app.Node.Mempool().(*mempl.CListMempool).RemoveTxByKey(txKey, false)
+	app.txWaiters.drop([][32]byte{txKey})
 }
 
 // GetBlockByHeight retreies a full Tendermint block indexed by its height.
@@ -327,16 +331,27 @@ func (app *BaseApplication) CheckTx(req abcitypes.RequestCheckTx) abcitypes.Resp
 	if tx, txBytes, signature, err = UnmarshalTx(req.Tx); err == nil {
 		if data, err = AddTx(tx, txBytes, signature, app.State, TxKey(req.Tx), false); err != nil {
 			log.Debugf("checkTx error: %s", err)
-			return abcitypes.ResponseCheckTx{Code: 1, Data: []byte("addTx " + err.Error())}
+			return abcitypes.ResponseCheckTx{Code: TxCode(err), Data: []byte("addTx " + err.Error())}
 		}
 	} else {
-		return abcitypes.ResponseCheckTx{Code: 1, Data: []byte("unmarshalTx " + err.Error())}
+		return abcitypes.ResponseCheckTx{Code: TxCodeInvalid, Data: []byte("unmarshalTx " + err.Error())}
 	}
 	return abcitypes.ResponseCheckTx{Code: 0, Data: data}
 }
 
 // DeliverTx unmarshals req.Tx and adds it to the State if it is valid
 func (app *BaseApplication) DeliverTx(req abcitypes.RequestDeliverTx) abcitypes.ResponseDeliverTx {
+	index := app.State.TxCounter()
+	resp, err := app.deliverTx(req)
+	app.txWaiters.deliver(req.Tx, app.Height()+1, index, TxCode(err), resp.Data)
+	return resp
+}
+
+// deliverTx executes the transaction, the returned error is the rejection
+// reason of the response. The DeliverTx results are part of the consensus, so
+// the rejected transactions keep the code 1 instead of the TxCode of the error.
+func (app *BaseApplication) deliverTx(
+	req abcitypes.RequestDeliverTx) (abcitypes.ResponseDeliverTx, error) {
 	var data []byte
 	var err error
 	var tx *models.Tx
@@ -348,21 +363,23 @@ func (app *BaseApplication) DeliverTx(req abcitypes.RequestDeliverTx) abcitypes.
 		log.Debugf("deliver tx: %s", log.FormatProto(tx))
 		if data, err = AddTx(tx, txBytes, signature, app.State, TxKey(req.Tx), true); err != nil {
 			log.Debugf("rejected tx: %v", err)
-			return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
+			return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}, err
 		}
 		for _, e := range app.State.eventListeners {
 			e.OnNewTx(app.Height()+1, app.State.TxCounter())
 		}
 	} else {
-		return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
+		return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}, err
 	}
-	return abcitypes.ResponseDeliverTx{Code: 0, Data: data}
+	return abcitypes.ResponseDeliverTx{Code: 0, Data: data}, nil
 }
 
 // Commit saves the current vochain state and returns a commit hash
 func (app *BaseApplication) Commit() abcitypes.ResponseCommit {
+	data := app.State.Save()
+	app.txWaiters.commit()
 	return abcitypes.ResponseCommit{
-		Data: app.State.Save(),
+		Data: data,
 	}
 }
 
@@ -413,6 +430,11 @@ func (app *BaseApplication) SetFnGetBlockByHeight(fn func(height int64) *tmtypes
 	app.fnGetBlockByHeight = fn
 }
 
+// SetFnGetBlockTxResults sets the getter for the DeliverTx responses of a block
+func (app *BaseApplication) SetFnGetBlockTxResults(fn func(height int64) ([]*abcitypes.ResponseDeliverTx, error)) {
+	app.fnGetBlockTxResults = fn
+}
+
 // SetFnGetBlockByHash sets the sendTx method
 func (app *BaseApplication) SetFnSendTx(fn func(tx []byte) (*ctypes.ResultBroadcastTx, error)) {
 	app.fnSendTx = fn
@@ -13,12 +13,19 @@ import (
 	"go.vocdoni.io/dvote/censustree/gravitontree"
 	"go.vocdoni.io/dvote/config"
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/util"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 
 	blind "github.com/arnaucube/go-blindsecp256k1"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	cfg "github.com/tendermint/tendermint/config"
@@ -98,7 +105,7 @@ func CheckProof(proof *models.Proof, censusOrigin models.CensusOrigin,
 		}
 		return true, big.NewInt(1), nil
 
-	case models.CensusOrigin_ERC20:
+	case models.CensusOrigin_ERC20, models.CensusOrigin_ERC721, models.CensusOrigin_ERC1155:
 		p := proof.GetEthereumStorage()
 		if p == nil {
 			return false, nil, fmt.Errorf("ethereum proof is empty")
@@ -113,7 +120,12 @@ func CheckProof(proof *models.Proof, censusOrigin models.CensusOrigin,
 		amount := big.Int{}
 		amount.SetBytes(p.Value)
 		hexamount := hexutil.Big(amount)
-		log.Debugf("validating erc20 storage proof for key %x and amount %s", p.Key, amount.String())
+		// holders of NFTs must own at least one token of the collection
+		if censusOrigin != models.CensusOrigin_ERC20 && amount.Sign() <= 0 {
+			return false, nil, fmt.Errorf("holder does not own any token")
+		}
+		log.Debugf("validating %s storage proof for key %x and amount %s",
+			censusOrigin, p.Key, amount.String())
 		valid, err := ethstorageproof.VerifyEthStorageProof(
 			&ethstorageproof.StorageResult{
 				Key:   fmt.Sprintf("%x", p.Key),
@@ -127,6 +139,130 @@ func CheckProof(proof *models.Proof, censusOrigin models.CensusOrigin,
 	return false, nil, fmt.Errorf("proof type not supported for census origin %d", censusOrigin)
 }
 
+// ethAccount is the RLP representation of an Ethereum account on the state trie
+type ethAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     ethcommon.Hash
+	CodeHash []byte
+}
+
+// CheckStorageRootEvidence checks that the EVM storage root used as census root is
+// consistent with an Ethereum block header. The account proof of the token contract
+// must be valid against the header state root and the proven storage root must
+// match storageRoot. The header itself must be trusted by the caller (i.e by
+// comparing its hash with a known canonical block hash).
+func CheckStorageRootEvidence(header *ethtypes.Header, proof *ethstorageproof.StorageProof,
+	contract, storageRoot []byte) error {
+	if header == nil || header.Number == nil {
+		return fmt.Errorf("block header is empty")
+	}
+	if proof == nil || len(proof.AccountProof) == 0 {
+		return fmt.Errorf("account proof is empty")
+	}
+	if !bytes.Equal(proof.Address.Bytes(), contract) {
+		return fmt.Errorf("account proof address and contract do not match (%x != %x)",
+			proof.Address, contract)
+	}
+	if proof.Height != nil && proof.Height.Cmp(header.Number) != 0 {
+		return fmt.Errorf("account proof height %s does not match header height %s",
+			proof.Height, header.Number)
+	}
+	// never trust the state root provided along with the proof, use the header one
+	nodes := memorydb.New()
+	for _, n := range proof.AccountProof {
+		node, err := hex.DecodeString(util.TrimHex(n))
+		if err != nil {
+			return fmt.Errorf("cannot decode account proof node: %w", err)
+		}
+		if err := nodes.Put(ethcrypto.Keccak256(node), node); err != nil {
+			return err
+		}
+	}
+	value, err := trie.VerifyProof(header.Root, ethcrypto.Keccak256(contract), nodes)
+	if err != nil {
+		return fmt.Errorf("account proof is not valid for state root %x: %w", header.Root, err)
+	}
+	if value == nil {
+		return fmt.Errorf("contract %x does not exist at block %s", contract, header.Number)
+	}
+	var account ethAccount
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return fmt.Errorf("cannot decode account: %w", err)
+	}
+	if !bytes.Equal(account.Root.Bytes(), storageRoot) {
+		return fmt.Errorf("account storage root %x does not match %x", account.Root, storageRoot)
+	}
+	return nil
+}
+
+// storageEvidenceField is the protobuf field number of the storage root evidence attached
+// to a NewProcessTx. The models do not define it, so it is kept as an unknown field, which
+// is signed by the oracle along with the rest of the transaction.
+const storageEvidenceField protowire.Number = 100
+
+// AttachStorageEvidence attaches the storage root evidence of an EVM census process to
+// its NewProcessTx, replacing any evidence attached before
+func AttachStorageEvidence(tx *models.NewProcessTx, evidence *headerstore.StorageEvidence) error {
+	data, err := json.Marshal(evidence)
+	if err != nil {
+		return fmt.Errorf("cannot marshal storage evidence: %w", err)
+	}
+	unknown := removeProtoField(tx.ProtoReflect().GetUnknown(), storageEvidenceField)
+	unknown = protowire.AppendTag(unknown, storageEvidenceField, protowire.BytesType)
+	tx.ProtoReflect().SetUnknown(protowire.AppendBytes(unknown, data))
+	return nil
+}
+
+// NewProcessStorageEvidence returns the storage root evidence attached to a NewProcessTx,
+// or nil if there is none
+func NewProcessStorageEvidence(tx *models.NewProcessTx) (*headerstore.StorageEvidence, error) {
+	var data []byte
+	for b := tx.ProtoReflect().GetUnknown(); len(b) > 0; {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("cannot decode storage evidence: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if num == storageEvidenceField && typ == protowire.BytesType {
+			if data, n = protowire.ConsumeBytes(b); n < 0 {
+				return nil, fmt.Errorf("cannot decode storage evidence: %w", protowire.ParseError(n))
+			}
+		} else if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return nil, fmt.Errorf("cannot decode storage evidence: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	if data == nil {
+		return nil, nil
+	}
+	evidence := &headerstore.StorageEvidence{}
+	if err := json.Unmarshal(data, evidence); err != nil {
+		return nil, fmt.Errorf("cannot decode storage evidence: %w", err)
+	}
+	return evidence, nil
+}
+
+// removeProtoField returns the encoded protobuf fields b without the field num
+func removeProtoField(b []byte, num protowire.Number) []byte {
+	var fields []byte
+	for len(b) > 0 {
+		fnum, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fields
+		}
+		m := protowire.ConsumeFieldValue(fnum, typ, b[n:])
+		if m < 0 {
+			return fields
+		}
+		if fnum != num {
+			fields = append(fields, b[:n+m]...)
+		}
+		b = b[n+m:]
+	}
+	return fields
+}
+
 // VerifySignatureAgainstOracles verifies that a signature match with one of the oracles
 func verifySignatureAgainstOracles(oracles []ethcommon.Address, message,
 	signature []byte) (bool, ethcommon.Address, error) {
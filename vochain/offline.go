@@ -0,0 +1,80 @@
+package vochain
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/store"
+	tmdb "github.com/tendermint/tm-db"
+	"go.vocdoni.io/dvote/log"
+)
+
+// NewOfflineApplication opens the local Vochain application state and the Tendermint
+// block and state stores found in dataDir, without starting a Tendermint node.
+// It is meant for maintenance tasks, such as rebuilding the scrutinizer database,
+// which need read access to the local blockchain while no node is running.
+// The returned function must be called to close the underlying databases.
+func NewOfflineApplication(dataDir string) (*BaseApplication, func() error, error) {
+	dbDir := filepath.Join(dataDir, "data")
+	app, err := NewBaseApplication(dbDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	blockDB, err := tmdb.NewDB("blockstore", tmdbBackend, dbDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open block store: %w", err)
+	}
+	stateDB, err := tmdb.NewDB("state", tmdbBackend, dbDir)
+	if err != nil {
+		blockDB.Close()
+		return nil, nil, fmt.Errorf("cannot open tendermint state store: %w", err)
+	}
+	blockStore := store.NewBlockStore(blockDB)
+	stateStore := sm.NewStore(stateDB)
+
+	app.SetFnGetBlockByHash(blockStore.LoadBlockByHash)
+	app.SetFnGetBlockByHeight(blockStore.LoadBlock)
+	app.fnGetBlockTxResults = func(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+		responses, err := stateStore.LoadABCIResponses(height)
+		if err != nil {
+			return nil, err
+		}
+		return responses.DeliverTxs, nil
+	}
+
+	// Blocks stored by Tendermint but not committed by the application are ignored,
+	// the application state is the source of truth regarding the chain height.
+	var height int64
+	if header := app.State.Header(true); header != nil {
+		height = header.Height
+	}
+	if bh := blockStore.Height(); bh < height {
+		height = bh
+	}
+	atomic.StoreUint32(&app.height, uint32(height))
+	log.Infof("opened offline vochain application at height %d", height)
+
+	closeFn := func() error {
+		if err := blockDB.Close(); err != nil {
+			return err
+		}
+		if err := stateDB.Close(); err != nil {
+			return err
+		}
+		return app.State.Store.Close()
+	}
+	return app, closeFn, nil
+}
+
+// GetBlockTxResults returns the DeliverTx responses of the transactions included in the
+// block at height, in the same order as the block transactions.
+// It is only available on offline applications (see NewOfflineApplication).
+func (app *BaseApplication) GetBlockTxResults(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+	if app.fnGetBlockTxResults == nil {
+		return nil, fmt.Errorf("block transaction results are not available")
+	}
+	return app.fnGetBlockTxResults(height)
+}
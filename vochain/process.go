@@ -329,7 +329,8 @@ func NewProcessTxCheck(vtx *models.Tx, txBytes,
 		return nil, err
 	}
 	if !authorized {
-		return nil, fmt.Errorf("unauthorized to create a process, recovered addr is %s", addr.Hex())
+		return nil, txErrorf(ErrUnauthorized, "unauthorized to create a process, recovered addr is %s",
+			addr.Hex())
 	}
 	// get process
 	_, err = state.Process(tx.Process.ProcessId, false)
@@ -344,6 +345,15 @@ func NewProcessTxCheck(vtx *models.Tx, txBytes,
 	case tx.Process.EnvelopeType.Serial:
 		return nil, fmt.Errorf("serial process not yet implemented")
 	}
+	if !state.CensusOriginActive(tx.Process.CensusOrigin) {
+		return nil, fmt.Errorf("census origin %s not yet enabled", tx.Process.CensusOrigin)
+	}
+	if CensusOrigins[tx.Process.CensusOrigin].NeedsIndexSlot &&
+		state.UpgradeActive(UpgradeStorageEvidence) {
+		if err := checkNewProcessStorageEvidence(tx); err != nil {
+			return nil, err
+		}
+	}
 
 	if tx.Process.EnvelopeType.EncryptedVotes || tx.Process.EnvelopeType.Anonymous {
 		// We consider the zero value as nil for security
@@ -355,6 +365,30 @@ func NewProcessTxCheck(vtx *models.Tx, txBytes,
 	return tx.Process, nil
 }
 
+// checkNewProcessStorageEvidence checks the storage root evidence attached to a new EVM
+// census process: the census root must be the storage root of the token contract (the
+// process entity) on the state of the Ethereum block at the process source height
+func checkNewProcessStorageEvidence(tx *models.NewProcessTx) error {
+	evidence, err := NewProcessStorageEvidence(tx)
+	if err != nil {
+		return err
+	}
+	if evidence == nil {
+		return fmt.Errorf("missing storage root evidence for census origin %s",
+			tx.Process.CensusOrigin)
+	}
+	if evidence.Header == nil || evidence.Header.Number == nil ||
+		evidence.Header.Number.Uint64() != tx.Process.GetSourceBlockHeight() {
+		return fmt.Errorf("storage root evidence block does not match the source block height %d",
+			tx.Process.GetSourceBlockHeight())
+	}
+	if err := CheckStorageRootEvidence(evidence.Header, evidence.Proof,
+		tx.Process.EntityId, tx.Process.CensusRoot); err != nil {
+		return fmt.Errorf("invalid storage root evidence: %w", err)
+	}
+	return nil
+}
+
 // SetProcessTxCheck is an abstraction of ABCI checkTx for canceling an existing process
 func SetProcessTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error {
 	tx := vtx.GetSetProcess()
@@ -373,7 +407,8 @@ func SetProcessTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State)
 		return err
 	}
 	if !authorized {
-		return fmt.Errorf("unauthorized to set process status, recovered addr is %s", addr.Hex())
+		return txErrorf(ErrUnauthorized, "unauthorized to set process status, recovered addr is %s",
+			addr.Hex())
 	}
 	// get process
 	process, err := state.Process(tx.ProcessId, false)
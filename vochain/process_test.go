@@ -1,6 +1,7 @@
 package vochain
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -471,3 +472,103 @@ func testSetProcessCensus(t *testing.T, pid []byte, oracle *ethereum.SignKeys,
 	app.Commit()
 	return nil
 }
+
+func TestSetProcessCheckTxCodes(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.SignKeys{}
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddOracle(common.HexToAddress(oracle.AddressString())); err != nil {
+		t.Fatal(err)
+	}
+	notOracle := ethereum.SignKeys{}
+	if err := notOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	censusURI := ipfsUrl
+	pid := util.RandomBytes(types.ProcessIDsize)
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{Interruptible: true},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 16, MaxValue: 16},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   util.RandomBytes(32),
+		CensusURI:    &censusURI,
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	setProcessTx := func(pid []byte, signer *ethereum.SignKeys) []byte {
+		status := models.ProcessStatus_PAUSED
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_SetProcess{
+			SetProcess: &models.SetProcessTx{
+				Txtype:    models.TxType_SET_PROCESS_STATUS,
+				Nonce:     util.RandomBytes(32),
+				ProcessId: pid,
+				Status:    &status,
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stx.Signature, err = signer.Sign(stx.Tx); err != nil {
+			t.Fatal(err)
+		}
+		txBytes, err := proto.Marshal(&stx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+	checkTx := func(pid []byte, signer *ethereum.SignKeys) uint32 {
+		return app.CheckTx(abcitypes.RequestCheckTx{Tx: setProcessTx(pid, signer)}).Code
+	}
+
+	if code := checkTx(pid, &notOracle); code != TxCodeUnauthorized {
+		t.Fatalf("expected code %d for a non oracle signer, got %d", TxCodeUnauthorized, code)
+	}
+	missing := util.RandomBytes(types.ProcessIDsize)
+	if code := checkTx(missing, &oracle); code != TxCodeProcessNotFound {
+		t.Fatalf("expected code %d for a missing process, got %d", TxCodeProcessNotFound, code)
+	}
+	if code := checkTx(pid, &oracle); code != TxCodeOK {
+		t.Fatalf("expected code %d, got %d", TxCodeOK, code)
+	}
+
+	// DeliverTx keeps the code and message of the results of the past blocks
+	resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: setProcessTx(pid, &notOracle)})
+	if resp.Code != 1 {
+		t.Fatalf("expected deliverTx code 1, got %d", resp.Code)
+	}
+	expected := "setProcess unauthorized to set process status, recovered addr is " +
+		notOracle.Address().Hex()
+	if string(resp.Data) != expected {
+		t.Fatalf("expected deliverTx data %q, got %q", expected, resp.Data)
+	}
+}
+
+func TestTxErrorWrap(t *testing.T) {
+	cause := fmt.Errorf("merkle tree error")
+	err := txErrorf(ErrInvalidCensusProof, "proof not valid: (%w)", cause)
+	if err.Error() != "proof not valid: (merkle tree error)" {
+		t.Fatalf("unexpected error message %q", err)
+	}
+	if !errors.Is(err, ErrInvalidCensusProof) || !errors.Is(err, cause) {
+		t.Fatalf("%v does not wrap both the error kind and its cause", err)
+	}
+	if errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("%v is not an unauthorized error", err)
+	}
+	if code := TxCode(fmt.Errorf("add tx: %w", err)); code != TxCodeInvalidCensusProof {
+		t.Fatalf("expected code %d, got %d", TxCodeInvalidCensusProof, code)
+	}
+}
@@ -4,12 +4,21 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	qt "github.com/frankban/quicktest"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
 	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
 	tree "go.vocdoni.io/dvote/censustree/gravitontree"
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/ethereum/headerstore"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/test/testcommon/testutil"
 	"go.vocdoni.io/dvote/types"
@@ -635,3 +644,206 @@ var ethVotingProofs = string(
     ]
   }  
   `)
+
+// proofList stores the trie proof nodes in order, from the root to the leaf
+type proofList [][]byte
+
+func (l *proofList) Put(key []byte, value []byte) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (l *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
+
+func TestNFTProof(t *testing.T) {
+	holder := ethereum.NewSignKeys()
+	qt.Assert(t, holder.Generate(), qt.IsNil)
+	indexSlot := uint32(3)
+	tokenID := "42"
+	for _, origin := range []models.CensusOrigin{models.CensusOrigin_ERC721, models.CensusOrigin_ERC1155} {
+		process := &models.Process{
+			ProcessId:    util.RandomBytes(types.ProcessIDsize),
+			CensusOrigin: origin,
+			EthIndexSlot: &indexSlot,
+			CensusURI:    &tokenID,
+		}
+		slot, err := StorageSlot(process, holder.Address())
+		qt.Assert(t, err, qt.IsNil)
+
+		// Build the contract storage with the holder owning 2 tokens and some other holders
+		storage, err := trie.NewSecure(ethcommon.Hash{}, trie.NewDatabase(memorydb.New()))
+		qt.Assert(t, err, qt.IsNil)
+		setBalance := func(slot []byte, balance int64) {
+			value, err := rlp.EncodeToBytes(big.NewInt(balance).Bytes())
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, storage.TryUpdate(slot, value), qt.IsNil)
+		}
+		setBalance(slot, 2)
+		for i := 0; i < 10; i++ {
+			setBalance(util.RandomBytes(32), 1)
+		}
+		process.CensusRoot = storage.Hash().Bytes()
+		var nodes proofList
+		// the secure trie proofs are built from the hashed key
+		qt.Assert(t, storage.Prove(crypto.Keccak256(slot), 0, &nodes), qt.IsNil)
+
+		proof := &models.Proof{Payload: &models.Proof_EthereumStorage{
+			EthereumStorage: &models.ProofEthereumStorage{
+				Key:      slot,
+				Value:    big.NewInt(2).Bytes(),
+				Siblings: nodes,
+			},
+		}}
+		valid, weight, err := CheckProof(proof, origin, process.CensusRoot, process.ProcessId, slot)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, valid, qt.IsTrue)
+		qt.Assert(t, weight.Int64(), qt.Equals, int64(2))
+
+		// A wrong balance does not verify
+		proof.GetEthereumStorage().Value = big.NewInt(5).Bytes()
+		valid, _, _ = CheckProof(proof, origin, process.CensusRoot, process.ProcessId, slot)
+		qt.Assert(t, valid, qt.IsFalse)
+
+		// Holders without tokens are rejected
+		proof.GetEthereumStorage().Value = nil
+		_, _, err = CheckProof(proof, origin, process.CensusRoot, process.ProcessId, slot)
+		qt.Assert(t, err, qt.Not(qt.IsNil))
+	}
+
+	// ERC1155 slots depend on the token ID, and it must be valid
+	process := &models.Process{CensusOrigin: models.CensusOrigin_ERC1155, EthIndexSlot: &indexSlot}
+	otherID := "43"
+	process.CensusURI = &tokenID
+	slot42, err := StorageSlot(process, holder.Address())
+	qt.Assert(t, err, qt.IsNil)
+	process.CensusURI = &otherID
+	slot43, err := StorageSlot(process, holder.Address())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, slot42, qt.Not(qt.DeepEquals), slot43)
+	invalidID := "foo"
+	process.CensusURI = &invalidID
+	_, err = StorageSlot(process, holder.Address())
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
+
+// newTestStorageEvidence builds an Ethereum block header at height 100 whose state holds
+// the contract with storageRoot, and the contract account proof
+func newTestStorageEvidence(t *testing.T, contract ethcommon.Address,
+	storageRoot ethcommon.Hash) (*ethtypes.Header, *ethstorageproof.StorageProof) {
+	// Build the Ethereum state with the token contract and some other accounts
+	state, err := trie.NewSecure(ethcommon.Hash{}, trie.NewDatabase(memorydb.New()))
+	qt.Assert(t, err, qt.IsNil)
+	setAccount := func(addr ethcommon.Address, root ethcommon.Hash) {
+		value, err := rlp.EncodeToBytes(&ethAccount{
+			Nonce:    1,
+			Balance:  big.NewInt(0),
+			Root:     root,
+			CodeHash: crypto.Keccak256(addr.Bytes()),
+		})
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, state.TryUpdate(addr.Bytes(), value), qt.IsNil)
+	}
+	setAccount(contract, storageRoot)
+	for i := 0; i < 10; i++ {
+		setAccount(ethcommon.BytesToAddress(util.RandomBytes(20)),
+			ethcommon.BytesToHash(util.RandomBytes(32)))
+	}
+	header := &ethtypes.Header{Number: big.NewInt(100), Root: state.Hash(), Difficulty: big.NewInt(1)}
+
+	var nodes proofList
+	qt.Assert(t, state.Prove(crypto.Keccak256(contract.Bytes()), 0, &nodes), qt.IsNil)
+	proof := &ethstorageproof.StorageProof{Address: contract, Height: big.NewInt(100)}
+	for _, n := range nodes {
+		proof.AccountProof = append(proof.AccountProof, fmt.Sprintf("0x%x", n))
+	}
+	return header, proof
+}
+
+func TestStorageRootEvidence(t *testing.T) {
+	contract := ethcommon.BytesToAddress(util.RandomBytes(20))
+	storageRoot := ethcommon.BytesToHash(util.RandomBytes(32))
+	header, proof := newTestStorageEvidence(t, contract, storageRoot)
+	qt.Assert(t, CheckStorageRootEvidence(header, proof, contract.Bytes(), storageRoot.Bytes()), qt.IsNil)
+
+	// The storage root must match the proven one
+	err := CheckStorageRootEvidence(header, proof, contract.Bytes(), util.RandomBytes(32))
+	qt.Assert(t, err, qt.ErrorMatches, "account storage root .*")
+
+	// The proof must belong to the contract
+	err = CheckStorageRootEvidence(header, proof, util.RandomBytes(20), storageRoot.Bytes())
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// The proof must be valid for the header state root
+	header.Root = ethcommon.BytesToHash(util.RandomBytes(32))
+	err = CheckStorageRootEvidence(header, proof, contract.Bytes(), storageRoot.Bytes())
+	qt.Assert(t, err, qt.ErrorMatches, "account proof is not valid .*")
+}
+
+func TestNewProcessStorageEvidence(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	oracle := ethereum.NewSignKeys()
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(oracle.Address()), qt.IsNil)
+
+	contract := ethcommon.BytesToAddress(util.RandomBytes(20))
+	storageRoot := ethcommon.BytesToHash(util.RandomBytes(32))
+	header, proof := newTestStorageEvidence(t, contract, storageRoot)
+	evidence := &headerstore.StorageEvidence{
+		CensusRoot: storageRoot.Bytes(),
+		Contract:   contract.Bytes(),
+		Header:     header,
+		Proof:      proof,
+	}
+
+	indexSlot, sourceHeight := uint32(1), uint64(100)
+	check := func(evidence *headerstore.StorageEvidence, censusRoot []byte) error {
+		tx := &models.NewProcessTx{
+			Txtype: models.TxType_NEW_PROCESS,
+			Nonce:  util.RandomBytes(32),
+			Process: &models.Process{
+				ProcessId:         util.RandomBytes(types.ProcessIDsize),
+				StartBlock:        1,
+				EnvelopeType:      &models.EnvelopeType{},
+				Mode:              &models.ProcessMode{AutoStart: true},
+				VoteOptions:       &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+				Status:            models.ProcessStatus_READY,
+				EntityId:          contract.Bytes(),
+				CensusRoot:        censusRoot,
+				CensusOrigin:      models.CensusOrigin_ERC20,
+				EthIndexSlot:      &indexSlot,
+				SourceBlockHeight: &sourceHeight,
+				BlockCount:        1024,
+			},
+		}
+		if evidence != nil {
+			// attaching it twice keeps a single evidence
+			qt.Assert(t, AttachStorageEvidence(tx, evidence), qt.IsNil)
+			qt.Assert(t, AttachStorageEvidence(tx, evidence), qt.IsNil)
+		}
+		txBytes, err := proto.Marshal(&models.Tx{Payload: &models.Tx_NewProcess{NewProcess: tx}})
+		qt.Assert(t, err, qt.IsNil)
+		signature, err := oracle.Sign(txBytes)
+		qt.Assert(t, err, qt.IsNil)
+		// the evidence travels on the transaction bytes
+		vtx := &models.Tx{}
+		qt.Assert(t, proto.Unmarshal(txBytes, vtx), qt.IsNil)
+		_, err = NewProcessTxCheck(vtx, txBytes, signature, app.State)
+		return err
+	}
+
+	app.State.SetChainID("local-chain")
+	qt.Assert(t, check(evidence, storageRoot.Bytes()), qt.IsNil)
+	qt.Assert(t, check(nil, storageRoot.Bytes()), qt.ErrorMatches, "missing storage root evidence .*")
+	qt.Assert(t, check(evidence, util.RandomBytes(32)), qt.ErrorMatches,
+		"invalid storage root evidence: account storage root .*")
+	sourceHeight = 101
+	qt.Assert(t, check(evidence, storageRoot.Bytes()), qt.ErrorMatches,
+		"storage root evidence block does not match .*")
+
+	// Before the upgrade the evidence is not required
+	app.State.SetChainID("vocdoni-release-1.0.1")
+	qt.Assert(t, check(nil, storageRoot.Bytes()), qt.IsNil)
+}
@@ -0,0 +1,191 @@
+package scrutinizer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timshannon/badgerhold/v3"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// ExportProcess builds the audit bundle of a process: the process parameters, the
+// indexed envelopes (sorted by height and transaction index), the encryption keys and
+// the results. Only max envelopes starting at from are included, or all of them if max
+// is zero. Votes are decrypted if the process keys have been revealed.
+func (s *Scrutinizer) ExportProcess(pid []byte,
+	from, max int) (*indexertypes.ProcessExport, error) {
+	startTime := time.Now()
+	defer func() { log.Debugf("ExportProcess took %s", time.Since(startTime)) }()
+	if from < 0 || max < 0 {
+		return nil, fmt.Errorf("exportProcess: invalid value: from %d listSize %d", from, max)
+	}
+	process, err := s.ProcessInfo(pid)
+	if err != nil {
+		return nil, err
+	}
+	export := &indexertypes.ProcessExport{
+		Process:               process,
+		EncryptionPublicKeys:  process.PublicKeys,
+		EncryptionPrivateKeys: process.PrivateKeys,
+		Envelopes:             []*indexertypes.ExportedEnvelope{},
+		FinalResults:          process.FinalResults,
+		BlockHeight:           s.App.Height(),
+		From:                  from,
+	}
+
+	query := badgerhold.Where("ProcessID").Eq(pid).Index("ProcessID")
+	count, err := s.db.Count(&indexertypes.VoteReference{}, query)
+	if err != nil {
+		return nil, err
+	}
+	export.EnvelopeCount = uint64(count)
+	query = query.SortBy("Height", "TxIndex").Skip(from)
+	if max > 0 {
+		query = query.Limit(max)
+	}
+	var refs []*indexertypes.VoteReference
+	if err := s.db.Find(&refs, query); err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		stx, txHash, err := s.App.GetTxHash(ref.Height, ref.TxIndex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get envelope %x: %w", ref.Nullifier, err)
+		}
+		tx := &models.Tx{}
+		if err := proto.Unmarshal(stx.Tx, tx); err != nil {
+			return nil, err
+		}
+		vote := tx.GetVote()
+		if vote == nil {
+			return nil, fmt.Errorf("transaction %d/%d is not an envelope", ref.Height, ref.TxIndex)
+		}
+		envelope := &indexertypes.ExportedEnvelope{
+			Nullifier:    ref.Nullifier,
+			Height:       ref.Height,
+			TxIndex:      ref.TxIndex,
+			TxHash:       txHash,
+			Weight:       ref.Weight.String(),
+			CreationTime: ref.CreationTime,
+		}
+		if vp, err := unmarshalEnvelope(process, vote); err == nil {
+			envelope.Votes = vp.Votes
+		}
+		export.Envelopes = append(export.Envelopes, envelope)
+	}
+
+	if process.HaveResults {
+		results, err := s.GetResults(pid)
+		if err != nil && err != ErrNoResultsYet {
+			return nil, err
+		}
+		if results != nil {
+			export.Results = GetFriendlyResults(results.Votes)
+			export.ResultsWeight = results.Weight.String()
+		}
+	}
+	return export, nil
+}
+
+// ExportCSV encodes the process export as a zip archive containing the CSV files
+// process.csv, keys.csv, envelopes.csv and results.csv.
+func ExportCSV(export *indexertypes.ProcessExport) ([]byte, error) {
+	p := export.Process
+	files := []struct {
+		name    string
+		records [][]string
+	}{
+		{"process.csv", [][]string{
+			{"field", "value"},
+			{"processId", fmt.Sprintf("%x", p.ID)},
+			{"entityId", fmt.Sprintf("%x", p.EntityID)},
+			{"startBlock", fmt.Sprint(p.StartBlock)},
+			{"endBlock", fmt.Sprint(p.EndBlock)},
+			{"censusRoot", fmt.Sprintf("%x", p.CensusRoot)},
+			{"censusURI", p.CensusURI},
+			{"censusOrigin", models.CensusOrigin(p.CensusOrigin).String()},
+			{"status", models.ProcessStatus(p.Status).String()},
+			{"namespace", fmt.Sprint(p.Namespace)},
+			{"encryptedVotes", fmt.Sprint(p.Envelope.GetEncryptedVotes())},
+			{"anonymous", fmt.Sprint(p.Envelope.GetAnonymous())},
+			{"maxCount", fmt.Sprint(p.VoteOpts.GetMaxCount())},
+			{"maxValue", fmt.Sprint(p.VoteOpts.GetMaxValue())},
+			{"metadata", p.Metadata},
+			{"creationTime", p.CreationTime.UTC().Format(time.RFC3339)},
+			{"finalResults", fmt.Sprint(export.FinalResults)},
+			{"blockHeight", fmt.Sprint(export.BlockHeight)},
+		}},
+		{"keys.csv", exportKeysCSV(export)},
+		{"envelopes.csv", exportEnvelopesCSV(export)},
+		{"results.csv", exportResultsCSV(export)},
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := csv.NewWriter(w).WriteAll(f.records); err != nil {
+			return nil, fmt.Errorf("cannot write %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func exportKeysCSV(export *indexertypes.ProcessExport) [][]string {
+	records := [][]string{{"index", "publicKey", "privateKey"}}
+	for i, pub := range export.EncryptionPublicKeys {
+		var priv string
+		if i < len(export.EncryptionPrivateKeys) {
+			priv = export.EncryptionPrivateKeys[i]
+		}
+		if pub == "" && priv == "" {
+			continue
+		}
+		records = append(records, []string{strconv.Itoa(i), pub, priv})
+	}
+	return records
+}
+
+func exportEnvelopesCSV(export *indexertypes.ProcessExport) [][]string {
+	records := [][]string{{"nullifier", "height", "txIndex", "txHash", "weight", "votes", "creationTime"}}
+	for _, e := range export.Envelopes {
+		votes := make([]string, len(e.Votes))
+		for i, v := range e.Votes {
+			votes[i] = strconv.Itoa(v)
+		}
+		records = append(records, []string{
+			fmt.Sprintf("%x", e.Nullifier),
+			fmt.Sprint(e.Height),
+			fmt.Sprint(e.TxIndex),
+			fmt.Sprintf("%x", e.TxHash),
+			e.Weight,
+			strings.Join(votes, " "),
+			e.CreationTime.UTC().Format(time.RFC3339),
+		})
+	}
+	return records
+}
+
+func exportResultsCSV(export *indexertypes.ProcessExport) [][]string {
+	records := [][]string{{"question", "value", "votes"}}
+	for q, values := range export.Results {
+		for v, votes := range values {
+			records = append(records, []string{strconv.Itoa(q), strconv.Itoa(v), votes})
+		}
+	}
+	return records
+}
@@ -0,0 +1,60 @@
+package scrutinizer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestExportProcess(t *testing.T) {
+	chain, pid := newTestChainWithVotes(t)
+	sc, err := NewScrutinizer(t.TempDir(), chain.app, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sc.Rebuild(), qt.IsNil)
+
+	export, err := sc.ExportProcess(pid, 0, 0)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, []byte(export.Process.ID), qt.DeepEquals, pid)
+	qt.Assert(t, export.FinalResults, qt.IsTrue)
+	qt.Assert(t, export.Envelopes, qt.HasLen, 10)
+	qt.Assert(t, export.EnvelopeCount, qt.Equals, uint64(10))
+	for i, e := range export.Envelopes {
+		qt.Assert(t, e.Height, qt.Equals, uint32(2))
+		qt.Assert(t, e.TxIndex, qt.Equals, int32(i))
+		qt.Assert(t, e.TxHash, qt.HasLen, 32)
+		qt.Assert(t, e.Votes, qt.DeepEquals, []int{1, 0})
+	}
+	qt.Assert(t, export.Results, qt.DeepEquals, [][]string{{"0", "10"}, {"10", "0"}})
+
+	// The envelopes can be exported in pages
+	page, err := sc.ExportProcess(pid, 8, 4)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, page.EnvelopeCount, qt.Equals, uint64(10))
+	qt.Assert(t, page.From, qt.Equals, 8)
+	qt.Assert(t, page.Envelopes, qt.DeepEquals, export.Envelopes[8:])
+	_, err = sc.ExportProcess(pid, -1, 4)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	bundle, err := ExportCSV(export)
+	qt.Assert(t, err, qt.IsNil)
+	zr, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	qt.Assert(t, err, qt.IsNil)
+	files := make(map[string][][]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		qt.Assert(t, err, qt.IsNil)
+		files[f.Name], err = csv.NewReader(r).ReadAll()
+		qt.Assert(t, err, qt.IsNil)
+		r.Close()
+	}
+	qt.Assert(t, files, qt.HasLen, 4)
+	// header plus one row per envelope
+	qt.Assert(t, files["envelopes.csv"], qt.HasLen, 11)
+	qt.Assert(t, files["envelopes.csv"][1][5], qt.Equals, "1 0")
+	// header plus one row per question and value
+	qt.Assert(t, files["results.csv"], qt.HasLen, 5)
+	qt.Assert(t, files["results.csv"][2], qt.DeepEquals, []string{"0", "1", "10"})
+}
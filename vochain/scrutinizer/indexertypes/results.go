@@ -17,12 +17,21 @@ const (
 	MaxOptions = 128
 )
 
+// Errors returned by AddVote when the vote does not follow the ballot protocol
+var (
+	ErrMaxCountOverflow = fmt.Errorf("max count overflow")
+	ErrValuesNotUnique  = fmt.Errorf("values are not unique")
+	ErrMaxValueOverflow = fmt.Errorf("max value overflow")
+	ErrMaxCostOverflow  = fmt.Errorf("max total cost overflow")
+)
+
 // Results holds the final results and relevant process info for a vochain process
 type Results struct {
 	ProcessID      types.HexBytes `badgerholdKey:"ProcessID"`
 	Votes          [][]*big.Int
 	Weight         *big.Int
 	EnvelopeHeight uint64
+	InvalidVotes   uint64
 	EnvelopeType   *models.EnvelopeType       `json:"envelopeType"`
 	VoteOpts       *models.ProcessVoteOptions `json:"voteOptions"`
 	Signatures     []types.HexBytes
@@ -54,6 +63,7 @@ func (r *Results) Add(new *Results) error {
 		r.BlockHeight = new.BlockHeight
 	}
 	r.EnvelopeHeight += new.EnvelopeHeight
+	r.InvalidVotes += new.InvalidVotes
 	// Update votes only if present
 	if len(new.Votes) == 0 {
 		return nil
@@ -94,7 +104,7 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 	}
 	// MaxCount
 	if len(voteValues) > int(r.VoteOpts.MaxCount) || len(voteValues) > MaxOptions {
-		return fmt.Errorf("max count overflow %d", len(voteValues))
+		return fmt.Errorf("%w %d", ErrMaxCountOverflow, len(voteValues))
 	}
 
 	// UniqueValues
@@ -102,7 +112,7 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 		votes := make(map[int]bool, len(voteValues))
 		for _, v := range voteValues {
 			if votes[v] {
-				return fmt.Errorf("values are not unique")
+				return ErrValuesNotUnique
 			}
 			votes[v] = true
 		}
@@ -112,7 +122,7 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 	if r.VoteOpts.MaxValue > 0 {
 		for _, v := range voteValues {
 			if uint32(v) > r.VoteOpts.MaxValue {
-				return fmt.Errorf("max value overflow %d", v)
+				return fmt.Errorf("%w %d", ErrMaxValueOverflow, v)
 			}
 		}
 	}
@@ -130,7 +140,7 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 		for _, v := range voteValues {
 			cost.Add(cost, new(big.Int).Exp(new(big.Int).SetUint64(uint64(v)), exponent, nil))
 			if cost.Cmp(maxCost) > 0 {
-				return fmt.Errorf("max total cost overflow: %s", cost)
+				return fmt.Errorf("%w: %s", ErrMaxCostOverflow, cost)
 			}
 		}
 	}
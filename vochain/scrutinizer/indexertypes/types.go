@@ -86,6 +86,29 @@ type VoteReference struct {
 	CreationTime time.Time
 }
 
+// InvalidReason is the category of the reason why an envelope has not been counted
+type InvalidReason string
+
+// Reasons why an envelope has not been counted on the results
+const (
+	InvalidReasonDecryption   InvalidReason = "decryptionFailure"
+	InvalidReasonMalformed    InvalidReason = "malformedBallot"
+	InvalidReasonMaxCount     InvalidReason = "maxCountOverflow"
+	InvalidReasonMaxValue     InvalidReason = "maxValueOverflow"
+	InvalidReasonNotUnique    InvalidReason = "nonUniqueValues"
+	InvalidReasonCostExceeded InvalidReason = "costExceeded"
+	InvalidReasonOther        InvalidReason = "other"
+)
+
+// InvalidEnvelope holds the db reference for an envelope that has not been counted
+// on the results, the reason category and the error message as detail
+type InvalidEnvelope struct {
+	Nullifier types.HexBytes `badgerholdKey:"Nullifier" json:"nullifier"`
+	ProcessID types.HexBytes `badgerholdIndex:"ProcessID" json:"process_id"`
+	Reason    InvalidReason  `json:"reason"`
+	Detail    string         `json:"detail"`
+}
+
 // EnvelopeMetadata contains vote information for the EnvelopeList api
 type EnvelopeMetadata struct {
 	ProcessId types.HexBytes `json:"process_id"`
@@ -95,6 +118,48 @@ type EnvelopeMetadata struct {
 	TxHash    types.HexBytes `json:"tx_hash"`
 }
 
+// VoterRecord contains the inclusion information of a voter envelope for the VoterHistory api
+type VoterRecord struct {
+	ProcessID    types.HexBytes `json:"process_id"`
+	Nullifier    types.HexBytes `json:"nullifier"`
+	Height       uint32         `json:"height"`
+	TxIndex      int32          `json:"tx_index"`
+	TxHash       types.HexBytes `json:"tx_hash"`
+	Weight       string         `json:"weight"`
+	CreationTime time.Time      `json:"creation_time"`
+	// MaxVoteOverwrites is the number of times the process allows the envelope to be
+	// overwritten. The chain rejects repeated nullifiers, so the record is the final vote.
+	MaxVoteOverwrites uint32 `json:"max_vote_overwrites"`
+}
+
+// ProcessExport is a self-contained audit bundle of a process, containing its parameters,
+// its envelopes, the keykeeper keys and the computed results. The envelopes may be a page
+// of EnvelopeCount starting at From.
+type ProcessExport struct {
+	Process               *Process            `json:"process"`
+	EncryptionPublicKeys  []string            `json:"encryptionPublicKeys,omitempty"`
+	EncryptionPrivateKeys []string            `json:"encryptionPrivateKeys,omitempty"`
+	Envelopes             []*ExportedEnvelope `json:"envelopes"`
+	EnvelopeCount         uint64              `json:"envelopeCount"`
+	From                  int                 `json:"from"`
+	Results               [][]string          `json:"results,omitempty"`
+	ResultsWeight         string              `json:"resultsWeight,omitempty"`
+	FinalResults          bool                `json:"finalResults"`
+	BlockHeight           uint32              `json:"blockHeight"`
+}
+
+// ExportedEnvelope contains an envelope of a ProcessExport. Votes are only included
+// if the vote package is not encrypted or the encryption keys have been revealed.
+type ExportedEnvelope struct {
+	Nullifier    types.HexBytes `json:"nullifier"`
+	Height       uint32         `json:"height"`
+	TxIndex      int32          `json:"tx_index"`
+	TxHash       types.HexBytes `json:"tx_hash"`
+	Weight       string         `json:"weight"`
+	Votes        []int          `json:"votes,omitempty"`
+	CreationTime time.Time      `json:"creation_time"`
+}
+
 // EnvelopePackage contains a VoteEnvelope and auxiliary information for the Envelope api
 type EnvelopePackage struct {
 	EncryptionKeyIndexes []uint32         `json:"encryption_key_indexes"`
@@ -243,6 +243,13 @@ func (s *Scrutinizer) computePendingProcesses(height uint32) {
 // newEmptyProcess creates a new empty process and stores it into the database.
 // The process must exist on the Vochain state, else an error is returned.
 func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
+	// Get the block time from the Header
+	return s.newEmptyProcessAt(pid, time.Unix(s.App.State.Header(false).Timestamp, 0))
+}
+
+// newEmptyProcessAt does the same as newEmptyProcess, but uses blockTime as the
+// process and entity creation time.
+func (s *Scrutinizer) newEmptyProcessAt(pid []byte, blockTime time.Time) error {
 	p, err := s.App.State.Process(pid, false)
 	if err != nil {
 		return fmt.Errorf("cannot create new empty process: %w", err)
@@ -290,9 +297,6 @@ func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
 	},
 	)
 
-	// Get the block time from the Header
-	currentBlockTime := time.Unix(s.App.State.Header(false).Timestamp, 0)
-
 	// Add the entity to the indexer database
 	eid := p.GetEntityId()
 	entity := &indexertypes.Entity{}
@@ -302,7 +306,7 @@ func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
 			return err
 		}
 		entity.ID = eid
-		entity.CreationTime = currentBlockTime
+		entity.CreationTime = blockTime
 		entity.ProcessCount = 0
 		// Increment the total entity count storage
 		s.db.UpdateMatching(&indexertypes.CountStore{}, badgerhold.Where(badgerhold.Key).Eq(indexertypes.CountStoreEntities), func(record interface{}) error {
@@ -349,7 +353,7 @@ func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
 		Envelope:          p.GetEnvelopeType(),
 		Mode:              p.GetMode(),
 		VoteOpts:          p.GetVoteOptions(),
-		CreationTime:      currentBlockTime,
+		CreationTime:      blockTime,
 		SourceBlockHeight: p.GetSourceBlockHeight(),
 		SourceNetworkId:   p.SourceNetworkId.String(),
 		Metadata:          p.GetMetadata(),
@@ -385,24 +389,8 @@ func (s *Scrutinizer) updateProcess(pid []byte) error {
 			update.HaveResults = false
 			update.FinalResults = true
 			update.Rheight = 0
-			if err := s.db.UpdateMatching(&indexertypes.Results{},
-				badgerhold.Where(badgerhold.Key).Eq(pid), func(record interface{}) error {
-					results, ok := record.(*indexertypes.Results)
-					if !ok {
-						return fmt.Errorf("record isn't the correct type! Wanted Result, got %T", record)
-					}
-					// On cancelled process, remove all results except for envelope height, weight, pid
-					results.Votes = [][]*big.Int{}
-					results.EnvelopeType = &models.EnvelopeType{}
-					results.VoteOpts = &models.ProcessVoteOptions{}
-					results.Signatures = []types.HexBytes{}
-					results.Final = false
-					results.BlockHeight = 0
-					return nil
-				}); err != nil {
-				if err != badgerhold.ErrNotFound {
-					log.Warnf("cannot remove CANCELED results: %v", err)
-				}
+			if err := s.resetCanceledResults(pid); err != nil {
+				log.Warnf("cannot remove CANCELED results: %v", err)
 			}
 		}
 		update.Status = int32(p.GetStatus())
@@ -414,6 +402,28 @@ func (s *Scrutinizer) updateProcess(pid []byte) error {
 	})
 }
 
+// resetCanceledResults removes all the results of a canceled process, except for
+// the envelope height, the weight and the process id.
+func (s *Scrutinizer) resetCanceledResults(pid []byte) error {
+	if err := s.db.UpdateMatching(&indexertypes.Results{},
+		badgerhold.Where(badgerhold.Key).Eq(pid), func(record interface{}) error {
+			results, ok := record.(*indexertypes.Results)
+			if !ok {
+				return fmt.Errorf("record isn't the correct type! Wanted Result, got %T", record)
+			}
+			results.Votes = [][]*big.Int{}
+			results.EnvelopeType = &models.EnvelopeType{}
+			results.VoteOpts = &models.ProcessVoteOptions{}
+			results.Signatures = []types.HexBytes{}
+			results.Final = false
+			results.BlockHeight = 0
+			return nil
+		}); err != nil && err != badgerhold.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
 // setResultsHeight updates the Rheight of any process whose ID is pid.
 func (s *Scrutinizer) setResultsHeight(pid []byte, height uint32) error {
 	return s.queryWithRetries(func() error {
@@ -0,0 +1,362 @@
+package scrutinizer
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/timshannon/badgerhold/v3"
+	"go.vocdoni.io/dvote/db/lru"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// Inconsistency kinds reported by Verify
+const (
+	InconsistencyEnvelopeCount = "envelopeCount"
+	InconsistencyEnvelope      = "envelope"
+	InconsistencyResults       = "results"
+	InconsistencyTransaction   = "transaction"
+	InconsistencyCountStore    = "countStore"
+)
+
+// Inconsistency describes a mismatch found between the scrutinizer database and the
+// Vochain state or the block store.
+type Inconsistency struct {
+	Kind    string
+	ID      types.HexBytes
+	Message string
+}
+
+func (i *Inconsistency) String() string {
+	return fmt.Sprintf("%s %x: %s", i.Kind, i.ID, i.Message)
+}
+
+// Rebuild wipes the scrutinizer database and indexes again all the transactions,
+// processes and envelopes found on the block store, up to the current application height.
+// Only the transactions that were successfully delivered are indexed, so the application
+// must provide the block transaction results (see vochain.NewOfflineApplication).
+// Final results are computed for all finished processes, live results are left to
+// AfterSyncBootstrap. This method must not be executed while the Vochain is running.
+func (s *Scrutinizer) Rebuild() error {
+	height := s.App.Height()
+	log.Infof("rebuilding scrutinizer database up to height %d", height)
+	startTime := time.Now()
+	if err := s.db.Badger().DropAll(); err != nil {
+		return fmt.Errorf("cannot wipe scrutinizer database: %w", err)
+	}
+	s.envelopeHeightCache = lru.New(countEnvelopeCacheSize)
+	s.resultsCache = lru.New(resultsCacheSize)
+	s.liveResultsProcs = sync.Map{}
+
+	var processes [][]byte
+	var txCount, voteCount uint64
+	for h := uint32(1); h <= height; h++ {
+		block := s.App.GetBlockByHeight(int64(h))
+		if block == nil {
+			return fmt.Errorf("cannot fetch block %d", h)
+		}
+		if len(block.Txs) == 0 {
+			continue
+		}
+		txResults, err := s.App.GetBlockTxResults(int64(h))
+		if err != nil {
+			return fmt.Errorf("cannot fetch transaction results for block %d: %w", h, err)
+		}
+		if len(txResults) != len(block.Txs) {
+			return fmt.Errorf("block %d has %d transactions but %d results",
+				h, len(block.Txs), len(txResults))
+		}
+		txn := s.db.Badger().NewTransaction(true)
+		for i, rawTx := range block.Txs {
+			if txResults[i].Code != 0 {
+				continue
+			}
+			txCount++
+			if err := s.db.TxInsert(txn, txCount, &indexertypes.TxReference{
+				Index:        txCount,
+				BlockHeight:  h,
+				TxBlockIndex: int32(i),
+			}); err != nil {
+				txn.Discard()
+				return fmt.Errorf("cannot index transaction %d: %w", txCount, err)
+			}
+			tx, txBytes, signature, err := vochain.UnmarshalTx(rawTx)
+			if err != nil {
+				log.Warnf("cannot unmarshal transaction %d of block %d: %v", i, h, err)
+				continue
+			}
+			switch {
+			case tx.GetNewProcess() != nil:
+				pid := tx.GetNewProcess().GetProcess().GetProcessId()
+				if err := s.newEmptyProcessAt(pid, block.Time); err != nil {
+					log.Warnf("cannot index process %x: %v", pid, err)
+					continue
+				}
+				processes = append(processes, pid)
+			case tx.GetVote() != nil:
+				vote := tx.GetVote()
+				process, err := s.App.State.Process(vote.ProcessId, true)
+				if err != nil {
+					log.Warnf("cannot fetch process %x: %v", vote.ProcessId, err)
+					continue
+				}
+				nullifier, weight, err := vochain.CheckVoteProof(process, vote, txBytes, signature)
+				if err != nil {
+					log.Warnf("cannot check vote %d of block %d: %v", i, h, err)
+					continue
+				}
+				if err := s.db.TxInsert(txn, nullifier, &indexertypes.VoteReference{
+					Nullifier:    nullifier,
+					ProcessID:    vote.ProcessId,
+					Height:       h,
+					Weight:       weight,
+					TxIndex:      int32(i),
+					CreationTime: block.Time,
+				}); err != nil {
+					log.Warnf("cannot index envelope %x: %v", nullifier, err)
+					continue
+				}
+				voteCount++
+			}
+		}
+		if err := txn.Commit(); err != nil {
+			return fmt.Errorf("cannot commit block %d: %w", h, err)
+		}
+		if h%1000 == 0 {
+			log.Infof("rebuilt scrutinizer up to height %d, %d transactions and %d envelopes",
+				h, txCount, voteCount)
+		}
+	}
+
+	// Compute the final results of the finished processes
+	for _, pid := range processes {
+		process, err := s.App.State.Process(pid, true)
+		if err != nil {
+			log.Warnf("cannot fetch process %x: %v", pid, err)
+			continue
+		}
+		switch {
+		case process.Status == models.ProcessStatus_CANCELED:
+			if err := s.setCanceled(pid); err != nil {
+				log.Warnf("cannot update canceled process %x: %v", pid, err)
+			}
+		case isFinished(process, height):
+			if err := s.ComputeResult(pid); err != nil {
+				log.Warnf("cannot compute results for %x: %v", pid, err)
+			}
+		}
+	}
+
+	// The count stores were wiped with the database, retrieveCounts creates them again
+	counts, err := s.retrieveCounts()
+	if err != nil {
+		return err
+	}
+	log.Infof("scrutinizer rebuild took %s, indexed %d transactions, %d envelopes, "+
+		"%d processes and %d entities", time.Since(startTime),
+		counts[indexertypes.CountStoreTransactions],
+		counts[indexertypes.CountStoreEnvelopes],
+		counts[indexertypes.CountStoreProcesses],
+		counts[indexertypes.CountStoreEntities])
+	return nil
+}
+
+// Close closes the scrutinizer database
+func (s *Scrutinizer) Close() error {
+	return s.db.Close()
+}
+
+// isFinished returns true if the process is not expecting more votes and the results
+// can be computed (all encryption keys are revealed if required).
+func isFinished(p *models.Process, height uint32) bool {
+	if p.Status != models.ProcessStatus_ENDED && p.Status != models.ProcessStatus_RESULTS &&
+		height <= p.StartBlock+p.BlockCount {
+		return false
+	}
+	if p.EnvelopeType.GetEncryptedVotes() && p.KeyIndex != nil && *p.KeyIndex > 0 {
+		return false
+	}
+	return true
+}
+
+// setCanceled marks the process as canceled the same way updateProcess does when the
+// process transitions to CANCELED.
+func (s *Scrutinizer) setCanceled(pid []byte) error {
+	if err := s.queryWithRetries(func() error {
+		return s.db.UpdateMatching(&indexertypes.Process{},
+			badgerhold.Where(badgerhold.Key).Eq(pid), func(record interface{}) error {
+				update, ok := record.(*indexertypes.Process)
+				if !ok {
+					return fmt.Errorf("record isn't the correct type! Wanted Process, got %T", record)
+				}
+				update.HaveResults = false
+				update.FinalResults = true
+				update.Rheight = 0
+				return nil
+			})
+	}); err != nil {
+		return err
+	}
+	return s.resetCanceledResults(pid)
+}
+
+// Verify compares the scrutinizer database against the Vochain state and the block store.
+// It checks the envelope count of each process, the envelope and transaction references
+// and the final results, and returns the list of inconsistencies found.
+func (s *Scrutinizer) Verify() ([]*Inconsistency, error) {
+	var found []*Inconsistency
+	report := func(kind string, id []byte, format string, args ...interface{}) {
+		i := &Inconsistency{Kind: kind, ID: id, Message: fmt.Sprintf(format, args...)}
+		log.Warnf("indexer inconsistency: %s", i)
+		found = append(found, i)
+	}
+
+	// Processes, envelope counts and results
+	var processes []*indexertypes.Process
+	if err := s.db.ForEach(&badgerhold.Query{}, func(p *indexertypes.Process) error {
+		processes = append(processes, p)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, p := range processes {
+		indexed, err := s.db.Count(&indexertypes.VoteReference{},
+			badgerhold.Where("ProcessID").Eq(p.ID).Index("ProcessID"))
+		if err != nil {
+			return nil, err
+		}
+		if onState := s.App.State.CountVotes(p.ID, true); uint32(indexed) != onState {
+			report(InconsistencyEnvelopeCount, p.ID,
+				"indexed %d envelopes, state has %d", indexed, onState)
+		}
+		if !p.FinalResults || !p.HaveResults {
+			continue
+		}
+		stored, err := s.GetResults(p.ID)
+		if err != nil {
+			report(InconsistencyResults, p.ID, "cannot get results: %v", err)
+			continue
+		}
+		computed, _, err := s.computeFinalResults(p)
+		if err != nil {
+			report(InconsistencyResults, p.ID, "cannot compute results: %v", err)
+			continue
+		}
+		if stored.Weight.Cmp(computed.Weight) != 0 {
+			report(InconsistencyResults, p.ID, "stored weight %s, computed weight %s",
+				stored.Weight, computed.Weight)
+		}
+		if !equalVotes(stored.Votes, computed.Votes) {
+			report(InconsistencyResults, p.ID, "stored results%s, computed results%s",
+				stored, computed)
+		}
+		process, err := s.App.State.Process(p.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		if process.Results != nil {
+			onChain := process.Results.GetVotes()
+			indexed := BuildProcessResult(stored, nil).GetVotes()
+			if !equalQuestionResults(onChain, indexed) {
+				report(InconsistencyResults, p.ID, "stored results do not match the on-chain results")
+			}
+		}
+	}
+
+	// Envelope references
+	var envelopes uint64
+	if err := s.db.ForEach(&badgerhold.Query{}, func(ref *indexertypes.VoteReference) error {
+		envelopes++
+		stx, err := s.App.GetTx(ref.Height, ref.TxIndex)
+		if err != nil {
+			report(InconsistencyEnvelope, ref.Nullifier, "cannot get transaction: %v", err)
+			return nil
+		}
+		tx := &models.Tx{}
+		if err := proto.Unmarshal(stx.Tx, tx); err != nil || tx.GetVote() == nil {
+			report(InconsistencyEnvelope, ref.Nullifier, "transaction %d/%d is not an envelope",
+				ref.Height, ref.TxIndex)
+			return nil
+		}
+		if !bytes.Equal(tx.GetVote().ProcessId, ref.ProcessID) {
+			report(InconsistencyEnvelope, ref.Nullifier, "envelope process id %x does not match %x",
+				tx.GetVote().ProcessId, ref.ProcessID)
+		}
+		if exist, err := s.App.State.EnvelopeExists(ref.ProcessID, ref.Nullifier, true); err != nil || !exist {
+			report(InconsistencyEnvelope, ref.Nullifier, "envelope does not exist on state")
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if count, err := s.GetEnvelopeHeight(nil); err != nil || count != envelopes {
+		report(InconsistencyCountStore, []byte{indexertypes.CountStoreEnvelopes},
+			"envelope count is %d, found %d envelopes (%v)", count, envelopes, err)
+	}
+
+	// Transaction references
+	var txs uint64
+	if err := s.db.ForEach(&badgerhold.Query{}, func(ref *indexertypes.TxReference) error {
+		txs++
+		if ref.BlockHeight > s.App.Height() {
+			report(InconsistencyTransaction, nil, "transaction %d points to future block %d",
+				ref.Index, ref.BlockHeight)
+			return nil
+		}
+		block := s.App.GetBlockByHeight(int64(ref.BlockHeight))
+		if block == nil || int(ref.TxBlockIndex) >= len(block.Txs) {
+			report(InconsistencyTransaction, nil, "transaction %d not found at %d/%d",
+				ref.Index, ref.BlockHeight, ref.TxBlockIndex)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if count, err := s.TransactionCount(); err != nil || count != txs {
+		report(InconsistencyCountStore, []byte{indexertypes.CountStoreTransactions},
+			"transaction count is %d, found %d transactions (%v)", count, txs, err)
+	}
+	return found, nil
+}
+
+func equalVotes(a, b [][]*big.Int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j].Cmp(b[i][j]) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func equalQuestionResults(a, b []*models.QuestionResult) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i].Question) != len(b[i].Question) {
+			return false
+		}
+		for j := range a[i].Question {
+			if new(big.Int).SetBytes(a[i].Question[j]).Cmp(
+				new(big.Int).SetBytes(b[i].Question[j])) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
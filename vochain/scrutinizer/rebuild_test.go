@@ -0,0 +1,185 @@
+package scrutinizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	tree "go.vocdoni.io/dvote/censustree/gravitontree"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// testChain delivers blocks to a vochain application and keeps them in memory,
+// along with the DeliverTx responses, as the Tendermint block and state stores would do.
+type testChain struct {
+	app       *vochain.BaseApplication
+	blocks    map[int64]*tmtypes.Block
+	txResults map[int64][]*abcitypes.ResponseDeliverTx
+}
+
+func newTestChain(app *vochain.BaseApplication) *testChain {
+	c := &testChain{
+		app:       app,
+		blocks:    make(map[int64]*tmtypes.Block),
+		txResults: make(map[int64][]*abcitypes.ResponseDeliverTx),
+	}
+	app.SetFnGetBlockByHeight(func(height int64) *tmtypes.Block { return c.blocks[height] })
+	app.SetFnGetBlockTxResults(func(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+		return c.txResults[height], nil
+	})
+	return c
+}
+
+func (c *testChain) deliverBlock(txs ...[]byte) {
+	height := int64(c.app.Height()) + 1
+	header := tmprototypes.Header{Height: height, Time: time.Unix(1600000000+height*10, 0)}
+	c.app.BeginBlock(abcitypes.RequestBeginBlock{Header: header})
+	block := &tmtypes.Block{Header: tmtypes.Header{Height: height, Time: header.Time}}
+	for _, tx := range txs {
+		resp := c.app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx})
+		block.Txs = append(block.Txs, tx)
+		c.txResults[height] = append(c.txResults[height], &resp)
+	}
+	c.blocks[height] = block
+	c.app.EndBlock(abcitypes.RequestEndBlock{Height: height})
+	c.app.Commit()
+}
+
+func signTx(t *testing.T, signer *ethereum.SignKeys, tx *models.Tx) []byte {
+	txBytes, err := proto.Marshal(tx)
+	qt.Assert(t, err, qt.IsNil)
+	signature, err := signer.Sign(txBytes)
+	qt.Assert(t, err, qt.IsNil)
+	stx, err := proto.Marshal(&models.SignedTx{Tx: txBytes, Signature: signature})
+	qt.Assert(t, err, qt.IsNil)
+	return stx
+}
+
+// newTestChainWithVotes creates a chain with an unencrypted process created on block 1,
+// voted by 10 voters on block 2 with votes [1, 0], and finished on block 5.
+func newTestChainWithVotes(t *testing.T) (*testChain, []byte) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	chain := newTestChain(app)
+
+	oracle := ethereum.NewSignKeys()
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(oracle.Address()), qt.IsNil)
+	app.Commit()
+
+	census, err := tree.NewTree("testrebuild", t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	voters := util.CreateEthRandomKeysBatch(10)
+	for _, v := range voters {
+		qt.Assert(t, census.Add(v.PublicKey(), nil), qt.IsNil)
+	}
+
+	// Block 1: create the process
+	pid := util.RandomBytes(types.ProcessIDsize)
+	censusURI := "ipfs://foobar"
+	chain.deliverBlock(signTx(t, oracle, &models.Tx{Payload: &models.Tx_NewProcess{
+		NewProcess: &models.NewProcessTx{
+			Txtype: models.TxType_NEW_PROCESS,
+			Nonce:  util.RandomBytes(32),
+			Process: &models.Process{
+				ProcessId:    pid,
+				EntityId:     util.RandomBytes(types.EthereumAddressSize),
+				StartBlock:   1,
+				BlockCount:   3,
+				EnvelopeType: &models.EnvelopeType{},
+				Mode:         &models.ProcessMode{},
+				Status:       models.ProcessStatus_READY,
+				VoteOptions:  &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 1},
+				CensusRoot:   census.Root(),
+				CensusURI:    &censusURI,
+				CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+			},
+		},
+	}}))
+
+	// Block 2: all the voters vote, the last vote is sent twice and must be rejected
+	var votes [][]byte
+	for _, v := range voters {
+		proof, err := census.GenProof(v.PublicKey(), nil)
+		qt.Assert(t, err, qt.IsNil)
+		vp, err := json.Marshal(vochain.VotePackage{
+			Nonce: fmt.Sprintf("%x", util.RandomBytes(32)),
+			Votes: []int{1, 0},
+		})
+		qt.Assert(t, err, qt.IsNil)
+		votes = append(votes, signTx(t, v, &models.Tx{Payload: &models.Tx_Vote{
+			Vote: &models.VoteEnvelope{
+				Nonce:       util.RandomBytes(32),
+				ProcessId:   pid,
+				VotePackage: vp,
+				Proof: &models.Proof{Payload: &models.Proof_Graviton{
+					Graviton: &models.ProofGraviton{Siblings: proof},
+				}},
+			},
+		}}))
+	}
+	votes = append(votes, votes[len(votes)-1])
+	chain.deliverBlock(votes...)
+	qt.Assert(t, chain.txResults[2][len(votes)-1].Code, qt.Not(qt.Equals), uint32(0))
+
+	// Blocks 3 to 5: wait for the process to finish
+	for i := 0; i < 3; i++ {
+		chain.deliverBlock()
+	}
+	return chain, pid
+}
+
+func TestRebuildAndVerify(t *testing.T) {
+	chain, pid := newTestChainWithVotes(t)
+	// The scrutinizer is created once the blocks are committed, as an empty database
+	sc, err := NewScrutinizer(t.TempDir(), chain.app, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sc.Rebuild(), qt.IsNil)
+
+	txCount, err := sc.TransactionCount()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txCount, qt.Equals, uint64(11))
+	envelopes, err := sc.GetEnvelopeHeight(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, envelopes, qt.Equals, uint64(10))
+	process, err := sc.ProcessInfo(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process.CreationTime.Unix(), qt.Equals, chain.blocks[1].Time.Unix())
+	qt.Assert(t, process.FinalResults, qt.IsTrue)
+	results, err := sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, results.Votes[0][1].Uint64(), qt.Equals, uint64(10))
+	qt.Assert(t, results.Votes[1][0].Uint64(), qt.Equals, uint64(10))
+
+	inconsistencies, err := sc.Verify()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, inconsistencies, qt.HasLen, 0)
+
+	// Remove an envelope from the index, verify must detect it
+	refs, err := sc.GetEnvelopes(pid, 1, 0, "")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, refs, qt.HasLen, 1)
+	err = sc.db.Delete([]byte(refs[0].Nullifier), &indexertypes.VoteReference{})
+	qt.Assert(t, err, qt.IsNil)
+	inconsistencies, err = sc.Verify()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, len(inconsistencies) > 0, qt.IsTrue)
+	qt.Assert(t, inconsistencies[0].Kind, qt.Equals, InconsistencyEnvelopeCount)
+
+	// A new rebuild fixes the index
+	qt.Assert(t, sc.Rebuild(), qt.IsNil)
+	inconsistencies, err = sc.Verify()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, inconsistencies, qt.HasLen, 0)
+}
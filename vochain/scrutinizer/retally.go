@@ -0,0 +1,97 @@
+package scrutinizer
+
+import (
+	"fmt"
+	"math/big"
+
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// Ballot is an envelope as found on the chain, used by Retally to recompute results
+type Ballot struct {
+	Nullifier            types.HexBytes
+	VotePackage          []byte
+	EncryptionKeyIndexes []uint32
+	Weight               *big.Int
+}
+
+// DiscardedBallot is a ballot that was not counted by Retally, the reason category
+// and the error message as detail
+type DiscardedBallot struct {
+	Nullifier types.HexBytes             `json:"nullifier"`
+	Reason    indexertypes.InvalidReason `json:"reason"`
+	Detail    string                     `json:"detail"`
+}
+
+// Retally computes the results of a process from its ballots without requiring a
+// scrutinizer database, so third parties can verify the results using raw chain data.
+// Ballots are decrypted with the revealed privateKeys (indexed as on the process keys) and
+// counted following the same ballot protocol rules as the scrutinizer. The ballots that
+// cannot be decrypted or do not follow the rules are returned as discarded.
+func Retally(envelopeType *models.EnvelopeType, voteOpts *models.ProcessVoteOptions,
+	privateKeys []string, ballots []*Ballot) (*indexertypes.Results, []*DiscardedBallot, error) {
+	if envelopeType == nil || voteOpts == nil {
+		return nil, nil, fmt.Errorf("envelope type and vote options are required")
+	}
+	if envelopeType.Anonymous {
+		return nil, nil, fmt.Errorf("anonymous processes are not supported")
+	}
+	process := &indexertypes.Process{
+		Envelope:    envelopeType,
+		VoteOpts:    voteOpts,
+		PrivateKeys: privateKeys,
+	}
+	results := &indexertypes.Results{
+		Votes:        indexertypes.NewEmptyVotes(int(voteOpts.MaxCount), int(voteOpts.MaxValue)+1),
+		Weight:       new(big.Int).SetUint64(0),
+		VoteOpts:     voteOpts,
+		EnvelopeType: envelopeType,
+		Final:        true,
+	}
+	discarded := []*DiscardedBallot{}
+	for _, b := range ballots {
+		vp, err := unmarshalEnvelope(process, &models.VoteEnvelope{
+			VotePackage:          b.VotePackage,
+			EncryptionKeyIndexes: b.EncryptionKeyIndexes,
+		})
+		if err != nil {
+			discarded = append(discarded, newDiscardedBallot(b.Nullifier, err))
+			continue
+		}
+		if err := results.AddVote(vp.Votes, b.Weight, nil); err != nil {
+			discarded = append(discarded, newDiscardedBallot(b.Nullifier, err))
+		}
+	}
+	return results, discarded, nil
+}
+
+func newDiscardedBallot(nullifier []byte, err error) *DiscardedBallot {
+	return &DiscardedBallot{Nullifier: nullifier, Reason: invalidReason(err), Detail: err.Error()}
+}
+
+// CompareResults checks that the votes matrix matches the expected results, given as
+// decimal strings (as returned by GetFriendlyResults).
+func CompareResults(votes [][]*big.Int, expected [][]string) error {
+	if len(votes) != len(expected) {
+		return fmt.Errorf("number of questions mismatch: %d != %d", len(votes), len(expected))
+	}
+	for q := range votes {
+		if len(votes[q]) != len(expected[q]) {
+			return fmt.Errorf("number of values mismatch on question %d: %d != %d",
+				q, len(votes[q]), len(expected[q]))
+		}
+		for v := range votes[q] {
+			e, ok := new(big.Int).SetString(expected[q][v], 10)
+			if !ok {
+				return fmt.Errorf("invalid value %q on question %d", expected[q][v], q)
+			}
+			if votes[q][v].Cmp(e) != 0 {
+				return fmt.Errorf("question %d value %d mismatch: %s != %s",
+					q, v, votes[q][v], e)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package scrutinizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestRetally(t *testing.T) {
+	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", ethereum.HashRaw(util.RandomBytes(32))))
+	qt.Assert(t, err, qt.IsNil)
+	privateKeys := make([]string, 4)
+	privateKeys[1] = fmt.Sprintf("%x", priv.Bytes())
+
+	ballot := func(votes []int, encrypt bool) *Ballot {
+		vp, err := json.Marshal(vochain.VotePackage{
+			Nonce: fmt.Sprintf("%x", util.RandomBytes(32)),
+			Votes: votes,
+		})
+		qt.Assert(t, err, qt.IsNil)
+		b := &Ballot{Nullifier: util.RandomBytes(32), Weight: big.NewInt(2)}
+		if encrypt {
+			vp, err = priv.Encrypt(vp, nil)
+			qt.Assert(t, err, qt.IsNil)
+			b.EncryptionKeyIndexes = []uint32{1}
+		}
+		b.VotePackage = vp
+		return b
+	}
+
+	envelopeType := &models.EnvelopeType{EncryptedVotes: true}
+	voteOpts := &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 2}
+	ballots := []*Ballot{
+		ballot([]int{1, 2}, true),
+		ballot([]int{2, 0}, true),
+		ballot([]int{3, 0}, true),  // max value overflow
+		ballot([]int{1, 1}, false), // not encrypted
+	}
+	results, discarded, err := Retally(envelopeType, voteOpts, privateKeys, ballots)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, discarded, qt.HasLen, 2)
+	qt.Assert(t, discarded[0].Reason, qt.Equals, indexertypes.InvalidReasonMaxValue)
+	qt.Assert(t, []byte(discarded[0].Nullifier), qt.DeepEquals, []byte(ballots[2].Nullifier))
+	qt.Assert(t, []byte(discarded[1].Nullifier), qt.DeepEquals, []byte(ballots[3].Nullifier))
+	qt.Assert(t, results.Weight.Uint64(), qt.Equals, uint64(4))
+
+	expected := [][]string{{"0", "2", "2"}, {"2", "0", "2"}}
+	qt.Assert(t, GetFriendlyResults(results.Votes), qt.DeepEquals, expected)
+	qt.Assert(t, CompareResults(results.Votes, expected), qt.IsNil)
+	expected[1][2] = "4"
+	qt.Assert(t, CompareResults(results.Votes, expected), qt.ErrorMatches, "question 1 value 2 mismatch.*")
+	qt.Assert(t, CompareResults(results.Votes, expected[:1]), qt.ErrorMatches, "number of questions.*")
+
+	// Without the revealed keys all the encrypted ballots are discarded
+	_, discarded, err = Retally(envelopeType, voteOpts, make([]string, 4), ballots)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, discarded, qt.HasLen, 4)
+
+	// A key index beyond the supplied keys discards the ballot
+	outOfRange := ballot([]int{1, 2}, true)
+	outOfRange.EncryptionKeyIndexes = []uint32{5}
+	_, discarded, err = Retally(envelopeType, voteOpts, privateKeys, []*Ballot{outOfRange})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, discarded, qt.HasLen, 1)
+	qt.Assert(t, discarded[0].Reason, qt.Equals, indexertypes.InvalidReasonDecryption)
+	qt.Assert(t, discarded[0].Detail, qt.Matches, ".*key index 5 not found")
+}
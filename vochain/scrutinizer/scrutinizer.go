@@ -2,6 +2,7 @@ package scrutinizer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -272,9 +273,14 @@ func (s *Scrutinizer) AfterSyncBootstrap() {
 			VoteOpts:     options,
 			EnvelopeType: process.EnvelopeType,
 		}
+		invalid := []*indexertypes.InvalidEnvelope{}
 		if err := s.WalkEnvelopes(p, false, func(vote *models.VoteEnvelope, weight *big.Int) {
 			if err := s.addLiveVote(vote.ProcessId, vote.VotePackage,
 				weight, results); err != nil {
+				var ierr *InvalidBallotError
+				if errors.As(err, &ierr) {
+					invalid = append(invalid, newInvalidEnvelope(vote.Nullifier, p, ierr.Err))
+				}
 				log.Warn(err)
 			}
 		}); err != nil {
@@ -286,6 +292,9 @@ func (s *Scrutinizer) AfterSyncBootstrap() {
 			log.Errorf("cannot commit live votes: (%v)", err)
 			continue
 		}
+		if err := s.storeInvalidEnvelopes(invalid); err != nil {
+			log.Errorf("cannot store invalid envelopes: (%v)", err)
+		}
 		// Add process to live results so new votes will be added
 		s.addProcessToLiveResults(p)
 	}
@@ -386,12 +395,17 @@ func (s *Scrutinizer) Commit(height uint32) error {
 			VoteOpts:     proc.VoteOpts,
 			EnvelopeType: proc.Envelope,
 		}
+		invalid := []*indexertypes.InvalidEnvelope{}
 		for _, v := range votes {
 			if err := s.addLiveVote(v.ProcessId,
 				v.VotePackage,
 				// TBD: Not 100% sure what happens if weight=nil
 				new(big.Int).SetBytes(v.GetWeight()),
 				results); err != nil {
+				var ierr *InvalidBallotError
+				if errors.As(err, &ierr) {
+					invalid = append(invalid, newInvalidEnvelope(v.Nullifier, v.ProcessId, ierr.Err))
+				}
 				log.Warnf("vote cannot be added: %v", err)
 			} else {
 				nvotes++
@@ -401,6 +415,9 @@ func (s *Scrutinizer) Commit(height uint32) error {
 		if err := s.commitVotes([]byte(pid), results, s.App.Height()); err != nil {
 			log.Errorf("cannot commit live votes from block %d: (%v)", err, height)
 		}
+		if err := s.storeInvalidEnvelopes(invalid); err != nil {
+			log.Errorf("cannot store invalid envelopes from block %d: (%v)", height, err)
+		}
 	}
 	if nvotes > 0 {
 		log.Infof("added %d live votes on block %d, took %s",
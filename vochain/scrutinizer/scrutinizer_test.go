@@ -3,6 +3,7 @@ package scrutinizer
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"testing"
@@ -980,3 +981,234 @@ func TestTxIndexer(t *testing.T) {
 		}
 	}
 }
+
+func TestVoterHistory(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+	app.SetTestingMethods()
+
+	voter := ethereum.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	eid := util.RandomBytes(types.EntityIDsize)
+
+	// Three processes of the same entity, the voter votes on the first two
+	var pids [][]byte
+	for i := 0; i < 3; i++ {
+		pid := util.RandomBytes(types.ProcessIDsize)
+		err := app.State.AddProcess(&models.Process{
+			ProcessId:    pid,
+			EntityId:     eid,
+			EnvelopeType: &models.EnvelopeType{},
+			Status:       models.ProcessStatus_READY,
+			BlockCount:   10,
+			VoteOptions: &models.ProcessVoteOptions{
+				MaxCount: 1, MaxValue: 1, MaxVoteOverwrites: 2,
+			},
+			Mode: &models.ProcessMode{AutoStart: true},
+		})
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, sc.newEmptyProcess(pid), qt.IsNil)
+		pids = append(pids, pid)
+	}
+	for i, pid := range pids[:2] {
+		voteTx, err := proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{
+			Vote: &models.VoteEnvelope{ProcessId: pid, Nonce: util.RandomBytes(32)},
+		}})
+		qt.Assert(t, err, qt.IsNil)
+		signedTx, err := proto.Marshal(&models.SignedTx{Tx: voteTx})
+		qt.Assert(t, err, qt.IsNil)
+		_, err = app.SendTx(signedTx)
+		qt.Assert(t, err, qt.IsNil)
+		nullifier := vochain.GenerateNullifier(voter.Address(), pid)
+		err = sc.addVoteIndex(nullifier, pid, uint32(i), big.NewInt(int64(i+1)).Bytes(), 0, nil)
+		qt.Assert(t, err, qt.IsNil)
+	}
+
+	records, err := sc.VoterHistory(voter.Address(), eid, 0, 64)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, records, qt.HasLen, 2)
+	for _, r := range records {
+		qt.Assert(t, r.Nullifier, qt.DeepEquals,
+			types.HexBytes(vochain.GenerateNullifier(voter.Address(), r.ProcessID)))
+		qt.Assert(t, r.TxHash, qt.HasLen, 32)
+		qt.Assert(t, r.Weight, qt.Equals, fmt.Sprintf("%d", r.Height+1))
+		qt.Assert(t, r.MaxVoteOverwrites, qt.Equals, uint32(2))
+	}
+
+	// The pages run over the envelopes
+	first, err := sc.VoterHistory(voter.Address(), eid, 0, 1)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, first, qt.HasLen, 1)
+	second, err := sc.VoterHistory(voter.Address(), eid, 1, 1)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, second, qt.HasLen, 1)
+	qt.Assert(t, second[0].ProcessID, qt.Not(qt.DeepEquals), first[0].ProcessID)
+	records, err = sc.VoterHistory(voter.Address(), eid, 2, 64)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, records, qt.HasLen, 0)
+
+	// A nullifier is only indexed once
+	nullifier := vochain.GenerateNullifier(voter.Address(), pids[0])
+	err = sc.addVoteIndex(nullifier, pids[0], 1, big.NewInt(2).Bytes(), 0, nil)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// Another voter has no history
+	other := ethereum.NewSignKeys()
+	qt.Assert(t, other.Generate(), qt.IsNil)
+	records, err = sc.VoterHistory(other.Address(), eid, 0, 64)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, records, qt.HasLen, 0)
+}
+
+func TestInvalidEnvelopes(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+	app.SetTestingMethods()
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	err = app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Status:       models.ProcessStatus_READY,
+		BlockCount:   10,
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 1},
+		Mode:         &models.ProcessMode{AutoStart: true},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sc.newEmptyProcess(pid), qt.IsNil)
+
+	votePackage := func(votes []int) []byte {
+		vp, err := json.Marshal(vochain.VotePackage{
+			Nonce: fmt.Sprintf("%x", util.RandomBytes(32)),
+			Votes: votes,
+		})
+		qt.Assert(t, err, qt.IsNil)
+		return vp
+	}
+	packages := [][]byte{
+		votePackage([]int{1, 0}),
+		votePackage([]int{1, 0}),
+		votePackage([]int{1, 0}),
+		votePackage([]int{2, 0}), // max value overflow
+		[]byte("not a vote package"),
+	}
+	var nullifiers [][]byte
+	for i, vp := range packages {
+		voteTx, err := proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{
+			Vote: &models.VoteEnvelope{ProcessId: pid, Nonce: util.RandomBytes(32), VotePackage: vp},
+		}})
+		qt.Assert(t, err, qt.IsNil)
+		signedTx, err := proto.Marshal(&models.SignedTx{Tx: voteTx})
+		qt.Assert(t, err, qt.IsNil)
+		_, err = app.SendTx(signedTx)
+		qt.Assert(t, err, qt.IsNil)
+		nullifier := util.RandomBytes(32)
+		err = sc.addVoteIndex(nullifier, pid, uint32(i), big.NewInt(1).Bytes(), 0, nil)
+		qt.Assert(t, err, qt.IsNil)
+		nullifiers = append(nullifiers, nullifier)
+	}
+
+	// Live results count the invalid votes
+	results := &indexertypes.Results{
+		Weight:       new(big.Int).SetUint64(0),
+		VoteOpts:     &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 1},
+		EnvelopeType: &models.EnvelopeType{},
+	}
+	for _, vp := range packages {
+		err := sc.addLiveVote(pid, vp, big.NewInt(1), results)
+		var ierr *InvalidBallotError
+		if err != nil {
+			qt.Assert(t, errors.As(err, &ierr), qt.IsTrue)
+		}
+	}
+	qt.Assert(t, results.InvalidVotes, qt.Equals, uint64(2))
+	// the weight of the invalid votes is not counted
+	qt.Assert(t, results.Weight.Uint64(), qt.Equals, uint64(3))
+
+	// Final results store the invalid envelopes with the reason
+	qt.Assert(t, sc.ComputeResult(pid), qt.IsNil)
+	final, err := sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, final.EnvelopeHeight, qt.Equals, uint64(3))
+	qt.Assert(t, final.InvalidVotes, qt.Equals, uint64(2))
+	qt.Assert(t, GetFriendlyResults(final.Votes), qt.DeepEquals, [][]string{{"0", "3"}, {"3", "0"}})
+
+	invalid, err := sc.GetInvalidEnvelopes(pid, 0, 64)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, invalid, qt.HasLen, 2)
+	reasons := make(map[string]*indexertypes.InvalidEnvelope)
+	for _, e := range invalid {
+		reasons[fmt.Sprintf("%x", e.Nullifier)] = e
+	}
+	overflow := reasons[fmt.Sprintf("%x", nullifiers[3])]
+	qt.Assert(t, overflow.Reason, qt.Equals, indexertypes.InvalidReasonMaxValue)
+	qt.Assert(t, overflow.Detail, qt.Matches, "max value overflow.*")
+	malformed := reasons[fmt.Sprintf("%x", nullifiers[4])]
+	qt.Assert(t, malformed.Reason, qt.Equals, indexertypes.InvalidReasonMalformed)
+	qt.Assert(t, malformed.Detail, qt.Matches, ".*cannot unmarshal vote.*")
+}
+
+func TestInvalidReason(t *testing.T) {
+	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", util.RandomBytes(32)))
+	qt.Assert(t, err, qt.IsNil)
+	other, err := nacl.DecodePrivate(fmt.Sprintf("%x", util.RandomBytes(32)))
+	qt.Assert(t, err, qt.IsNil)
+	encrypted, err := priv.Encrypt([]byte(`{"votes":[1]}`), nil)
+	qt.Assert(t, err, qt.IsNil)
+	process := &indexertypes.Process{
+		Envelope:    &models.EnvelopeType{EncryptedVotes: true},
+		PrivateKeys: []string{fmt.Sprintf("%x", other.Bytes())},
+	}
+	_, decryptErr := unmarshalEnvelope(process, &models.VoteEnvelope{
+		VotePackage:          encrypted,
+		EncryptionKeyIndexes: []uint32{0},
+	})
+	_, keyErr := unmarshalEnvelope(process, &models.VoteEnvelope{
+		VotePackage:          encrypted,
+		EncryptionKeyIndexes: []uint32{3},
+	})
+	_, malformedErr := unmarshalVote([]byte("{"), nil)
+
+	addVote := func(envelope *models.EnvelopeType, opts *models.ProcessVoteOptions,
+		votes []int) error {
+		results := &indexertypes.Results{
+			Weight:       new(big.Int),
+			VoteOpts:     opts,
+			EnvelopeType: envelope,
+		}
+		return results.AddVote(votes, big.NewInt(1), nil)
+	}
+	for _, tc := range []struct {
+		name   string
+		err    error
+		reason indexertypes.InvalidReason
+	}{
+		{"decryption", decryptErr, indexertypes.InvalidReasonDecryption},
+		{"keyIndex", keyErr, indexertypes.InvalidReasonDecryption},
+		{"malformed", malformedErr, indexertypes.InvalidReasonMalformed},
+		{"maxCount", addVote(&models.EnvelopeType{},
+			&models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2}, []int{1, 1}),
+			indexertypes.InvalidReasonMaxCount},
+		{"maxValue", addVote(&models.EnvelopeType{},
+			&models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2}, []int{3}),
+			indexertypes.InvalidReasonMaxValue},
+		{"notUnique", addVote(&models.EnvelopeType{UniqueValues: true},
+			&models.ProcessVoteOptions{MaxCount: 2, MaxValue: 2}, []int{1, 1}),
+			indexertypes.InvalidReasonNotUnique},
+		{"cost", addVote(&models.EnvelopeType{},
+			&models.ProcessVoteOptions{MaxCount: 2, MaxValue: 2, MaxTotalCost: 2,
+				CostExponent: 1}, []int{2, 2}),
+			indexertypes.InvalidReasonCostExceeded},
+		{"other", fmt.Errorf("unknown"), indexertypes.InvalidReasonOther},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			qt.Assert(t, tc.err, qt.Not(qt.IsNil))
+			qt.Assert(t, invalidReason(tc.err), qt.Equals, tc.reason)
+			qt.Assert(t, invalidReason(&InvalidBallotError{Err: tc.err}), qt.Equals, tc.reason)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package scrutinizer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/timshannon/badgerhold/v3"
 	"go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
@@ -24,6 +26,56 @@ import (
 // it does not have yet reuslts
 var ErrNoResultsYet = fmt.Errorf("no results yet")
 
+// Errors returned when a ballot cannot be decoded
+var (
+	ErrBallotDecryption = fmt.Errorf("cannot decrypt ballot")
+	ErrMalformedBallot  = fmt.Errorf("malformed ballot")
+)
+
+// InvalidBallotError is returned when a ballot cannot be decoded or does not follow
+// the ballot protocol, so it is not counted on the results
+type InvalidBallotError struct {
+	Err error
+}
+
+func (e *InvalidBallotError) Error() string {
+	return fmt.Sprintf("invalid ballot: %v", e.Err)
+}
+
+func (e *InvalidBallotError) Unwrap() error {
+	return e.Err
+}
+
+// invalidReason returns the category of the error that invalidated a ballot
+func invalidReason(err error) indexertypes.InvalidReason {
+	switch {
+	case errors.Is(err, ErrBallotDecryption):
+		return indexertypes.InvalidReasonDecryption
+	case errors.Is(err, ErrMalformedBallot):
+		return indexertypes.InvalidReasonMalformed
+	case errors.Is(err, indexertypes.ErrMaxCountOverflow):
+		return indexertypes.InvalidReasonMaxCount
+	case errors.Is(err, indexertypes.ErrMaxValueOverflow):
+		return indexertypes.InvalidReasonMaxValue
+	case errors.Is(err, indexertypes.ErrValuesNotUnique):
+		return indexertypes.InvalidReasonNotUnique
+	case errors.Is(err, indexertypes.ErrMaxCostOverflow):
+		return indexertypes.InvalidReasonCostExceeded
+	default:
+		return indexertypes.InvalidReasonOther
+	}
+}
+
+// newInvalidEnvelope builds the reference of an envelope invalidated by err
+func newInvalidEnvelope(nullifier, pid []byte, err error) *indexertypes.InvalidEnvelope {
+	return &indexertypes.InvalidEnvelope{
+		Nullifier: nullifier,
+		ProcessID: pid,
+		Reason:    invalidReason(err),
+		Detail:    err.Error(),
+	}
+}
+
 // ErrNotFoundIndatabase is raised if a database query returns no results
 var ErrNotFoundInDatabase = badgerhold.ErrNotFound
 
@@ -75,6 +127,83 @@ func (s *Scrutinizer) GetEnvelope(nullifier []byte) (*indexertypes.EnvelopePacka
 	}, nil
 }
 
+// voterHistoryProcessBatch is the number of processes read at a time by VoterHistory
+const voterHistoryProcessBatch = 64
+
+// VoterHistory returns the envelopes cast by address on the processes of entityID,
+// skipping the first from envelopes and returning up to max. The envelopes are found
+// by computing the address nullifier for each process, so anonymous processes are skipped.
+func (s *Scrutinizer) VoterHistory(address common.Address, entityID []byte,
+	from, max int) ([]*indexertypes.VoterRecord, error) {
+	startTime := time.Now()
+	defer func() { log.Debugf("VoterHistory took %s", time.Since(startTime)) }()
+	if from < 0 {
+		return nil, fmt.Errorf("voterHistory: invalid value: from is invalid value %d", from)
+	}
+	records := []*indexertypes.VoterRecord{}
+	for pfrom := 0; len(records) < max; pfrom += voterHistoryProcessBatch {
+		pids, err := s.ProcessList(entityID, pfrom, voterHistoryProcessBatch, "", 0, "", "", false)
+		if err != nil {
+			return nil, err
+		}
+		for _, pid := range pids {
+			record, err := s.voterRecord(address, pid)
+			if err != nil {
+				return nil, err
+			}
+			if record == nil {
+				continue
+			}
+			if from > 0 {
+				from--
+				continue
+			}
+			records = append(records, record)
+			if len(records) == max {
+				break
+			}
+		}
+		if len(pids) < voterHistoryProcessBatch {
+			break
+		}
+	}
+	return records, nil
+}
+
+// voterRecord returns the envelope cast by address on the process pid, or nil if there is none
+func (s *Scrutinizer) voterRecord(address common.Address,
+	pid []byte) (*indexertypes.VoterRecord, error) {
+	process, err := s.ProcessInfo(pid)
+	if err != nil {
+		return nil, err
+	}
+	if process.Envelope.GetAnonymous() {
+		return nil, nil
+	}
+	nullifier := vochain.GenerateNullifier(address, pid)
+	voteRef, err := s.GetEnvelopeReference(nullifier)
+	if err != nil {
+		if err == ErrNotFoundInDatabase {
+			return nil, nil
+		}
+		return nil, err
+	}
+	_, txHash, err := s.App.GetTxHash(voteRef.Height, voteRef.TxIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &indexertypes.VoterRecord{
+		ProcessID:         pid,
+		Nullifier:         nullifier,
+		Height:            voteRef.Height,
+		TxIndex:           voteRef.TxIndex,
+		TxHash:            txHash,
+		Weight:            voteRef.Weight.String(),
+		CreationTime:      voteRef.CreationTime,
+		MaxVoteOverwrites: process.VoteOpts.GetMaxVoteOverwrites(),
+	}, nil
+}
+
 // WalkEnvelopes executes callback for each envelopes of the ProcessId.
 // The callback function is executed async (in a goroutine) if async=true.
 // The method will return once all goroutines have finished the work.
@@ -111,6 +240,8 @@ func (s *Scrutinizer) WalkEnvelopes(processId []byte, async bool,
 					log.Errorf("transaction is not an Envelope")
 					return
 				}
+				// The nullifier is computed by the Vochain, take it from the reference
+				envelope.Nullifier = txRef.Nullifier
 				callback(envelope, txRef.Weight)
 			}
 			if async {
@@ -244,8 +375,8 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 		return fmt.Errorf("computeResult: cannot load processID %x from database: %w", processID, err)
 	}
 	// Compute the results
-	var results *indexertypes.Results
-	if results, err = s.computeFinalResults(p); err != nil {
+	results, invalid, err := s.computeFinalResults(p)
+	if err != nil {
 		return err
 	}
 
@@ -274,6 +405,9 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 	if err := s.queryWithRetries(func() error { return s.db.Upsert(processID, results) }); err != nil {
 		return err
 	}
+	if err := s.storeInvalidEnvelopes(invalid); err != nil {
+		log.Warnf("cannot store invalid envelopes of %x: %v", processID, err)
+	}
 
 	// Execute callbacks
 	for _, l := range s.eventListeners {
@@ -339,22 +473,23 @@ func unmarshalVote(VotePackage []byte, keys []string) (*vochain.VotePackage, err
 		for i := len(keys) - 1; i >= 0; i-- {
 			priv, err := nacl.DecodePrivate(keys[i])
 			if err != nil {
-				return nil, fmt.Errorf("cannot create private key cipher: (%s)", err)
+				return nil, fmt.Errorf("%w: cannot create private key cipher: (%v)", ErrBallotDecryption, err)
 			}
 			if rawVote, err = priv.Decrypt(rawVote); err != nil {
-				return nil, fmt.Errorf("cannot decrypt vote with index key %d: %w", i, err)
+				return nil, fmt.Errorf("%w with index key %d: %v", ErrBallotDecryption, i, err)
 			}
 		}
 	}
 	if err := json.Unmarshal(rawVote, &vote); err != nil {
-		return nil, fmt.Errorf("cannot unmarshal vote: %w", err)
+		return nil, fmt.Errorf("%w: cannot unmarshal vote: %v", ErrMalformedBallot, err)
 	}
 	return &vote, nil
 }
 
 // addLiveVote adds the envelope vote to the results. It does not commit to the database.
 // This method is triggered by OnVote callback for each vote added to the blockchain.
-// If encrypted vote, only weight will be updated.
+// If encrypted vote, only weight will be updated. If the vote cannot be decoded or does not
+// follow the ballot protocol, it is counted as invalid and an InvalidBallotError is returned.
 func (s *Scrutinizer) addLiveVote(pid []byte, VotePackage []byte, weight *big.Int,
 	results *indexertypes.Results) error {
 	// If live process, add vote to temporary results
@@ -362,8 +497,8 @@ func (s *Scrutinizer) addLiveVote(pid []byte, VotePackage []byte, weight *big.In
 	if open, err := s.isOpenProcess(pid); open && err == nil {
 		vote, err = unmarshalVote(VotePackage, []string{})
 		if err != nil {
-			log.Warnf("cannot unmarshal vote: %v", err)
-			vote = nil
+			results.InvalidVotes++
+			return &InvalidBallotError{Err: err}
 		}
 	} else if err != nil {
 		return fmt.Errorf("cannot check if process is open: %v", err)
@@ -372,7 +507,8 @@ func (s *Scrutinizer) addLiveVote(pid []byte, VotePackage []byte, weight *big.In
 	// Add the vote only if the election is unencrypted
 	if vote != nil {
 		if err := results.AddVote(vote.Votes, weight, nil); err != nil {
-			return err
+			results.InvalidVotes++
+			return &InvalidBallotError{Err: err}
 		}
 	} else {
 		// If encrypted, just add the weight
@@ -466,15 +602,17 @@ func (s *Scrutinizer) commitVotesUnsafe(pid []byte,
 }
 
 // computeFinalResults walks through the envelopes of a process and computes the results.
-func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertypes.Results, error) {
+// The envelopes that are not counted are returned along with the reason.
+func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertypes.Results,
+	[]*indexertypes.InvalidEnvelope, error) {
 	if p == nil {
-		return nil, fmt.Errorf("process is nil")
+		return nil, nil, fmt.Errorf("process is nil")
 	}
 	if p.VoteOpts.MaxCount == 0 || p.VoteOpts.MaxValue == 0 {
-		return nil, fmt.Errorf("computeNonLiveResults: maxCount and/or maxValue is zero")
+		return nil, nil, fmt.Errorf("computeNonLiveResults: maxCount and/or maxValue is zero")
 	}
 	if p.VoteOpts.MaxCount > MaxQuestions || p.VoteOpts.MaxValue > MaxOptions {
-		return nil, fmt.Errorf("maxCount and/or maxValue overflows hardcoded maximum")
+		return nil, nil, fmt.Errorf("maxCount and/or maxValue overflows hardcoded maximum")
 	}
 	results := &indexertypes.Results{
 		Votes:        indexertypes.NewEmptyVotes(int(p.VoteOpts.MaxCount), int(p.VoteOpts.MaxValue)+1),
@@ -489,50 +627,94 @@ func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertype
 	var nvotes uint64
 	var err error
 	lock := sync.Mutex{}
+	invalid := []*indexertypes.InvalidEnvelope{}
+	addInvalid := func(vote *models.VoteEnvelope, err error) {
+		log.Debugf("vote %x invalid: %v", vote.Nullifier, err)
+		lock.Lock()
+		defer lock.Unlock()
+		invalid = append(invalid, newInvalidEnvelope(vote.Nullifier, p.ID, err))
+	}
 
 	if err = s.WalkEnvelopes(p.ID, true, func(vote *models.VoteEnvelope,
 		weight *big.Int) {
-		var vp *vochain.VotePackage
-		var err error
-		if p.Envelope.GetEncryptedVotes() {
-			if len(p.PrivateKeys) < len(vote.GetEncryptionKeyIndexes()) {
-				log.Errorf("encryptionKeyIndexes has too many fields")
-				return
-			} else {
-				keys := []string{}
-				for _, k := range vote.GetEncryptionKeyIndexes() {
-					if k >= types.KeyKeeperMaxKeyIndex {
-						log.Warn("key index overflow")
-						return
-					}
-					keys = append(keys, p.PrivateKeys[k])
-				}
-				if len(keys) == 0 || err != nil {
-					log.Warn("no keys provided or wrong index")
-					return
-				} else {
-					vp, err = unmarshalVote(vote.GetVotePackage(), keys)
-				}
-			}
-		} else {
-			vp, err = unmarshalVote(vote.GetVotePackage(), []string{})
-		}
+		vp, err := unmarshalEnvelope(p, vote)
 		if err != nil {
-			log.Debugf("vote invalid: %v", err)
+			addInvalid(vote, err)
 			return
 		}
 
 		if err = results.AddVote(vp.Votes, weight, &lock); err != nil {
-			log.Warnf("addVote failed: %v", err)
+			addInvalid(vote, err)
 			return
 		}
 		atomic.AddUint64(&nvotes, 1)
 	}); err == nil {
-		log.Infof("computed results for process %x with %d votes", p.ID, nvotes)
+		log.Infof("computed results for process %x with %d votes and %d invalid",
+			p.ID, nvotes, len(invalid))
 		log.Debugf("results: %s", results)
 	}
 	results.EnvelopeHeight = nvotes
-	return results, err
+	results.InvalidVotes = uint64(len(invalid))
+	return results, invalid, err
+}
+
+// storeInvalidEnvelopes stores the references of the envelopes not counted on the results
+func (s *Scrutinizer) storeInvalidEnvelopes(invalid []*indexertypes.InvalidEnvelope) error {
+	if len(invalid) == 0 {
+		return nil
+	}
+	return s.queryWithRetries(func() error {
+		txn := s.db.Badger().NewTransaction(true)
+		defer txn.Discard()
+		for _, e := range invalid {
+			if err := s.db.TxUpsert(txn, []byte(e.Nullifier), e); err != nil {
+				return err
+			}
+		}
+		return txn.Commit()
+	})
+}
+
+// GetInvalidEnvelopes returns the envelopes of a process that have not been counted on the
+// results, along with the reason. Returns up to max envelopes starting at from.
+func (s *Scrutinizer) GetInvalidEnvelopes(processID []byte,
+	from, max int) ([]*indexertypes.InvalidEnvelope, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("invalid value: from is invalid value %d", from)
+	}
+	invalid := []*indexertypes.InvalidEnvelope{}
+	err := s.db.Find(&invalid, badgerhold.Where("ProcessID").Eq(processID).
+		Index("ProcessID").Skip(from).Limit(max))
+	if err != nil && err != ErrNotFoundInDatabase {
+		return nil, err
+	}
+	return invalid, nil
+}
+
+// unmarshalEnvelope returns the vote package of an envelope. If the process has encrypted
+// votes, the package is decrypted using the process private keys.
+func unmarshalEnvelope(p *indexertypes.Process,
+	vote *models.VoteEnvelope) (*vochain.VotePackage, error) {
+	if !p.Envelope.GetEncryptedVotes() {
+		return unmarshalVote(vote.GetVotePackage(), []string{})
+	}
+	if len(p.PrivateKeys) < len(vote.GetEncryptionKeyIndexes()) {
+		return nil, fmt.Errorf("%w: encryptionKeyIndexes has too many fields", ErrBallotDecryption)
+	}
+	keys := []string{}
+	for _, k := range vote.GetEncryptionKeyIndexes() {
+		if k >= types.KeyKeeperMaxKeyIndex {
+			return nil, fmt.Errorf("%w: key index overflow", ErrBallotDecryption)
+		}
+		if k >= uint32(len(p.PrivateKeys)) {
+			return nil, fmt.Errorf("%w: key index %d not found", ErrBallotDecryption, k)
+		}
+		keys = append(keys, p.PrivateKeys[k])
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no keys provided or wrong index", ErrBallotDecryption)
+	}
+	return unmarshalVote(vote.GetVotePackage(), keys)
 }
 
 // BuildProcessResult takes the indexer Results type and builds the protobuf type ProcessResult.
@@ -20,6 +20,7 @@ import (
 	nm "github.com/tendermint/tendermint/node"
 	"github.com/tendermint/tendermint/p2p"
 	"github.com/tendermint/tendermint/proxy"
+	tmtypes "github.com/tendermint/tendermint/types"
 	"go.vocdoni.io/dvote/log"
 )
 
@@ -60,6 +61,7 @@ func NewVochain(vochaincfg *config.VochainCfg, genesis []byte) *BaseApplication
 		for _, key := range keys {
 			mp.RemoveTxByKey(key, removeFromCache)
 		}
+		app.txWaiters.drop(keys)
 	}
 	// Create custom logger for mempool
 	logDisable := false
@@ -291,6 +293,13 @@ func newTendermint(app *BaseApplication,
 		}
 		log.Infof("new genesis created, stored at %s", tconfig.Genesis)
 	}
+	// The chain ID must be known before the blocks are replayed, so the upgrades
+	// are enabled at the same heights as when the blocks were created
+	genesisDoc, err := tmtypes.GenesisDocFromFile(tconfig.GenesisFile())
+	if err != nil {
+		return nil, fmt.Errorf("cannot load genesis: %w", err)
+	}
+	app.State.SetChainID(genesisDoc.ChainID)
 
 	if localConfig.TendermintMetrics {
 		tconfig.Instrumentation = &tmcfg.InstrumentationConfig{
@@ -65,6 +65,8 @@ type State struct {
 	txCounter           int32
 	eventListeners      []EventListener
 	height              uint32
+	// chainID is used to know the activation height of the upgrades
+	chainID string
 }
 
 // ImmutableState holds the latest trees version saved on disk
@@ -579,6 +581,24 @@ func (v *State) Rollback() {
 	atomic.StoreInt32(&v.txCounter, 0)
 }
 
+// SetChainID sets the chain ID of the blockchain. It must be set before any
+// transaction is processed, since the active upgrades depend on it.
+func (v *State) SetChainID(chainID string) {
+	v.chainID = chainID
+}
+
+// UpgradeActive returns true if the upgrade is active at the current state height
+func (v *State) UpgradeActive(upgrade Upgrade) bool {
+	return UpgradeActive(v.chainID, upgrade, v.Height())
+}
+
+// CensusOriginActive returns true if the processes with the census origin are accepted
+// at the current state height
+func (v *State) CensusOriginActive(origin models.CensusOrigin) bool {
+	upgrade, ok := censusOriginUpgrades[origin]
+	return !ok || v.UpgradeActive(upgrade)
+}
+
 // Height returns the current state height (block count)
 func (v *State) Height() uint32 {
 	return atomic.LoadUint32(&v.height)
@@ -6,6 +6,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	ethtoken "github.com/vocdoni/storage-proofs-eth-go/token"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
@@ -146,11 +147,11 @@ func VoteTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State,
 	endBlock := process.StartBlock + process.BlockCount
 
 	if height < process.StartBlock || height > endBlock {
-		return nil, fmt.Errorf("process %x not started or finished", tx.ProcessId)
+		return nil, txErrorf(ErrProcessNotActive, "process %x not started or finished", tx.ProcessId)
 	}
 
 	if process.Status != models.ProcessStatus_READY {
-		return nil, fmt.Errorf("process %x not in READY state", tx.ProcessId)
+		return nil, txErrorf(ErrProcessNotActive, "process %x not in READY state", tx.ProcessId)
 	}
 
 	// Check in case of keys required, they have been sent by some keykeeper
@@ -185,7 +186,7 @@ func VoteTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State,
 				if err != nil {
 					return nil, err
 				}
-				return nil, fmt.Errorf("vote %x already exists", vote.Nullifier)
+				return nil, txErrorf(ErrVoteAlreadyExists, "vote %x already exists", vote.Nullifier)
 			}
 			return vote, nil
 		}
@@ -193,7 +194,7 @@ func VoteTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State,
 		// if not forCommit, it is a mempool check,
 		// reject it since we already processed the transaction before.
 		if !forCommit && vote != nil {
-			return nil, fmt.Errorf("vote %x already exists in cache", vote.Nullifier)
+			return nil, txErrorf(ErrVoteAlreadyExists, "vote %x already exists in cache", vote.Nullifier)
 		}
 
 		// if not in cache, full check
@@ -231,7 +232,8 @@ func VoteTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State,
 		// check that nullifier does not exist in cache already, this avoids
 		// processing multiple transactions with same nullifier.
 		if state.CacheHasNullifier(vote.Nullifier) {
-			return nil, fmt.Errorf("nullifier %x already exists in cache", vote.Nullifier)
+			return nil, txErrorf(ErrVoteAlreadyExists,
+				"nullifier %x already exists in cache", vote.Nullifier)
 		}
 
 		// check if vote already exists
@@ -240,54 +242,16 @@ func VoteTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State,
 			if err != nil {
 				return nil, err
 			}
-			return nil, fmt.Errorf("vote %x already exists", vote.Nullifier)
+			return nil, txErrorf(ErrVoteAlreadyExists, "vote %x already exists", vote.Nullifier)
 		}
 		log.Debugf("new vote %x for address %s and process %x", vote.Nullifier, addr.Hex(), tx.ProcessId)
 
-		// check census origin and compute vote digest identifier
-		var pubKeyDigested []byte
-		switch process.CensusOrigin {
-		case models.CensusOrigin_OFF_CHAIN_TREE:
-			if process.EnvelopeType.Anonymous {
-				// TODO Poseidon hash of pubKey
-				// pubKeyDigested = snarks.Poseidon.Hash(pubKey)
-			} else {
-				pubKeyDigested = pubKey
-			}
-		case models.CensusOrigin_OFF_CHAIN_CA:
-			pubKeyDigested = addr.Bytes()
-		case models.CensusOrigin_ERC20:
-			if process.EthIndexSlot == nil {
-				return nil, fmt.Errorf("index slot not found for process %x", process.ProcessId)
-			}
-			slot, err := ethtoken.GetSlot(addr.Hex(), int(*process.EthIndexSlot))
-			if err != nil {
-				return nil, fmt.Errorf("cannot fetch slot: %w", err)
-			}
-			pubKeyDigested = slot[:]
-			log.Debugf("ERC20 index slot %d, storage slot %x", *process.EthIndexSlot, pubKeyDigested)
-		default:
+		if !state.CensusOriginActive(process.CensusOrigin) {
 			return nil, fmt.Errorf("census origin not compatible")
 		}
-
-		// check the digested payload has a minimum length
-		if len(pubKeyDigested) < 20 { // Minimum size is an Ethereum Address
-			return nil, fmt.Errorf("cannot digest public key: (%w)", err)
-		}
-
-		// check census proof
-		var valid bool
-		var weight *big.Int
-		valid, weight, err = CheckProof(tx.Proof,
-			process.CensusOrigin,
-			process.CensusRoot,
-			process.ProcessId,
-			pubKeyDigested)
+		weight, err := voteWeight(process, tx.Proof, pubKey, addr)
 		if err != nil {
-			return nil, fmt.Errorf("proof not valid: (%w)", err)
-		}
-		if !valid {
-			return nil, fmt.Errorf("proof not valid")
+			return nil, err
 		}
 		vote.Weight = weight.Bytes()
 
@@ -297,6 +261,115 @@ func VoteTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State,
 	return vote, nil
 }
 
+// CheckVoteProof recovers the voter from the signature of a vote transaction and checks
+// its census proof against the process. Returns the vote nullifier and its weight.
+// It does not check the process status nor if the vote already exists.
+func CheckVoteProof(process *models.Process, tx *models.VoteEnvelope,
+	txBytes, signature []byte) ([]byte, *big.Int, error) {
+	if process == nil || process.EnvelopeType == nil {
+		return nil, nil, fmt.Errorf("process is nil or malformed")
+	}
+	if tx == nil || tx.Proof == nil {
+		return nil, nil, fmt.Errorf("proof not found on transaction")
+	}
+	if process.EnvelopeType.Anonymous {
+		return nil, nil, fmt.Errorf("snark vote not implemented")
+	}
+	pubKey, err := ethereum.PubKeyFromSignature(txBytes, signature)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot extract public key from signature: (%w)", err)
+	}
+	addr, err := ethereum.AddrFromPublicKey(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot extract address from public key: (%w)", err)
+	}
+	weight, err := voteWeight(process, tx.Proof, pubKey, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return GenerateNullifier(addr, tx.ProcessId), weight, nil
+}
+
+// voteWeight computes the census key of the voter according to the process census origin,
+// checks the census proof and returns the weight of the vote.
+func voteWeight(process *models.Process, proof *models.Proof,
+	pubKey []byte, addr common.Address) (*big.Int, error) {
+	// check census origin and compute vote digest identifier
+	var pubKeyDigested []byte
+	switch process.CensusOrigin {
+	case models.CensusOrigin_OFF_CHAIN_TREE:
+		if process.EnvelopeType.Anonymous {
+			// TODO Poseidon hash of pubKey
+			// pubKeyDigested = snarks.Poseidon.Hash(pubKey)
+		} else {
+			pubKeyDigested = pubKey
+		}
+	case models.CensusOrigin_OFF_CHAIN_CA:
+		pubKeyDigested = addr.Bytes()
+	case models.CensusOrigin_ERC20, models.CensusOrigin_ERC721, models.CensusOrigin_ERC1155:
+		slot, err := StorageSlot(process, addr)
+		if err != nil {
+			return nil, err
+		}
+		pubKeyDigested = slot
+		log.Debugf("%s index slot %d, storage slot %x",
+			process.CensusOrigin, process.GetEthIndexSlot(), pubKeyDigested)
+	default:
+		return nil, fmt.Errorf("census origin not compatible")
+	}
+
+	// check the digested payload has a minimum length
+	if len(pubKeyDigested) < 20 { // Minimum size is an Ethereum Address
+		return nil, fmt.Errorf("cannot digest public key")
+	}
+
+	// check census proof
+	valid, weight, err := CheckProof(proof,
+		process.CensusOrigin,
+		process.CensusRoot,
+		process.ProcessId,
+		pubKeyDigested)
+	if err != nil {
+		return nil, txErrorf(ErrInvalidCensusProof, "proof not valid: (%w)", err)
+	}
+	if !valid {
+		return nil, txErrorf(ErrInvalidCensusProof, "proof not valid")
+	}
+	return weight, nil
+}
+
+// StorageSlot returns the Ethereum storage slot holding the token balance of the holder,
+// for the processes with an EVM census origin. ERC20 and ERC721 keep the balances on a
+// mapping(address => uint256) at the process index slot. ERC1155 keeps them on a
+// mapping(uint256 => mapping(address => uint256)), the token ID is the process census URI.
+func StorageSlot(process *models.Process, holder common.Address) ([]byte, error) {
+	if process.EthIndexSlot == nil {
+		return nil, fmt.Errorf("index slot not found for process %x", process.ProcessId)
+	}
+	switch process.CensusOrigin {
+	case models.CensusOrigin_ERC20, models.CensusOrigin_ERC721:
+		slot, err := ethtoken.GetSlot(holder.Hex(), int(*process.EthIndexSlot))
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch slot: %w", err)
+		}
+		return slot[:], nil
+	case models.CensusOrigin_ERC1155:
+		tokenID, ok := new(big.Int).SetString(process.GetCensusURI(), 0)
+		if !ok || tokenID.Sign() < 0 {
+			return nil, fmt.Errorf("invalid token ID %q for process %x",
+				process.GetCensusURI(), process.ProcessId)
+		}
+		// keccak256(holder . keccak256(tokenID . indexSlot))
+		idSlot := crypto.Keccak256(
+			common.LeftPadBytes(tokenID.Bytes(), 32),
+			common.LeftPadBytes(new(big.Int).SetUint64(uint64(*process.EthIndexSlot)).Bytes(), 32),
+		)
+		return crypto.Keccak256(common.LeftPadBytes(holder.Bytes(), 32), idSlot), nil
+	default:
+		return nil, fmt.Errorf("census origin %s has no storage slot", process.CensusOrigin)
+	}
+}
+
 // AdminTxCheck is an abstraction of ABCI checkTx for an admin transaction
 func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error {
 	tx := vtx.GetAdmin()
@@ -314,7 +387,7 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 		oracles, txBytes, signature); err != nil {
 		return err
 	} else if !authorized {
-		return fmt.Errorf("unauthorized to perform an adminTx, address: %s", addr.Hex())
+		return txErrorf(ErrUnauthorized, "unauthorized to perform an adminTx, address: %s", addr.Hex())
 	}
 
 	switch tx.Txtype {
@@ -328,7 +401,7 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 			return err
 		}
 		if process == nil {
-			return fmt.Errorf("process with id (%x) does not exist", tx.ProcessId)
+			return txErrorf(ErrProcessNotFound, "process with id (%x) does not exist", tx.ProcessId)
 		}
 		// check process actually requires keys
 		if !process.EnvelopeType.EncryptedVotes && !process.EnvelopeType.Anonymous {
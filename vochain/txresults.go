@@ -0,0 +1,135 @@
+package vochain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// ErrTxDropped is returned while waiting for a transaction that is removed
+// from the mempool before being included on a block
+var ErrTxDropped = fmt.Errorf("transaction dropped from the mempool")
+
+// TxResult is the result of a transaction included on a committed block
+type TxResult struct {
+	Height uint32
+	Index  int32
+	Hash   []byte
+	// Code is the TxCode of the DeliverTx error, TxCodeOK if the transaction
+	// was successfully executed, and Data is the DeliverTx response data
+	Code uint32
+	Data []byte
+}
+
+// txWaiters keeps the transactions waited by SendTxAndWait, so the DeliverTx
+// results are notified once the block is committed. The same transaction can
+// be waited by several callers, each one with its own channel.
+type txWaiters struct {
+	lock    sync.Mutex
+	waiters map[[32]byte][]chan *TxResult
+	// delivered are the results of the current block waited transactions
+	delivered []*TxResult
+	keys      [][32]byte
+}
+
+func (w *txWaiters) add(key [32]byte) chan *TxResult {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.waiters == nil {
+		w.waiters = make(map[[32]byte][]chan *TxResult)
+	}
+	ch := make(chan *TxResult, 1)
+	w.waiters[key] = append(w.waiters[key], ch)
+	return ch
+}
+
+// remove stops waiting the transaction key on the channel ch
+func (w *txWaiters) remove(key [32]byte, ch chan *TxResult) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	chans := w.waiters[key]
+	for i, c := range chans {
+		if c == ch {
+			chans = append(chans[:i], chans[i+1:]...)
+			break
+		}
+	}
+	if len(chans) == 0 {
+		delete(w.waiters, key)
+		return
+	}
+	w.waiters[key] = chans
+}
+
+// deliver stores the result of a transaction on the current block, if waited
+func (w *txWaiters) deliver(tx []byte, height uint32, index int32,
+	code uint32, data []byte) {
+	key := TxKey(tx)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.waiters[key]; !ok {
+		return
+	}
+	w.delivered = append(w.delivered, &TxResult{
+		Height: height,
+		Index:  index,
+		Hash:   tmtypes.Tx(tx).Hash(),
+		Code:   code,
+		Data:   data,
+	})
+	w.keys = append(w.keys, key)
+}
+
+// commit notifies the results of the current block transactions
+func (w *txWaiters) commit() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for i, key := range w.keys {
+		for _, ch := range w.waiters[key] {
+			ch <- w.delivered[i]
+		}
+		delete(w.waiters, key)
+	}
+	w.delivered = nil
+	w.keys = nil
+}
+
+// drop notifies the waited transactions removed from the mempool
+func (w *txWaiters) drop(keys [][32]byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, key := range keys {
+		for _, ch := range w.waiters[key] {
+			close(ch)
+		}
+		delete(w.waiters, key)
+	}
+}
+
+// SendTxAndWait sends a transaction to the mempool and waits until it is
+// included on a committed block. If the mempool rejects the transaction, the
+// CheckTx response is returned without waiting. If the transaction is dropped
+// from the mempool, ErrTxDropped is returned. The DeliverTx response code of
+// the result must be checked, since included transactions might fail.
+func (app *BaseApplication) SendTxAndWait(ctx context.Context,
+	tx []byte) (*ctypes.ResultBroadcastTx, *TxResult, error) {
+	key := TxKey(tx)
+	ch := app.txWaiters.add(key)
+	defer app.txWaiters.remove(key, ch)
+	res, err := app.SendTx(tx)
+	if err != nil || res == nil || res.Code != 0 {
+		return res, nil, err
+	}
+	select {
+	case result, ok := <-ch:
+		if !ok {
+			return res, nil, ErrTxDropped
+		}
+		return res, result, nil
+	case <-ctx.Done():
+		return res, nil, ctx.Err()
+	}
+}
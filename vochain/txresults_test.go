@@ -0,0 +1,198 @@
+package vochain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSendTxAndWait(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.SignKeys{}
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddOracle(common.HexToAddress(oracle.AddressString())); err != nil {
+		t.Fatal(err)
+	}
+	censusURI := ipfsUrl
+	pid := util.RandomBytes(types.ProcessIDsize)
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{Interruptible: true},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 16, MaxValue: 16},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   util.RandomBytes(32),
+		CensusURI:    &censusURI,
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mempool checks the transactions and keeps them for the next block
+	var mempool [][]byte
+	var lock sync.Mutex
+	app.SetFnGetBlockByHeight(func(height int64) *tmtypes.Block { return nil })
+	app.SetFnSendTx(func(tx []byte) (*ctypes.ResultBroadcastTx, error) {
+		res := app.CheckTx(abcitypes.RequestCheckTx{Tx: tx})
+		if res.Code == 0 {
+			lock.Lock()
+			mempool = append(mempool, tx)
+			lock.Unlock()
+		}
+		return &ctypes.ResultBroadcastTx{Code: res.Code, Data: res.Data,
+			Hash: tmtypes.Tx(tx).Hash()}, nil
+	})
+	setStatusTx := func(status models.ProcessStatus) []byte {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_SetProcess{
+			SetProcess: &models.SetProcessTx{
+				Txtype:    models.TxType_SET_PROCESS_STATUS,
+				Nonce:     util.RandomBytes(32),
+				ProcessId: pid,
+				Status:    &status,
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stx.Signature, err = oracle.Sign(stx.Tx); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := proto.Marshal(&stx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	// Both transactions are accepted by the mempool, but only the first one
+	// can pause the process
+	type waitResult struct {
+		result *TxResult
+		err    error
+	}
+	txs := [][]byte{
+		setStatusTx(models.ProcessStatus_PAUSED),
+		setStatusTx(models.ProcessStatus_PAUSED),
+	}
+	results := make([]chan waitResult, len(txs))
+	for i, tx := range txs {
+		results[i] = make(chan waitResult, 1)
+		go func(tx []byte, ch chan waitResult) {
+			_, result, err := app.SendTxAndWait(context.Background(), tx)
+			ch <- waitResult{result, err}
+		}(tx, results[i])
+	}
+	waitMempool := func(n int) {
+		for {
+			lock.Lock()
+			size := len(mempool)
+			lock.Unlock()
+			if size == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitMempool(len(txs))
+	for _, tx := range txs {
+		app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx})
+	}
+	app.EndBlock(abcitypes.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	for i, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.result.Height != 1 || r.result.Index != int32(i) {
+			t.Fatalf("unexpected block position %d/%d", r.result.Height, r.result.Index)
+		}
+		if hash := tmtypes.Tx(txs[i]).Hash(); string(r.result.Hash) != string(hash) {
+			t.Fatalf("unexpected hash %x", r.result.Hash)
+		}
+		if (i == 0) != (r.result.Code == TxCodeOK) {
+			t.Fatalf("unexpected code %d for transaction %d", r.result.Code, i)
+		}
+	}
+
+	// A transaction removed from the mempool is notified as dropped
+	tx := setStatusTx(models.ProcessStatus_READY)
+	dropped := make(chan waitResult, 1)
+	go func() {
+		_, result, err := app.SendTxAndWait(context.Background(), tx)
+		dropped <- waitResult{result, err}
+	}()
+	waitMempool(len(txs) + 1)
+	app.txWaiters.drop([][32]byte{TxKey(tx)})
+	if r := <-dropped; !errors.Is(r.err, ErrTxDropped) {
+		t.Fatalf("expected dropped transaction error, got %v", r.err)
+	}
+
+	// The wait is bounded by the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := app.SendTxAndWait(ctx, setStatusTx(models.ProcessStatus_READY)); !errors.Is(
+		err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestTxWaitersSameTx(t *testing.T) {
+	var w txWaiters
+	tx := []byte("transaction")
+	key := TxKey(tx)
+
+	// Every caller waiting the same transaction gets its result
+	ch1, ch2 := w.add(key), w.add(key)
+	w.deliver(tx, 1, 0, TxCodeOK, nil)
+	w.commit()
+	for _, ch := range []chan *TxResult{ch1, ch2} {
+		select {
+		case r := <-ch:
+			if r.Height != 1 || r.Code != TxCodeOK {
+				t.Fatalf("unexpected result %+v", r)
+			}
+		default:
+			t.Fatal("transaction result not notified")
+		}
+	}
+	w.remove(key, ch1)
+	w.remove(key, ch2)
+	if len(w.waiters) != 0 {
+		t.Fatalf("waiters not removed: %v", w.waiters)
+	}
+
+	// A caller giving up does not remove the other waits
+	ch1, ch2 = w.add(key), w.add(key)
+	w.remove(key, ch1)
+	w.drop([][32]byte{key})
+	if _, ok := <-ch2; ok {
+		t.Fatal("expected the dropped transaction channel to be closed")
+	}
+	select {
+	case <-ch1:
+		t.Fatal("removed waiter notified")
+	default:
+	}
+}
@@ -2,6 +2,7 @@ package vochain
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -19,14 +20,70 @@ const (
 )
 
 var (
-	ErrVoteDoesNotExist = fmt.Errorf("vote does not exist")
-	ErrProcessNotFound  = fmt.Errorf("process not found")
+	ErrVoteDoesNotExist   = fmt.Errorf("vote does not exist")
+	ErrProcessNotFound    = fmt.Errorf("process not found")
+	ErrProcessNotActive   = fmt.Errorf("process not active")
+	ErrVoteAlreadyExists  = fmt.Errorf("vote already exists")
+	ErrInvalidCensusProof = fmt.Errorf("census proof not valid")
+	ErrUnauthorized       = fmt.Errorf("unauthorized")
 	// keys; not constants because of []byte
 	headerKey    = []byte("header")
 	oracleKey    = []byte("oracle")
 	validatorKey = []byte("validator")
 )
 
+// ABCI response codes of the rejected transactions, so the clients can
+// identify the rejection reason without parsing the response data
+const (
+	TxCodeOK uint32 = iota
+	TxCodeInvalid
+	TxCodeProcessNotFound
+	TxCodeProcessNotActive
+	TxCodeVoteAlreadyExists
+	TxCodeInvalidCensusProof
+	TxCodeUnauthorized
+)
+
+// txError is a transaction check error of the kind of one of the errors above.
+// Its message is kept as it was before the error kinds were added, since the
+// DeliverTx response data is part of the consensus (LastResultsHash).
+type txError struct {
+	kind error
+	err  error
+}
+
+// txErrorf returns a txError of the given kind, with the message formatted as
+// fmt.Errorf does, including the wrapping of the %w argument
+func txErrorf(kind error, format string, a ...interface{}) error {
+	return &txError{kind: kind, err: fmt.Errorf(format, a...)}
+}
+
+func (e *txError) Error() string { return e.err.Error() }
+
+func (e *txError) Unwrap() error { return e.err }
+
+func (e *txError) Is(target error) bool { return target == e.kind }
+
+// TxCode returns the ABCI response code for a transaction check error
+func TxCode(err error) uint32 {
+	switch {
+	case err == nil:
+		return TxCodeOK
+	case errors.Is(err, ErrProcessNotFound):
+		return TxCodeProcessNotFound
+	case errors.Is(err, ErrProcessNotActive):
+		return TxCodeProcessNotActive
+	case errors.Is(err, ErrVoteAlreadyExists):
+		return TxCodeVoteAlreadyExists
+	case errors.Is(err, ErrInvalidCensusProof):
+		return TxCodeInvalidCensusProof
+	case errors.Is(err, ErrUnauthorized):
+		return TxCodeUnauthorized
+	default:
+		return TxCodeInvalid
+	}
+}
+
 // PrefixDBCacheSize is the size of the cache for the MutableTree IAVL databases
 var PrefixDBCacheSize = 0
 
@@ -139,4 +196,8 @@ var CensusOrigins = map[models.CensusOrigin]CensusProperties{
 		WeightedSupport: true, NeedsIndexSlot: true},
 	models.CensusOrigin_OFF_CHAIN_CA: {Name: "ca", WeightedSupport: true,
 		NeedsURI: true, AllowCensusUpdate: true},
+	models.CensusOrigin_ERC721: {Name: "erc721", WeightedSupport: true, NeedsIndexSlot: true},
+	// The ERC1155 token ID is stored as the census URI
+	models.CensusOrigin_ERC1155: {Name: "erc1155", WeightedSupport: true, NeedsIndexSlot: true,
+		NeedsURI: true},
 }
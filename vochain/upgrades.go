@@ -0,0 +1,46 @@
+package vochain
+
+import models "go.vocdoni.io/proto/build/go/models"
+
+// Upgrade is a change of the transaction validation rules. It is enabled from an
+// activation height, so the nodes replaying the blocks created before it reach
+// the same state and results.
+type Upgrade int
+
+const (
+	// UpgradeNFTCensus accepts the processes with an ERC721 or ERC1155 census
+	// origin and their votes, which were rejected as not compatible before
+	UpgradeNFTCensus Upgrade = iota
+	// UpgradeStorageEvidence requires the processes with an EVM census origin to carry
+	// the evidence linking their census root to an Ethereum block header, which is
+	// checked against the header state root
+	UpgradeStorageEvidence
+)
+
+// censusOriginUpgrades holds the upgrade that enables each census origin. The census
+// origins not listed are always enabled.
+var censusOriginUpgrades = map[models.CensusOrigin]Upgrade{
+	models.CensusOrigin_ERC721:  UpgradeNFTCensus,
+	models.CensusOrigin_ERC1155: UpgradeNFTCensus,
+}
+
+// upgradeHeights holds the activation height of the upgrades on the public chains,
+// by chain ID. The upgrades not listed for a chain are not active on it yet.
+// The chains not listed (such as local testing chains) enable all the upgrades
+// from genesis.
+var upgradeHeights = map[string]map[Upgrade]uint32{
+	"vocdoni-release-1.0.1":  {},
+	"vocdoni-stage-9":        {},
+	"vocdoni-development-43": {},
+}
+
+// UpgradeActive returns true if the upgrade is active on the chain once the block
+// at height is committed
+func UpgradeActive(chainID string, upgrade Upgrade, height uint32) bool {
+	heights, ok := upgradeHeights[chainID]
+	if !ok {
+		return true
+	}
+	activation, ok := heights[upgrade]
+	return ok && height >= activation
+}
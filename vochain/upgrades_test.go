@@ -0,0 +1,119 @@
+package vochain
+
+import (
+	"errors"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUpgradeActive(t *testing.T) {
+	upgradeHeights["test-chain"] = map[Upgrade]uint32{UpgradeNFTCensus: 100}
+	t.Cleanup(func() { delete(upgradeHeights, "test-chain") })
+	for _, tc := range []struct {
+		name    string
+		chainID string
+		height  uint32
+		active  bool
+	}{
+		{"not listed chain", "local-chain", 0, true},
+		{"not scheduled", "vocdoni-release-1.0.1", 1 << 30, false},
+		{"before activation", "test-chain", 99, false},
+		{"at activation", "test-chain", 100, true},
+		{"after activation", "test-chain", 1000, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			qt.Assert(t, UpgradeActive(tc.chainID, UpgradeNFTCensus, tc.height), qt.Equals, tc.active)
+		})
+	}
+}
+
+func TestNFTCensusUpgrade(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	indexSlot := uint32(1)
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   util.RandomBytes(32),
+		CensusOrigin: models.CensusOrigin_ERC721,
+		EthIndexSlot: &indexSlot,
+		BlockCount:   1024,
+	}), qt.IsNil)
+
+	voter := ethereum.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	vtx := &models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{
+		ProcessId:   pid,
+		Nonce:       util.RandomBytes(32),
+		VotePackage: []byte("{}"),
+		Proof: &models.Proof{Payload: &models.Proof_EthereumStorage{
+			EthereumStorage: &models.ProofEthereumStorage{Key: util.RandomBytes(32)},
+		}},
+	}}}
+	txBytes, err := proto.Marshal(vtx)
+	qt.Assert(t, err, qt.IsNil)
+	signature, err := voter.Sign(txBytes)
+	qt.Assert(t, err, qt.IsNil)
+
+	// Before the upgrade the votes are rejected without checking the proof
+	app.State.SetChainID("vocdoni-release-1.0.1")
+	_, err = VoteTxCheck(vtx, txBytes, signature, app.State, [32]byte{1}, true)
+	qt.Assert(t, err, qt.ErrorMatches, "census origin not compatible")
+
+	// Once active, the census proof is checked
+	app.State.SetChainID("local-chain")
+	_, err = VoteTxCheck(vtx, txBytes, signature, app.State, [32]byte{2}, true)
+	qt.Assert(t, errors.Is(err, ErrInvalidCensusProof), qt.IsTrue, qt.Commentf("%v", err))
+}
+
+func TestNFTCensusProcessUpgrade(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	oracle := ethereum.NewSignKeys()
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(oracle.Address()), qt.IsNil)
+
+	indexSlot := uint32(1)
+	vtx := &models.Tx{Payload: &models.Tx_NewProcess{NewProcess: &models.NewProcessTx{
+		Txtype: models.TxType_NEW_PROCESS,
+		Nonce:  util.RandomBytes(32),
+		Process: &models.Process{
+			ProcessId:    util.RandomBytes(types.ProcessIDsize),
+			StartBlock:   1,
+			EnvelopeType: &models.EnvelopeType{},
+			Mode:         &models.ProcessMode{AutoStart: true},
+			VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+			Status:       models.ProcessStatus_READY,
+			EntityId:     util.RandomBytes(types.EthereumAddressSize),
+			CensusRoot:   util.RandomBytes(32),
+			CensusOrigin: models.CensusOrigin_ERC1155,
+			EthIndexSlot: &indexSlot,
+			BlockCount:   1024,
+		},
+	}}}
+	txBytes, err := proto.Marshal(vtx)
+	qt.Assert(t, err, qt.IsNil)
+	signature, err := oracle.Sign(txBytes)
+	qt.Assert(t, err, qt.IsNil)
+
+	// Before the upgrade the processes are rejected
+	app.State.SetChainID("vocdoni-release-1.0.1")
+	_, err = NewProcessTxCheck(vtx, txBytes, signature, app.State)
+	qt.Assert(t, err, qt.ErrorMatches, "census origin ERC1155 not yet enabled")
+
+	// Once active, the process goes on to the storage root evidence check
+	app.State.SetChainID("local-chain")
+	_, err = NewProcessTxCheck(vtx, txBytes, signature, app.State)
+	qt.Assert(t, err, qt.ErrorMatches, "missing storage root evidence .*")
+}